@@ -0,0 +1,189 @@
+package main
+
+import (
+    "strconv"
+    "strings"
+)
+
+// SR26 also ships as ABBREV.txt, one row per food with the common nutrients
+// as columns and two household measures on the end. It has no NUTR_DEF.txt,
+// so the columns are described here, with the ids and descriptions
+// NUTR_DEF.txt uses so targets match either way.
+const abbrevFile = "ABBREV.txt"
+
+// abbrevNutrients are ABBREV.txt's nutrient columns, starting at field 3.
+var abbrevNutrients = []struct {
+    id int
+    units string
+    description string
+}{
+    {255, "g", "Water"},
+    // Named as getNutrients names it once "Energy, kJ" is alongside
+    {208, "kcal", "Energy, kcal"},
+    {203, "g", "Protein"},
+    {204, "g", "Total lipid (fat)"},
+    {207, "g", "Ash"},
+    {205, "g", "Carbohydrate, by difference"},
+    {291, "g", "Fiber, total dietary"},
+    {269, "g", "Sugars, total"},
+    {301, "mg", "Calcium, Ca"},
+    {303, "mg", "Iron, Fe"},
+    {304, "mg", "Magnesium, Mg"},
+    {305, "mg", "Phosphorus, P"},
+    {306, "mg", "Potassium, K"},
+    {307, "mg", "Sodium, Na"},
+    {309, "mg", "Zinc, Zn"},
+    {312, "mg", "Copper, Cu"},
+    {315, "mg", "Manganese, Mn"},
+    {317, "µg", "Selenium, Se"},
+    {401, "mg", "Vitamin C, total ascorbic acid"},
+    {404, "mg", "Thiamin"},
+    {405, "mg", "Riboflavin"},
+    {406, "mg", "Niacin"},
+    {410, "mg", "Pantothenic acid"},
+    {415, "mg", "Vitamin B-6"},
+    {417, "µg", "Folate, total"},
+    {431, "µg", "Folic acid"},
+    {432, "µg", "Folate, food"},
+    {435, "µg", "Folate, DFE"},
+    {421, "mg", "Choline, total"},
+    {418, "µg", "Vitamin B-12"},
+    {318, "IU", "Vitamin A, IU"},
+    {320, "µg", "Vitamin A, RAE"},
+    {319, "µg", "Retinol"},
+    {322, "µg", "Carotene, alpha"},
+    {321, "µg", "Carotene, beta"},
+    {334, "µg", "Cryptoxanthin, beta"},
+    {337, "µg", "Lycopene"},
+    {338, "µg", "Lutein + zeaxanthin"},
+    {323, "mg", "Vitamin E (alpha-tocopherol)"},
+    {328, "µg", "Vitamin D (D2 + D3)"},
+    {324, "IU", "Vitamin D"},
+    {430, "µg", "Vitamin K (phylloquinone)"},
+    {606, "g", "Fatty acids, total saturated"},
+    {645, "g", "Fatty acids, total monounsaturated"},
+    {646, "g", "Fatty acids, total polyunsaturated"},
+    {601, "mg", "Cholesterol"},
+}
+
+// The fields after the nutrients: two gram weights with their descriptions,
+// then the refuse percentage
+const abbrevFirstMeasureField = 2 + 46
+const abbrevRefuseField = abbrevFirstMeasureField + 4
+
+// usesAbbrev reports whether dataDir has ABBREV.txt and not the full
+// format, which is preferred when both are there.
+func usesAbbrev(dataDir string) bool {
+    return !dataFileExists(dataDir, "FOOD_DES.txt") && dataFileExists(dataDir, abbrevFile)
+}
+
+// newSRSource reads an SR dataset in whichever format dataDir holds.
+func newSRSource(dataDir string, keep func(*Food) bool) FoodSource {
+    if usesAbbrev(dataDir) {
+        return abbrevSource{dataDir, keep}
+    }
+    return sr26Source{dataDir, keep}
+}
+
+// abbrevSource reads ABBREV.txt in dataDir. The file has no food groups,
+// but SR numbers foods within their group, so the group is taken from the
+// first two digits of the NDB number. Nor does it say how many data points
+// are behind each value, so --imputed has nothing to go on and every value
+// is used as is.
+type abbrevSource struct {
+    dataDir string
+    keep func(*Food) bool
+}
+
+func (source abbrevSource) Name() string {
+    return "SR26 abbreviated in " + source.dataDir
+}
+
+func (source abbrevSource) LoadNutrients() (map[int]Nutrient, error) {
+    nutrients := make(map[int]Nutrient, len(abbrevNutrients))
+    for _, column := range abbrevNutrients {
+        nutrients[column.id] = Nutrient{id: column.id, units: column.units, description: column.description}
+    }
+    return nutrients, nil
+}
+
+func (source abbrevSource) LoadFoods(nutrients map[int]Nutrient) (map[int]Food, error) {
+    abbrev := openUSDAFile(source.dataDir, abbrevFile)
+    defer abbrev.close()
+
+    foods := make(map[int]Food, 9000)
+    for record := abbrev.next(); record != nil; record = abbrev.next() {
+        ndbText, err := abbrev.text(record, 0)
+        if err != nil { abbrev.skip(err); continue }
+        ndb, err := strconv.Atoi(ndbText)
+        if err != nil { abbrev.skip(abbrev.fieldError(record, 0, "expected a number, got %q", ndbText)); continue }
+        description, err := abbrev.text(record, 1)
+        if err != nil { abbrev.skip(err); continue }
+        if _, exists := foods[ndb]; exists {
+            abbrev.skip(abbrev.fieldError(record, 0, "food %d is already defined", ndb))
+            continue
+        }
+
+        food := Food{id: ndb, description: description}
+        if len(ndbText) >= 2 {
+            food.foodGroup = ndbText[:2] + "00"
+        }
+        if source.keep != nil && !source.keep(&food) {
+            continue
+        }
+
+        malformed := false
+        for i, column := range abbrevNutrients {
+            field := 2 + i
+            // Blank means not measured
+            if field >= len(record) || record[field] == "" {
+                continue
+            }
+            amount, err := abbrev.float(record, field)
+            if err != nil { abbrev.skip(err); malformed = true; break }
+            nutrient, exists := nutrients[column.id]
+            if !exists {
+                continue
+            }
+            // divide by 100 because this measurement is for 100g
+            food.nutrients = append(food.nutrients, NutrientInFood{nutrient: nutrient, amountPerG: amount / 100})
+        }
+        if malformed {
+            continue
+        }
+
+        if abbrevRefuseField < len(record) && record[abbrevRefuseField] != "" {
+            food.refusePct, err = abbrev.float(record, abbrevRefuseField)
+            if err != nil { abbrev.skip(err); continue }
+        }
+
+        for field := abbrevFirstMeasureField; field + 1 < abbrevRefuseField && field + 1 < len(record); field += 2 {
+            if record[field] == "" {
+                continue
+            }
+            grams, err := abbrev.float(record, field)
+            if err != nil || grams <= 0 {
+                continue
+            }
+            measureDescription, err := abbrev.text(record, field + 1)
+            if err != nil {
+                continue
+            }
+            food.measures = append(food.measures, abbrevMeasure(measureDescription, grams))
+        }
+        foods[ndb] = food
+    }
+    return foods, nil
+}
+
+// abbrevMeasure splits a GmWt_Desc like "1 cup, chopped" into the amount
+// and description WEIGHT.txt would have given separately.
+func abbrevMeasure(description string, grams float64) Measure {
+    fields := strings.SplitN(strings.TrimSpace(description), " ", 2)
+    if len(fields) == 2 {
+        if amount, err := strconv.ParseFloat(fields[0], 64); err == nil && amount > 0 {
+            return Measure{amount, strings.TrimSpace(fields[1]), grams}
+        }
+    }
+    return Measure{1, description, grams}
+}
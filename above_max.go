@@ -0,0 +1,49 @@
+package main
+
+import (
+    "fmt"
+)
+
+// A max is usually a tolerable upper intake level, and going well past one
+// is worse than missing a min: too much selenium, vitamin A or iron is
+// toxic. calcPenalty's excess penalty is linear, 100 at the max and only
+// another 100 at twice it, so --above-max says what happens past a max:
+//
+//     linear   carry on the same, as it always did
+//     steep    the default, also cost the percentage over the max, squared,
+//              so 10% over costs another 100 and twice the max 10000
+//     hard     every max is hard (see hard.go), so no recipe over one is
+//              returned
+//
+// The linear program (lp.go) can't square, so with steep it stays linear.
+
+const (
+    aboveMaxLinear = "linear"
+    aboveMaxSteep = "steep"
+    aboveMaxHard = "hard"
+)
+
+func checkAboveMax(opts *Options) error {
+    switch opts.aboveMax {
+    case aboveMaxLinear, aboveMaxSteep, aboveMaxHard:
+        return nil
+    }
+    return fmt.Errorf("--above-max %q: want linear, steep or hard", opts.aboveMax)
+}
+
+// aboveMaxPenalty is what amount of target's nutrient costs past the max,
+// on top of calcPenalty.
+func aboveMaxPenalty(opts *Options, target Target, amount float64, verbose bool) float64 {
+    if opts.aboveMax != aboveMaxSteep || target.max == 0 || amount <= target.max {
+        return 0
+    }
+    percentOver := (amount - target.max) / target.max * 100
+    penalty := percentOver * percentOver
+    if verbose { fmt.Printf("Penalty for %s %.1f%% over its max: %f\n", target.nutrient, percentOver, penalty) }
+    return penalty
+}
+
+// maxIsHard is whether no recipe over target's max may be returned.
+func (problem *Problem) maxIsHard(target Target) bool {
+    return target.max > 0 && (target.hard || problem.opts.aboveMax == aboveMaxHard)
+}
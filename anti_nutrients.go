@@ -0,0 +1,163 @@
+package main
+
+import (
+    "encoding/csv"
+    "fmt"
+    "io"
+    "os"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// --anti-nutrients adds amounts of things like oxalate, phytate and
+// goitrogens, which no dataset has, to foods from a CSV with a row per
+// food and anti-nutrient:
+//
+//     food_id,nutrient,amount_per_100g,units
+//     11457,Oxalate,970,mg
+//     12061,Oxalate,469,mg
+//     12061,Phytate,1280,mg
+//
+// Each name becomes a nutrient, in the units of its first row, so it can be
+// given a max like any other:
+//
+//     --target "Oxalate=:200mg"
+//
+// and is scored, reported and put in the linear program the same way. Foods
+// the table has no row for count as having none, so leaving out a food high
+// in one lets it through. A name the data already has, like Caffeine,
+// replaces the food's value for it.
+
+// Anti-nutrients get nutrient ids after SR26's
+const firstAntiNutrientId = 9001
+
+var antiNutrientsHeader = []string{"food_id", "nutrient", "amount_per_100g", "units"}
+
+type antiNutrientRow struct {
+    foodId int
+    nutrient string
+    amount float64
+    units string
+}
+
+// antiNutrientSource is a source with the --anti-nutrients table's amounts
+// added to its foods.
+type antiNutrientSource struct {
+    FoodSource
+    path string
+}
+
+func readAntiNutrients(path string) ([]antiNutrientRow, error) {
+    file, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+
+    reader := csv.NewReader(file)
+    reader.Comment = '#'
+    reader.FieldsPerRecord = len(antiNutrientsHeader)
+    var rows []antiNutrientRow
+    for line := 1; ; line++ {
+        record, err := reader.Read()
+        if err == io.EOF {
+            break
+        } else if err != nil {
+            return nil, fmt.Errorf("%s: %v", path, err)
+        }
+        if line == 1 && record[0] == antiNutrientsHeader[0] {
+            continue
+        }
+        foodId, err := strconv.Atoi(strings.TrimSpace(record[0]))
+        if err != nil {
+            return nil, fmt.Errorf("%s line %d: bad food id %q", path, line, record[0])
+        }
+        amount, err := strconv.ParseFloat(strings.TrimSpace(record[2]), 64)
+        if err != nil || amount < 0 {
+            return nil, fmt.Errorf("%s line %d: bad amount %q", path, line, record[2])
+        }
+        nutrient := strings.TrimSpace(record[1])
+        if nutrient == "" {
+            return nil, fmt.Errorf("%s line %d: no nutrient", path, line)
+        }
+        rows = append(rows, antiNutrientRow{foodId, nutrient, amount, strings.TrimSpace(record[3])})
+    }
+    return rows, nil
+}
+
+func (source antiNutrientSource) Name() string {
+    return source.FoodSource.Name() + " + " + source.path
+}
+
+// LoadNutrients adds a nutrient for each name in the table the source
+// doesn't have.
+func (source antiNutrientSource) LoadNutrients() (map[int]Nutrient, error) {
+    nutrients, err := source.FoodSource.LoadNutrients()
+    if err != nil {
+        return nil, err
+    }
+    rows, err := readAntiNutrients(source.path)
+    if err != nil {
+        return nil, err
+    }
+
+    nutrientNameToId := nutrientNameIndex(nutrients)
+    units := make(map[string]string)
+    for _, row := range rows {
+        if _, exists := nutrientNameToId[row.nutrient]; !exists && units[row.nutrient] == "" {
+            units[row.nutrient] = canonicalUnit(row.units)
+        }
+    }
+    // Ids in name order, so they're the same every run
+    names := make([]string, 0, len(units))
+    for name := range units {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    id := firstAntiNutrientId
+    for _, name := range names {
+        for nutrients[id].description != "" {
+            id++
+        }
+        nutrients[id] = Nutrient{id: id, units: units[name], description: name}
+    }
+    return nutrients, nil
+}
+
+func (source antiNutrientSource) LoadFoods(nutrients map[int]Nutrient) (map[int]Food, error) {
+    foods, err := source.FoodSource.LoadFoods(nutrients)
+    if err != nil {
+        return nil, err
+    }
+    rows, err := readAntiNutrients(source.path)
+    if err != nil {
+        return nil, err
+    }
+
+    nutrientNameToId := nutrientNameIndex(nutrients)
+    for _, row := range rows {
+        food, exists := foods[row.foodId]
+        if !exists {
+            // Filtered out while loading, or not in this dataset
+            continue
+        }
+        nutrient := nutrients[nutrientNameToId[row.nutrient]]
+        converted, err := convertNutrientUnits(row.amount, row.units, nutrient.units, row.nutrient)
+        if err != nil {
+            return nil, fmt.Errorf("%s: food %d, %s: %v", source.path, row.foodId, row.nutrient, err)
+        }
+        nutrientInFood := NutrientInFood{nutrient: nutrient, amountPerG: converted / 100, sourceCode: sourceLiterature}
+        replaced := false
+        for i := range food.nutrients {
+            if food.nutrients[i].nutrient.id == nutrient.id {
+                food.nutrients[i], replaced = nutrientInFood, true
+            }
+        }
+        if !replaced {
+            food.nutrients = append(food.nutrients, nutrientInFood)
+        }
+        foods[row.foodId] = food
+    }
+    return foods, nil
+}
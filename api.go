@@ -0,0 +1,182 @@
+package main
+
+import (
+    "encoding/json"
+    "net/http"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// The read-only API over the loaded foods and nutrients, for front ends
+// building pickers and autocomplete:
+//
+//     GET /api/foods?q=spin&limit=20  foods whose description has words
+//                                     starting with each word of q
+//     GET /api/foods/<id>             one food with its nutrients and measures
+//     GET /api/nutrients              every nutrient
+//
+// Only the foods recipes may use are there, the server's filters applied.
+
+// How many foods a search returns unless limit says otherwise, and at most
+const defaultAPILimit = 20
+const maxAPILimit = 100
+
+type apiFood struct {
+    Id int `json:"id"`
+    Description string `json:"description"`
+    FoodGroup string `json:"food_group"`
+    Manufacturer string `json:"manufacturer,omitempty"`
+}
+
+type apiFoodDetail struct {
+    apiFood
+    RefusePct float64 `json:"refuse_pct,omitempty"`
+    Nutrients []apiFoodNutrient `json:"nutrients"`
+    Measures []apiMeasure `json:"measures"`
+}
+
+type apiFoodNutrient struct {
+    Id int `json:"id"`
+    Description string `json:"description"`
+    Units string `json:"units"`
+    Per100g float64 `json:"per_100g"`
+}
+
+type apiMeasure struct {
+    Amount float64 `json:"amount"`
+    Description string `json:"description"`
+    Grams float64 `json:"grams"`
+}
+
+type apiNutrient struct {
+    Id int `json:"id"`
+    Description string `json:"description"`
+    Units string `json:"units"`
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+    // Anyone may read the database, from any page
+    w.Header().Set("Access-Control-Allow-Origin", "*")
+    writePrivateJSON(w, value)
+}
+
+// writePrivateJSON is writeJSON for the user's own data, their profiles,
+// history and jobs, which other sites' pages mustn't be able to read.
+func writePrivateJSON(w http.ResponseWriter, value interface{}) {
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(value)
+}
+
+func newAPIFood(food *Food) apiFood {
+    return apiFood{food.id, food.description, food.foodGroup, food.manufacturer}
+}
+
+// searchFoods returns the foods matching every word of query by prefix,
+// shortest description first since that's usually the plain food.
+func searchFoods(foods map[int]Food, query string, limit int) []apiFood {
+    var queryWords []string
+    for word := range matchWords(query) {
+        queryWords = append(queryWords, word)
+    }
+
+    var matches []apiFood
+    for _, food := range foods {
+        words := matchWords(food.description)
+        matched := true
+        for _, queryWord := range queryWords {
+            found := false
+            for word := range words {
+                if strings.HasPrefix(word, queryWord) {
+                    found = true
+                    break
+                }
+            }
+            if !found {
+                matched = false
+                break
+            }
+        }
+        if matched {
+            matches = append(matches, newAPIFood(&food))
+        }
+    }
+    sort.Slice(matches, func(i, j int) bool {
+        if len(matches[i].Description) != len(matches[j].Description) {
+            return len(matches[i].Description) < len(matches[j].Description)
+        }
+        return matches[i].Id < matches[j].Id
+    })
+    if len(matches) > limit {
+        matches = matches[:limit]
+    }
+    return matches
+}
+
+func (s *server) handleFoods(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        http.Error(w, "GET only", http.StatusMethodNotAllowed)
+        return
+    }
+    limit := defaultAPILimit
+    if text := r.URL.Query().Get("limit"); text != "" {
+        var err error
+        limit, err = strconv.Atoi(text)
+        if err != nil || limit <= 0 {
+            http.Error(w, "limit should be a positive number", http.StatusBadRequest)
+            return
+        }
+        if limit > maxAPILimit {
+            limit = maxAPILimit
+        }
+    }
+    foods := searchFoods(s.problem.foods, r.URL.Query().Get("q"), limit)
+    if foods == nil {
+        foods = []apiFood{}
+    }
+    writeJSON(w, foods)
+}
+
+func (s *server) handleFood(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        http.Error(w, "GET only", http.StatusMethodNotAllowed)
+        return
+    }
+    id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/foods/"))
+    if err != nil {
+        http.NotFound(w, r)
+        return
+    }
+    food, exists := s.problem.foods[id]
+    if !exists {
+        http.NotFound(w, r)
+        return
+    }
+
+    detail := apiFoodDetail{apiFood: newAPIFood(&food), RefusePct: food.refusePct,
+        Nutrients: []apiFoodNutrient{}, Measures: []apiMeasure{}}
+    for _, nutrientInFood := range food.nutrients {
+        nutrient := nutrientInFood.nutrient
+        detail.Nutrients = append(detail.Nutrients, apiFoodNutrient{nutrient.id, nutrient.description, nutrient.units, nutrientInFood.amountPerG * 100})
+    }
+    sort.Slice(detail.Nutrients, func(i, j int) bool {
+        return detail.Nutrients[i].Id < detail.Nutrients[j].Id
+    })
+    for _, measure := range food.measures {
+        detail.Measures = append(detail.Measures, apiMeasure{measure.amount, measure.description, measure.grams})
+    }
+    writeJSON(w, detail)
+}
+
+func (s *server) handleNutrients(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        http.Error(w, "GET only", http.StatusMethodNotAllowed)
+        return
+    }
+    nutrients := []apiNutrient{}
+    for _, id := range sortedNutrientIds(s.problem.nutrients) {
+        nutrient := s.problem.nutrients[id]
+        nutrients = append(nutrients, apiNutrient{id, nutrient.description, nutrient.units})
+    }
+    writeJSON(w, nutrients)
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+    "encoding/csv"
+    "fmt"
+    "math"
+    "os"
+    "strconv"
+    "strings"
+)
+
+// --baseline is what's already been eaten today, so the recipe only has to
+// fill the gap: each target's min and max have the baseline's amount of
+// the nutrient taken off. It's either a Cronometer export, servings or
+// daily nutrition, whose last day in the file is used, or a CSV of
+// nutrient amounts, with units or in the nutrient's own:
+//
+//     nutrient,amount
+//     Protein,35g
+//     "Calcium, Ca",400mg
+//     "Vitamin D (D2 + D3)",400 IU
+//
+// A target already met stays met with a min of 0. One whose max the
+// baseline is already at or over keeps 1% of the max, rather than none,
+// which would mean no max at all.
+
+// Cronometer's export columns, "Name (units)", by name, and the nutrients
+// they are in the data
+var cronometerNutrients = map[string]string{
+    "Energy": "Energy, kcal",
+    "Water": "Water",
+    "Caffeine": "Caffeine",
+    "Alcohol": "Alcohol, ethyl",
+    "B1 (Thiamine)": "Thiamin",
+    "B2 (Riboflavin)": "Riboflavin",
+    "B3 (Niacin)": "Niacin",
+    "B5 (Pantothenic Acid)": "Pantothenic acid",
+    "B6 (Pyridoxine)": "Vitamin B-6",
+    "B12 (Cobalamin)": "Vitamin B-12",
+    "Folate": "Folate",
+    "Vitamin A": "Vitamin A, RAE",
+    "Vitamin C": "Vitamin C, total ascorbic acid",
+    "Vitamin D": "Vitamin D (D2 + D3)",
+    "Vitamin E": "Vitamin E (alpha-tocopherol)",
+    "Vitamin K": "Vitamin K (phylloquinone)",
+    "Choline": "Choline, total",
+    "Calcium": "Calcium, Ca",
+    "Copper": "Copper, Cu",
+    "Iron": "Iron, Fe",
+    "Magnesium": "Magnesium, Mg",
+    "Manganese": "Manganese, Mn",
+    "Phosphorus": "Phosphorus, P",
+    "Potassium": "Potassium, K",
+    "Selenium": "Selenium, Se",
+    "Sodium": "Sodium, Na",
+    "Zinc": "Zinc, Zn",
+    "Carbs": "Carbohydrate, by difference",
+    "Fiber": "Fiber, total dietary",
+    "Sugars": "Sugars, total",
+    "Added Sugars": "Sugars, added",
+    "Net Carbs": "Net carbs",
+    "Fat": "Total lipid (fat)",
+    "Cholesterol": "Cholesterol",
+    "Saturated": "Fatty acids, total saturated",
+    "Monounsaturated": "Fatty acids, total monounsaturated",
+    "Polyunsaturated": "Fatty acids, total polyunsaturated",
+    "Trans-Fats": "Fatty acids, total trans",
+    "Omega-3": "Omega-3",
+    "Omega-6": "Omega-6",
+    "Protein": "Protein",
+    "Cystine": "Cystine",
+    "Histidine": "Histidine",
+    "Isoleucine": "Isoleucine",
+    "Leucine": "Leucine",
+    "Lysine": "Lysine",
+    "Methionine": "Methionine",
+    "Phenylalanine": "Phenylalanine",
+    "Threonine": "Threonine",
+    "Tryptophan": "Tryptophan",
+    "Tyrosine": "Tyrosine",
+    "Valine": "Valine",
+}
+
+// readBaseline reads a --baseline file into amounts by nutrient, in the
+// nutrients' units, with amounts per kg of body weight multiplied by weightKg.
+func readBaseline(path string, nutrients map[int]Nutrient, nutrientNameToId map[string]int, weightKg float64) (map[string]float64, error) {
+    file, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+    reader := csv.NewReader(file)
+    reader.Comment = '#'
+    reader.FieldsPerRecord = -1
+    records, err := reader.ReadAll()
+    if err != nil {
+        return nil, fmt.Errorf("%s: %v", path, err)
+    }
+    if len(records) == 0 {
+        return nil, fmt.Errorf("%s: empty", path)
+    }
+    if findColumn(records[0], []string{"Energy (kcal)"}) >= 0 {
+        return readCronometerBaseline(path, records, nutrients, nutrientNameToId)
+    }
+
+    baseline := make(map[string]float64)
+    for i, record := range records {
+        if i == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "nutrient") {
+            continue
+        }
+        if len(record) != 2 {
+            return nil, fmt.Errorf("%s line %d: want nutrient,amount", path, i + 1)
+        }
+        nutrient := strings.TrimSpace(record[0])
+        if !isTargetNutrient(nutrientNameToId, nutrient) {
+            return nil, fmt.Errorf("%s line %d: no nutrient %q", path, i + 1, nutrient)
+        }
+        amount, err := parseTargetBound(record[1], targetUnits(nutrients, nutrientNameToId, nutrient), nutrient, weightKg)
+        if err != nil {
+            return nil, fmt.Errorf("%s line %d: %v", path, i + 1, err)
+        }
+        baseline[nutrient] += amount
+    }
+    return baseline, nil
+}
+
+// readCronometerBaseline adds up the rows of the last day in a Cronometer
+// export. Columns for nutrients the data doesn't have are skipped.
+func readCronometerBaseline(path string, records [][]string, nutrients map[int]Nutrient, nutrientNameToId map[string]int) (map[string]float64, error) {
+    header := records[0]
+    dateColumn := findColumn(header, []string{"Day", "Date"})
+    if dateColumn < 0 {
+        return nil, fmt.Errorf("%s: Cronometer export without a Day or Date column", path)
+    }
+    lastDay := ""
+    days := make([]string, len(records))
+    for i, record := range records[1:] {
+        if len(record) <= dateColumn {
+            continue
+        }
+        day, err := parseImportDate(record[dateColumn])
+        if err != nil {
+            return nil, fmt.Errorf("%s line %d: %v", path, i + 2, err)
+        }
+        days[i + 1] = day
+        // YYYY-MM-DD compares as a string
+        if day > lastDay {
+            lastDay = day
+        }
+    }
+
+    baseline := make(map[string]float64)
+    for column, name := range header {
+        open := strings.LastIndex(name, " (")
+        if open < 0 || !strings.HasSuffix(name, ")") {
+            continue
+        }
+        nutrient, known := cronometerNutrients[strings.TrimSpace(name[:open])]
+        if !known || !isTargetNutrient(nutrientNameToId, nutrient) {
+            continue
+        }
+        units := name[open + 2:len(name) - 1]
+        for i, record := range records {
+            if days[i] != lastDay || i == 0 || column >= len(record) || strings.TrimSpace(record[column]) == "" {
+                continue
+            }
+            amount, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(record[column]), ",", ""), 64)
+            if err != nil {
+                return nil, fmt.Errorf("%s line %d: bad %s %q", path, i + 1, name, record[column])
+            }
+            converted, err := convertNutrientUnits(amount, units, targetUnits(nutrients, nutrientNameToId, nutrient), nutrient)
+            if err != nil {
+                return nil, fmt.Errorf("%s: %s: %v", path, name, err)
+            }
+            baseline[nutrient] += converted
+        }
+    }
+    return baseline, nil
+}
+
+// baselineAmount is the baseline's amount of nutrient, worked out from its
+// parts for a derived one the baseline doesn't have itself.
+func baselineAmount(baseline map[string]float64, nutrient string) (float64, bool) {
+    if amount, exists := baseline[nutrient]; exists {
+        return amount, true
+    }
+    total, found := float64(0), false
+    for _, part := range derivedNutrients[nutrient] {
+        if amount, exists := baseline[part.nutrient]; exists {
+            total += amount * part.factor
+            found = true
+        }
+    }
+    return total, found
+}
+
+// subtractBaseline takes the --baseline file's amounts off the targets.
+func subtractBaseline(targets []Target, opts *Options, nutrients map[int]Nutrient, nutrientNameToId map[string]int) ([]Target, error) {
+    if opts.baselinePath == "" {
+        return targets, nil
+    }
+    baseline, err := readBaseline(opts.baselinePath, nutrients, nutrientNameToId, opts.weightKg)
+    if err != nil {
+        return nil, err
+    }
+    for i, target := range targets {
+        eaten, exists := baselineAmount(baseline, target.nutrient)
+        if !exists || eaten == 0 {
+            continue
+        }
+        target.min = math.Max(target.min - eaten, 0)
+        if target.max > 0 {
+            target.max = math.Max(target.max - eaten, target.max * 0.01)
+        }
+        note := fmt.Sprintf("less %g %s already eaten, from %s", math.Round(eaten * 100) / 100, targetUnits(nutrients, nutrientNameToId, target.nutrient), opts.baselinePath)
+        if target.notes != "" {
+            note = target.notes + "; " + note
+        }
+        target.notes = note
+        targets[i] = target
+    }
+    return targets, nil
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+    "encoding/json"
+    "flag"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync"
+)
+
+// "supershake batch jobs.yaml" optimizes a recipe for each of a list of
+// jobs, say one per client, loading the data once for all of them. Each job
+// is a name and the flags it runs with, like a profile (see profiles.go):
+//
+//     jobs:
+//       - name: alice
+//         sex: female
+//         age: 34
+//         weight-kg: 60
+//         height-cm: 165
+//         preset: [pregnancy]
+//       - name: bob
+//         targets: bob_targets.yaml
+//         max-cost: 8
+//       - name: carol
+//         profile: carol
+//
+// Flags on the batch command line go to every job, with a job's own flags
+// winning. Each job's report, the same as GET /jobs/<id> answers with, is
+// written to --out as <name>.json. Every job is set up before any is
+// optimized, so a job with bad flags stops the batch before it starts.
+
+type batchJob struct {
+    name string
+    flags map[string][]string
+    opts *Options
+    problem *Problem
+}
+
+// readBatchJobs reads a jobs file, in its order.
+func readBatchJobs(path string) ([]batchJob, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    document, err := parseYAML(string(data))
+    if err != nil {
+        return nil, fmt.Errorf("%s: %v", path, err)
+    }
+    root, _ := document.(map[string]interface{})
+    entries, ok := root["jobs"].([]interface{})
+    if !ok || len(entries) == 0 {
+        return nil, fmt.Errorf("%s: expected a jobs: list", path)
+    }
+    var jobs []batchJob
+    names := make(map[string]bool)
+    for i, entry := range entries {
+        fields, ok := entry.(map[string]interface{})
+        if !ok {
+            return nil, fmt.Errorf("%s: job %d is not a map of flags", path, i + 1)
+        }
+        job := batchJob{flags: make(map[string][]string)}
+        job.name, _ = fields["name"].(string)
+        // The name is a file name in --out
+        if !validStoreKey(job.name) {
+            return nil, fmt.Errorf("%s: job %d needs a name of letters, digits, -, _ and dots", path, i + 1)
+        }
+        if names[job.name] {
+            return nil, fmt.Errorf("%s: there are two jobs named %s", path, job.name)
+        }
+        names[job.name] = true
+        for key, value := range fields {
+            if key == "name" {
+                continue
+            }
+            items, isList := value.([]interface{})
+            if !isList {
+                items = []interface{}{value}
+            }
+            for _, item := range items {
+                text, _ := item.(string)
+                job.flags[key] = append(job.flags[key], text)
+            }
+        }
+        jobs = append(jobs, job)
+    }
+    return jobs, nil
+}
+
+// args are the job's flags as a command line.
+func (job *batchJob) args() []string {
+    var names []string
+    for name := range job.flags {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    var args []string
+    for _, name := range names {
+        for _, value := range job.flags[name] {
+            args = append(args, "--" + strings.TrimLeft(name, "-") + "=" + value)
+        }
+    }
+    return args
+}
+
+// batchData is a dataset loaded for some of the jobs.
+type batchData struct {
+    nutrients map[int]Nutrient
+    nutrientNameToId map[string]int
+    foods map[int]Food
+}
+
+// batchDataKey is the same for jobs that load the same data, so they share
+// it.
+func batchDataKey(opts *Options) string {
+    return fmt.Sprintf("%q", []interface{}{opts.source, opts.dataDir, opts.customFoodsPath, opts.extraDataDirs, opts.tableMappings,
+        opts.brandedDir, opts.offDumpPath, opts.sourcePriority, opts.dedupDescriptions, opts.snapshotPath,
+        opts.validationReportPath, opts.quarantine})
+}
+
+// runBatch handles "supershake batch".
+func runBatch(args []string) {
+    fs := flag.NewFlagSet("supershake batch", flag.ExitOnError)
+    newOptions(fs)
+    out := fs.String("out", "batch", "directory to write each job's report to")
+    parallel := fs.Int("parallel", 1, "how many jobs to optimize at once")
+    fs.Parse(args)
+    if fs.NArg() != 1 || *parallel < 1 {
+        fmt.Fprintln(os.Stderr, "Usage: supershake batch [--out dir] [--parallel n] [flags] jobs.yaml")
+        os.Exit(2)
+    }
+    path := fs.Arg(0)
+    shared := args[:len(args) - 1]
+    if len(shared) > 0 && shared[len(shared) - 1] == "--" {
+        shared = shared[:len(shared) - 1]
+    }
+
+    jobs, err := readBatchJobs(path)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(2)
+    }
+    for i := range jobs {
+        job := &jobs[i]
+        jobFlags := flag.NewFlagSet("job " + job.name, flag.ContinueOnError)
+        jobFlags.SetOutput(io.Discard)
+        job.opts = newOptions(jobFlags)
+        jobFlags.String("out", "", "")
+        jobFlags.Int("parallel", 1, "")
+        if err := jobFlags.Parse(append(append([]string{}, shared...), job.args()...)); err != nil {
+            fmt.Fprintf(os.Stderr, "%s: job %s: %v\n", path, job.name, err)
+            os.Exit(2)
+        }
+        applyProfile(jobFlags, job.opts)
+    }
+    if err := os.MkdirAll(*out, 0755); err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(1)
+    }
+
+    fmt.Println(tr("Loading"))
+    datasets := make(map[string]*batchData)
+    for i := range jobs {
+        job := &jobs[i]
+        key := batchDataKey(job.opts)
+        data, loaded := datasets[key]
+        if !loaded {
+            data = &batchData{}
+            data.nutrients, data.nutrientNameToId, data.foods = loadNutrientsAndFoods(job.opts)
+            data.foods = validateFoods(job.opts, data.nutrients, data.nutrientNameToId, data.foods)
+            datasets[key] = data
+        }
+        fmt.Printf("Setting up %s\n", job.name)
+        job.problem = newProblem(job.opts, data.nutrients, data.nutrientNameToId, filterFoods(job.opts, data.foods))
+    }
+
+    // The jobs share nothing once set up, so any number can run at once
+    var wg sync.WaitGroup
+    var printing sync.Mutex
+    failed := false
+    queue := make(chan *batchJob)
+    for worker := 0; worker < *parallel; worker++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for job := range queue {
+                problem := job.problem
+                recipe, _ := optimize(problem, NewRecipe(problem.foods, problem.nutrients), false)
+                report := newJobReport(problem, job.name, recipe)
+                err := recipe.feasible(problem)
+                if err != nil {
+                    report = jobReport{Job: job.name, Status: "infeasible", Error: err.Error()}
+                }
+                data, writeErr := json.MarshalIndent(report, "", "  ")
+                if writeErr == nil {
+                    writeErr = os.WriteFile(filepath.Join(*out, job.name + ".json"), append(data, '\n'), 0644)
+                }
+                if err == nil {
+                    err = writeErr
+                }
+
+                met := 0
+                for _, nutrient := range report.Nutrients {
+                    if nutrient.Met {
+                        met++
+                    }
+                }
+                printing.Lock()
+                if err != nil {
+                    fmt.Fprintf(os.Stderr, "%s: %v\n", job.name, err)
+                    failed = true
+                } else {
+                    fmt.Printf("%-20s score %8.2f, %d of %d targets met, %d g\n", job.name, report.Score, met, len(report.Nutrients), recipe.Mass())
+                }
+                printing.Unlock()
+            }
+        }()
+    }
+    for i := range jobs {
+        queue <- &jobs[i]
+    }
+    close(queue)
+    wg.Wait()
+    if failed {
+        os.Exit(1)
+    }
+    fmt.Printf("Wrote %d reports to %s\n", len(jobs), *out)
+}
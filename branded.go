@@ -0,0 +1,189 @@
+package main
+
+import (
+    "encoding/csv"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+)
+
+// The USDA Branded Food Products Database (BFPD) in its CSV release:
+//
+//     Products.csv      NDB_Number, long_name, ..., manufacturer, ...
+//     Nutrients.csv     NDB_No, Nutrient_Code, Nutrient_name, Derivation_Code, Output_value, Output_uom
+//     Serving_size.csv  NDB_No, Serving_Size, Serving_Size_UOM, Household_Serving_Size, Household_Serving_Size_UOM, ...
+//
+// Nutrient codes are the same ids NUTR_DEF.txt uses. Label values have
+// already been scaled from the serving size to per 100g (or 100ml, which is
+// taken as 100g), so they just need dividing down to per gram. The serving
+// size becomes a household measure.
+
+const brandedFoodGroup = "branded"
+
+type brandedSource struct {
+    dir string
+}
+
+func (source brandedSource) Name() string {
+    return "branded foods in " + source.dir
+}
+
+func (source brandedSource) LoadNutrients() (map[int]Nutrient, error) {
+    return nil, nil
+}
+
+// openBrandedFile opens one of the BFPD CSVs and finds the columns wanted in
+// its header.
+func openBrandedFile(dir string, filename string, columns ...string) (*os.File, *csv.Reader, []int, error) {
+    path := filepath.Join(dir, filename)
+    file, err := os.Open(path)
+    if err != nil {
+        fmt.Println("Branded foods file not found. Download the BFPD CSV release from https://fdc.nal.usda.gov/download-datasets.html")
+        return nil, nil, nil, err
+    }
+    reader := csv.NewReader(file)
+    reader.FieldsPerRecord = -1
+    reader.LazyQuotes = true
+    header, err := reader.Read()
+    if err != nil {
+        file.Close()
+        return nil, nil, nil, fmt.Errorf("%s: %v", path, err)
+    }
+
+    indexes := make([]int, len(columns))
+    for i, column := range columns {
+        indexes[i] = findColumn(header, []string{column})
+        if indexes[i] < 0 {
+            file.Close()
+            return nil, nil, nil, fmt.Errorf("%s line 1: no %s column", path, column)
+        }
+    }
+    return file, reader, indexes, nil
+}
+
+func brandedField(record []string, index int) string {
+    if index >= len(record) {
+        return ""
+    }
+    return strings.TrimSpace(record[index])
+}
+
+func (source brandedSource) LoadFoods(nutrients map[int]Nutrient) (map[int]Food, error) {
+    foods := make(map[int]Food, 250000)
+
+    productsFile, products, columns, err := openBrandedFile(source.dir, "Products.csv", "NDB_Number", "long_name", "manufacturer")
+    if err != nil {
+        return nil, err
+    }
+    defer productsFile.Close()
+    for {
+        record, err := products.Read()
+        if err == io.EOF {
+            break
+        } else if err != nil {
+            return nil, fmt.Errorf("%s: %v", productsFile.Name(), err)
+        }
+        id, err := strconv.Atoi(brandedField(record, columns[0]))
+        if err != nil {
+            continue
+        }
+        foods[id] = Food{id: id, foodGroup: brandedFoodGroup, description: brandedField(record, columns[1]), manufacturer: brandedField(record, columns[2])}
+    }
+
+    nutrientsFile, nutrientRows, columns, err := openBrandedFile(source.dir, "Nutrients.csv", "NDB_No", "Nutrient_Code", "Output_value", "Output_uom")
+    if err != nil {
+        return nil, err
+    }
+    defer nutrientsFile.Close()
+    for {
+        record, err := nutrientRows.Read()
+        if err == io.EOF {
+            break
+        } else if err != nil {
+            return nil, fmt.Errorf("%s: %v", nutrientsFile.Name(), err)
+        }
+        id, err := strconv.Atoi(brandedField(record, columns[0]))
+        if err != nil {
+            continue
+        }
+        food, exists := foods[id]
+        if !exists {
+            continue
+        }
+        nutrientId, err := strconv.Atoi(brandedField(record, columns[1]))
+        if err != nil {
+            continue
+        }
+        nutrient, exists := nutrients[nutrientId]
+        if !exists {
+            continue
+        }
+        amount, err := strconv.ParseFloat(brandedField(record, columns[2]), 64)
+        if err != nil {
+            continue
+        }
+        amount, err = convertNutrientUnits(amount, brandedField(record, columns[3]), nutrient.units, nutrient.description)
+        if err != nil {
+            continue
+        }
+        food.nutrients = append(food.nutrients, NutrientInFood{nutrient: nutrient, amountPerG: amount / 100, sourceCode: sourceLabel})
+        foods[id] = food
+    }
+
+    servingsFile, servings, columns, err := openBrandedFile(source.dir, "Serving_size.csv", "NDB_No", "Serving_Size", "Serving_Size_UOM", "Household_Serving_Size", "Household_Serving_Size_UOM")
+    if err != nil {
+        return nil, err
+    }
+    defer servingsFile.Close()
+    for {
+        record, err := servings.Read()
+        if err == io.EOF {
+            break
+        } else if err != nil {
+            return nil, fmt.Errorf("%s: %v", servingsFile.Name(), err)
+        }
+        id, err := strconv.Atoi(brandedField(record, columns[0]))
+        if err != nil {
+            continue
+        }
+        food, exists := foods[id]
+        if !exists {
+            continue
+        }
+        size, err := strconv.ParseFloat(brandedField(record, columns[1]), 64)
+        if err != nil || size <= 0 {
+            continue
+        }
+        grams, err := brandedServingGrams(size, brandedField(record, columns[2]))
+        if err != nil {
+            continue
+        }
+        householdAmount, err := strconv.ParseFloat(brandedField(record, columns[3]), 64)
+        householdUnit := strings.ToLower(brandedField(record, columns[4]))
+        if err != nil || householdAmount <= 0 || householdUnit == "" {
+            householdAmount, householdUnit = 1, "serving"
+        }
+        food.measures = append(food.measures, Measure{householdAmount, householdUnit, grams})
+        foods[id] = food
+    }
+
+    // A product with no nutrients is no use to the optimizer
+    for id, food := range foods {
+        if len(food.nutrients) == 0 {
+            delete(foods, id)
+        }
+    }
+    return foods, nil
+}
+
+// brandedServingGrams converts a serving size to grams, taking a millilitre
+// to weigh a gram.
+func brandedServingGrams(size float64, unit string) (float64, error) {
+    if strings.EqualFold(unit, "ml") {
+        return size, nil
+    }
+    return convertUnits(size, unit, "g")
+}
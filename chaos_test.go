@@ -0,0 +1,160 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "math"
+    "math/rand"
+    "testing"
+    "time"
+)
+
+// The chaos test is a regression harness for the optimizer. It runs the hill
+// climber with random noise added to every score, checks the recipe's
+// invariants at every evaluation (quantities above 0, nutrient totals
+// matching a sum from scratch, the mass, mercury and cost caps) and that
+// each run still settles. Run it after touching the recipe bookkeeping or
+// anything that caches.
+//
+// A noisy run can't be expected to land where the noise-free one does, a
+// different move early on leads to another local optimum. What it can be
+// expected to do is stop only where no move is better by more than the noise
+// could hide. Scores are scaled by 1 ± noise, so the climber sees a move as
+// no better when it really is better by up to score × 2 noise / (1 + noise),
+// which is the tolerance.
+
+const (
+    chaosSeed = 1
+    chaosRunsPerCase = 2
+    chaosNoise = 0.001 // scores are scaled by a random factor within 1 ± this
+    chaosTimeout = 2 * time.Minute // a run that hasn't settled by then fails
+)
+
+// Totals may drift this much from a sum from scratch through rounding
+const chaosTotalsTolerance = 1e-6
+
+type chaosNoiseSource struct {
+    problem *Problem
+    rng *rand.Rand
+    evaluations int
+    violation error // the first broken invariant seen
+}
+
+// perturb checks recipe and returns score with noise added.
+func (chaos *chaosNoiseSource) perturb(recipe *Recipe, score float64) float64 {
+    chaos.evaluations++
+    if chaos.violation == nil {
+        if err := checkRecipeInvariants(chaos.problem, recipe); err != nil {
+            chaos.violation = fmt.Errorf("evaluation %d: %v", chaos.evaluations, err)
+        }
+    }
+    return score * (1 + chaosNoise * (chaos.rng.Float64() * 2 - 1))
+}
+
+// checkRecipeInvariants returns what's wrong with recipe's bookkeeping, nil
+// if nothing is.
+func checkRecipeInvariants(problem *Problem, recipe *Recipe) error {
+    totals := make(map[int]float64, len(recipe.nutrientTotals))
+    for foodId, grams := range recipe.foodQuantities {
+        if grams <= 0 {
+            return fmt.Errorf("food %d has %d g", foodId, grams)
+        }
+        food, exists := problem.foods[foodId]
+        if !exists {
+            return fmt.Errorf("food %d isn't one the optimizer may use", foodId)
+        }
+        for _, nutrientInFood := range food.nutrients {
+            totals[nutrientInFood.nutrient.id] += nutrientInFood.amountPerG * float64(grams)
+        }
+    }
+    for nutrientId := range problem.nutrients {
+        if _, exists := recipe.nutrientTotals[nutrientId]; !exists {
+            return fmt.Errorf("no total for nutrient %d", nutrientId)
+        }
+    }
+    for nutrientId, total := range recipe.nutrientTotals {
+        if math.Abs(total - totals[nutrientId]) > chaosTotalsTolerance * math.Max(1, math.Abs(totals[nutrientId])) {
+            return fmt.Errorf("nutrient %d totals %g, but the foods add up to %g", nutrientId, total, totals[nutrientId])
+        }
+    }
+    if maxMassG := problem.opts.maxMassG; maxMassG > 0 && recipe.Mass() > maxMassG {
+        return fmt.Errorf("recipe weighs %d g, over --max-mass-g %d", recipe.Mass(), maxMassG)
+    }
+    if maxMercuryUg := problem.opts.maxMercuryUg; maxMercuryUg > 0 && recipe.mercuryUg(problem) > maxMercuryUg {
+        return fmt.Errorf("recipe has %.2f µg of mercury, over --max-mercury-ug %g", recipe.mercuryUg(problem), maxMercuryUg)
+    }
+    if maxCost := problem.opts.maxCost; maxCost > 0 && recipe.cost(problem) > maxCost * (1 + chaosTotalsTolerance) {
+        return fmt.Errorf("recipe costs %.2f, over --max-cost %g", recipe.cost(problem), maxCost)
+    }
+    return nil
+}
+
+// bestMove is the best score one move from recipe reaches, trying the moves
+// optimize would, and the move.
+func bestMove(problem *Problem, recipe *Recipe) (float64, Move) {
+    current := recipe.Clone(problem.foods, problem.nutrients)
+    hard := problem.hasHardTargets()
+    hardMinsMet := 0
+    if hard {
+        hardMinsMet = current.hardMinsMet(problem)
+    }
+    best, bestMove := current.Score(problem, false), Move(nil)
+    for _, generator := range problem.moveGenerators {
+        generator.Moves(problem, current, problem.opts.stepSize, func(move Move) {
+            if !problem.canTry(current, move) {
+                return
+            }
+            move.apply(problem.foods, current)
+            defer move.undo(problem.foods, current)
+            if !problem.allowedAfter(current, move, hard, hardMinsMet) {
+                return
+            }
+            if score := current.Score(problem, false); score < best {
+                best, bestMove = score, move
+            }
+        })
+    }
+    return best, bestMove
+}
+
+func TestChaos(t *testing.T) {
+    if testing.Short() {
+        t.Skip("optimizes several times")
+    }
+    cases := []struct {
+        name string
+        args []string
+    }{
+        {"targets", []string{"--imputed", "trust"}},
+        {"mass cap", []string{"--imputed", "trust", "--max-mass-g", "900"}},
+    }
+    for _, test := range cases {
+        problem := fixtureProblem(t, test.args...)
+        for run := 1; run <= chaosRunsPerCase; run++ {
+            t.Run(fmt.Sprintf("%s/%d", test.name, run), func(t *testing.T) {
+                chaos := &chaosNoiseSource{problem: problem, rng: rand.New(rand.NewSource(chaosSeed + int64(run)))}
+                noisy := *problem
+                noisy.perturb = chaos.perturb
+
+                ctx, cancel := context.WithTimeout(context.Background(), chaosTimeout)
+                defer cancel()
+                recipe, _ := optimizeContext(ctx, &noisy, NewRecipe(noisy.foods, noisy.nutrients), false)
+                if ctx.Err() != nil {
+                    t.Fatalf("didn't settle within %v, after %d evaluations", chaosTimeout, chaos.evaluations)
+                }
+
+                if chaos.violation != nil {
+                    t.Error(chaos.violation)
+                }
+                if err := checkRecipeInvariants(problem, recipe); err != nil {
+                    t.Errorf("final recipe: %v", err)
+                }
+                score := recipe.Score(problem, false)
+                tolerance := math.Abs(score) * 2 * chaosNoise / (1 + chaosNoise)
+                if better, move := bestMove(problem, recipe); better < score - tolerance {
+                    t.Errorf("stopped at %.4f, but %v reaches %.4f, more than the noise's %.4f better", score, move, better, tolerance)
+                }
+            })
+        }
+    }
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+    "encoding/csv"
+    "fmt"
+    "io"
+    "strconv"
+    "strings"
+)
+
+// Health Canada's Canadian Nutrient File (CNF) in its CSV release:
+//
+//     FOOD NAME.csv          FoodID, FoodCode, FoodGroupID, ..., FoodDescription, ...
+//     NUTRIENT NAME.csv      NutrientID, NutrientCode, NutrientSymbol, NutrientUnit, NutrientName, ...
+//     NUTRIENT AMOUNT.csv    FoodID, NutrientID, NutrientValue, StandardError, NumberofObservations, ...
+//     MEASURE NAME.csv       MeasureID, MeasureDescription, ...
+//     CONVERSION FACTOR.csv  FoodID, MeasureID, ConversionFactorValue, ...
+//
+// CNF's NutrientCode is SR26's nutrient number and its food groups are
+// numbered like SR26's, so CNF foods slot in alongside SR26 ones. Nutrients
+// take SR26's descriptions, so the same targets apply. Values are per 100 g,
+// and a measure's conversion factor is what to multiply them by, so the
+// measure weighs 100 times that. The files are Latin-1, like SR26.
+//
+// --source cnf reads it from --data-dir in place of SR26.
+
+const (
+    sourceSR26 = "sr26"
+    sourceCNF = "cnf"
+)
+
+// cnfUnits maps CNF's units onto NUTR_DEF.txt's. The equivalents (niacin,
+// retinol activity, dietary folate) are in the units they're equivalent to.
+var cnfUnits = map[string]string{
+    "kcal": "kcal",
+    "kj": "kJ",
+    "g": "g",
+    "mg": "mg",
+    "µg": "µg",
+    "ug": "µg",
+    "iu": "IU",
+    "ne": "mg",
+    "rae": "µg",
+    "dfe": "µg",
+    "ate": "mg",
+}
+
+// cnfDescriptions are SR26's descriptions for the nutrients targets use
+// that aren't in ABBREV.txt, which covers the rest.
+var cnfDescriptions = map[int]string{
+    268: "Energy, kJ",
+    262: "Caffeine",
+    263: "Theobromine",
+    429: "Dihydrophylloquinone",
+    501: "Tryptophan",
+    502: "Threonine",
+    503: "Isoleucine",
+    504: "Leucine",
+    505: "Lysine",
+    506: "Methionine",
+    507: "Cystine",
+    508: "Phenylalanine",
+    509: "Tyrosine",
+    510: "Valine",
+    512: "Histidine",
+    605: "Fatty acids, total trans",
+    621: "22:6 n-3 (DHA)",
+    629: "20:5 n-3 (EPA)",
+    851: "18:3 n-3 c,c,c (ALA)",
+}
+
+type cnfSource struct {
+    dataDir string
+    keep func(*Food) bool
+}
+
+func (source cnfSource) Name() string {
+    return "CNF in " + source.dataDir
+}
+
+// cnfFile is one of the CNF CSVs, with the wanted columns found in its
+// header.
+type cnfFile struct {
+    name string
+    closer io.Closer
+    reader *csv.Reader
+    columns []int
+    err error // why next stopped early
+}
+
+func openCNFFile(dataDir string, filename string, columns ...string) (*cnfFile, error) {
+    file, err := openDataFile(dataDir, filename)
+    if err != nil {
+        fmt.Println("CNF file not found. Download the Canadian Nutrient File CSVs from https://www.canada.ca/en/health-canada/services/food-nutrition/healthy-eating/nutrient-data.html")
+        return nil, err
+    }
+    reader := csv.NewReader(newTextReader(file))
+    reader.FieldsPerRecord = -1
+    reader.LazyQuotes = true
+    header, err := reader.Read()
+    if err != nil {
+        file.Close()
+        return nil, fmt.Errorf("%s: %v", filename, err)
+    }
+
+    indexes := make([]int, len(columns))
+    for i, column := range columns {
+        indexes[i] = findColumn(header, []string{column})
+        if indexes[i] < 0 {
+            file.Close()
+            return nil, fmt.Errorf("%s line 1: no %s column", filename, column)
+        }
+    }
+    return &cnfFile{name: filename, closer: file, reader: reader, columns: indexes}, nil
+}
+
+// next returns the wanted columns of the next row, nil at the end, or at a
+// row that can't be read, which close then returns.
+func (f *cnfFile) next() []string {
+    record, err := f.reader.Read()
+    if err == io.EOF {
+        return nil
+    } else if err != nil {
+        f.err = fmt.Errorf("%s: %v", f.name, err)
+        return nil
+    }
+    fields := make([]string, len(f.columns))
+    for i, index := range f.columns {
+        fields[i] = brandedField(record, index)
+    }
+    return fields
+}
+
+// close closes the file, returning why next stopped early, if it did.
+func (f *cnfFile) close() error {
+    f.closer.Close()
+    return f.err
+}
+
+// cnfNutrientIds reads NUTRIENT NAME.csv as CNF's NutrientID -> nutrient,
+// whose id is the SR26 number.
+func (source cnfSource) cnfNutrientIds() (map[string]Nutrient, error) {
+    srDescriptions := make(map[int]string, len(abbrevNutrients) + len(cnfDescriptions))
+    for _, column := range abbrevNutrients {
+        srDescriptions[column.id] = column.description
+    }
+    for id, description := range cnfDescriptions {
+        srDescriptions[id] = description
+    }
+
+    byCNFId := make(map[string]Nutrient, 160)
+    names, err := openCNFFile(source.dataDir, "NUTRIENT NAME.csv", "NutrientID", "NutrientCode", "NutrientUnit", "NutrientName")
+    if err != nil {
+        return nil, err
+    }
+    for fields := names.next(); fields != nil; fields = names.next() {
+        code, err := strconv.Atoi(fields[1])
+        if err != nil {
+            continue
+        }
+        units, known := cnfUnits[strings.ToLower(fields[2])]
+        if !known {
+            units = fields[2]
+        }
+        description, known := srDescriptions[code]
+        if !known {
+            description = fields[3]
+        }
+        byCNFId[fields[0]] = Nutrient{id: code, units: units, description: description}
+    }
+    return byCNFId, names.close()
+}
+
+func (source cnfSource) LoadNutrients() (map[int]Nutrient, error) {
+    byCNFId, err := source.cnfNutrientIds()
+    if err != nil {
+        return nil, err
+    }
+    nutrients := make(map[int]Nutrient, 160)
+    for _, nutrient := range byCNFId {
+        nutrients[nutrient.id] = nutrient
+    }
+    return nutrients, nil
+}
+
+// LoadFoods reads the foods, their nutrients and measures. CNF gives the
+// number of observations but not which values were imputed, so --imputed
+// has nothing to go on and every value is used as is.
+func (source cnfSource) LoadFoods(nutrients map[int]Nutrient) (map[int]Food, error) {
+    foods := make(map[int]Food, 6000)
+    names, err := openCNFFile(source.dataDir, "FOOD NAME.csv", "FoodID", "FoodGroupID", "FoodDescription")
+    if err != nil {
+        return nil, err
+    }
+    for fields := names.next(); fields != nil; fields = names.next() {
+        id, err := strconv.Atoi(fields[0])
+        if err != nil {
+            continue
+        }
+        food := Food{id: id, description: fields[2]}
+        if group, err := strconv.Atoi(fields[1]); err == nil {
+            food.foodGroup = fmt.Sprintf("%02d00", group)
+        }
+        if source.keep != nil && !source.keep(&food) {
+            continue
+        }
+        foods[id] = food
+    }
+    if err := names.close(); err != nil {
+        return nil, err
+    }
+
+    byCNFId, err := source.cnfNutrientIds()
+    if err != nil {
+        return nil, err
+    }
+    amounts, err := openCNFFile(source.dataDir, "NUTRIENT AMOUNT.csv", "FoodID", "NutrientID", "NutrientValue", "StandardError", "NumberofObservations")
+    if err != nil {
+        return nil, err
+    }
+    for fields := amounts.next(); fields != nil; fields = amounts.next() {
+        id, err := strconv.Atoi(fields[0])
+        if err != nil {
+            continue
+        }
+        food, exists := foods[id]
+        if !exists {
+            continue
+        }
+        nutrient, exists := nutrients[byCNFId[fields[1]].id]
+        if !exists {
+            continue
+        }
+        amount, err := strconv.ParseFloat(fields[2], 64)
+        if err != nil {
+            continue
+        }
+        // divide by 100 because this measurement is for 100g
+        nutrientInFood := NutrientInFood{nutrient: nutrient, amountPerG: amount / 100}
+        nutrientInFood.dataPoints, _ = strconv.Atoi(fields[4])
+        if stdError, err := strconv.ParseFloat(fields[3], 64); err == nil {
+            nutrientInFood.stdErrorPerG = stdError / 100
+        }
+        food.nutrients = append(food.nutrients, nutrientInFood)
+        foods[id] = food
+    }
+    if err := amounts.close(); err != nil {
+        return nil, err
+    }
+
+    measureNames := make(map[string]string, 1200)
+    measures, err := openCNFFile(source.dataDir, "MEASURE NAME.csv", "MeasureID", "MeasureDescription")
+    if err != nil {
+        return nil, err
+    }
+    for fields := measures.next(); fields != nil; fields = measures.next() {
+        measureNames[fields[0]] = fields[1]
+    }
+    if err := measures.close(); err != nil {
+        return nil, err
+    }
+
+    factors, err := openCNFFile(source.dataDir, "CONVERSION FACTOR.csv", "FoodID", "MeasureID", "ConversionFactorValue")
+    if err != nil {
+        return nil, err
+    }
+    for fields := factors.next(); fields != nil; fields = factors.next() {
+        id, err := strconv.Atoi(fields[0])
+        if err != nil {
+            continue
+        }
+        food, exists := foods[id]
+        if !exists {
+            continue
+        }
+        description, exists := measureNames[fields[1]]
+        factor, err := strconv.ParseFloat(fields[2], 64)
+        if !exists || err != nil || factor <= 0 {
+            continue
+        }
+        food.measures = append(food.measures, abbrevMeasure(description, factor * 100))
+        foods[id] = food
+    }
+    return foods, factors.close()
+}
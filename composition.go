@@ -0,0 +1,66 @@
+package main
+
+import (
+    "fmt"
+    "sort"
+)
+
+// The composition summary breaks a recipe down by food group, by weight and
+// by energy, to hold it up against dietary pattern guidance like MyPlate's
+// half a plate of fruit and vegetables or EAT-Lancet's grams a day of each
+// group.
+
+type groupShare struct {
+    group string
+    grams int
+    kcal float64
+}
+
+// recipeComposition is the recipe's grams and kcal by food group, most
+// grams first. The kcal are macroKcal's, as SR26's energy values are often
+// imputed and so zeroed.
+func recipeComposition(problem *Problem, recipe *Recipe) []groupShare {
+    byGroup := make(map[string]*groupShare)
+    for foodId, grams := range recipe.foodQuantities {
+        food := problem.foods[foodId]
+        share, exists := byGroup[food.foodGroup]
+        if !exists {
+            share = &groupShare{group: food.foodGroup}
+            byGroup[food.foodGroup] = share
+        }
+        share.grams += grams
+        kcal := macroKcal(func(nutrient string) float64 { return food.perGramOf(problem.nutrientNameToId, nutrient) * float64(grams) })
+        share.kcal += kcal[0] + kcal[1] + kcal[2]
+    }
+    shares := make([]groupShare, 0, len(byGroup))
+    for _, share := range byGroup {
+        shares = append(shares, *share)
+    }
+    sort.Slice(shares, func(i, j int) bool {
+        if shares[i].grams != shares[j].grams {
+            return shares[i].grams > shares[j].grams
+        }
+        return shares[i].group < shares[j].group
+    })
+    return shares
+}
+
+func printComposition(problem *Problem, recipe *Recipe) {
+    shares := recipeComposition(problem, recipe)
+    mass := float64(recipe.Mass())
+    kcal := float64(0)
+    for _, share := range shares {
+        kcal += share.kcal
+    }
+
+    fmt.Println("BY FOOD GROUP")
+    fmt.Printf("  %-36s %7s %7s %8s %7s\n", "", "grams", "% mass", "kcal", "% kcal")
+    for _, share := range shares {
+        energy := "-"
+        if kcal > 0 {
+            energy = fmt.Sprintf("%.0f%%", share.kcal / kcal * 100)
+        }
+        fmt.Printf("  %-36s %7d %6.0f%% %8.0f %7s\n", foodGroupName(share.group), share.grams,
+            float64(share.grams) / mass * 100, share.kcal, energy)
+    }
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+    "fmt"
+    "sort"
+)
+
+// The cost report says what each ingredient costs for what it does. What it
+// does is how much worse the penalty score gets without it, so an
+// ingredient's cost per point is its price over that. Ingredients costing
+// well over the recipe's typical cost per point get a cheaper food
+// suggested in their place, if a priced one does most of the same job.
+
+// An ingredient is expensive when it costs this many times the median per
+// point
+const expensiveFactor = 2
+
+// A substitute has to win back this share of the ingredient's points
+const substituteShare = 0.8
+
+type ingredientCost struct {
+    foodId int
+    cost float64
+    points float64 // the penalty score reduction the ingredient is worth
+    substitute *costSubstitution // a cheaper food to use instead, or nil
+}
+
+// costPerPoint is the ingredient's cost per penalty point, or -1 when it
+// doesn't help the score at all.
+func (ingredient ingredientCost) costPerPoint() float64 {
+    if ingredient.points <= 0 {
+        return -1
+    }
+    return ingredient.cost / ingredient.points
+}
+
+type costSubstitution struct {
+    food Food
+    grams int
+    cost float64
+    points float64
+}
+
+// ingredientCosts works out each priced ingredient's cost per point against
+// the penalty score, whatever the objective.
+func ingredientCosts(problem *Problem, recipe *Recipe) []ingredientCost {
+    penaltyProblem := *problem
+    penaltyProblem.objective = ""
+    baseScore := recipe.Score(&penaltyProblem, false)
+
+    var ingredients []ingredientCost
+    for _, foodId := range recipe.FoodIdsByGrams() {
+        price, priced := problem.prices[foodId]
+        if !priced {
+            continue
+        }
+        food := problem.foods[foodId]
+        grams := recipe.foodQuantities[foodId]
+        without := recipe.Clone(problem.foods, problem.nutrients)
+        without.RemoveFood(problem.foods, &food, grams)
+        ingredients = append(ingredients, ingredientCost{
+            foodId: foodId,
+            cost: price * float64(food.purchasedGrams(grams)),
+            points: without.Score(&penaltyProblem, false) - baseScore,
+        })
+    }
+
+    var perPoint []float64
+    for _, ingredient := range ingredients {
+        if ingredient.costPerPoint() >= 0 {
+            perPoint = append(perPoint, ingredient.costPerPoint())
+        }
+    }
+    if len(perPoint) == 0 {
+        return ingredients
+    }
+    sort.Float64s(perPoint)
+    median := perPoint[len(perPoint) / 2]
+    for i := range ingredients {
+        if ingredients[i].costPerPoint() > median * expensiveFactor {
+            ingredients[i].substitute = cheaperSubstitute(&penaltyProblem, recipe, ingredients[i])
+        }
+    }
+    return ingredients
+}
+
+// cheaperSubstitute looks for a priced food not in the recipe that, swapped
+// in for the ingredient at half, the same or double its weight, wins back
+// most of its points for less money. The cheapest per point wins.
+func cheaperSubstitute(penaltyProblem *Problem, recipe *Recipe, ingredient ingredientCost) *costSubstitution {
+    food := penaltyProblem.foods[ingredient.foodId]
+    grams := recipe.foodQuantities[ingredient.foodId]
+    without := recipe.Clone(penaltyProblem.foods, penaltyProblem.nutrients)
+    without.RemoveFood(penaltyProblem.foods, &food, grams)
+    withoutScore := without.Score(penaltyProblem, false)
+
+    candidateIds := make([]int, 0, len(penaltyProblem.prices))
+    for id := range penaltyProblem.prices {
+        if _, inRecipe := recipe.foodQuantities[id]; !inRecipe {
+            candidateIds = append(candidateIds, id)
+        }
+    }
+    sort.Ints(candidateIds)
+
+    var best *costSubstitution
+    for _, id := range candidateIds {
+        candidate, exists := penaltyProblem.foods[id]
+        if !exists {
+            continue
+        }
+        for _, candidateGrams := range []int{roundUpToStep(float64(grams) / 2, penaltyProblem.opts.stepSize), grams, grams * 2} {
+            cost := penaltyProblem.prices[id] * float64(candidate.purchasedGrams(candidateGrams))
+            if cost >= ingredient.cost {
+                continue
+            }
+            swapped := without.Clone(penaltyProblem.foods, penaltyProblem.nutrients)
+            swapped.AddFood(penaltyProblem.foods, &candidate, candidateGrams)
+            points := withoutScore - swapped.Score(penaltyProblem, false)
+            if points < ingredient.points * substituteShare {
+                continue
+            }
+            if best == nil || cost / points < best.cost / best.points {
+                best = &costSubstitution{candidate, candidateGrams, cost, points}
+            }
+        }
+    }
+    return best
+}
+
+// printCostReport prints each priced ingredient's cost per point, and the
+// cheaper substitutes for the expensive ones.
+func printCostReport(problem *Problem, recipe *Recipe) {
+    ingredients := ingredientCosts(problem, recipe)
+    if len(ingredients) == 0 {
+        fmt.Printf("None of the ingredients have prices in %s, so there's no cost report\n", problem.opts.pricesPath)
+        return
+    }
+
+    fmt.Println("COST-EFFECTIVENESS (cost per point of penalty score the ingredient saves)")
+    fmt.Printf("%8s %8s %10s  %s\n", "cost", "points", "per point", "ingredient")
+    for _, ingredient := range ingredients {
+        food := problem.foods[ingredient.foodId]
+        perPoint := "-"
+        if ingredient.costPerPoint() >= 0 {
+            perPoint = fmt.Sprintf("%.3f", ingredient.costPerPoint())
+        }
+        fmt.Printf("%8.2f %8.2f %10s  %s of %s\n", ingredient.cost, ingredient.points, perPoint,
+            food.gramsString(recipe.foodQuantities[ingredient.foodId]), food.description)
+        if ingredient.substitute != nil {
+            substitute := ingredient.substitute
+            fmt.Printf("%29s  expensive: %s of %s does %.0f%% of the job for %.2f\n", "",
+                substitute.food.gramsString(substitute.grams), substitute.food.description,
+                substitute.points / ingredient.points * 100, substitute.cost)
+        }
+    }
+    unpriced := len(recipe.foodQuantities) - len(ingredients)
+    if unpriced > 0 {
+        fmt.Printf("%d ingredients have no price and are left out\n", unpriced)
+    }
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+    "fmt"
+    "io"
+    "net/http"
+    "strconv"
+)
+
+// POST /api/curve?food=<id>&from=0&to=300&step=5 with a recipe, as saved by
+// --save-recipe, returns how the score and each target nutrient change as
+// that food's grams go from from to to, for sliders in a front end. The
+// food needn't be in the recipe yet. The curve is one walk along the range,
+// adding a step of the food at a time as the optimizer does, so it costs
+// about as much as that many optimizer moves.
+//
+// Each nutrient's amounts line up with grams, which keeps the response
+// small enough to fetch as a slider moves.
+
+// Curves longer than this are refused
+const maxCurvePoints = 1000
+
+// Curves can't go past this many grams, which keeps the arithmetic on them
+// well clear of overflowing
+const maxCurveGrams = 100000
+
+type apiCurve struct {
+    Food apiFood `json:"food"`
+    Grams []int `json:"grams"`
+    Score []float64 `json:"score"`
+    Nutrients []apiCurveNutrient `json:"nutrients"`
+}
+
+type apiCurveNutrient struct {
+    Nutrient string `json:"nutrient"`
+    Units string `json:"units"`
+    Min float64 `json:"min"`
+    Max float64 `json:"max,omitempty"`
+    Amounts []float64 `json:"amounts"`
+}
+
+// ingredientCurve evaluates recipe with food at from, from + step, ... up to
+// to grams.
+func ingredientCurve(problem *Problem, recipe *Recipe, food *Food, from, to, step int) apiCurve {
+    curve := apiCurve{Food: newAPIFood(food)}
+    for _, target := range problem.targets {
+        curve.Nutrients = append(curve.Nutrients, apiCurveNutrient{target.nutrient,
+            targetUnits(problem.nutrients, problem.nutrientNameToId, target.nutrient), target.min, target.max, nil})
+    }
+
+    walk := recipe.Clone(problem.foods, problem.nutrients)
+    if grams := walk.foodQuantities[food.id]; grams > 0 {
+        walk.RemoveFood(problem.foods, food, grams)
+    }
+    if from > 0 {
+        walk.AddFood(problem.foods, food, from)
+    }
+    points := (to - from) / step + 1
+    for i := 0; i < points; i++ {
+        grams := from + i * step
+        if i > 0 {
+            walk.AddFood(problem.foods, food, step)
+        }
+        curve.Grams = append(curve.Grams, grams)
+        curve.Score = append(curve.Score, walk.Score(problem, false))
+        for i := range curve.Nutrients {
+            amount := walk.amountOf(problem.nutrientNameToId, curve.Nutrients[i].Nutrient)
+            curve.Nutrients[i].Amounts = append(curve.Nutrients[i].Amounts, amount)
+        }
+    }
+    return curve
+}
+
+// curveParam reads an integer query parameter, def when it's missing, up to
+// maxCurveGrams.
+func curveParam(r *http.Request, name string, def int) (int, error) {
+    text := r.URL.Query().Get(name)
+    if text == "" {
+        return def, nil
+    }
+    value, err := strconv.Atoi(text)
+    if err != nil || value < 0 || value > maxCurveGrams {
+        return 0, fmt.Errorf("%s should be a whole number of grams, at most %d", name, maxCurveGrams)
+    }
+    return value, nil
+}
+
+func (s *server) handleCurve(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "POST a recipe to get a curve for one of its foods", http.StatusMethodNotAllowed)
+        return
+    }
+    data, err := io.ReadAll(io.LimitReader(r.Body, maxShareBytes))
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    quantities, err := parseRecipeFile(data, "recipe")
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    recipe, err := s.recipeFrom(quantities)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    foodId, err := strconv.Atoi(r.URL.Query().Get("food"))
+    food, exists := s.problem.foods[foodId]
+    if err != nil || !exists {
+        http.Error(w, "food should be the id of a food recipes may use", http.StatusBadRequest)
+        return
+    }
+    // By default from none to double what the recipe has
+    to := 2 * quantities[foodId]
+    if to == 0 {
+        to = 200
+    }
+    if to > maxCurveGrams || to < 0 {
+        to = maxCurveGrams
+    }
+    from, err := curveParam(r, "from", 0)
+    if err == nil {
+        to, err = curveParam(r, "to", to)
+    }
+    step := s.problem.opts.stepSize
+    if err == nil {
+        step, err = curveParam(r, "step", step)
+    }
+    if err == nil && (step == 0 || to < from) {
+        err = fmt.Errorf("want from <= to and a step above 0")
+    }
+    if err == nil && (to - from) / step + 1 > maxCurvePoints {
+        err = fmt.Errorf("that's more than %d points, use a bigger step", maxCurvePoints)
+    }
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    // No need for the optimizer's lock, this only reads the problem, and a
+    // slider shouldn't wait on an optimization
+    writeJSON(w, ingredientCurve(s.problem, recipe, &food, from, to, step))
+}
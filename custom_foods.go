@@ -0,0 +1,281 @@
+package main
+
+import (
+    "bufio"
+    "encoding/csv"
+    "flag"
+    "fmt"
+    "io"
+    "os"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// Custom foods live in a CSV file with one row per nutrient:
+//
+//     id,description,nutrient,amount_per_100g,units
+//     900001,Acme Whey Isolate,Protein,80,g
+//
+// Nutrients are named by their NUTR_DEF.txt description. foods add writes
+// amounts in the units NUTR_DEF.txt uses, but any units that convert to those
+// are fine. The foods are loaded alongside SR26 and skip its exclusion rules.
+
+const firstCustomFoodId = 900001
+
+var customFoodsHeader = []string{"id", "description", "nutrient", "amount_per_100g", "units"}
+
+// CustomFood is a food entered by the user rather than read from a dataset.
+type CustomFood struct {
+    id int
+    description string
+    per100g map[string]float64 // nutrient description -> amount per 100g
+    units map[string]string // nutrient description -> units, as written in the file
+}
+
+// readCustomFoods reads a custom foods file. A missing file is the same as
+// an empty one.
+func readCustomFoods(path string) ([]*CustomFood, error) {
+    file, err := os.Open(path)
+    if os.IsNotExist(err) {
+        return nil, nil
+    } else if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+
+    reader := csv.NewReader(file)
+    reader.Comment = '#'
+    reader.FieldsPerRecord = len(customFoodsHeader)
+
+    var foods []*CustomFood
+    byId := make(map[int]*CustomFood)
+    for line := 1; ; line++ {
+        record, err := reader.Read()
+        if err == io.EOF {
+            break
+        } else if err != nil {
+            return nil, fmt.Errorf("%s: %v", path, err)
+        }
+        if line == 1 && record[0] == customFoodsHeader[0] {
+            continue
+        }
+
+        id, err := strconv.Atoi(record[0])
+        if err != nil {
+            return nil, fmt.Errorf("%s line %d: bad id %q", path, line, record[0])
+        }
+        amount, err := strconv.ParseFloat(record[3], 64)
+        if err != nil {
+            return nil, fmt.Errorf("%s line %d: bad amount %q", path, line, record[3])
+        }
+
+        food, exists := byId[id]
+        if !exists {
+            food = &CustomFood{id: id, description: record[1], per100g: make(map[string]float64), units: make(map[string]string)}
+            byId[id] = food
+            foods = append(foods, food)
+        } else if food.description != record[1] {
+            return nil, fmt.Errorf("%s line %d: id %d is already used by %q", path, line, id, food.description)
+        }
+        food.per100g[record[2]] = amount
+        food.units[record[2]] = record[4]
+    }
+    return foods, nil
+}
+
+// customFoodsSource makes the custom foods file a FoodSource. It defines no
+// nutrients of its own; every row has to name one from NUTR_DEF.txt.
+type customFoodsSource struct {
+    path string
+}
+
+// Custom foods get this in place of a food group, so the exclusion rules
+// meant for SR26 don't throw out the user's own pantry.
+const customFoodGroup = "custom"
+
+func (source customFoodsSource) Name() string {
+    return source.path
+}
+
+func (source customFoodsSource) LoadNutrients() (map[int]Nutrient, error) {
+    return nil, nil
+}
+
+func (source customFoodsSource) LoadFoods(nutrients map[int]Nutrient) (map[int]Food, error) {
+    customFoods, err := readCustomFoods(source.path)
+    if err != nil {
+        return nil, err
+    }
+
+    nutrientNameToId := nutrientNameIndex(nutrients)
+    foods := make(map[int]Food, len(customFoods))
+    for _, customFood := range customFoods {
+        food := Food{id: customFood.id, foodGroup: customFoodGroup, description: customFood.description}
+        for description, amount := range customFood.per100g {
+            nutrientId, exists := nutrientNameToId[description]
+            if !exists {
+                return nil, fmt.Errorf("%s: %s uses unknown nutrient %q", source.path, customFood.description, description)
+            }
+            nutrient := nutrients[nutrientId]
+            converted, err := convertNutrientUnits(amount, customFood.units[description], nutrient.units, description)
+            if err != nil {
+                return nil, fmt.Errorf("%s: %s, %s: %v", source.path, customFood.description, description, err)
+            }
+            food.nutrients = append(food.nutrients, NutrientInFood{nutrient: nutrient, amountPerG: converted / 100})
+        }
+        foods[food.id] = food
+    }
+    return foods, nil
+}
+
+func nextCustomFoodId(foods []*CustomFood) int {
+    next := firstCustomFoodId
+    for _, food := range foods {
+        if food.id >= next {
+            next = food.id + 1
+        }
+    }
+    return next
+}
+
+// appendCustomFood adds food to the end of the custom foods file, creating
+// the file with a header if needed.
+func appendCustomFood(path string, food *CustomFood, nutrients map[int]Nutrient, nutrientNameToId map[string]int) error {
+    _, err := os.Stat(path)
+    isNew := os.IsNotExist(err)
+
+    file, err := os.OpenFile(path, os.O_APPEND | os.O_CREATE | os.O_WRONLY, 0644)
+    if err != nil {
+        return err
+    }
+    writer := csv.NewWriter(file)
+    if isNew {
+        writer.Write(customFoodsHeader)
+    }
+
+    descriptions := make([]string, 0, len(food.per100g))
+    for description := range food.per100g {
+        descriptions = append(descriptions, description)
+    }
+    sort.Strings(descriptions)
+    for _, description := range descriptions {
+        units := nutrients[nutrientNameToId[description]].units
+        writer.Write([]string{
+            strconv.Itoa(food.id), food.description, description,
+            strconv.FormatFloat(food.per100g[description], 'f', -1, 64), units,
+        })
+    }
+
+    writer.Flush()
+    if err := writer.Error(); err != nil {
+        file.Close()
+        return err
+    }
+    return file.Close()
+}
+
+func printCustomFood(food *CustomFood, nutrients map[int]Nutrient, nutrientNameToId map[string]int) {
+    fmt.Printf("%d: %s, per 100g\n", food.id, food.description)
+    descriptions := make([]string, 0, len(food.per100g))
+    for description := range food.per100g {
+        descriptions = append(descriptions, description)
+    }
+    sort.Strings(descriptions)
+    for _, description := range descriptions {
+        units := nutrients[nutrientNameToId[description]].units
+        fmt.Printf("  %.2f%s of %s\n", food.per100g[description], units, description)
+    }
+}
+
+// runFoods handles "supershake foods add", which turns a nutrition label or a
+// barcode into a custom food.
+func runFoods(args []string) {
+    if len(args) == 0 || args[0] != "add" {
+        fmt.Fprintln(os.Stderr, "Usage: supershake foods add (--label label.csv | --interactive | --barcode code) [flags]")
+        os.Exit(2)
+    }
+
+    fs := flag.NewFlagSet("supershake foods add", flag.ExitOnError)
+    opts := newOptions(fs)
+    labelPath := fs.String("label", "", "nutrition label CSV to convert, values per serving")
+    interactive := fs.Bool("interactive", false, "type the label in at the prompt")
+    barcode := fs.String("barcode", "", "look the food up on Open Food Facts by barcode")
+    cacheDir := fs.String("off-cache", "off_cache", "directory caching Open Food Facts lookups")
+    yes := fs.Bool("yes", false, "add looked up foods without asking for confirmation")
+    id := fs.Int("id", 0, "id for the new food, defaults to the next free custom id")
+    fs.Parse(args[1:])
+
+    nutrients, nutrientNameToId := getNutrients(opts.dataDir)
+
+    var food *CustomFood
+    var err error
+    switch {
+    case *labelPath != "" || *interactive:
+        var rows [][]string
+        if *interactive {
+            rows = promptForLabel(os.Stdin, os.Stdout, nutrientNameToId)
+        } else {
+            file, err := os.Open(*labelPath)
+            if err != nil {
+                fmt.Fprintln(os.Stderr, err)
+                os.Exit(1)
+            }
+            rows, err = readLabelFile(file)
+            file.Close()
+            if err != nil {
+                fmt.Fprintf(os.Stderr, "%s: %v\n", *labelPath, err)
+                os.Exit(1)
+            }
+        }
+        food, err = labelToCustomFood(rows, nutrients, nutrientNameToId)
+    case *barcode != "":
+        var product *openFoodFactsProduct
+        product, err = lookupBarcode(*barcode, *cacheDir)
+        if err == nil {
+            food, err = openFoodFactsToCustomFood(product, nutrients)
+        }
+    default:
+        fmt.Fprintln(os.Stderr, "Give one of --label, --interactive or --barcode")
+        os.Exit(2)
+    }
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(1)
+    }
+
+    existing, err := readCustomFoods(opts.customFoodsPath)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(1)
+    }
+    food.id = *id
+    if food.id == 0 {
+        food.id = nextCustomFoodId(existing)
+    }
+    for _, other := range existing {
+        if other.id == food.id {
+            fmt.Fprintf(os.Stderr, "Id %d is already used by %q\n", food.id, other.description)
+            os.Exit(1)
+        }
+    }
+
+    printCustomFood(food, nutrients, nutrientNameToId)
+    // Crowd-sourced data gets a look before it goes into the pantry
+    if *barcode != "" && !*yes && !confirm("Add this food?") {
+        fmt.Println("Not added")
+        return
+    }
+    if err := appendCustomFood(opts.customFoodsPath, food, nutrients, nutrientNameToId); err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(1)
+    }
+    fmt.Println("Added to", opts.customFoodsPath)
+}
+
+func confirm(question string) bool {
+    fmt.Print(question + " [y/N] ")
+    answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+    answer = strings.ToLower(strings.TrimSpace(answer))
+    return answer == "y" || answer == "yes"
+}
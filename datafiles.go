@@ -0,0 +1,129 @@
+package main
+
+import (
+    "archive/zip"
+    "compress/gzip"
+    "io"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+)
+
+// The data files don't have to be extracted. Each one is looked for as is,
+// then gzipped alongside (NUT_DATA.txt.gz), then inside any zip in the data
+// directory, so sr26.zip can sit next to the binary untouched. --data-dir
+// can also name the zip itself.
+
+// dataFileInfo is where a data file was found.
+type dataFileInfo struct {
+    path string // the file on disk, which may be a .gz or the zip
+    entry *zip.File // the file inside the zip, nil when not zipped
+    size int64
+    modTime time.Time
+}
+
+// findDataFile looks for name in dataDir, returning os.ErrNotExist if it
+// isn't there in any form.
+func findDataFile(dataDir string, name string) (*dataFileInfo, error) {
+    if strings.EqualFold(filepath.Ext(dataDir), ".zip") {
+        return findInZip(dataDir, name)
+    }
+    for _, path := range []string{filepath.Join(dataDir, name), filepath.Join(dataDir, name + ".gz")} {
+        if info, err := os.Stat(path); err == nil {
+            return &dataFileInfo{path: path, size: info.Size(), modTime: info.ModTime()}, nil
+        }
+    }
+    zips, _ := filepath.Glob(filepath.Join(dataDir, "*.zip"))
+    for _, zipPath := range zips {
+        if found, err := findInZip(zipPath, name); err == nil {
+            return found, nil
+        }
+    }
+    return nil, &os.PathError{Op: "open", Path: filepath.Join(dataDir, name), Err: os.ErrNotExist}
+}
+
+// findInZip looks for name anywhere inside the zip, ignoring case like
+// extractDataFiles does.
+func findInZip(zipPath string, name string) (*dataFileInfo, error) {
+    archive, err := zip.OpenReader(zipPath)
+    if err != nil {
+        return nil, err
+    }
+    // Only the entry's header is kept, the zip is reopened to read it
+    defer archive.Close()
+    for _, entry := range archive.File {
+        if strings.EqualFold(filepath.Base(entry.Name), name) {
+            return &dataFileInfo{path: zipPath, entry: entry, size: int64(entry.UncompressedSize64), modTime: entry.Modified}, nil
+        }
+    }
+    return nil, &os.PathError{Op: "open", Path: filepath.Join(zipPath, name), Err: os.ErrNotExist}
+}
+
+func dataFileExists(dataDir string, name string) bool {
+    _, err := findDataFile(dataDir, name)
+    return err == nil
+}
+
+// openDataFile opens name in dataDir wherever findDataFile finds it,
+// decompressing as it's read.
+func openDataFile(dataDir string, name string) (io.ReadCloser, error) {
+    found, err := findDataFile(dataDir, name)
+    if err != nil {
+        return nil, err
+    }
+    if found.entry != nil {
+        archive, err := zip.OpenReader(found.path)
+        if err != nil {
+            return nil, err
+        }
+        for _, entry := range archive.File {
+            if entry.Name == found.entry.Name {
+                reader, err := entry.Open()
+                if err != nil {
+                    archive.Close()
+                    return nil, err
+                }
+                return &zipEntryReader{reader, archive}, nil
+            }
+        }
+        archive.Close()
+        return nil, &os.PathError{Op: "open", Path: filepath.Join(found.path, name), Err: os.ErrNotExist}
+    }
+
+    file, err := os.Open(found.path)
+    if err != nil {
+        return nil, err
+    }
+    if !strings.HasSuffix(found.path, ".gz") {
+        return file, nil
+    }
+    reader, err := gzip.NewReader(file)
+    if err != nil {
+        file.Close()
+        return nil, err
+    }
+    return &gzipFileReader{reader, file}, nil
+}
+
+// zipEntryReader closes the zip along with the entry.
+type zipEntryReader struct {
+    io.ReadCloser
+    archive *zip.ReadCloser
+}
+
+func (r *zipEntryReader) Close() error {
+    r.ReadCloser.Close()
+    return r.archive.Close()
+}
+
+// gzipFileReader closes the file along with the gzip reader.
+type gzipFileReader struct {
+    *gzip.Reader
+    file *os.File
+}
+
+func (r *gzipFileReader) Close() error {
+    r.Reader.Close()
+    return r.file.Close()
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+    "embed"
+    "io/fs"
+    "os"
+    "path/filepath"
+)
+
+// The fixture dataset is built into the binary, so "supershake demo" and
+// --demo work from anywhere without downloading SR26. It's small (a few
+// hundred KB) and generated from a fixed seed, so it's also the same
+// dataset everywhere for checking changes to the optimizer against.
+
+//go:embed testdata/fixture/*.txt
+var embeddedFixture embed.FS
+
+// extractEmbeddedFixture writes the built in fixture to a directory under
+// the temp dir, since the readers want files, and returns it.
+func extractEmbeddedFixture() (string, error) {
+    dir := filepath.Join(os.TempDir(), "supershake-demo")
+    if err := os.MkdirAll(dir, 0755); err != nil {
+        return "", err
+    }
+    files, err := fs.Glob(embeddedFixture, "testdata/fixture/*.txt")
+    if err != nil {
+        return "", err
+    }
+    for _, name := range files {
+        data, err := embeddedFixture.ReadFile(name)
+        if err != nil {
+            return "", err
+        }
+        // Rewritten every time, so a binary never runs with another's data
+        if err := os.WriteFile(filepath.Join(dir, filepath.Base(name)), data, 0644); err != nil {
+            return "", err
+        }
+    }
+    return dir, nil
+}
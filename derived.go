@@ -0,0 +1,187 @@
+package main
+
+import (
+    _ "embed"
+    "fmt"
+    "os"
+    "strconv"
+    "strings"
+)
+
+// Derived nutrients are sums of the nutrients the data reports, each times
+// a factor, like folate DFE or net carbs. Being linear they score, report
+// and go into the linear program like any other nutrient, through amountOf
+// and perGramOf. The built in ones are in derived.yaml, and --derived adds
+// more from a file in the same format.
+
+//go:embed derived.yaml
+var derivedYAML string
+
+type derivedPart struct {
+    nutrient string
+    factor float64
+}
+
+// derivedNutrients are the derived nutrients' parts, by name, all of them
+// reported nutrients.
+var derivedNutrients = mustParseDerived(derivedYAML)
+
+func mustParseDerived(data string) map[string][]derivedPart {
+    derived := make(map[string][]derivedPart)
+    if err := parseDerived(data, "derived.yaml", nil, derived); err != nil {
+        panic(err)
+    }
+    return derived
+}
+
+// loadDerivedNutrients adds the --derived file's nutrients. The same
+// definitions can be loaded again, as every job in a batch does, but a name
+// can't be given two.
+func loadDerivedNutrients(opts *Options, nutrientNameToId map[string]int) error {
+    if opts.derivedPath == "" {
+        return nil
+    }
+    data, err := os.ReadFile(opts.derivedPath)
+    if err != nil {
+        return err
+    }
+    return parseDerived(string(data), opts.derivedPath, nutrientNameToId, derivedNutrients)
+}
+
+// parseDerived reads a derived nutrients file from data into derived.
+// Without nutrientNameToId the nutrients aren't checked, which is how the
+// built in ones are read before any data is loaded.
+func parseDerived(data string, source string, nutrientNameToId map[string]int, derived map[string][]derivedPart) error {
+    document, err := parseYAML(data)
+    if err != nil {
+        return fmt.Errorf("%s: %v", source, err)
+    }
+    root, _ := document.(map[string]interface{})
+    entries, ok := root["derived"].([]interface{})
+    if !ok {
+        return fmt.Errorf("%s: expected a list under \"derived:\"", source)
+    }
+    for i, entry := range entries {
+        fields, _ := entry.(map[string]interface{})
+        name, _ := fields["name"].(string)
+        expression, _ := fields["expression"].(string)
+        if name == "" || expression == "" {
+            return fmt.Errorf("%s: derived nutrient %d needs a name and an expression", source, i + 1)
+        }
+        if _, reported := nutrientNameToId[name]; reported {
+            return fmt.Errorf("%s: %q is already a nutrient in the data", source, name)
+        }
+        parts, err := parseDerivedExpression(expression, nutrientNameToId, derived)
+        if err != nil {
+            return fmt.Errorf("%s: %s: %v", source, name, err)
+        }
+        if existing, exists := derived[name]; exists {
+            if !sameDerivedParts(existing, parts) {
+                return fmt.Errorf("%s: %q is already derived another way", source, name)
+            }
+            continue
+        }
+        derived[name] = parts
+    }
+    return nil
+}
+
+func sameDerivedParts(a, b []derivedPart) bool {
+    if len(a) != len(b) {
+        return false
+    }
+    for i := range a {
+        if a[i] != b[i] {
+            return false
+        }
+    }
+    return true
+}
+
+// splitExpression splits expression at any of operators outside quotes,
+// returning the operands and the operators between them.
+func splitExpression(expression string, operators ...string) ([]string, []string) {
+    var operands, between []string
+    quoted := false
+    start := 0
+    for i := 0; i < len(expression); i++ {
+        if expression[i] == '"' {
+            quoted = !quoted
+            continue
+        }
+        if quoted {
+            continue
+        }
+        for _, operator := range operators {
+            if strings.HasPrefix(expression[i:], operator) {
+                operands = append(operands, expression[start:i])
+                between = append(between, strings.TrimSpace(operator))
+                i += len(operator) - 1
+                start = i + 1
+                break
+            }
+        }
+    }
+    return append(operands, expression[start:]), between
+}
+
+// parseDerivedExpression reads an expression like "Niacin + Tryptophan *
+// 1000 / 60" into its parts, with derived nutrients in it replaced by
+// theirs.
+func parseDerivedExpression(expression string, nutrientNameToId map[string]int, derived map[string][]derivedPart) ([]derivedPart, error) {
+    var parts []derivedPart
+    add := func(nutrient string, factor float64) {
+        for i := range parts {
+            if parts[i].nutrient == nutrient {
+                parts[i].factor += factor
+                return
+            }
+        }
+        parts = append(parts, derivedPart{nutrient, factor})
+    }
+
+    terms, signs := splitExpression(expression, " + ", " - ")
+    for i, term := range terms {
+        sign := float64(1)
+        if i > 0 && signs[i - 1] == "-" {
+            sign = -1
+        }
+        operands, operators := splitExpression(term, " * ", " / ")
+        nutrient := ""
+        factor := sign
+        for j, operand := range operands {
+            operand = strings.TrimSpace(operand)
+            if number, err := strconv.ParseFloat(operand, 64); err == nil {
+                if j > 0 && operators[j - 1] == "/" {
+                    if number == 0 {
+                        return nil, fmt.Errorf("divides by 0")
+                    }
+                    number = 1 / number
+                }
+                factor *= number
+                continue
+            }
+            if nutrient != "" {
+                return nil, fmt.Errorf("%q multiplies two nutrients, which isn't a sum of nutrients", strings.TrimSpace(term))
+            }
+            if j > 0 && operators[j - 1] == "/" {
+                return nil, fmt.Errorf("%q divides by a nutrient, which isn't a sum of nutrients", strings.TrimSpace(term))
+            }
+            nutrient = strings.Trim(operand, "\"")
+        }
+        if nutrient == "" {
+            return nil, fmt.Errorf("%q has no nutrient in it", strings.TrimSpace(term))
+        }
+
+        if inner, exists := derived[nutrient]; exists {
+            for _, part := range inner {
+                add(part.nutrient, part.factor * factor)
+            }
+        } else if _, reported := nutrientNameToId[nutrient]; reported || nutrientNameToId == nil {
+            add(nutrient, factor)
+        } else {
+            return nil, fmt.Errorf("no nutrient %q", nutrient)
+        }
+    }
+    return parts, nil
+}
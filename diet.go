@@ -0,0 +1,255 @@
+package main
+
+import (
+    "fmt"
+    "os"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// --diet keeps recipes to what a diet allows. Each food is tagged with the
+// animal products (and alcohol) in it, worked out from its food group and
+// description, and a diet is the tags it rules out. It's a heuristic: it
+// can't know how meat was slaughtered or what's certified, so kosher and
+// halal leave out meat altogether, and kosher keeps to dairy and pareve
+// foods so a shake never mixes meat and milk. --diet-tags corrects foods it
+// gets wrong.
+
+const (
+    tagPlant = "plant" // no animal products, only used in --diet-tags
+    tagDairy = "dairy"
+    tagEgg = "egg"
+    tagHoney = "honey"
+    tagGelatin = "gelatin"
+    tagFish = "fish"
+    tagScaleless = "scaleless-fish" // fish without fins and scales, not kosher
+    tagShellfish = "shellfish"
+    tagMeat = "meat"
+    tagPork = "pork"
+    tagAlcohol = "alcohol"
+    tagMixed = "mixed" // a mixed dish that may have anything in it
+)
+
+var dietTags = []string{tagPlant, tagDairy, tagEgg, tagHoney, tagGelatin, tagFish, tagScaleless, tagShellfish, tagMeat, tagPork, tagAlcohol, tagMixed}
+
+// diets maps each diet to the tags it rules out.
+var diets = map[string][]string{
+    "vegan": {tagDairy, tagEgg, tagHoney, tagGelatin, tagFish, tagScaleless, tagShellfish, tagMeat, tagPork, tagMixed},
+    "vegetarian": {tagGelatin, tagFish, tagScaleless, tagShellfish, tagMeat, tagPork, tagMixed},
+    "pescatarian": {tagGelatin, tagMeat, tagPork, tagMixed},
+    "kosher": {tagGelatin, tagScaleless, tagShellfish, tagMeat, tagPork},
+    "halal": {tagGelatin, tagMeat, tagPork, tagAlcohol},
+}
+
+// dietFoodGroups tags whole SR26 food groups.
+var dietFoodGroups = map[string][]string{
+    "0500": {tagMeat}, // poultry
+    "0700": {tagMeat}, // sausages and luncheon meats
+    "1000": {tagPork},
+    "1300": {tagMeat}, // beef
+    "1500": {tagFish}, // finfish and shellfish, told apart by name below
+    "1700": {tagMeat}, // lamb, veal and game
+    // Groups of mixed dishes
+    "0300": {tagMixed}, // baby foods
+    "0600": {tagMixed}, // soups and sauces
+    "1800": {tagMixed}, // baked products
+    "2100": {tagMixed}, // fast foods
+    "2200": {tagMixed}, // meals and entrees
+    "3500": {tagMixed}, // American Indian/Alaska Native foods
+    "3600": {tagMixed}, // restaurant foods
+}
+
+// dietWords tags foods by a word anywhere in the description, e.g. the
+// cheese in "Crackers, with cheese filling".
+var dietWords = map[string]string{
+    "milk": tagDairy, "buttermilk": tagDairy, "cheese": tagDairy, "yogurt": tagDairy, "butter": tagDairy,
+    "cream": tagDairy, "whey": tagDairy, "casein": tagDairy, "kefir": tagDairy, "ghee": tagDairy,
+    "egg": tagEgg, "eggs": tagEgg,
+    "honey": tagHoney,
+    "gelatin": tagGelatin, "gelatins": tagGelatin,
+    "fish": tagFish, "sardine": tagFish, "sardines": tagFish, "salmon": tagFish, "tuna": tagFish,
+    "anchovy": tagFish, "anchovies": tagFish, "herring": tagFish, "mackerel": tagFish, "cod": tagFish,
+    "trout": tagFish, "roe": tagFish,
+    "catfish": tagScaleless, "eel": tagScaleless, "shark": tagScaleless, "swordfish": tagScaleless,
+    "sturgeon": tagScaleless, "monkfish": tagScaleless, "skate": tagScaleless, "caviar": tagScaleless,
+    "crustaceans": tagShellfish, "mollusks": tagShellfish, "shrimp": tagShellfish, "crab": tagShellfish,
+    "lobster": tagShellfish, "clam": tagShellfish, "clams": tagShellfish, "oyster": tagShellfish,
+    "oysters": tagShellfish, "mussel": tagShellfish, "mussels": tagShellfish, "scallop": tagShellfish,
+    "scallops": tagShellfish, "squid": tagShellfish, "octopus": tagShellfish,
+    "beef": tagMeat, "veal": tagMeat, "lamb": tagMeat, "mutton": tagMeat, "chicken": tagMeat,
+    "turkey": tagMeat, "duck": tagMeat, "goose": tagMeat, "venison": tagMeat, "bison": tagMeat,
+    "meat": tagMeat, "tallow": tagMeat, "broth": tagMeat,
+    "pork": tagPork, "bacon": tagPork, "ham": tagPork, "lard": tagPork,
+    "alcoholic": tagAlcohol, "wine": tagAlcohol, "beer": tagAlcohol, "liqueur": tagAlcohol,
+    "rum": tagAlcohol, "vodka": tagAlcohol, "whiskey": tagAlcohol,
+}
+
+// dietPlantWords before one of dietWords make it a plant food: coconut
+// milk, peanut butter, cocoa butter.
+var dietPlantWords = map[string]bool{
+    "coconut": true, "almond": true, "soy": true, "rice": true, "oat": true, "peanut": true,
+    "cashew": true, "nut": true, "cocoa": true, "apple": true, "sunflower": true, "sesame": true,
+    "vegetable": true, "imitation": true, "substitute": true, "nondairy": true, "vegan": true,
+}
+
+// dietPlantAfter after one of dietWords makes it a plant food too, e.g.
+// "vinegar, red wine" is fine but "cream of tartar" is never dairy.
+var dietPlantAfter = map[string]bool{
+    "substitute": true, "substitutes": true, "vinegar": true, "replacer": true, "of": true,
+}
+
+// foodDietTags works out a food's tags, sorted. A food with none is plant
+// based.
+func foodDietTags(food *Food) []string {
+    tags := make(map[string]bool)
+    for _, tag := range dietFoodGroups[food.foodGroup] {
+        tags[tag] = true
+    }
+    words := strings.FieldsFunc(strings.ToLower(food.description), func(r rune) bool {
+        return !(r >= 'a' && r <= 'z')
+    })
+    for i, word := range words {
+        tag, exists := dietWords[word]
+        if !exists {
+            continue
+        }
+        if i > 0 && dietPlantWords[words[i - 1]] {
+            continue
+        }
+        if i + 1 < len(words) && dietPlantAfter[words[i + 1]] {
+            continue
+        }
+        tags[tag] = true
+    }
+    // "Fish, catfish" is scaleless, not both
+    if tags[tagScaleless] || tags[tagShellfish] {
+        delete(tags, tagFish)
+    }
+
+    list := make([]string, 0, len(tags))
+    for tag := range tags {
+        list = append(list, tag)
+    }
+    sort.Strings(list)
+    return list
+}
+
+// checkDiets makes sure every --diet is one we know.
+func checkDiets(opts *Options) error {
+    for _, diet := range opts.dietNames() {
+        if _, exists := diets[diet]; !exists {
+            names := make([]string, 0, len(diets))
+            for name := range diets {
+                names = append(names, name)
+            }
+            sort.Strings(names)
+            return fmt.Errorf("unknown --diet %q, want %s", diet, strings.Join(names, ", "))
+        }
+    }
+    return nil
+}
+
+// dietNames splits the --diet flags, which may be repeated or comma
+// separated.
+func (opts *Options) dietNames() []string {
+    var names []string
+    for _, flag := range opts.diets {
+        for _, name := range strings.Split(flag, ",") {
+            if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+                names = append(names, name)
+            }
+        }
+    }
+    return names
+}
+
+// foodTags is food's tags from --diet-tags, or as worked out when it isn't
+// listed there.
+func foodTags(opts *Options, food *Food) []string {
+    if tags, overridden := opts.dietTagOverrides()[food.id]; overridden {
+        return tags
+    }
+    return foodDietTags(food)
+}
+
+// dietRule is the first --diet food doesn't fit, "" if it fits them all.
+func dietRule(opts *Options, food *Food) string {
+    if len(opts.diets) == 0 {
+        return ""
+    }
+    tags := foodTags(opts, food)
+    for _, diet := range opts.dietNames() {
+        for _, ruledOut := range diets[diet] {
+            for _, tag := range tags {
+                if tag == ruledOut {
+                    return "--diet " + diet + " (" + tag + ")"
+                }
+            }
+        }
+    }
+    return ""
+}
+
+// readDietTags reads the --diet-tags file, food id: tags, e.g.
+//
+//   11457: [plant]
+//   900001: [dairy, egg]
+func readDietTags(path string) (map[int][]string, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    document, err := parseYAML(string(data))
+    if err != nil {
+        return nil, fmt.Errorf("%s: %v", path, err)
+    }
+    entries, ok := document.(map[string]interface{})
+    if !ok {
+        return nil, fmt.Errorf("%s: expected food id: tags", path)
+    }
+    overrides := make(map[int][]string, len(entries))
+    for key, value := range entries {
+        id, err := strconv.Atoi(key)
+        if err != nil {
+            return nil, fmt.Errorf("%s: %q is not a food id", path, key)
+        }
+        items, isList := value.([]interface{})
+        if !isList {
+            items = []interface{}{value}
+        }
+        tags := []string{}
+        for _, item := range items {
+            tag, _ := item.(string)
+            known := false
+            for _, dietTag := range dietTags {
+                known = known || tag == dietTag
+            }
+            if !known {
+                return nil, fmt.Errorf("%s: food %d: unknown tag %q, want some of %s", path, id, tag, strings.Join(dietTags, ", "))
+            }
+            if tag != tagPlant {
+                tags = append(tags, tag)
+            }
+        }
+        overrides[id] = tags
+    }
+    return overrides, nil
+}
+
+// dietTagOverrides is the --diet-tags file, read the first time it's
+// needed. A bad file exits.
+func (opts *Options) dietTagOverrides() map[int][]string {
+    if opts.dietTagsPath == "" {
+        return nil
+    }
+    if opts.dietTags == nil {
+        overrides, err := readDietTags(opts.dietTagsPath)
+        if err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            os.Exit(2)
+        }
+        opts.dietTags = overrides
+    }
+    return opts.dietTags
+}
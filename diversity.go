@@ -0,0 +1,92 @@
+package main
+
+import (
+    "fmt"
+    "sort"
+)
+
+// --max-share hedges against a food's value being wrong, or less of it
+// being absorbed than the table says, by not leaning on any one food for a
+// nutrient: each food supplying more than that percentage of a target's
+// min costs, for each target, (share - max-share) / 100 * --max-share-weight.
+// So with --max-share 50, a food with the whole min of something costs 5.
+//
+// The shares come from problem.contributions, each food's amount of each
+// target nutrient per gram, worked out once. The linear program leaves this
+// out, as it would take a variable for every food and nutrient.
+
+// contributionMatrix is nutrient -> food id -> amount per gram, for the
+// targets' nutrients the food has any of.
+type contributionMatrix map[string]map[int]float64
+
+func newContributionMatrix(nutrientNameToId map[string]int, foods map[int]Food, targets []Target) contributionMatrix {
+    matrix := make(contributionMatrix, len(targets))
+    for _, target := range targets {
+        perGram := make(map[int]float64)
+        for id, food := range foods {
+            if amount := food.perGramOf(nutrientNameToId, target.nutrient); amount > 0 {
+                perGram[id] = amount
+            }
+        }
+        matrix[target.nutrient] = perGram
+    }
+    return matrix
+}
+
+// shareOver is how many percent of target's min food supplies past
+// --max-share, 0 if none.
+func (recipe *Recipe) shareOver(problem *Problem, target Target, foodId int) float64 {
+    if target.min <= 0 {
+        return 0
+    }
+    share := float64(recipe.foodQuantities[foodId]) * problem.contributions[target.nutrient][foodId] / target.min * 100
+    if share <= problem.opts.maxShare {
+        return 0
+    }
+    return share - problem.opts.maxShare
+}
+
+// diversityPenalty is the --max-share part of the score.
+func (recipe *Recipe) diversityPenalty(problem *Problem, verbose bool) float64 {
+    if problem.contributions == nil {
+        return 0
+    }
+    penalty := float64(0)
+    for _, target := range problem.targets {
+        for foodId := range recipe.foodQuantities {
+            penalty += recipe.shareOver(problem, target, foodId) / 100 * problem.opts.maxShareWeight * target.penaltyWeight()
+        }
+    }
+    if verbose { fmt.Printf("Penalty for foods over --max-share: %f\n", penalty) }
+    return penalty
+}
+
+func printSourceDiversity(problem *Problem, recipe *Recipe) {
+    type overShare struct {
+        nutrient string
+        food string
+        share float64
+    }
+    var over []overShare
+    for _, target := range problem.targets {
+        for foodId := range recipe.foodQuantities {
+            if excess := recipe.shareOver(problem, target, foodId); excess > 0 {
+                over = append(over, overShare{target.nutrient, problem.foods[foodId].description, excess + problem.opts.maxShare})
+            }
+        }
+    }
+    if len(over) == 0 {
+        fmt.Printf("SOURCE DIVERSITY: no food supplies over %g%% of any min\n", problem.opts.maxShare)
+        return
+    }
+    sort.Slice(over, func(i, j int) bool {
+        if over[i].share != over[j].share {
+            return over[i].share > over[j].share
+        }
+        return over[i].nutrient + over[i].food < over[j].nutrient + over[j].food
+    })
+    fmt.Printf("SOURCE DIVERSITY: foods supplying over %g%% of a min\n", problem.opts.maxShare)
+    for _, entry := range over {
+        fmt.Printf("  %-32s %5.0f%% from %s\n", entry.nutrient, entry.share, entry.food)
+    }
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+    "archive/zip"
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+    "io"
+    "net/http"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+)
+
+const sr26URL = "https://www.ars.usda.gov/SP2UserFiles/Place/12354500/Data/SR26/dnload/sr26.zip"
+
+// The sha256 of the zips --download knows, so they're verified without
+// --download-sha256. A zip whose sha256 isn't known, and isn't given, isn't
+// extracted, unless --download-sha256 is skip. sr26.zip's goes here once
+// it's been taken from a download checked against USDA's; until then
+// --download needs --download-sha256.
+var datasetSHA256 = map[string]string{}
+
+// What --download-sha256 is to extract a zip without verifying it
+const skipSHA256 = "skip"
+
+// sr26.zip is a few MB, this is long enough for a slow line but not for a
+// stalled one
+const downloadTimeout = 10 * time.Minute
+
+// The SR26 files supershake reads.
+var sr26DataFiles = []string{"NUTR_DEF.txt", "FOOD_DES.txt", "NUT_DATA.txt", "WEIGHT.txt"}
+
+// dataFilesIn is the files supershake reads from dataDir: ABBREV.txt alone
+// if that's what's there, otherwise the full format.
+func dataFilesIn(dataDir string) []string {
+    if usesAbbrev(dataDir) {
+        return []string{abbrevFile}
+    }
+    return sr26DataFiles
+}
+
+func missingDataFiles(dataDir string) []string {
+    var missing []string
+    for _, name := range dataFilesIn(dataDir) {
+        if !dataFileExists(dataDir, name) {
+            missing = append(missing, name)
+        }
+    }
+    return missing
+}
+
+// ensureDataFiles finds the dataset when files are missing and --data-dir
+// was left as ".", looking in the standard places and then asking, or
+// downloads and extracts it when --download was given.
+func ensureDataFiles(opts *Options) {
+    // Only SR26 can be found or downloaded
+    if opts.source != sourceSR26 {
+        return
+    }
+    missing := missingDataFiles(opts.dataDir)
+    if len(missing) == 0 {
+        return
+    }
+    if opts.dataDir == "." {
+        if dir := findStandardDataDir(); dir != "" {
+            fmt.Println("Using the dataset in", dir)
+            opts.dataDir = dir
+            return
+        }
+        if !opts.download && isInteractive() {
+            if dir := promptForDataDir(os.Stdin, os.Stdout); dir != "" {
+                opts.dataDir = dir
+            }
+            return
+        }
+    }
+    if !opts.download {
+        return
+    }
+    fmt.Printf("Missing %s, downloading %s\n", strings.Join(missing, ", "), opts.downloadURL)
+    if err := downloadDataset(opts.downloadURL, opts.downloadSHA256, opts.dataDir); err != nil {
+        fmt.Fprintln(os.Stderr, tr("Download failed:"), err)
+        os.Exit(1)
+    }
+}
+
+// downloadDataset fetches the zip into dataDir, checks it against
+// wantSHA256, or the sha256 known for url, and extracts the data files next
+// to it. A wantSHA256 of skip extracts it unverified, with a warning.
+func downloadDataset(url string, wantSHA256 string, dataDir string) error {
+    if wantSHA256 == "" {
+        wantSHA256 = datasetSHA256[url]
+    }
+    if wantSHA256 == "" {
+        return fmt.Errorf("no known sha256 for %s, pass --download-sha256 with it, or --download-sha256 %s to extract it unverified", url, skipSHA256)
+    }
+    if err := os.MkdirAll(dataDir, 0755); err != nil {
+        return err
+    }
+
+    zipPath := filepath.Join(dataDir, filepath.Base(url))
+    if !strings.HasSuffix(zipPath, ".zip") {
+        zipPath = filepath.Join(dataDir, "dataset.zip")
+    }
+    tmpPath := zipPath + ".part"
+
+    client := &http.Client{Timeout: downloadTimeout}
+    response, err := client.Get(url)
+    if err != nil {
+        return err
+    }
+    defer response.Body.Close()
+    if response.StatusCode != http.StatusOK {
+        return fmt.Errorf("%s returned %s", url, response.Status)
+    }
+
+    file, err := os.Create(tmpPath)
+    if err != nil {
+        return err
+    }
+    hash := sha256.New()
+    if _, err := io.Copy(io.MultiWriter(file, hash), response.Body); err != nil {
+        file.Close()
+        os.Remove(tmpPath)
+        return err
+    }
+    if err := file.Close(); err != nil {
+        os.Remove(tmpPath)
+        return err
+    }
+
+    gotSHA256 := hex.EncodeToString(hash.Sum(nil))
+    if wantSHA256 == skipSHA256 {
+        fmt.Fprintf(os.Stderr, "Warning: extracting %s without verifying it, its sha256 is %s\n", filepath.Base(zipPath), gotSHA256)
+    } else if !strings.EqualFold(gotSHA256, wantSHA256) {
+        os.Remove(tmpPath)
+        return fmt.Errorf("checksum mismatch: got sha256 %s, want %s", gotSHA256, wantSHA256)
+    }
+    if err := os.Rename(tmpPath, zipPath); err != nil {
+        os.Remove(tmpPath)
+        return err
+    }
+
+    return extractDataFiles(zipPath, dataDir)
+}
+
+// extractDataFiles pulls the files supershake reads out of the zip, wherever
+// they sit inside it.
+func extractDataFiles(zipPath string, dataDir string) error {
+    archive, err := zip.OpenReader(zipPath)
+    if err != nil {
+        return err
+    }
+    defer archive.Close()
+
+    // The abbreviated zip only has ABBREV.txt, which will do instead
+    wanted := make(map[string]string, len(sr26DataFiles) + 1)
+    for _, name := range sr26DataFiles {
+        wanted[strings.ToUpper(name)] = name
+    }
+    wanted[strings.ToUpper(abbrevFile)] = abbrevFile
+
+    for _, entry := range archive.File {
+        name, isWanted := wanted[strings.ToUpper(filepath.Base(entry.Name))]
+        if !isWanted {
+            continue
+        }
+        if err := extractZipEntry(entry, filepath.Join(dataDir, name)); err != nil {
+            return err
+        }
+        delete(wanted, strings.ToUpper(name))
+    }
+
+    _, abbrevMissing := wanted[strings.ToUpper(abbrevFile)]
+    delete(wanted, strings.ToUpper(abbrevFile))
+    if !abbrevMissing && len(wanted) == len(sr26DataFiles) {
+        return nil
+    }
+    if len(wanted) > 0 {
+        var missing []string
+        for _, name := range wanted {
+            missing = append(missing, name)
+        }
+        return fmt.Errorf("%s does not contain %s", zipPath, strings.Join(missing, ", "))
+    }
+    return nil
+}
+
+func extractZipEntry(entry *zip.File, path string) error {
+    reader, err := entry.Open()
+    if err != nil {
+        return err
+    }
+    defer reader.Close()
+
+    file, err := os.Create(path)
+    if err != nil {
+        return err
+    }
+    if _, err := io.Copy(file, reader); err != nil {
+        file.Close()
+        return err
+    }
+    return file.Close()
+}
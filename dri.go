@@ -0,0 +1,195 @@
+package main
+
+import (
+    "fmt"
+    "math"
+    "strings"
+)
+
+// The default targets are for a 145 lb man. --sex, --age, --weight-kg and
+// --height-cm work them out for someone else from the Dietary Reference
+// Intakes instead:
+//
+//   - energy is Mifflin-St Jeor's resting energy times an --activity factor
+//   - protein is --protein-g-per-kg, the RDA of 0.8 by default, fat 20-35%
+//     of energy and fiber 14 g per 1000 kcal
+//   - vitamins and minerals are the IOM's RDA or AI for the sex and age,
+//     up to the UL, with potassium and sodium from the 2019 update
+//   - essential amino acids scale with weight, from the WHO's mg per kg
+//
+// Targets the DRIs don't cover (lutein, EPA and DHA) keep their defaults.
+// --target still overrides any of them.
+
+const targetSourceDRI = "DRI for your body stats"
+
+// Mifflin-St Jeor resting energy is multiplied by these
+var activityFactors = map[string]float64{
+    "sedentary": 1.2,
+    "light": 1.375,
+    "moderate": 1.55,
+    "active": 1.725,
+    "very-active": 1.9,
+}
+
+// The DRI age groups, and the youngest age covered
+var driAgeGroups = []int{14, 19, 31, 51, 71}
+
+// driRow is one nutrient's RDA or AI by sex and age group, and its UL by
+// age group, 0 for none, all in the units NUTR_DEF.txt uses.
+type driRow struct {
+    nutrient string
+    male [5]float64
+    female [5]float64
+    upper [5]float64
+}
+
+var driTable = []driRow{
+    {"Calcium, Ca", [5]float64{1300, 1000, 1000, 1000, 1200}, [5]float64{1300, 1000, 1000, 1200, 1200}, [5]float64{3000, 2500, 2500, 2000, 2000}},
+    {"Iron, Fe", [5]float64{11, 8, 8, 8, 8}, [5]float64{15, 18, 18, 8, 8}, [5]float64{45, 45, 45, 45, 45}},
+    // The magnesium UL is for supplements only
+    {"Magnesium, Mg", [5]float64{410, 400, 420, 420, 420}, [5]float64{360, 310, 320, 320, 320}, [5]float64{}},
+    {"Phosphorus, P", [5]float64{1250, 700, 700, 700, 700}, [5]float64{1250, 700, 700, 700, 700}, [5]float64{4000, 4000, 4000, 4000, 3000}},
+    {"Potassium, K", [5]float64{3000, 3400, 3400, 3400, 3400}, [5]float64{2300, 2600, 2600, 2600, 2600}, [5]float64{}},
+    // The max is the level to reduce chronic disease risk above
+    {"Sodium, Na", [5]float64{1500, 1500, 1500, 1500, 1500}, [5]float64{1500, 1500, 1500, 1500, 1500}, [5]float64{2300, 2300, 2300, 2300, 2300}},
+    {"Zinc, Zn", [5]float64{11, 11, 11, 11, 11}, [5]float64{9, 8, 8, 8, 8}, [5]float64{34, 40, 40, 40, 40}},
+    {"Copper, Cu", [5]float64{0.89, 0.9, 0.9, 0.9, 0.9}, [5]float64{0.89, 0.9, 0.9, 0.9, 0.9}, [5]float64{8, 10, 10, 10, 10}},
+    {"Manganese, Mn", [5]float64{2.2, 2.3, 2.3, 2.3, 2.3}, [5]float64{1.6, 1.8, 1.8, 1.8, 1.8}, [5]float64{9, 11, 11, 11, 11}},
+    {"Selenium, Se", [5]float64{55, 55, 55, 55, 55}, [5]float64{55, 55, 55, 55, 55}, [5]float64{400, 400, 400, 400, 400}},
+    {"Vitamin A, RAE", [5]float64{900, 900, 900, 900, 900}, [5]float64{700, 700, 700, 700, 700}, [5]float64{2800, 3000, 3000, 3000, 3000}},
+    {"Vitamin E (alpha-tocopherol)", [5]float64{15, 15, 15, 15, 15}, [5]float64{15, 15, 15, 15, 15}, [5]float64{800, 1000, 1000, 1000, 1000}},
+    {"Vitamin C, total ascorbic acid", [5]float64{75, 90, 90, 90, 90}, [5]float64{65, 75, 75, 75, 75}, [5]float64{1800, 2000, 2000, 2000, 2000}},
+    {"Thiamin", [5]float64{1.2, 1.2, 1.2, 1.2, 1.2}, [5]float64{1.0, 1.1, 1.1, 1.1, 1.1}, [5]float64{}},
+    {"Riboflavin", [5]float64{1.3, 1.3, 1.3, 1.3, 1.3}, [5]float64{1.0, 1.1, 1.1, 1.1, 1.1}, [5]float64{}},
+    {"Niacin", [5]float64{16, 16, 16, 16, 16}, [5]float64{14, 14, 14, 14, 14}, [5]float64{30, 35, 35, 35, 35}},
+    {"Pantothenic acid", [5]float64{5, 5, 5, 5, 5}, [5]float64{5, 5, 5, 5, 5}, [5]float64{}},
+    {"Vitamin B-6", [5]float64{1.3, 1.3, 1.3, 1.7, 1.7}, [5]float64{1.2, 1.3, 1.3, 1.5, 1.5}, [5]float64{80, 100, 100, 100, 100}},
+    {"Vitamin B-12", [5]float64{2.4, 2.4, 2.4, 2.4, 2.4}, [5]float64{2.4, 2.4, 2.4, 2.4, 2.4}, [5]float64{}},
+    {"Choline, total", [5]float64{550, 550, 550, 550, 550}, [5]float64{400, 425, 425, 425, 425}, [5]float64{3000, 3500, 3500, 3500, 3500}},
+    {"Vitamin K (phylloquinone)", [5]float64{75, 120, 120, 120, 120}, [5]float64{75, 90, 90, 90, 90}, [5]float64{}},
+    {"Folate", [5]float64{400, 400, 400, 400, 400}, [5]float64{400, 400, 400, 400, 400}, [5]float64{800, 1000, 1000, 1000, 1000}},
+    {"18:3 n-3 c,c,c (ALA)", [5]float64{1.6, 1.6, 1.6, 1.6, 1.6}, [5]float64{1.1, 1.1, 1.1, 1.1, 1.1}, [5]float64{}},
+}
+
+// Essential amino acids, in mg per kg of body weight a day, from the WHO
+var aminoAcidsMgPerKg = []struct {
+    nutrient string
+    mgPerKg float64
+}{
+    {"Lysine", 30},
+    {"Leucine", 39},
+    {"Methionine", 10},
+    {"Cystine", 4},
+    {"Valine", 26},
+    {"Histidine", 10},
+    {"Tryptophan", 4},
+    {"Threonine", 15},
+    {"Isoleucine", 20},
+    {"Phenylalanine + Tyrosine", 25},
+}
+
+// Total water from food and drink, L a day, by sex for 14-18 and adults.
+// Recipes aim for the same share of it as the defaults do.
+var waterAI = map[string][2]float64{"male": {3.3, 3.7}, "female": {2.3, 2.7}}
+
+const defaultWaterShare = 946.0 / 3700
+
+// bodyStats are what the DRI targets are worked out from.
+type bodyStats struct {
+    sex string
+    age int
+    weightKg float64
+    heightCm float64
+    activity string
+    proteinGPerKg float64
+}
+
+// bodyStatsFrom reads the body stat flags, nil when none are given.
+func bodyStatsFrom(opts *Options) (*bodyStats, error) {
+    if opts.sex == "" {
+        // --weight-kg alone is for per kg targets
+        if opts.age != 0 || opts.heightCm != 0 {
+            return nil, fmt.Errorf("--age and --height-cm need --sex too")
+        }
+        return nil, nil
+    }
+    stats := &bodyStats{strings.ToLower(opts.sex), opts.age, opts.weightKg, opts.heightCm, opts.activity, opts.proteinGPerKg}
+    if _, known := waterAI[stats.sex]; !known {
+        return nil, fmt.Errorf("unknown --sex %q, want male or female", opts.sex)
+    }
+    if stats.age < driAgeGroups[0] || stats.weightKg <= 0 || stats.heightCm <= 0 {
+        return nil, fmt.Errorf("--sex needs --age (%d or over), --weight-kg and --height-cm", driAgeGroups[0])
+    }
+    if _, known := activityFactors[stats.activity]; !known {
+        return nil, fmt.Errorf("unknown --activity %q, want sedentary, light, moderate, active or very-active", stats.activity)
+    }
+    if stats.proteinGPerKg <= 0 {
+        return nil, fmt.Errorf("--protein-g-per-kg should be above 0")
+    }
+    return stats, nil
+}
+
+// energyKcal is Mifflin-St Jeor's resting energy times the activity
+// factor.
+func (stats *bodyStats) energyKcal() float64 {
+    resting := 10 * stats.weightKg + 6.25 * stats.heightCm - 5 * float64(stats.age)
+    if stats.sex == "male" {
+        resting += 5
+    } else {
+        resting -= 161
+    }
+    return resting * activityFactors[stats.activity]
+}
+
+func (stats *bodyStats) ageGroup() int {
+    group := 0
+    for i, youngest := range driAgeGroups {
+        if stats.age >= youngest {
+            group = i
+        }
+    }
+    return group
+}
+
+// driTargets is targets with everything the DRIs cover worked out for
+// stats.
+func driTargets(targets []Target, stats *bodyStats) []Target {
+    kcal := stats.energyKcal()
+    group := stats.ageGroup()
+
+    targets = setTarget(targets, Target{nutrient: energyNutrient, min: math.Round(kcal), max: math.Round(kcal * 1.3)})
+    targets = setTarget(targets, Target{nutrient: "Protein", min: stats.proteinGPerKg * stats.weightKg})
+    // 20-35% of energy, at 9 kcal per g
+    targets = setTarget(targets, Target{nutrient: "Total lipid (fat)", min: math.Round(kcal * 0.20 / 9), max: math.Round(kcal * 0.35 / 9)})
+    targets = setTarget(targets, Target{nutrient: "Fiber, total dietary", min: math.Round(kcal * 14 / 1000)})
+
+    for _, row := range driTable {
+        min := row.male[group]
+        if stats.sex == "female" {
+            min = row.female[group]
+        }
+        targets = setTarget(targets, Target{nutrient: row.nutrient, min: min, max: row.upper[group]})
+    }
+    for _, aminoAcid := range aminoAcidsMgPerKg {
+        targets = setTarget(targets, Target{nutrient: aminoAcid.nutrient, min: aminoAcid.mgPerKg * stats.weightKg / 1000})
+    }
+
+    adult := 1
+    if group == 0 {
+        adult = 0
+    }
+    water := waterAI[stats.sex][adult] * 1000 * defaultWaterShare
+    return setTarget(targets, Target{nutrient: "Water", min: math.Round(water)})
+}
+
+// driNutrients are the nutrients driTargets sets.
+func driNutrients() []string {
+    nutrients := []string{energyNutrient, "Protein", "Total lipid (fat)", "Fiber, total dietary", "Water"}
+    for _, row := range driTable {
+        nutrients = append(nutrients, row.nutrient)
+    }
+    for _, aminoAcid := range aminoAcidsMgPerKg {
+        nutrients = append(nutrients, aminoAcid.nutrient)
+    }
+    return nutrients
+}
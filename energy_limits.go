@@ -0,0 +1,130 @@
+package main
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+)
+
+// --energy-limit caps the share of the recipe's energy from a nutrient,
+// the way dietary guidelines limit sugar and saturated fat:
+//
+//     --energy-limit "Fatty acids, total saturated=10"
+//
+// keeps saturated fat under 10% of kcal. Each percentage point over costs
+// --energy-limit-weight, so with the usual 10, 2 points over is like a
+// target a fifth short. --dga adds the AHA and Dietary Guidelines limits:
+// saturated fat and added sugar under 10% of kcal and trans fat as near 0
+// as WHO's 1%. SR26 only has added sugar for a few foods, so without it
+// total sugar, fruit and milk's included, stands in.
+//
+// The kcal are worked out from fat, protein and carbs with Atwater's
+// factors, by recipeKcal as --macros does, rather than taken from the foods' energy
+// values, which are often imputed. The share is of the recipe's total, so
+// it isn't linear in the grams, and the linear program (lp.go) leaves it
+// out.
+
+// kcal per g of the nutrients whose energy share can be limited
+var energyPerGram = map[string]float64{
+    "Total lipid (fat)": 9,
+    "Fatty acids, total saturated": 9,
+    "Fatty acids, total trans": 9,
+    "Fatty acids, total monounsaturated": 9,
+    "Fatty acids, total polyunsaturated": 9,
+    "Protein": 4,
+    "Carbohydrate, by difference": 4,
+    "Sugars, total": 4,
+    "Sugars, added": 4,
+    "Net carbs": 4,
+}
+
+type energyLimit struct {
+    nutrient string
+    maxPercent float64
+}
+
+// dgaLimits are --dga's limits, with the sugar picked by parseEnergyLimits.
+var dgaLimits = []energyLimit{
+    {"Fatty acids, total saturated", 10},
+    {"Sugars, added", 10},
+    {"Fatty acids, total trans", 1},
+}
+
+// parseEnergyLimits reads --dga and the --energy-limit flags, which win for
+// the same nutrient.
+func parseEnergyLimits(opts *Options, nutrientNameToId map[string]int) ([]energyLimit, error) {
+    if opts.energyLimitWeight < 0 {
+        return nil, fmt.Errorf("--energy-limit-weight can't be negative")
+    }
+    var limits []energyLimit
+    set := func(limit energyLimit) {
+        for i := range limits {
+            if limits[i].nutrient == limit.nutrient {
+                limits[i] = limit
+                return
+            }
+        }
+        limits = append(limits, limit)
+    }
+    if opts.dga {
+        for _, limit := range dgaLimits {
+            if _, exists := nutrientNameToId[limit.nutrient]; !exists && limit.nutrient == "Sugars, added" {
+                limit.nutrient = "Sugars, total"
+            }
+            set(limit)
+        }
+    }
+    for _, spec := range opts.energyLimits {
+        equals := strings.LastIndex(spec, "=")
+        if equals < 0 {
+            return nil, fmt.Errorf("--energy-limit %q should look like \"Nutrient=percent\"", spec)
+        }
+        nutrient := strings.TrimSpace(spec[:equals])
+        if _, known := energyPerGram[nutrient]; !known {
+            return nil, fmt.Errorf("--energy-limit %q: can't work out the energy of %q, only of fats, protein, carbs and sugars", spec, nutrient)
+        }
+        if !isTargetNutrient(nutrientNameToId, nutrient) {
+            return nil, fmt.Errorf("--energy-limit %q: no nutrient %q in the data", spec, nutrient)
+        }
+        percent, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(spec[equals + 1:]), "%"), 64)
+        if err != nil || percent < 0 || percent > 100 {
+            return nil, fmt.Errorf("--energy-limit %q: want a percentage of kcal", spec)
+        }
+        set(energyLimit{nutrient, percent})
+    }
+    return limits, nil
+}
+
+// percentOfEnergy is the share of recipe's kcal from limit's nutrient, 0
+// for a recipe without energy.
+func (limit energyLimit) percentOfEnergy(problem *Problem, recipe *Recipe) float64 {
+    kcal := recipeKcal(problem, recipe)
+    if kcal <= 0 {
+        return 0
+    }
+    return recipe.amountOf(problem.nutrientNameToId, limit.nutrient) * energyPerGram[limit.nutrient] / kcal * 100
+}
+
+// energyLimitPenalty is the --energy-limit part of the score.
+func (recipe *Recipe) energyLimitPenalty(problem *Problem, verbose bool) float64 {
+    penalty := float64(0)
+    for _, limit := range problem.energyLimits {
+        if over := limit.percentOfEnergy(problem, recipe) - limit.maxPercent; over > 0 {
+            if verbose { fmt.Printf("Penalty for %s %.1f points over %g%% of kcal: %f\n", limit.nutrient, over, limit.maxPercent, over * problem.opts.energyLimitWeight) }
+            penalty += over * problem.opts.energyLimitWeight
+        }
+    }
+    return penalty
+}
+
+func printEnergyLimits(problem *Problem, recipe *Recipe) {
+    fmt.Println("ENERGY LIMITS")
+    for _, limit := range problem.energyLimits {
+        percent := limit.percentOfEnergy(problem, recipe)
+        status := "ok"
+        if percent >= limit.maxPercent + 0.05 {
+            status = fmt.Sprintf("%+.1f points", percent - limit.maxPercent)
+        }
+        fmt.Printf("  %-32s %5.1f%% of kcal, limit %g%%  %s\n", limit.nutrient, percent, limit.maxPercent, status)
+    }
+}
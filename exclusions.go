@@ -0,0 +1,211 @@
+package main
+
+import (
+    _ "embed"
+    "fmt"
+    "os"
+    "regexp"
+    "strings"
+)
+
+// The foods kept out of recipes are listed in exclusions.yaml, which is
+// built in as the default, so anyone can keep their own list with
+// --exclusions without touching the code.
+
+const defaultExclusionsPath = "exclusions.yaml"
+
+//go:embed exclusions.yaml
+var defaultExclusions string
+
+// The kinds of exclusion, as they're written in the file
+var exclusionKinds = []string{"food_group", "contains", "contains_any_case", "regex", "manufacturer"}
+
+type exclusion struct {
+    reason string
+    preset string // the preset the rule comes from, "" for the list's own
+    kind string
+    values []string // lower case for contains_any_case
+    patterns []*regexp.Regexp // for regex
+}
+
+type exclusionList struct {
+    source string // where the list came from, for messages
+    exclusions []exclusion
+    presets []string // added with addPreset
+}
+
+// matches reports whether the exclusion applies to food.
+func (rule *exclusion) matches(food *Food) bool {
+    return rule.matchedValue(food) != ""
+}
+
+// matchedValue is the value in the rule that food matches, "" if none does.
+func (rule *exclusion) matchedValue(food *Food) string {
+    switch rule.kind {
+    case "food_group":
+        for _, group := range rule.values {
+            if food.foodGroup == group {
+                return group
+            }
+        }
+    case "contains":
+        for _, text := range rule.values {
+            if strings.Contains(food.description, text) {
+                return text
+            }
+        }
+    case "contains_any_case":
+        description := strings.ToLower(food.description)
+        for _, text := range rule.values {
+            if strings.Contains(description, text) {
+                return text
+            }
+        }
+    case "regex":
+        for i, pattern := range rule.patterns {
+            if pattern.MatchString(food.description) {
+                return rule.values[i]
+            }
+        }
+    case "manufacturer":
+        for _, manufacturer := range rule.values {
+            if food.manufacturer == manufacturer {
+                return manufacturer
+            }
+        }
+    }
+    return ""
+}
+
+// match is the first exclusion that applies to food, nil if none does.
+func (list *exclusionList) match(food *Food) *exclusion {
+    for i := range list.exclusions {
+        if list.exclusions[i].matches(food) {
+            return &list.exclusions[i]
+        }
+    }
+    return nil
+}
+
+// parseExclusions reads an exclusions file, source saying where it came
+// from in errors. The presets it lists are added after its own rules.
+func parseExclusions(data string, source string) (*exclusionList, error) {
+    document, err := parseYAML(data)
+    if err != nil {
+        return nil, fmt.Errorf("%s: %v", source, err)
+    }
+    root, _ := document.(map[string]interface{})
+    entries, ok := root["exclusions"].([]interface{})
+    presets, hasPresets := root["presets"].([]interface{})
+    if !ok && !(hasPresets && root["exclusions"] == nil) {
+        return nil, fmt.Errorf("%s: expected a list under \"exclusions:\"", source)
+    }
+
+    list := &exclusionList{source: source}
+    list.exclusions, err = parseExclusionRules(entries, source)
+    if err != nil {
+        return nil, err
+    }
+    for _, preset := range presets {
+        name, _ := preset.(string)
+        lifeStage, err := isLifeStagePreset(name)
+        if err != nil {
+            return nil, err
+        }
+        if lifeStage {
+            return nil, fmt.Errorf("%s: %s changes targets, give it with --preset", source, name)
+        }
+        if err := list.addPreset(name); err != nil {
+            return nil, fmt.Errorf("%s: %v", source, err)
+        }
+    }
+    return list, nil
+}
+
+// parseExclusionRules reads a list of exclusions.
+func parseExclusionRules(entries []interface{}, source string) ([]exclusion, error) {
+    var rules []exclusion
+    for i, entry := range entries {
+        fields, ok := entry.(map[string]interface{})
+        if !ok {
+            return nil, fmt.Errorf("%s: exclusion %d is not a map", source, i + 1)
+        }
+        rule := exclusion{}
+        for key, value := range fields {
+            if key == "reason" {
+                rule.reason, _ = value.(string)
+                continue
+            }
+            known := false
+            for _, kind := range exclusionKinds {
+                known = known || key == kind
+            }
+            if !known {
+                return nil, fmt.Errorf("%s: exclusion %d: unknown field %q, want reason or one of %s", source, i + 1, key, strings.Join(exclusionKinds, ", "))
+            }
+            if rule.kind != "" {
+                return nil, fmt.Errorf("%s: exclusion %d has both %s and %s, give each its own", source, i + 1, rule.kind, key)
+            }
+            rule.kind = key
+
+            items, isList := value.([]interface{})
+            if !isList {
+                items = []interface{}{value}
+            }
+            for _, item := range items {
+                text, _ := item.(string)
+                if text == "" {
+                    return nil, fmt.Errorf("%s: exclusion %d: empty %s", source, i + 1, key)
+                }
+                switch key {
+                case "contains_any_case":
+                    text = strings.ToLower(text)
+                case "regex":
+                    pattern, err := regexp.Compile(text)
+                    if err != nil {
+                        return nil, fmt.Errorf("%s: exclusion %d: %v", source, i + 1, err)
+                    }
+                    rule.patterns = append(rule.patterns, pattern)
+                }
+                rule.values = append(rule.values, text)
+            }
+        }
+        if rule.kind == "" {
+            return nil, fmt.Errorf("%s: exclusion %d says nothing to exclude", source, i + 1)
+        }
+        rules = append(rules, rule)
+    }
+    return rules, nil
+}
+
+// loadExclusions reads the --exclusions file, or the built in list if
+// it's the default file and isn't there.
+func loadExclusions(path string) (*exclusionList, error) {
+    data, err := os.ReadFile(path)
+    if os.IsNotExist(err) && path == defaultExclusionsPath {
+        return parseExclusions(defaultExclusions, "the built in exclusions")
+    } else if err != nil {
+        return nil, err
+    }
+    return parseExclusions(string(data), path)
+}
+
+// exclusions is the exclusion list the options ask for, read the first time
+// it's needed. A bad file exits.
+func (opts *Options) exclusions() *exclusionList {
+    if opts.exclusionList == nil {
+        list, err := loadExclusions(opts.exclusionsPath)
+        if err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            os.Exit(2)
+        }
+        for _, preset := range opts.presets {
+            if err := list.addPreset(preset); err != nil {
+                fmt.Fprintf(os.Stderr, "--preset: %v\n", err)
+                os.Exit(2)
+            }
+        }
+        opts.exclusionList = list
+    }
+    return opts.exclusionList
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+    "bufio"
+    "flag"
+    "fmt"
+    "math"
+    "math/rand"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+)
+
+// The fixture generator writes a small synthetic dataset in the SR26 file
+// format, so tests, examples and the demo can run without the full USDA
+// download. Values are loosely based on real SR26 entries and then jittered,
+// so recipes come out looking plausible without being real data.
+
+type fixtureNutrient struct {
+    id int
+    units string
+    tagname string
+    description string
+}
+
+// Ordered so that taking the first N still leaves the nutrients the scorer
+// leans on the most.
+var fixtureNutrients = []fixtureNutrient{
+    {203, "g", "PROCNT", "Protein"},
+    {204, "g", "FAT", "Total lipid (fat)"},
+    {205, "g", "CHOCDF", "Carbohydrate, by difference"},
+    {208, "kcal", "ENERC_KCAL", "Energy"},
+    {255, "g", "WATER", "Water"},
+    {291, "g", "FIBTG", "Fiber, total dietary"},
+    {301, "mg", "CA", "Calcium, Ca"},
+    {303, "mg", "FE", "Iron, Fe"},
+    {304, "mg", "MG", "Magnesium, Mg"},
+    {305, "mg", "P", "Phosphorus, P"},
+    {306, "mg", "K", "Potassium, K"},
+    {307, "mg", "NA", "Sodium, Na"},
+    {309, "mg", "ZN", "Zinc, Zn"},
+    {312, "mg", "CU", "Copper, Cu"},
+    {315, "mg", "MN", "Manganese, Mn"},
+    {317, "µg", "SE", "Selenium, Se"},
+    {320, "µg", "VITA_RAE", "Vitamin A, RAE"},
+    {323, "mg", "TOCPHA", "Vitamin E (alpha-tocopherol)"},
+    {338, "µg", "LUT+ZEA", "Lutein + zeaxanthin"},
+    {401, "mg", "VITC", "Vitamin C, total ascorbic acid"},
+    {404, "mg", "THIA", "Thiamin"},
+    {405, "mg", "RIBF", "Riboflavin"},
+    {406, "mg", "NIA", "Niacin"},
+    {410, "mg", "PANTAC", "Pantothenic acid"},
+    {415, "mg", "VITB6A", "Vitamin B-6"},
+    {418, "µg", "VITB12", "Vitamin B-12"},
+    {421, "mg", "CHOLN", "Choline, total"},
+    {430, "µg", "VITK1", "Vitamin K (phylloquinone)"},
+    {432, "µg", "FOLFD", "Folate, food"},
+    {431, "µg", "FOLAC", "Folic acid"},
+    {501, "g", "TRP_G", "Tryptophan"},
+    {502, "g", "THR_G", "Threonine"},
+    {503, "g", "ILE_G", "Isoleucine"},
+    {504, "g", "LEU_G", "Leucine"},
+    {505, "g", "LYS_G", "Lysine"},
+    {506, "g", "MET_G", "Methionine"},
+    {507, "g", "CYS_G", "Cystine"},
+    {508, "g", "PHE_G", "Phenylalanine"},
+    {509, "g", "TYR_G", "Tyrosine"},
+    {510, "g", "VAL_G", "Valine"},
+    {512, "g", "HISTN_G", "Histidine"},
+    {851, "g", "F18D3CN3", "18:3 n-3 c,c,c (ALA)"},
+    {629, "g", "F20D5", "20:5 n-3 (EPA)"},
+    {621, "g", "F22D6", "22:6 n-3 (DHA)"},
+    {268, "kJ", "ENERC_KJ", "Energy"},
+    {269, "g", "SUGAR", "Sugars, total"},
+    {606, "g", "FASAT", "Fatty acids, total saturated"},
+    {605, "g", "FATRN", "Fatty acids, total trans"},
+    {262, "mg", "CAFFN", "Caffeine"},
+    {263, "mg", "THEBRN", "Theobromine"},
+    {429, "µg", "VITK1D", "Dihydrophylloquinone"},
+}
+
+var fixtureFoodGroups = map[string]string{
+    "0100": "Dairy and Egg Products",
+    "0400": "Fats and Oils",
+    "0900": "Fruits and Fruit Juices",
+    "1100": "Vegetables and Vegetable Products",
+    "1200": "Nut and Seed Products",
+    "1500": "Finfish and Shellfish Products",
+    "1600": "Legumes and Legume Products",
+    "1900": "Sweets",
+    "2000": "Cereal Grains and Pasta",
+}
+
+// Grams of each essential amino acid per gram of protein.
+var fixturePlantAminoAcids = map[int]float64{
+    501: 0.012, 502: 0.038, 503: 0.043, 504: 0.075, 505: 0.055, 506: 0.016,
+    507: 0.014, 508: 0.050, 509: 0.033, 510: 0.050, 512: 0.026,
+}
+var fixtureAnimalAminoAcids = map[int]float64{
+    501: 0.013, 502: 0.045, 503: 0.052, 504: 0.086, 505: 0.080, 506: 0.027,
+    507: 0.012, 508: 0.045, 509: 0.040, 510: 0.058, 512: 0.028,
+}
+
+type fixtureArchetype struct {
+    foodGroup string
+    variants []string // the first one is the reference, the rest are jittered
+    animal bool
+    refuse int
+    refuseDescription string
+    per100g map[int]float64 // nutrient id -> amount, macros and micros only
+}
+
+var fixtureArchetypes = []fixtureArchetype{
+    {"1100", []string{"Spinach, raw", "Spinach, frozen, chopped or leaf, unprepared", "Spinach, canned, regular pack, drained solids"}, false, 28, "Large stems and roots",
+        map[int]float64{255: 91.4, 203: 2.86, 204: 0.39, 205: 3.63, 291: 2.2, 269: 0.42, 301: 99, 303: 2.71, 304: 79, 305: 49, 306: 558, 307: 79, 309: 0.53, 312: 0.13, 315: 0.897, 317: 1, 320: 469, 323: 2.03, 338: 12198, 401: 28.1, 404: 0.078, 405: 0.189, 406: 0.724, 410: 0.065, 415: 0.195, 421: 19.3, 430: 482.9, 432: 194, 851: 0.138}},
+    {"1100", []string{"Kale, raw", "Kale, frozen, unprepared"}, false, 39, "Stems and ribs",
+        map[int]float64{255: 84, 203: 4.28, 204: 0.93, 205: 8.75, 291: 3.6, 269: 2.26, 301: 150, 303: 1.47, 304: 47, 305: 92, 306: 491, 307: 38, 309: 0.56, 312: 1.5, 315: 0.66, 317: 0.9, 320: 241, 323: 1.54, 338: 6261, 401: 120, 404: 0.11, 405: 0.13, 406: 1.18, 410: 0.09, 415: 0.27, 421: 0.8, 430: 704.8, 432: 141, 851: 0.18}},
+    {"1100", []string{"Sweet potato, raw, unprepared", "Sweet potato, canned, mashed"}, false, 28, "Parings and trimmings",
+        map[int]float64{255: 77.28, 203: 1.57, 204: 0.05, 205: 20.12, 291: 3, 269: 4.18, 301: 30, 303: 0.61, 304: 25, 305: 47, 306: 337, 307: 55, 309: 0.3, 312: 0.151, 315: 0.258, 317: 0.6, 320: 709, 323: 0.26, 401: 2.4, 404: 0.078, 405: 0.061, 406: 0.557, 410: 0.8, 415: 0.209, 421: 12.3, 430: 1.8, 432: 11}},
+    {"1100", []string{"Carrots, raw", "Carrots, frozen, unprepared", "Carrot juice, canned"}, false, 11, "Crown, tops and scrapings",
+        map[int]float64{255: 88.29, 203: 0.93, 204: 0.24, 205: 9.58, 291: 2.8, 269: 4.74, 301: 33, 303: 0.3, 304: 12, 305: 35, 306: 320, 307: 69, 309: 0.24, 312: 0.045, 315: 0.143, 317: 0.1, 320: 835, 323: 0.66, 338: 256, 401: 5.9, 404: 0.066, 405: 0.058, 406: 0.983, 410: 0.273, 415: 0.138, 421: 8.8, 430: 13.2, 432: 19, 851: 0.002}},
+    {"1600", []string{"Lentils, raw", "Lentils, pink or red, raw", "Lentils, sprouted, raw"}, false, 0, "",
+        map[int]float64{255: 10.4, 203: 24.63, 204: 1.06, 205: 63.35, 291: 10.7, 269: 2.03, 301: 35, 303: 6.51, 304: 47, 305: 281, 306: 677, 307: 6, 309: 3.27, 312: 0.754, 315: 1.393, 317: 0.1, 320: 2, 323: 0.49, 401: 4.5, 404: 0.873, 405: 0.211, 406: 2.605, 410: 2.14, 415: 0.54, 421: 96.4, 430: 5, 432: 479, 851: 0.1}},
+    {"1600", []string{"Beans, black, mature seeds, raw", "Beans, black turtle, mature seeds, raw", "Beans, black, mature seeds, canned, low sodium"}, false, 0, "",
+        map[int]float64{255: 11, 203: 21.6, 204: 1.42, 205: 62.36, 291: 15.5, 269: 2.12, 301: 123, 303: 5.02, 304: 171, 305: 352, 306: 1483, 307: 5, 309: 3.65, 312: 0.841, 315: 1.06, 317: 3.2, 323: 0.21, 404: 0.9, 405: 0.193, 406: 1.955, 410: 0.899, 415: 0.286, 421: 66.4, 430: 5.6, 432: 444, 851: 0.17}},
+    {"1600", []string{"Soymilk, original and vanilla, unfortified", "Soymilk, chocolate, unfortified"}, false, 0, "",
+        map[int]float64{255: 88.05, 203: 3.27, 204: 1.75, 205: 6.28, 291: 0.6, 269: 3.99, 301: 25, 303: 0.64, 304: 25, 305: 52, 306: 118, 307: 51, 309: 0.12, 312: 0.128, 315: 0.223, 317: 4.8, 320: 3, 323: 0.11, 404: 0.06, 405: 0.069, 406: 0.513, 410: 0.373, 415: 0.077, 421: 23.3, 430: 3, 432: 18, 851: 0.068}},
+    {"1200", []string{"Nuts, almonds", "Nuts, almonds, blanched", "Nuts, almond butter, plain, without salt added"}, false, 60, "Shells",
+        map[int]float64{255: 4.41, 203: 21.15, 204: 49.93, 205: 21.55, 291: 12.5, 269: 4.35, 301: 269, 303: 3.71, 304: 270, 305: 481, 306: 733, 307: 1, 309: 3.12, 312: 1.031, 315: 2.179, 317: 4.1, 323: 25.63, 338: 1, 404: 0.205, 405: 1.138, 406: 3.618, 410: 0.471, 415: 0.137, 421: 52.1, 432: 44, 851: 0.003}},
+    {"1200", []string{"Seeds, flaxseed", "Seeds, chia seeds, dried"}, false, 0, "",
+        map[int]float64{255: 6.96, 203: 18.29, 204: 42.16, 205: 28.88, 291: 27.3, 269: 1.55, 301: 255, 303: 5.73, 304: 392, 305: 642, 306: 813, 307: 30, 309: 4.34, 312: 1.22, 315: 2.482, 317: 25.4, 323: 0.31, 338: 651, 401: 0.6, 404: 1.644, 405: 0.161, 406: 3.08, 410: 0.985, 415: 0.473, 421: 78.7, 430: 4.3, 432: 87, 851: 22.8}},
+    {"1200", []string{"Seeds, sunflower seed kernels, dried", "Seeds, pumpkin and squash seed kernels, dried"}, false, 46, "Hulls",
+        map[int]float64{255: 4.73, 203: 20.78, 204: 51.46, 205: 20, 291: 8.6, 269: 2.62, 301: 78, 303: 5.25, 304: 325, 305: 660, 306: 645, 307: 9, 309: 5, 312: 1.8, 315: 1.95, 317: 53, 320: 3, 323: 35.17, 401: 1.4, 404: 1.48, 405: 0.355, 406: 8.335, 410: 1.13, 415: 1.345, 421: 55.1, 432: 227, 851: 0.06}},
+    {"2000", []string{"Oats", "Oat bran, raw", "Cereals, oats, regular and quick, not fortified, dry"}, false, 0, "",
+        map[int]float64{255: 8.22, 203: 16.89, 204: 6.9, 205: 66.27, 291: 10.6, 269: 0.99, 301: 54, 303: 4.72, 304: 177, 305: 523, 306: 429, 307: 2, 309: 3.97, 312: 0.626, 315: 4.916, 317: 28.9, 323: 0.42, 338: 180, 404: 0.763, 405: 0.139, 406: 0.961, 410: 1.349, 415: 0.119, 432: 56, 851: 0.11}},
+    {"2000", []string{"Quinoa, uncooked", "Buckwheat groats, roasted, dry"}, false, 0, "",
+        map[int]float64{255: 13.28, 203: 14.12, 204: 6.07, 205: 64.16, 291: 7, 301: 47, 303: 4.57, 304: 197, 305: 457, 306: 563, 307: 5, 309: 3.1, 312: 0.59, 315: 2.033, 317: 8.5, 320: 1, 323: 2.44, 338: 163, 404: 0.36, 405: 0.318, 406: 1.52, 410: 0.772, 415: 0.487, 421: 70.2, 432: 184, 851: 0.26}},
+    {"0900", []string{"Bananas, raw", "Bananas, dehydrated, or banana powder"}, false, 36, "Skin",
+        map[int]float64{255: 74.91, 203: 1.09, 204: 0.33, 205: 22.84, 291: 2.6, 269: 12.23, 301: 5, 303: 0.26, 304: 27, 305: 22, 306: 358, 307: 1, 309: 0.15, 312: 0.078, 315: 0.27, 317: 1, 320: 3, 323: 0.1, 338: 22, 401: 8.7, 404: 0.031, 405: 0.073, 406: 0.665, 410: 0.334, 415: 0.367, 421: 9.8, 430: 0.5, 432: 20, 851: 0.027}},
+    {"0900", []string{"Oranges, raw, all commercial varieties", "Orange juice, raw"}, false, 27, "Peel and seeds",
+        map[int]float64{255: 86.75, 203: 0.94, 204: 0.12, 205: 11.75, 291: 2.4, 269: 9.35, 301: 40, 303: 0.1, 304: 10, 305: 14, 306: 181, 309: 0.07, 312: 0.045, 315: 0.025, 317: 0.5, 320: 11, 323: 0.18, 338: 129, 401: 53.2, 404: 0.087, 405: 0.04, 406: 0.282, 410: 0.25, 415: 0.06, 421: 8.4, 432: 30, 851: 0.01}},
+    {"0900", []string{"Blueberries, raw", "Blueberries, frozen, unsweetened"}, false, 5, "Stems and spoiled berries",
+        map[int]float64{255: 84.21, 203: 0.74, 204: 0.33, 205: 14.49, 291: 2.4, 269: 9.96, 301: 6, 303: 0.28, 304: 6, 305: 12, 306: 77, 307: 1, 309: 0.16, 312: 0.057, 315: 0.336, 317: 0.1, 320: 3, 323: 0.57, 338: 80, 401: 9.7, 404: 0.037, 405: 0.041, 406: 0.418, 410: 0.124, 415: 0.052, 421: 6, 430: 19.3, 432: 6, 851: 0.058}},
+    {"0100", []string{"Milk, reduced fat, fluid, 2% milkfat, with added vitamin A and vitamin D", "Milk, whole, 3.25% milkfat, without added vitamin A and vitamin D"}, true, 0, "",
+        map[int]float64{255: 89.33, 203: 3.3, 204: 1.98, 205: 4.8, 269: 5.06, 301: 120, 303: 0.02, 304: 11, 305: 92, 306: 140, 307: 47, 309: 0.48, 312: 0.01, 315: 0.003, 317: 2.5, 320: 55, 323: 0.03, 401: 0.2, 404: 0.039, 405: 0.185, 406: 0.092, 410: 0.356, 415: 0.038, 418: 0.53, 421: 16.4, 430: 0.2, 432: 5, 851: 0.01, 606: 1.257}},
+    {"0100", []string{"Yogurt, Greek, plain, nonfat", "Yogurt, plain, whole milk"}, true, 0, "",
+        map[int]float64{255: 85.1, 203: 10.19, 204: 0.39, 205: 3.6, 269: 3.24, 301: 110, 303: 0.07, 304: 11, 305: 135, 306: 141, 307: 36, 309: 0.52, 312: 0.017, 315: 0.009, 317: 9.7, 320: 1, 323: 0.01, 404: 0.023, 405: 0.278, 406: 0.208, 410: 0.331, 415: 0.063, 418: 0.75, 421: 15.1, 432: 7, 606: 0.117}},
+    {"0100", []string{"Egg, whole, raw, fresh", "Egg, white, raw, fresh"}, true, 12, "Shell",
+        map[int]float64{255: 76.15, 203: 12.56, 204: 9.51, 205: 0.72, 269: 0.37, 301: 56, 303: 1.75, 304: 12, 305: 198, 306: 138, 307: 142, 309: 1.29, 312: 0.072, 315: 0.028, 317: 30.7, 320: 160, 323: 1.05, 338: 503, 404: 0.04, 405: 0.457, 406: 0.075, 410: 1.533, 415: 0.17, 418: 0.89, 421: 293.8, 430: 0.3, 432: 47, 851: 0.035, 621: 0.058, 606: 3.126}},
+    {"1500", []string{"Sardines, atlantic, canned in oil, drained solids with bone", "Sardines, pacific, canned in tomato sauce, drained solids with bone"}, true, 0, "",
+        map[int]float64{255: 59.61, 203: 24.62, 204: 11.45, 301: 382, 303: 2.92, 304: 39, 305: 490, 306: 397, 307: 307, 309: 1.31, 312: 0.186, 315: 0.108, 317: 52.7, 320: 32, 323: 2.04, 404: 0.08, 405: 0.227, 406: 5.245, 410: 0.642, 415: 0.167, 418: 8.94, 421: 75, 430: 2.6, 432: 10, 851: 0.498, 629: 0.473, 621: 0.509, 606: 1.528}},
+    {"0400", []string{"Oil, canola", "Oil, olive, salad or cooking"}, false, 0, "",
+        map[int]float64{204: 100, 323: 17.46, 430: 71.3, 851: 9.137, 606: 7.365, 605: 0.395}},
+    {"1900", []string{"Cocoa, dry powder, unsweetened"}, false, 0, "",
+        map[int]float64{255: 3, 203: 19.6, 204: 13.7, 205: 57.9, 291: 37, 269: 1.75, 301: 128, 303: 13.86, 304: 499, 305: 734, 306: 1524, 307: 21, 309: 6.81, 312: 3.788, 315: 3.837, 317: 14.3, 323: 0.1, 338: 38, 404: 0.078, 405: 0.241, 406: 2.185, 410: 0.254, 415: 0.118, 421: 12, 430: 2.5, 432: 32, 606: 8.07, 262: 230, 263: 2057}},
+}
+
+type fixtureMeasure struct {
+    amount float64
+    description string
+    grams float64
+}
+
+// Household measures for WEIGHT.txt, keyed by each archetype's reference
+// variant. Every variant of the archetype gets the same ones.
+var fixtureMeasures = map[string][]fixtureMeasure{
+    "Spinach, raw": {{1, "cup", 30}, {1, "bunch", 340}, {1, "leaf", 10}},
+    "Kale, raw": {{1, "cup, chopped", 67}, {1, "oz", 28.35}},
+    "Sweet potato, raw, unprepared": {{1, "cup, cubes", 133}, {1, "medium", 130}},
+    "Carrots, raw": {{1, "medium", 61}, {1, "cup, chopped", 128}, {1, "tbsp", 9}},
+    "Lentils, raw": {{1, "cup", 192}, {1, "tbsp", 12}},
+    "Beans, black, mature seeds, raw": {{1, "cup", 194}, {1, "tbsp", 12}},
+    "Soymilk, original and vanilla, unfortified": {{1, "cup", 243}, {1, "fl oz", 30.4}},
+    "Nuts, almonds": {{1, "oz (23 whole kernels)", 28.35}, {1, "cup, whole", 143}, {1, "almond", 1.2}},
+    "Seeds, flaxseed": {{1, "tbsp, whole", 10.3}, {1, "cup, whole", 168}},
+    "Seeds, sunflower seed kernels, dried": {{1, "oz", 28.35}, {1, "cup", 140}, {1, "tbsp", 8.8}},
+    "Oats": {{1, "cup", 156}, {1, "tbsp", 5}},
+    "Quinoa, uncooked": {{1, "cup", 170}, {1, "tbsp", 10.6}},
+    "Bananas, raw": {{1, "medium (7\" to 7-7/8\" long)", 118}, {1, "cup, sliced", 150}},
+    "Oranges, raw, all commercial varieties": {{1, "large (3-1/16\" dia)", 184}, {1, "cup, sections", 180}},
+    "Blueberries, raw": {{1, "cup", 148}, {50, "berries", 68}},
+    "Milk, reduced fat, fluid, 2% milkfat, with added vitamin A and vitamin D": {{1, "cup", 244}, {1, "fl oz", 30.5}},
+    "Yogurt, Greek, plain, nonfat": {{1, "container (6 oz)", 170}, {1, "cup", 245}},
+    "Egg, whole, raw, fresh": {{1, "large", 50}, {1, "medium", 44}, {1, "cup (4.86 large eggs)", 243}},
+    "Sardines, atlantic, canned in oil, drained solids with bone": {{1, "sardine", 12}, {1, "can (3.75 oz)", 92}},
+    "Oil, canola": {{1, "tbsp", 14}, {1, "tsp", 4.7}, {1, "cup", 218}},
+    "Cocoa, dry powder, unsweetened": {{1, "tbsp", 5.4}, {1, "cup", 86}},
+}
+
+func runFixture(args []string) {
+    fs := flag.NewFlagSet("supershake fixture", flag.ExitOnError)
+    outDir := fs.String("out", filepath.Join("testdata", "fixture"), "directory to write the dataset to")
+    numFoods := fs.Int("foods", 50, "number of foods to generate")
+    numNutrients := fs.Int("nutrients", len(fixtureNutrients), "number of nutrients to generate")
+    seed := fs.Int64("seed", 1, "random seed, the same seed always produces the same dataset")
+    fs.Parse(args)
+
+    if *numNutrients > len(fixtureNutrients) {
+        *numNutrients = len(fixtureNutrients)
+    }
+
+    if err := os.MkdirAll(*outDir, 0755); err != nil {
+        panic(err)
+    }
+    writeFixture(*outDir, *numFoods, fixtureNutrients[:*numNutrients], rand.New(rand.NewSource(*seed)))
+    fmt.Printf("Wrote %d foods and %d nutrients to %s\n", *numFoods, *numNutrients, *outDir)
+}
+
+func usdaText(s string) string {
+    return "~" + s + "~"
+}
+
+func writeUSDAFile(dir string, filename string, lines []string) {
+    f, err := os.Create(filepath.Join(dir, filename))
+    if err != nil { panic(err) }
+    w := bufio.NewWriter(f)
+    for _, line := range lines {
+        // SR26 files use DOS line endings
+        w.WriteString(line + "\r\n")
+    }
+    if err := w.Flush(); err != nil { panic(err) }
+    if err := f.Close(); err != nil { panic(err) }
+}
+
+func writeFixture(dir string, numFoods int, nutrients []fixtureNutrient, rng *rand.Rand) {
+    included := make(map[int]bool, len(nutrients))
+    var nutrientLines []string
+    for i, n := range nutrients {
+        included[n.id] = true
+        nutrientLines = append(nutrientLines, strings.Join([]string{
+            usdaText(fmt.Sprint(n.id)), usdaText(n.units), usdaText(n.tagname),
+            usdaText(n.description), usdaText("2"), usdaText(fmt.Sprint((i + 1) * 100)),
+        }, "^"))
+    }
+
+    // One made up study per food group backs its analysed values
+    var groupLines, sourceLines []string
+    for _, code := range []string{"0100", "0400", "0900", "1100", "1200", "1500", "1600", "1900", "2000"} {
+        groupLines = append(groupLines, usdaText(code) + "^" + usdaText(fixtureFoodGroups[code]))
+        sourceLines = append(sourceLines, strings.Join([]string{
+            usdaText("F" + code), usdaText("Fixture, A."), usdaText("Nutrient composition of " + strings.ToLower(fixtureFoodGroups[code])),
+            usdaText("2009"), usdaText("Synthetic Food Data"), usdaText(""), usdaText(""), usdaText(""), usdaText(""),
+        }, "^"))
+    }
+
+    var langualDescriptionLines []string
+    for _, factor := range fixtureLangualFactors {
+        langualDescriptionLines = append(langualDescriptionLines, usdaText(factor.code) + "^" + usdaText(factor.description))
+    }
+
+    var foodLines, dataLines, weightLines, sourceLinkLines, langualLines []string
+    nextInGroup := make(map[string]int)
+    for i := 0; i < numFoods; i++ {
+        // Walk the archetypes round robin, one variant per pass
+        archetype := fixtureArchetypes[i % len(fixtureArchetypes)]
+        pass := i / len(fixtureArchetypes)
+        description := archetype.variants[pass % len(archetype.variants)]
+        if pass >= len(archetype.variants) {
+            description = fmt.Sprintf("%s, lot %d", description, pass / len(archetype.variants) + 1)
+        }
+
+        nextInGroup[archetype.foodGroup]++
+        ndb := fmt.Sprintf("%s%03d", archetype.foodGroup[:2], nextInGroup[archetype.foodGroup])
+
+        refuseDescription := archetype.refuseDescription
+        refuse := ""
+        if archetype.refuse > 0 {
+            refuse = fmt.Sprint(archetype.refuse)
+        }
+        shortDescription := strings.ToUpper(description)
+        if len(shortDescription) > 60 {
+            shortDescription = shortDescription[:60]
+        }
+        foodLines = append(foodLines, strings.Join([]string{
+            usdaText(ndb), usdaText(archetype.foodGroup), usdaText(description), usdaText(shortDescription),
+            usdaText(""), usdaText(""), usdaText(""), usdaText(refuseDescription), refuse, usdaText(""),
+            "6.25", "", "", "",
+        }, "^"))
+
+        for _, code := range fixtureLangualCodes(archetype, description) {
+            langualLines = append(langualLines, usdaText(ndb) + "^" + usdaText(code))
+        }
+
+        for seq, measure := range fixtureMeasures[archetype.variants[0]] {
+            weightLines = append(weightLines, strings.Join([]string{
+                usdaText(ndb), usdaText(fmt.Sprint(seq + 1)), fmt.Sprint(measure.amount),
+                usdaText(measure.description), fmt.Sprintf("%.1f", measure.grams), "", "",
+            }, "^"))
+        }
+
+        amounts := fixtureAmounts(archetype, pass > 0, rng)
+        for _, n := range nutrients {
+            amount, exists := amounts[n.id]
+            if !exists {
+                continue
+            }
+            // Energy is calculated in SR26 rather than analysed, which shows
+            // up as zero data points and source code 4
+            numDataPoints := 1 + rng.Intn(12)
+            sourceCode := "1"
+            if n.id == 208 || n.id == 268 {
+                numDataPoints = 0
+                sourceCode = "4"
+            }
+            // SR26 only gives a standard error with two or more data points
+            stdError := ""
+            if numDataPoints > 1 {
+                stdError = fmt.Sprintf("%.3f", amount * 0.1 / math.Sqrt(float64(numDataPoints)))
+            }
+            dataLines = append(dataLines, strings.Join([]string{
+                usdaText(ndb), usdaText(fmt.Sprint(n.id)), fmt.Sprintf("%.3f", amount), fmt.Sprint(numDataPoints),
+                stdError, usdaText(sourceCode), usdaText(""), usdaText(""), usdaText(""), "", "", "", "", "", "",
+                usdaText(""), usdaText("03/2009"), usdaText(""),
+            }, "^"))
+            if sourceCode == "1" {
+                sourceLinkLines = append(sourceLinkLines, strings.Join([]string{
+                    usdaText(ndb), usdaText(fmt.Sprint(n.id)), usdaText("F" + archetype.foodGroup),
+                }, "^"))
+            }
+        }
+    }
+
+    writeUSDAFile(dir, "NUTR_DEF.txt", nutrientLines)
+    writeUSDAFile(dir, "FD_GROUP.txt", groupLines)
+    writeUSDAFile(dir, "FOOD_DES.txt", foodLines)
+    writeUSDAFile(dir, "NUT_DATA.txt", dataLines)
+    writeUSDAFile(dir, "WEIGHT.txt", weightLines)
+    writeUSDAFile(dir, "DATA_SRC.txt", sourceLines)
+    writeUSDAFile(dir, "DATSRCLN.txt", sourceLinkLines)
+    writeUSDAFile(dir, "LANGDESC.txt", langualDescriptionLines)
+    writeUSDAFile(dir, "LANGUAL.txt", langualLines)
+}
+
+// A handful of LanguaL factors, enough for the facet filters to have
+// something to work with.
+var fixtureLangualFactors = []struct {
+    code string
+    description string
+}{
+    {"B1222", "SARDINE"},
+    {"B1341", "CATTLE"},
+    {"B1457", "CHICKEN"},
+    {"B1406", "PLANT USED AS FOOD SOURCE"},
+    {"F0003", "NOT HEAT-TREATED"},
+    {"F0014", "FULLY HEAT-TREATED"},
+    {"H0138", "FERMENTED"},
+    {"J0131", "FROZEN"},
+    {"J0136", "CANNED, STERILIZED"},
+}
+
+// fixtureLangualCodes indexes a food by its source and, going by the
+// description, how it was treated.
+func fixtureLangualCodes(archetype fixtureArchetype, description string) []string {
+    var codes []string
+    switch {
+    case !archetype.animal:
+        codes = append(codes, "B1406")
+    case archetype.foodGroup == "1500":
+        codes = append(codes, "B1222")
+    case strings.HasPrefix(description, "Egg"):
+        codes = append(codes, "B1457")
+    default:
+        codes = append(codes, "B1341")
+    }
+    lower := strings.ToLower(description)
+    switch {
+    case strings.Contains(lower, "canned"):
+        codes = append(codes, "F0014", "J0136")
+    case strings.Contains(lower, "frozen"):
+        codes = append(codes, "F0003", "J0131")
+    case strings.Contains(lower, "raw") || strings.Contains(lower, "uncooked") || strings.Contains(lower, "unprepared"):
+        codes = append(codes, "F0003")
+    }
+    if strings.HasPrefix(description, "Yogurt") {
+        codes = append(codes, "H0138")
+    }
+    return codes
+}
+
+// fixtureAmounts fills in the per-100g amounts for one food, deriving amino
+// acids, fat breakdown and energy from the macros.
+func fixtureAmounts(archetype fixtureArchetype, jitter bool, rng *rand.Rand) map[int]float64 {
+    // Sorted so the same seed always draws the same jitter for each nutrient
+    ids := make([]int, 0, len(archetype.per100g))
+    for id := range archetype.per100g {
+        ids = append(ids, id)
+    }
+    sort.Ints(ids)
+
+    amounts := make(map[int]float64, len(archetype.per100g) + 20)
+    for _, id := range ids {
+        amount := archetype.per100g[id]
+        if jitter {
+            amount *= 0.85 + rng.Float64() * 0.3
+        }
+        amounts[id] = amount
+    }
+
+    aminoAcids := fixturePlantAminoAcids
+    if archetype.animal {
+        aminoAcids = fixtureAnimalAminoAcids
+    }
+    for id, perGramProtein := range aminoAcids {
+        amounts[id] = amounts[203] * perGramProtein
+    }
+
+    if _, exists := amounts[606]; !exists {
+        amounts[606] = amounts[204] * 0.15
+    }
+    if _, exists := amounts[605]; !exists {
+        amounts[605] = 0
+    }
+
+    kcal := 4 * amounts[203] + 4 * amounts[205] + 9 * amounts[204]
+    amounts[208] = kcal
+    amounts[268] = kcal * 4.184
+    return amounts
+}
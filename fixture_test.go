@@ -0,0 +1,27 @@
+package main
+
+import (
+    "flag"
+    "testing"
+)
+
+// The tests run against testdata/fixture, the small synthetic dataset in
+// the SR26 format that "supershake fixture" writes (see fixture.go).
+const fixtureDir = "testdata/fixture"
+
+// fixtureProblem loads the fixture with the built in exclusions and args
+// as the flags, whatever is in the working directory.
+func fixtureProblem(t *testing.T, args ...string) *Problem {
+    t.Helper()
+    fs := flag.NewFlagSet("test", flag.ContinueOnError)
+    opts := newOptions(fs)
+    if err := fs.Parse(append([]string{"--data-dir", fixtureDir, "--rejected", ""}, args...)); err != nil {
+        t.Fatal(err)
+    }
+    var err error
+    opts.exclusionList, err = parseExclusions(defaultExclusions, "the built in exclusions")
+    if err != nil {
+        t.Fatal(err)
+    }
+    return loadProblem(opts)
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+    "fmt"
+    "os"
+    "sort"
+    "strings"
+)
+
+// --food-group keeps only the food groups given, and --without-food-group
+// leaves groups out, each by SR26 code or name ("0300", "baby foods", or
+// any unambiguous part of a name like "baby"). Without --without-food-group
+// the groups in defaultWithoutFoodGroups are left out, unless --food-group
+// asks for them; "--without-food-group none" keeps them all.
+//
+// --dry-run loads everything and reports how many foods each filter
+// leaves, then stops, for tuning the filters without optimizing.
+
+var sr26FoodGroups = map[string]string{
+    "0100": "Dairy and Egg Products",
+    "0200": "Spices and Herbs",
+    "0300": "Baby Foods",
+    "0400": "Fats and Oils",
+    "0500": "Poultry Products",
+    "0600": "Soups, Sauces, and Gravies",
+    "0700": "Sausages and Luncheon Meats",
+    "0800": "Breakfast Cereals",
+    "0900": "Fruits and Fruit Juices",
+    "1000": "Pork Products",
+    "1100": "Vegetables and Vegetable Products",
+    "1200": "Nut and Seed Products",
+    "1300": "Beef Products",
+    "1400": "Beverages",
+    "1500": "Finfish and Shellfish Products",
+    "1600": "Legumes and Legume Products",
+    "1700": "Lamb, Veal, and Game Products",
+    "1800": "Baked Products",
+    "1900": "Sweets",
+    "2000": "Cereal Grains and Pasta",
+    "2100": "Fast Foods",
+    "2200": "Meals, Entrees, and Side Dishes",
+    "2500": "Snacks",
+    "3500": "American Indian/Alaska Native Foods",
+    "3600": "Restaurant Foods",
+}
+
+// Baby foods, breakfast cereals, beverages, fast foods and restaurant foods
+// don't belong in a shake
+var defaultWithoutFoodGroups = []string{"0300", "0800", "1400", "2100", "3600"}
+
+// foodGroupName is the group's name, or its code when it isn't an SR26 one.
+func foodGroupName(code string) string {
+    if name, known := sr26FoodGroups[code]; known {
+        return name
+    }
+    if code == customFoodGroup {
+        return "Custom foods"
+    }
+    return code
+}
+
+// resolveFoodGroup turns a code or name into a code.
+func resolveFoodGroup(text string) (string, error) {
+    text = strings.TrimSpace(text)
+    if _, known := sr26FoodGroups[text]; known {
+        return text, nil
+    }
+    lower := strings.ToLower(text)
+    var matches []string
+    for code, name := range sr26FoodGroups {
+        if strings.ToLower(name) == lower {
+            return code, nil
+        }
+        if strings.Contains(strings.ToLower(name), lower) {
+            matches = append(matches, code)
+        }
+    }
+    sort.Strings(matches)
+    switch len(matches) {
+    case 1:
+        return matches[0], nil
+    case 0:
+        // Other datasets have their own codes
+        if text != "" && strings.Trim(text, "0123456789") == "" {
+            return text, nil
+        }
+        return "", fmt.Errorf("no food group %q", text)
+    }
+    var names []string
+    for _, code := range matches {
+        names = append(names, code + " " + sr26FoodGroups[code])
+    }
+    return "", fmt.Errorf("food group %q could be any of %s", text, strings.Join(names, "; "))
+}
+
+// foodGroupFilter is which groups --food-group and --without-food-group
+// keep.
+type foodGroupFilter struct {
+    only map[string]bool // nil for every group
+    without map[string]bool
+}
+
+func (filter *foodGroupFilter) allows(group string) bool {
+    return (filter.only == nil || filter.only[group]) && !filter.without[group]
+}
+
+// newFoodGroupFilter resolves the flags.
+func newFoodGroupFilter(opts *Options) (*foodGroupFilter, error) {
+    filter := &foodGroupFilter{without: make(map[string]bool)}
+    for _, text := range opts.foodGroups {
+        code, err := resolveFoodGroup(text)
+        if err != nil {
+            return nil, fmt.Errorf("--food-group: %v", err)
+        }
+        if filter.only == nil {
+            filter.only = make(map[string]bool)
+        }
+        filter.only[code] = true
+    }
+
+    without := opts.withoutFoodGroups
+    if len(without) == 0 {
+        for _, code := range defaultWithoutFoodGroups {
+            // Asking for a group outright overrides the default
+            if !filter.only[code] {
+                without = append(without, code)
+            }
+        }
+    }
+    for _, text := range without {
+        if strings.EqualFold(text, "none") {
+            continue
+        }
+        code, err := resolveFoodGroup(text)
+        if err != nil {
+            return nil, fmt.Errorf("--without-food-group: %v", err)
+        }
+        filter.without[code] = true
+    }
+    return filter, nil
+}
+
+// foodGroupFilter is the food group filter, resolved the first time it's needed.
+// Bad flags exit.
+func (opts *Options) foodGroupFilter() *foodGroupFilter {
+    if opts.groupFilter == nil {
+        filter, err := newFoodGroupFilter(opts)
+        if err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            os.Exit(2)
+        }
+        opts.groupFilter = filter
+    }
+    return opts.groupFilter
+}
+
+// filterStages are keepFood's rules, in the order it applies them.
+var filterStages = []string{ruleOnlyFoods, ruleRejected, ruleFoodGroup, ruleDiet, ruleMaxNova, ruleBlender, ruleMaxPrep, ruleMaxPrice, ruleExclusionList, rulePreset}
+
+// runDryRun loads the foods and prints how many are left after each
+// filter, in the order they're applied, and what's left by food group.
+func runDryRun(opts *Options) {
+    if err := checkBlender(opts.blender); err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(2)
+    }
+    if err := checkDiets(opts); err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(2)
+    }
+    opts.foodGroupFilter()
+    opts.exclusions()
+    fmt.Println(tr("Loading"))
+    nutrients, nutrientNameToId, allFoods := loadNutrientsAndFoods(opts)
+    foods := validateFoods(opts, nutrients, nutrientNameToId, allFoods)
+
+    fmt.Println("\nFILTERS")
+    left := len(allFoods)
+    fmt.Printf("  %-34s %6d foods\n", "loaded", left)
+    stage := func(name string, removed int) {
+        left -= removed
+        fmt.Printf("  %-34s %6d left, %d removed\n", name, left, removed)
+    }
+    if opts.quarantine {
+        stage("--quarantine", len(allFoods) - len(foods))
+    }
+
+    removedBy := make(map[string]int)
+    kept := make(map[int]Food, len(foods))
+    for id, food := range foods {
+        rule := exclusionRule(opts, &food)
+        if rule == "" {
+            kept[id] = food
+            continue
+        }
+        for _, name := range filterStages {
+            if strings.HasPrefix(rule, name) {
+                removedBy[name]++
+            }
+        }
+    }
+    for _, name := range filterStages {
+        stage(name, removedBy[name])
+    }
+
+    faceted, err := filterByFacets(opts, kept)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(2)
+    }
+    if len(opts.facets) > 0 || len(opts.withoutFacets) > 0 {
+        stage(ruleFacets, len(kept) - len(faceted))
+    }
+
+    policies, err := newImputedPolicies(opts, nutrientNameToId)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(2)
+    }
+    if excluded := applyImputedPolicy(policies, faceted); excluded > 0 {
+        stage("--imputed exclude-food", excluded)
+    }
+    if opts.minimize == costObjective {
+        prices, err := readPrices(opts.pricesPath)
+        if err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            os.Exit(2)
+        }
+        priced := pricedFoods(faceted, prices)
+        stage("--minimize cost (unpriced)", len(faceted) - len(priced))
+        faceted = priced
+    }
+
+    byGroup := make(map[string]int)
+    for _, food := range faceted {
+        byGroup[food.foodGroup]++
+    }
+    groups := make([]string, 0, len(byGroup))
+    for group := range byGroup {
+        groups = append(groups, group)
+    }
+    sort.Strings(groups)
+    fmt.Println("\nLEFT BY FOOD GROUP")
+    for _, group := range groups {
+        fmt.Printf("  %s %-40s %6d\n", group, foodGroupName(group), byGroup[group])
+    }
+}
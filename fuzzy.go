@@ -0,0 +1,66 @@
+package main
+
+import (
+    "sort"
+    "strings"
+    "unicode"
+)
+
+// foodMatcher finds the food whose description best matches a free text
+// name, like "Banana, raw" from another app's export. It compares the sets
+// of words in each, so word order and punctuation don't matter.
+type foodMatcher struct {
+    foodIds []int
+    words map[int]map[string]bool
+    foods map[int]Food
+}
+
+func matchWords(text string) map[string]bool {
+    words := make(map[string]bool)
+    fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+        return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+    })
+    for _, word := range fields {
+        // Crude plural folding, so "bananas" matches "banana"
+        if len(word) > 3 && strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") {
+            word = word[:len(word) - 1]
+        }
+        words[word] = true
+    }
+    return words
+}
+
+func newFoodMatcher(foods map[int]Food) *foodMatcher {
+    matcher := &foodMatcher{words: make(map[int]map[string]bool, len(foods)), foods: foods}
+    for id, food := range foods {
+        matcher.foodIds = append(matcher.foodIds, id)
+        matcher.words[id] = matchWords(food.description)
+    }
+    // Ties go to the lowest id, so matches don't change between runs
+    sort.Ints(matcher.foodIds)
+    return matcher
+}
+
+// match returns the best matching food and how similar it is, from 0 for
+// no words in common to 1 for the same words.
+func (matcher *foodMatcher) match(name string) (Food, float64) {
+    query := matchWords(name)
+    bestId, bestScore := 0, float64(0)
+    for _, id := range matcher.foodIds {
+        words := matcher.words[id]
+        common := 0
+        for word := range query {
+            if words[word] {
+                common++
+            }
+        }
+        if common == 0 {
+            continue
+        }
+        score := 2 * float64(common) / float64(len(query) + len(words))
+        if score > bestScore {
+            bestId, bestScore = id, score
+        }
+    }
+    return matcher.foods[bestId], bestScore
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+    "fmt"
+    "math"
+    "sort"
+)
+
+// The gap analyzer looks at a day's intake and, for each target it misses,
+// suggests the one change that fixes it while doing the least damage to the
+// rest of the score.
+
+// Don't suggest eating more than this much of anything to close a gap
+const maxGapGrams = 500
+
+type gapSuggestion struct {
+    target Target
+    amount float64 // of the target nutrient before the change
+    food Food
+    grams int // to add, or to cut when negative
+    scoreChange float64 // negative is better
+}
+
+func (suggestion gapSuggestion) String() string {
+    if suggestion.grams < 0 {
+        return fmt.Sprintf("%s is high at %.2f (want %s): cut %s of %s (score %+.2f)",
+            suggestion.target.nutrient, suggestion.amount, suggestion.target.rangeString(),
+            suggestion.food.gramsString(-suggestion.grams), suggestion.food.description, suggestion.scoreChange)
+    }
+    return fmt.Sprintf("%s is low at %.2f (want %s): add %s of %s (score %+.2f)",
+        suggestion.target.nutrient, suggestion.amount, suggestion.target.rangeString(),
+        suggestion.food.gramsString(suggestion.grams), suggestion.food.description, suggestion.scoreChange)
+}
+
+// roundUpToStep rounds grams up to a whole number of optimizer steps.
+func roundUpToStep(grams float64, step int) int {
+    if step <= 0 {
+        step = 1
+    }
+    return int(math.Ceil(grams / float64(step))) * step
+}
+
+// analyzeGaps suggests a change for each target recipe misses. Additions
+// are picked from candidates; cuts come from what's already in the recipe.
+// problem.foods must hold everything in recipe.
+func analyzeGaps(problem *Problem, recipe *Recipe, candidates map[int]Food) []gapSuggestion {
+    nutrientNameToId := problem.nutrientNameToId
+    baseScore := recipe.Score(problem, false)
+
+    candidateIds := make([]int, 0, len(candidates))
+    for id := range candidates {
+        candidateIds = append(candidateIds, id)
+    }
+    sort.Ints(candidateIds)
+
+    var suggestions []gapSuggestion
+    for _, target := range problem.targets {
+        amount := recipe.amountOf(nutrientNameToId, target.nutrient)
+        if target.isMet(amount) {
+            continue
+        }
+
+        var best *gapSuggestion
+        if amount < target.min {
+            for _, id := range candidateIds {
+                food := candidates[id]
+                perGram := food.perGramOf(nutrientNameToId, target.nutrient)
+                if perGram <= 0 {
+                    continue
+                }
+                grams := roundUpToStep((target.min - amount) / perGram, problem.opts.stepSize)
+                if grams > maxGapGrams {
+                    continue
+                }
+                changed := recipe.Clone(problem.foods, problem.nutrients)
+                changed.AddFood(problem.foods, &food, grams)
+                scoreChange := changed.Score(problem, false) - baseScore
+                if best == nil || scoreChange < best.scoreChange {
+                    best = &gapSuggestion{target, amount, food, grams, scoreChange}
+                }
+            }
+        } else {
+            // Over the max: cut back whatever contributes the most
+            for _, id := range recipe.FoodIdsByGrams() {
+                food := problem.foods[id]
+                perGram := food.perGramOf(nutrientNameToId, target.nutrient)
+                if perGram <= 0 {
+                    continue
+                }
+                grams := roundUpToStep((amount - target.max) / perGram, problem.opts.stepSize)
+                if grams > recipe.foodQuantities[id] {
+                    grams = recipe.foodQuantities[id]
+                }
+                changed := recipe.Clone(problem.foods, problem.nutrients)
+                changed.RemoveFood(problem.foods, &food, grams)
+                scoreChange := changed.Score(problem, false) - baseScore
+                if best == nil || scoreChange < best.scoreChange {
+                    best = &gapSuggestion{target, amount, food, -grams, scoreChange}
+                }
+            }
+        }
+        if best != nil {
+            suggestions = append(suggestions, *best)
+        }
+    }
+    return suggestions
+}
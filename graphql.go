@@ -0,0 +1,407 @@
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "strconv"
+    "strings"
+    "unicode"
+)
+
+// A small GraphQL executor, enough for front ends to ask for exactly the
+// fields they render without pulling in a dependency. It handles queries
+// and mutations with nested selections, aliases, arguments and $variables.
+// Fragments, directives, subscriptions and introspection aren't supported.
+// The schema itself is in graphql_schema.go.
+
+// gqlField is one field of a selection set.
+type gqlField struct {
+    alias string // the key in the result, the name unless aliased
+    name string
+    args map[string]interface{} // literals, with gqlVariable for $variables
+    selections []gqlField
+}
+
+type gqlVariable string
+
+// gqlObject is anything with fields to select.
+type gqlObject interface {
+    resolve(field string, args map[string]interface{}) (interface{}, error)
+}
+
+// gqlResult is an object in the response, keeping the fields in the order
+// they were asked for.
+type gqlResult struct {
+    keys []string
+    values []interface{}
+}
+
+func (result *gqlResult) MarshalJSON() ([]byte, error) {
+    var out bytes.Buffer
+    out.WriteByte('{')
+    for i, key := range result.keys {
+        if i > 0 {
+            out.WriteByte(',')
+        }
+        keyJSON, _ := json.Marshal(key)
+        out.Write(keyJSON)
+        out.WriteByte(':')
+        valueJSON, err := json.Marshal(result.values[i])
+        if err != nil {
+            return nil, err
+        }
+        out.Write(valueJSON)
+    }
+    out.WriteByte('}')
+    return out.Bytes(), nil
+}
+
+// executeGraphQL runs a parsed operation's selections against root, the
+// Query or Mutation type as the operation is one or the other.
+func executeGraphQL(root gqlObject, selections []gqlField, variables map[string]interface{}) (interface{}, error) {
+    return executeSelections(root, selections, variables)
+}
+
+func executeSelections(object gqlObject, selections []gqlField, variables map[string]interface{}) (*gqlResult, error) {
+    result := &gqlResult{}
+    for _, field := range selections {
+        args := make(map[string]interface{}, len(field.args))
+        for name, value := range field.args {
+            resolved, err := resolveVariables(value, variables)
+            if err != nil {
+                return nil, err
+            }
+            args[name] = resolved
+        }
+        value, err := object.resolve(field.name, args)
+        if err != nil {
+            return nil, fmt.Errorf("%s: %v", field.alias, err)
+        }
+        value, err = completeValue(value, field, variables)
+        if err != nil {
+            return nil, err
+        }
+        result.keys = append(result.keys, field.alias)
+        result.values = append(result.values, value)
+    }
+    return result, nil
+}
+
+// completeValue selects the asked for fields of objects, in lists too.
+func completeValue(value interface{}, field gqlField, variables map[string]interface{}) (interface{}, error) {
+    if value == nil {
+        return nil, nil
+    }
+    switch value := value.(type) {
+    case gqlObject:
+        if len(field.selections) == 0 {
+            return nil, fmt.Errorf("%s is an object, select some of its fields", field.alias)
+        }
+        return executeSelections(value, field.selections, variables)
+    case []interface{}:
+        completed := make([]interface{}, len(value))
+        for i, item := range value {
+            var err error
+            completed[i], err = completeValue(item, field, variables)
+            if err != nil {
+                return nil, err
+            }
+        }
+        return completed, nil
+    }
+    if len(field.selections) > 0 {
+        return nil, fmt.Errorf("%s has no fields to select", field.alias)
+    }
+    return value, nil
+}
+
+func resolveVariables(value interface{}, variables map[string]interface{}) (interface{}, error) {
+    switch value := value.(type) {
+    case gqlVariable:
+        resolved, exists := variables[string(value)]
+        if !exists {
+            return nil, fmt.Errorf("variable $%s is not given", value)
+        }
+        return resolved, nil
+    case []interface{}:
+        resolved := make([]interface{}, len(value))
+        for i, item := range value {
+            var err error
+            resolved[i], err = resolveVariables(item, variables)
+            if err != nil {
+                return nil, err
+            }
+        }
+        return resolved, nil
+    }
+    return value, nil
+}
+
+// The parser works on tokens: names, numbers, strings and punctuation.
+
+type gqlParser struct {
+    tokens []string
+    pos int
+}
+
+func lexGraphQL(query string) ([]string, error) {
+    var tokens []string
+    runes := []rune(query)
+    for i := 0; i < len(runes); {
+        r := runes[i]
+        switch {
+        case unicode.IsSpace(r) || r == ',':
+            // Commas are insignificant in GraphQL
+            i++
+        case r == '#':
+            for i < len(runes) && runes[i] != '\n' {
+                i++
+            }
+        case strings.ContainsRune("{}():[]!$=@", r):
+            tokens = append(tokens, string(r))
+            i++
+        case r == '.':
+            if i + 2 < len(runes) && runes[i + 1] == '.' && runes[i + 2] == '.' {
+                return nil, fmt.Errorf("fragments are not supported")
+            }
+            return nil, fmt.Errorf("unexpected %q", r)
+        case r == '"':
+            start := i
+            i++
+            for i < len(runes) && runes[i] != '"' {
+                if runes[i] == '\\' {
+                    i++
+                }
+                i++
+            }
+            if i >= len(runes) {
+                return nil, fmt.Errorf("unterminated string")
+            }
+            i++
+            tokens = append(tokens, string(runes[start:i]))
+        case r == '-' || unicode.IsDigit(r) || r == '_' || unicode.IsLetter(r):
+            start := i
+            i++
+            for i < len(runes) && (runes[i] == '_' || runes[i] == '.' || runes[i] == '-' || unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])) {
+                i++
+            }
+            tokens = append(tokens, string(runes[start:i]))
+        default:
+            return nil, fmt.Errorf("unexpected %q", r)
+        }
+    }
+    return tokens, nil
+}
+
+// The operations parseGraphQL knows
+const (
+    gqlQueryOperation = "query"
+    gqlMutationOperation = "mutation"
+)
+
+// parseGraphQL parses a document holding one query or mutation, returning
+// which it is and its top level selections. A bare { ... } is a query.
+func parseGraphQL(query string) (string, []gqlField, error) {
+    tokens, err := lexGraphQL(query)
+    if err != nil {
+        return "", nil, err
+    }
+    parser := &gqlParser{tokens: tokens}
+    if parser.peek() == "subscription" {
+        return "", nil, fmt.Errorf("subscriptions are not supported")
+    }
+    operation := gqlQueryOperation
+    if parser.peek() == gqlQueryOperation || parser.peek() == gqlMutationOperation {
+        operation = parser.next()
+        if parser.peek() != "{" && parser.peek() != "(" {
+            parser.pos++ // the operation name
+        }
+        if parser.peek() == "(" {
+            // Variable types aren't checked, the values are used as given
+            for parser.peek() != ")" && parser.peek() != "" {
+                parser.pos++
+            }
+            if err := parser.expect(")"); err != nil {
+                return "", nil, err
+            }
+        }
+    }
+    selections, err := parser.parseSelections()
+    if err != nil {
+        return "", nil, err
+    }
+    if parser.peek() != "" {
+        return "", nil, fmt.Errorf("unexpected %q after the %s, only one operation is supported", parser.peek(), operation)
+    }
+    return operation, selections, nil
+}
+
+func (parser *gqlParser) peek() string {
+    if parser.pos >= len(parser.tokens) {
+        return ""
+    }
+    return parser.tokens[parser.pos]
+}
+
+func (parser *gqlParser) next() string {
+    token := parser.peek()
+    parser.pos++
+    return token
+}
+
+func (parser *gqlParser) expect(token string) error {
+    if got := parser.next(); got != token {
+        if got == "" {
+            got = "the end of the query"
+        }
+        return fmt.Errorf("expected %q, got %q", token, got)
+    }
+    return nil
+}
+
+func isGraphQLName(token string) bool {
+    return token != "" && (token[0] == '_' || unicode.IsLetter(rune(token[0])))
+}
+
+func (parser *gqlParser) parseSelections() ([]gqlField, error) {
+    if err := parser.expect("{"); err != nil {
+        return nil, err
+    }
+    var fields []gqlField
+    for parser.peek() != "}" {
+        name := parser.next()
+        if name == "@" {
+            return nil, fmt.Errorf("directives are not supported")
+        }
+        if !isGraphQLName(name) {
+            return nil, fmt.Errorf("expected a field name, got %q", name)
+        }
+        field := gqlField{alias: name, name: name}
+        if parser.peek() == ":" {
+            parser.pos++
+            field.name = parser.next()
+            if !isGraphQLName(field.name) {
+                return nil, fmt.Errorf("expected a field name after %q:, got %q", name, field.name)
+            }
+        }
+        if parser.peek() == "(" {
+            parser.pos++
+            field.args = make(map[string]interface{})
+            for parser.peek() != ")" {
+                argName := parser.next()
+                if !isGraphQLName(argName) {
+                    return nil, fmt.Errorf("expected an argument name, got %q", argName)
+                }
+                if err := parser.expect(":"); err != nil {
+                    return nil, err
+                }
+                value, err := parser.parseValue()
+                if err != nil {
+                    return nil, err
+                }
+                field.args[argName] = value
+            }
+            parser.pos++
+        }
+        if parser.peek() == "{" {
+            selections, err := parser.parseSelections()
+            if err != nil {
+                return nil, err
+            }
+            field.selections = selections
+        }
+        fields = append(fields, field)
+    }
+    parser.pos++
+    return fields, nil
+}
+
+func (parser *gqlParser) parseValue() (interface{}, error) {
+    token := parser.next()
+    switch {
+    case token == "$":
+        name := parser.next()
+        if !isGraphQLName(name) {
+            return nil, fmt.Errorf("expected a variable name, got %q", name)
+        }
+        return gqlVariable(name), nil
+    case token == "[":
+        list := []interface{}{}
+        for parser.peek() != "]" {
+            if parser.peek() == "" {
+                return nil, fmt.Errorf("unterminated list")
+            }
+            value, err := parser.parseValue()
+            if err != nil {
+                return nil, err
+            }
+            list = append(list, value)
+        }
+        parser.pos++
+        return list, nil
+    case strings.HasPrefix(token, "\""):
+        return strconv.Unquote(token)
+    case token == "true" || token == "false":
+        return token == "true", nil
+    case token == "null":
+        return nil, nil
+    case isGraphQLName(token):
+        // An enum value
+        return token, nil
+    }
+    if number, err := strconv.ParseInt(token, 10, 64); err == nil {
+        return float64(number), nil
+    }
+    if number, err := strconv.ParseFloat(token, 64); err == nil {
+        return number, nil
+    }
+    return nil, fmt.Errorf("unexpected %q", token)
+}
+
+// Arguments arrive as JSON would decode them, numbers as float64.
+
+func gqlStringArg(args map[string]interface{}, name string, fallback string) (string, error) {
+    value, given := args[name]
+    if !given || value == nil {
+        return fallback, nil
+    }
+    text, ok := value.(string)
+    if !ok {
+        return "", fmt.Errorf("%s should be a string", name)
+    }
+    return text, nil
+}
+
+func gqlIntArg(args map[string]interface{}, name string, fallback int) (int, error) {
+    value, given := args[name]
+    if !given || value == nil {
+        return fallback, nil
+    }
+    number, ok := value.(float64)
+    if !ok || number != float64(int(number)) {
+        return 0, fmt.Errorf("%s should be a whole number", name)
+    }
+    return int(number), nil
+}
+
+func gqlStringListArg(args map[string]interface{}, name string) ([]string, error) {
+    value, given := args[name]
+    if !given || value == nil {
+        return nil, nil
+    }
+    list, ok := value.([]interface{})
+    if !ok {
+        // A single value is taken as a list of one, as GraphQL does
+        list = []interface{}{value}
+    }
+    var texts []string
+    for _, item := range list {
+        text, ok := item.(string)
+        if !ok {
+            return nil, fmt.Errorf("%s should be a list of strings", name)
+        }
+        texts = append(texts, text)
+    }
+    return texts, nil
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "io"
+    "mime"
+    "net/http"
+    "sort"
+)
+
+// The GraphQL schema served at /graphql by "supershake serve --graphql":
+//
+//     type Query {
+//         foods(q: String, limit: Int): [Food]    # as /api/foods
+//         food(id: Int!): Food
+//         nutrients: [Nutrient]
+//         targets: [Target]
+//         sharedRecipe(token: String!): Recipe
+//     }
+//     type Mutation {
+//         optimize: Recipe                        # with the server's flags
+//     }
+//     type Food { id description foodGroup manufacturer refusePct novaClass
+//                 nutrients(names: [String]): [FoodNutrient] measures: [Measure] }
+//     type FoodNutrient { nutrient: Nutrient per100g dataPoints }
+//     type Nutrient { id description units }
+//     type Measure { amount description grams }
+//     type Target { nutrient min max source }
+//     type Recipe { score mass foods: [RecipeFood] report: [ReportRow] }
+//     type RecipeFood { food: Food grams amount }
+//     type ReportRow { nutrient amount min max met }
+//
+// A max of 0 means there is none, as in the targets table.
+//
+// optimize is a mutation since it adds to the history, and takes a while,
+// so mutations are only taken POSTed as JSON, which another site's page
+// can't send without the CORS headers /graphql never answers with. One
+// mutation optimizes once, asking for optimize again under another alias is
+// an error rather than another run.
+
+type gqlQuery struct {
+    s *server
+}
+
+func (query gqlQuery) resolve(field string, args map[string]interface{}) (interface{}, error) {
+    problem := query.s.problem
+    switch field {
+    case "foods":
+        q, err := gqlStringArg(args, "q", "")
+        if err != nil {
+            return nil, err
+        }
+        limit, err := gqlIntArg(args, "limit", defaultAPILimit)
+        if err != nil {
+            return nil, err
+        }
+        if limit > maxAPILimit {
+            limit = maxAPILimit
+        }
+        foods := []interface{}{}
+        for _, match := range searchFoods(problem.foods, q, limit) {
+            food := problem.foods[match.Id]
+            foods = append(foods, gqlFood{&food})
+        }
+        return foods, nil
+    case "food":
+        id, err := gqlIntArg(args, "id", 0)
+        if err != nil {
+            return nil, err
+        }
+        food, exists := problem.foods[id]
+        if !exists {
+            return nil, nil
+        }
+        return gqlFood{&food}, nil
+    case "nutrients":
+        nutrients := []interface{}{}
+        for _, id := range sortedNutrientIds(problem.nutrients) {
+            nutrients = append(nutrients, gqlNutrient(problem.nutrients[id]))
+        }
+        return nutrients, nil
+    case "targets":
+        targets := []interface{}{}
+        for _, target := range problem.targets {
+            targets = append(targets, gqlTarget{target, problem.targetSources[target.nutrient]})
+        }
+        return targets, nil
+    case "sharedRecipe":
+        token, err := gqlStringArg(args, "token", "")
+        if err != nil {
+            return nil, err
+        }
+        recipe, err := query.s.sharedRecipe(token)
+        if err != nil {
+            return nil, err
+        }
+        return gqlRecipe{problem, recipe}, nil
+    case "__typename":
+        return "Query", nil
+    }
+    return nil, fmt.Errorf("Query has no field %q", field)
+}
+
+type gqlMutation struct {
+    s *server
+}
+
+func (mutation gqlMutation) resolve(field string, args map[string]interface{}) (interface{}, error) {
+    switch field {
+    case "optimize":
+        recipe, err := mutation.s.optimize()
+        if err != nil {
+            return nil, err
+        }
+        return gqlRecipe{mutation.s.problem, recipe}, nil
+    case "__typename":
+        return "Mutation", nil
+    }
+    return nil, fmt.Errorf("Mutation has no field %q", field)
+}
+
+// checkOneOptimize makes sure a mutation optimizes at most once.
+func checkOneOptimize(selections []gqlField) error {
+    count := 0
+    for _, field := range selections {
+        if field.name == "optimize" {
+            count++
+        }
+    }
+    if count > 1 {
+        return fmt.Errorf("optimize is asked for %d times, a request can only optimize once", count)
+    }
+    return nil
+}
+
+type gqlFood struct {
+    food *Food
+}
+
+func (food gqlFood) resolve(field string, args map[string]interface{}) (interface{}, error) {
+    switch field {
+    case "id":
+        return food.food.id, nil
+    case "description":
+        return food.food.description, nil
+    case "foodGroup":
+        return food.food.foodGroup, nil
+    case "manufacturer":
+        return food.food.manufacturer, nil
+    case "refusePct":
+        return food.food.refusePct, nil
+    case "novaClass":
+        return novaClass(food.food), nil
+    case "nutrients":
+        names, err := gqlStringListArg(args, "names")
+        if err != nil {
+            return nil, err
+        }
+        wanted := make(map[string]bool, len(names))
+        for _, name := range names {
+            wanted[name] = true
+        }
+        nutrients := make([]NutrientInFood, 0, len(food.food.nutrients))
+        for _, nutrientInFood := range food.food.nutrients {
+            if len(wanted) == 0 || wanted[nutrientInFood.nutrient.description] {
+                nutrients = append(nutrients, nutrientInFood)
+            }
+        }
+        sort.Slice(nutrients, func(i, j int) bool {
+            return nutrients[i].nutrient.id < nutrients[j].nutrient.id
+        })
+        list := []interface{}{}
+        for _, nutrientInFood := range nutrients {
+            list = append(list, gqlFoodNutrient(nutrientInFood))
+        }
+        return list, nil
+    case "measures":
+        measures := []interface{}{}
+        for _, measure := range food.food.measures {
+            measures = append(measures, gqlMeasure(measure))
+        }
+        return measures, nil
+    case "__typename":
+        return "Food", nil
+    }
+    return nil, fmt.Errorf("Food has no field %q", field)
+}
+
+type gqlFoodNutrient NutrientInFood
+
+func (nutrientInFood gqlFoodNutrient) resolve(field string, args map[string]interface{}) (interface{}, error) {
+    switch field {
+    case "nutrient":
+        return gqlNutrient(nutrientInFood.nutrient), nil
+    case "per100g":
+        return nutrientInFood.amountPerG * 100, nil
+    case "dataPoints":
+        return nutrientInFood.dataPoints, nil
+    case "__typename":
+        return "FoodNutrient", nil
+    }
+    return nil, fmt.Errorf("FoodNutrient has no field %q", field)
+}
+
+type gqlNutrient Nutrient
+
+func (nutrient gqlNutrient) resolve(field string, args map[string]interface{}) (interface{}, error) {
+    switch field {
+    case "id":
+        return nutrient.id, nil
+    case "description":
+        return nutrient.description, nil
+    case "units":
+        return nutrient.units, nil
+    case "__typename":
+        return "Nutrient", nil
+    }
+    return nil, fmt.Errorf("Nutrient has no field %q", field)
+}
+
+type gqlMeasure Measure
+
+func (measure gqlMeasure) resolve(field string, args map[string]interface{}) (interface{}, error) {
+    switch field {
+    case "amount":
+        return measure.amount, nil
+    case "description":
+        return measure.description, nil
+    case "grams":
+        return measure.grams, nil
+    case "__typename":
+        return "Measure", nil
+    }
+    return nil, fmt.Errorf("Measure has no field %q", field)
+}
+
+type gqlTarget struct {
+    target Target
+    source string
+}
+
+func (target gqlTarget) resolve(field string, args map[string]interface{}) (interface{}, error) {
+    switch field {
+    case "nutrient":
+        return target.target.nutrient, nil
+    case "min":
+        return target.target.min, nil
+    case "max":
+        return target.target.max, nil
+    case "source":
+        return target.source, nil
+    case "__typename":
+        return "Target", nil
+    }
+    return nil, fmt.Errorf("Target has no field %q", field)
+}
+
+type gqlRecipe struct {
+    problem *Problem
+    recipe *Recipe
+}
+
+func (recipe gqlRecipe) resolve(field string, args map[string]interface{}) (interface{}, error) {
+    problem := recipe.problem
+    switch field {
+    case "score":
+        return recipe.recipe.Score(problem, false), nil
+    case "mass":
+        return recipe.recipe.Mass(), nil
+    case "foods":
+        foods := []interface{}{}
+        for _, foodId := range recipe.recipe.FoodIdsByGrams() {
+            food := problem.foods[foodId]
+            foods = append(foods, gqlRecipeFood{&food, recipe.recipe.foodQuantities[foodId]})
+        }
+        return foods, nil
+    case "report":
+        rows := []interface{}{}
+        for _, target := range problem.targets {
+            rows = append(rows, gqlReportRow{target, recipe.recipe.amountOf(problem.nutrientNameToId, target.nutrient)})
+        }
+        return rows, nil
+    case "__typename":
+        return "Recipe", nil
+    }
+    return nil, fmt.Errorf("Recipe has no field %q", field)
+}
+
+type gqlRecipeFood struct {
+    food *Food
+    grams int
+}
+
+func (recipeFood gqlRecipeFood) resolve(field string, args map[string]interface{}) (interface{}, error) {
+    switch field {
+    case "food":
+        return gqlFood{recipeFood.food}, nil
+    case "grams":
+        return recipeFood.grams, nil
+    case "amount":
+        return recipeFood.food.gramsString(recipeFood.grams), nil
+    case "__typename":
+        return "RecipeFood", nil
+    }
+    return nil, fmt.Errorf("RecipeFood has no field %q", field)
+}
+
+type gqlReportRow struct {
+    target Target
+    amount float64
+}
+
+func (row gqlReportRow) resolve(field string, args map[string]interface{}) (interface{}, error) {
+    switch field {
+    case "nutrient":
+        return row.target.nutrient, nil
+    case "amount":
+        return row.amount, nil
+    case "min":
+        return row.target.min, nil
+    case "max":
+        return row.target.max, nil
+    case "met":
+        return row.target.isMet(row.amount), nil
+    case "__typename":
+        return "ReportRow", nil
+    }
+    return nil, fmt.Errorf("ReportRow has no field %q", field)
+}
+
+// handleGraphQL takes a query as GET ?query= or as a POSTed JSON
+// {"query": ..., "variables": {...}}, and a mutation only POSTed, answering
+// {"data": ...} or {"errors": [{"message": ...}]}. The answers can hold
+// optimized recipes, so there's no CORS header.
+func (s *server) handleGraphQL(w http.ResponseWriter, r *http.Request) {
+    var request struct {
+        Query string `json:"query"`
+        Variables map[string]interface{} `json:"variables"`
+    }
+    switch r.Method {
+    case http.MethodGet:
+        request.Query = r.URL.Query().Get("query")
+        if variables := r.URL.Query().Get("variables"); variables != "" {
+            if err := json.Unmarshal([]byte(variables), &request.Variables); err != nil {
+                http.Error(w, "variables: " + err.Error(), http.StatusBadRequest)
+                return
+            }
+        }
+    case http.MethodPost:
+        // A form or text/plain POST needs no preflight, so another site's
+        // page could send one
+        if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+            http.Error(w, "POST the query as application/json", http.StatusUnsupportedMediaType)
+            return
+        }
+        data, err := io.ReadAll(io.LimitReader(r.Body, maxShareBytes))
+        if err == nil {
+            err = json.Unmarshal(data, &request)
+        }
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+    default:
+        http.Error(w, "GET or POST a query", http.StatusMethodNotAllowed)
+        return
+    }
+
+    operation, selections, err := parseGraphQL(request.Query)
+    var root gqlObject = gqlQuery{s}
+    if err == nil && operation == gqlMutationOperation {
+        if r.Method != http.MethodPost {
+            http.Error(w, "POST mutations", http.StatusMethodNotAllowed)
+            return
+        }
+        root = gqlMutation{s}
+        err = checkOneOptimize(selections)
+    }
+    var data interface{}
+    if err == nil {
+        data, err = executeGraphQL(root, selections, request.Variables)
+    }
+    if err != nil {
+        writePrivateJSON(w, map[string]interface{}{"errors": []map[string]string{{"message": err.Error()}}})
+        return
+    }
+    writePrivateJSON(w, map[string]interface{}{"data": data})
+}
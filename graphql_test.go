@@ -0,0 +1,171 @@
+package main
+
+import (
+    "encoding/json"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func TestLexGraphQL(t *testing.T) {
+    for _, test := range []struct {
+        query string
+        want []string
+        err string
+    }{
+        {"{ foods }", []string{"{", "foods", "}"}, ""},
+        {"food(id: 11001) { id, description }", []string{"food", "(", "id", ":", "11001", ")", "{", "id", "description", "}"}, ""},
+        {"# a comment\n{ a }", []string{"{", "a", "}"}, ""},
+        {`f(q: "say \"hi\"", n: -1.5)`, []string{"f", "(", "q", ":", `"say \"hi\""`, "n", ":", "-1.5", ")"}, ""},
+        {"query Q($id: Int!) { food(id: $id) }", []string{"query", "Q", "(", "$", "id", ":", "Int", "!", ")", "{", "food", "(", "id", ":", "$", "id", ")", "}"}, ""},
+        {"{ ...f }", nil, "fragments are not supported"},
+        {`{ f(q: "open }`, nil, "unterminated string"},
+        {"{ a; }", nil, "unexpected ';'"},
+    } {
+        tokens, err := lexGraphQL(test.query)
+        if test.err != "" {
+            if err == nil || err.Error() != test.err {
+                t.Errorf("%q: got error %v, want %q", test.query, err, test.err)
+            }
+            continue
+        }
+        if err != nil {
+            t.Errorf("%q: %v", test.query, err)
+        } else if !reflect.DeepEqual(tokens, test.want) {
+            t.Errorf("%q: got %q, want %q", test.query, tokens, test.want)
+        }
+    }
+}
+
+func TestParseGraphQL(t *testing.T) {
+    for _, test := range []struct {
+        name string
+        query string
+        operation string
+        want []gqlField
+    }{
+        {"bare", "{ nutrients { id } }", gqlQueryOperation, []gqlField{
+            {alias: "nutrients", name: "nutrients", selections: []gqlField{{alias: "id", name: "id"}}},
+        }},
+        {"named query", "query Foods { foods(q: \"kale\", limit: 2) { description } }", gqlQueryOperation, []gqlField{
+            {alias: "foods", name: "foods", args: map[string]interface{}{"q": "kale", "limit": float64(2)}, selections: []gqlField{
+                {alias: "description", name: "description"},
+            }},
+        }},
+        {"variables", "query ($id: Int) { food(id: $id) { id } }", gqlQueryOperation, []gqlField{
+            {alias: "food", name: "food", args: map[string]interface{}{"id": gqlVariable("id")}, selections: []gqlField{{alias: "id", name: "id"}}},
+        }},
+        {"aliases", "{ a: food(id: 1) { id } b: food(id: 2) { id } }", gqlQueryOperation, []gqlField{
+            {alias: "a", name: "food", args: map[string]interface{}{"id": float64(1)}, selections: []gqlField{{alias: "id", name: "id"}}},
+            {alias: "b", name: "food", args: map[string]interface{}{"id": float64(2)}, selections: []gqlField{{alias: "id", name: "id"}}},
+        }},
+        {"mutation", "mutation { optimize(exclude: [\"soy\", \"nuts\"], lp: true, seed: null) { score } }", gqlMutationOperation, []gqlField{
+            {alias: "optimize", name: "optimize", args: map[string]interface{}{
+                "exclude": []interface{}{"soy", "nuts"}, "lp": true, "seed": nil,
+            }, selections: []gqlField{{alias: "score", name: "score"}}},
+        }},
+        {"enum and float", "{ f(order: DESC, min: 0.5) }", gqlQueryOperation, []gqlField{
+            {alias: "f", name: "f", args: map[string]interface{}{"order": "DESC", "min": 0.5}},
+        }},
+    } {
+        t.Run(test.name, func(t *testing.T) {
+            operation, selections, err := parseGraphQL(test.query)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if operation != test.operation {
+                t.Errorf("operation %q, want %q", operation, test.operation)
+            }
+            if !reflect.DeepEqual(selections, test.want) {
+                t.Errorf("got %+v, want %+v", selections, test.want)
+            }
+        })
+    }
+}
+
+func TestParseGraphQLErrors(t *testing.T) {
+    for _, test := range []struct {
+        query string
+        err string
+    }{
+        {"", `expected "{", got "the end of the query"`},
+        {"{ a", `expected a field name, got ""`},
+        {"{ a @skip }", "directives are not supported"},
+        {"{ a: 1 }", `expected a field name after "a":, got "1"`},
+        {"{ a(1: 2) }", `expected an argument name, got "1"`},
+        {"{ a(b 2) }", `expected ":", got "2"`},
+        {"{ a(b: [1, 2) }", `unexpected ")"`},
+        {"{ a(b: [1, 2", "unterminated list"},
+        {"{ a(b: $1) }", `expected a variable name, got "1"`},
+        {"{ a } { b }", `unexpected "{" after the query, only one operation is supported`},
+        {"subscription { a }", "subscriptions are not supported"},
+    } {
+        _, _, err := parseGraphQL(test.query)
+        if err == nil || err.Error() != test.err {
+            t.Errorf("%q: got error %v, want %q", test.query, err, test.err)
+        }
+    }
+}
+
+func TestExecuteGraphQL(t *testing.T) {
+    root := gqlQuery{&server{problem: fixtureProblem(t, "--imputed", "trust")}}
+    for _, test := range []struct {
+        name string
+        query string
+        variables map[string]interface{}
+        want string
+        err string
+    }{
+        {"food", "{ food(id: 11002) { id description } }", nil,
+            `{"food":{"id":11002,"description":"Kale, raw"}}`, ""},
+        {"variable", "query ($id: Int) { f: food(id: $id) { description __typename } }", map[string]interface{}{"id": float64(11001)},
+            `{"f":{"description":"Spinach, raw","__typename":"Food"}}`, ""},
+        {"missing food", "{ food(id: 1) { id } }", nil, `{"food":null}`, ""},
+        {"search", "{ foods(q: \"lentils\", limit: 1) { id } }", nil, `{"foods":[{"id":16001}]}`, ""},
+        {"unknown field", "{ food(id: 11002) { colour } }", nil, "", "colour"},
+        {"unknown variable", "{ food(id: $id) { id } }", nil, "", "$id"},
+    } {
+        t.Run(test.name, func(t *testing.T) {
+            _, selections, err := parseGraphQL(test.query)
+            if err != nil {
+                t.Fatal(err)
+            }
+            result, err := executeGraphQL(root, selections, test.variables)
+            if test.err != "" {
+                if err == nil || !strings.Contains(err.Error(), test.err) {
+                    t.Errorf("got error %v, want one about %s", err, test.err)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatal(err)
+            }
+            got, err := json.Marshal(result)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if string(got) != test.want {
+                t.Errorf("got %s, want %s", got, test.want)
+            }
+        })
+    }
+}
+
+func TestCheckOneOptimize(t *testing.T) {
+    for _, test := range []struct {
+        query string
+        ok bool
+    }{
+        {"mutation { optimize { score } }", true},
+        {"mutation { __typename }", true},
+        {"mutation { a: optimize { score } b: optimize { mass } }", false},
+    } {
+        _, selections, err := parseGraphQL(test.query)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if err := checkOneOptimize(selections); (err == nil) != test.ok {
+            t.Errorf("%q: got error %v", test.query, err)
+        }
+    }
+}
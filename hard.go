@@ -0,0 +1,123 @@
+package main
+
+import (
+    "fmt"
+    "strings"
+)
+
+// Targets are soft, missing one only costs score, unless they're hard:
+// "hard: true" in a targets file, or --hard with the nutrient. A recipe
+// outside a hard target's range is infeasible, and no recipe is better
+// than a feasible one. The hill climber never steps over a hard max, or
+// below a hard min it has reached, and scores a recipe short of a hard min
+// hardPenalty plus hardGradient for each whole min short, so it heads for
+// them first. If it still ends outside one, there's no recipe: the command
+// fails rather than print it. The linear program makes them constraints.
+// --above-max hard makes every max hard, and leaves the mins soft.
+
+// An infeasible recipe costs at least this, more than any feasible one
+const hardPenalty = 1e6
+
+// And this much more per whole min it's short, or max it's over
+const hardGradient = 1e4
+
+// infeasibleError is returned for a recipe outside hard targets.
+type infeasibleError struct {
+    violations []string
+}
+
+func (err *infeasibleError) Error() string {
+    return "no recipe meets the hard targets: " + strings.Join(err.violations, "; ")
+}
+
+// markHardTargets makes the --hard targets hard.
+func markHardTargets(targets []Target, nutrients []string) ([]Target, error) {
+    for _, nutrient := range nutrients {
+        found := false
+        for i := range targets {
+            if targets[i].nutrient == nutrient {
+                targets[i].hard = true
+                found = true
+            }
+        }
+        if !found {
+            return nil, fmt.Errorf("--hard %q: there's no target for it, give one with --target", nutrient)
+        }
+    }
+    return targets, nil
+}
+
+// hardViolations describes each hard target recipe is outside, nil when
+// it's feasible.
+func (recipe *Recipe) hardViolations(problem *Problem) []string {
+    var violations []string
+    for _, target := range problem.targets {
+        if !target.hard && !problem.maxIsHard(target) {
+            continue
+        }
+        amount := recipe.amountOf(problem.nutrientNameToId, target.nutrient)
+        if target.hard && amount < target.min {
+            violations = append(violations, fmt.Sprintf("%s %.2f under its min of %g", target.nutrient, amount, target.min))
+        } else if problem.maxIsHard(target) && amount > target.max {
+            violations = append(violations, fmt.Sprintf("%s %.2f over its max of %g", target.nutrient, amount, target.max))
+        }
+    }
+    return violations
+}
+
+// feasible is an infeasibleError if recipe is outside a hard target.
+func (recipe *Recipe) feasible(problem *Problem) error {
+    if violations := recipe.hardViolations(problem); len(violations) > 0 {
+        return &infeasibleError{violations}
+    }
+    return nil
+}
+
+// hardTargetPenalty is the part of the score for hard targets.
+func (recipe *Recipe) hardTargetPenalty(problem *Problem, verbose bool) float64 {
+    penalty := float64(0)
+    for _, target := range problem.targets {
+        if !target.hard && !problem.maxIsHard(target) {
+            continue
+        }
+        amount := recipe.amountOf(problem.nutrientNameToId, target.nutrient)
+        if target.hard && amount < target.min {
+            penalty += hardPenalty + (target.min - amount) / target.min * hardGradient
+        } else if problem.maxIsHard(target) && amount > target.max {
+            penalty += hardPenalty + (amount - target.max) / target.max * hardGradient
+        }
+    }
+    if verbose && penalty > 0 { fmt.Printf("Penalty for being outside hard targets: %f\n", penalty) }
+    return penalty
+}
+
+// overHardMax is whether recipe has more than a hard target's max of
+// anything.
+func (recipe *Recipe) overHardMax(problem *Problem) bool {
+    for _, target := range problem.targets {
+        if problem.maxIsHard(target) && recipe.amountOf(problem.nutrientNameToId, target.nutrient) > target.max {
+            return true
+        }
+    }
+    return false
+}
+
+// hardMinsMet is how many hard mins recipe reaches.
+func (recipe *Recipe) hardMinsMet(problem *Problem) int {
+    met := 0
+    for _, target := range problem.targets {
+        if target.hard && target.min > 0 && recipe.amountOf(problem.nutrientNameToId, target.nutrient) >= target.min {
+            met++
+        }
+    }
+    return met
+}
+
+func (problem *Problem) hasHardTargets() bool {
+    for _, target := range problem.targets {
+        if target.hard || problem.maxIsHard(target) {
+            return true
+        }
+    }
+    return false
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+    "bufio"
+    "bytes"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "math"
+    "os"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// The history file is one JSON line per saved recipe: the recipe as saved,
+// where it was saved and its score at the time. "supershake history" shows
+// it as a tree of recipes and what changed from each parent.
+
+type historyEntry struct {
+    recipeFile
+    Path string `json:"path"`
+    Score float64 `json:"score"`
+}
+
+func appendHistory(path string, passphrase string, entry historyEntry) error {
+    data, err := json.Marshal(entry)
+    if err != nil {
+        return err
+    }
+    return appendStoreFile(path, passphrase, append(data, '\n'))
+}
+
+// readHistory reads the history file, oldest first. A missing file is an
+// empty history.
+func readHistory(path string, passphrase string) ([]historyEntry, error) {
+    data, err := readStoreFile(path, passphrase)
+    if os.IsNotExist(err) {
+        return nil, nil
+    } else if err != nil {
+        return nil, err
+    }
+
+    var entries []historyEntry
+    scanner := bufio.NewScanner(bytes.NewReader(data))
+    scanner.Buffer(make([]byte, 64 * 1024), 1 << 20)
+    for line := 1; scanner.Scan(); line++ {
+        if strings.TrimSpace(scanner.Text()) == "" {
+            continue
+        }
+        var entry historyEntry
+        if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+            return nil, fmt.Errorf("%s line %d: %v", path, line, err)
+        }
+        entries = append(entries, entry)
+    }
+    return entries, scanner.Err()
+}
+
+// historyChanges describes how a recipe's foods differ from its parent's,
+// e.g. "+90g Spinach, raw", "Oats 100g -> 150g".
+func historyChanges(parent, child recipeFile) []string {
+    parentGrams := make(map[int]recipeFileFood, len(parent.Foods))
+    for _, food := range parent.Foods {
+        parentGrams[food.Id] = food
+    }
+    var changes []string
+    for _, food := range child.Foods {
+        before, existed := parentGrams[food.Id]
+        switch {
+        case !existed:
+            changes = append(changes, fmt.Sprintf("+%dg %s", food.Grams, food.Description))
+        case before.Grams != food.Grams:
+            changes = append(changes, fmt.Sprintf("%s %dg -> %dg", food.Description, before.Grams, food.Grams))
+        }
+        delete(parentGrams, food.Id)
+    }
+    var removed []recipeFileFood
+    for _, food := range parentGrams {
+        removed = append(removed, food)
+    }
+    sort.Slice(removed, func(i, j int) bool { return removed[i].Id < removed[j].Id })
+    for _, food := range removed {
+        changes = append(changes, fmt.Sprintf("-%dg %s", food.Grams, food.Description))
+    }
+    return changes
+}
+
+// runHistory handles "supershake history".
+func runHistory(args []string) {
+    fs := flag.NewFlagSet("supershake history", flag.ExitOnError)
+    opts := newOptions(fs)
+    id := fs.String("id", "", "only show this recipe's ancestors and descendants")
+    changes := fs.Bool("changes", true, "show what changed from each parent")
+    fs.Parse(args)
+
+    entries, err := readHistory(opts.historyPath, opts.passphrase())
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(1)
+    }
+    if len(entries) == 0 {
+        fmt.Println("No recipes saved yet. Save one with --save-recipe.")
+        return
+    }
+
+    byId := make(map[string]*historyEntry, len(entries))
+    children := make(map[string][]*historyEntry)
+    for i := range entries {
+        entry := &entries[i]
+        byId[entry.Id] = entry
+    }
+    var roots []*historyEntry
+    for i := range entries {
+        entry := &entries[i]
+        if _, known := byId[entry.Parent]; entry.Parent != "" && known {
+            children[entry.Parent] = append(children[entry.Parent], entry)
+        } else {
+            roots = append(roots, entry)
+        }
+    }
+
+    // With --id, only the line through that recipe
+    onPath := map[string]bool{}
+    if *id != "" {
+        entry, exists := byId[*id]
+        if !exists {
+            fmt.Fprintf(os.Stderr, "No recipe %q in %s\n", *id, opts.historyPath)
+            os.Exit(1)
+        }
+        for ancestor := entry; ancestor != nil; ancestor = byId[ancestor.Parent] {
+            onPath[ancestor.Id] = true
+            if ancestor.Parent == "" {
+                break
+            }
+        }
+        var markDescendants func(string)
+        markDescendants = func(parent string) {
+            for _, child := range children[parent] {
+                onPath[child.Id] = true
+                markDescendants(child.Id)
+            }
+        }
+        markDescendants(*id)
+    }
+
+    var show func(entry *historyEntry, depth int)
+    show = func(entry *historyEntry, depth int) {
+        if *id != "" && !onPath[entry.Id] {
+            return
+        }
+        indent := strings.Repeat("  ", depth)
+        derivedBy := entry.DerivedBy
+        if entry.Note != "" {
+            derivedBy += " (" + entry.Note + ")"
+        }
+        created := entry.Created
+        if len(created) >= 16 {
+            created = strings.Replace(created[:16], "T", " ", 1)
+        }
+        fmt.Printf("%s%s  %s  %-20s score %.2f  %s\n", indent, entry.Id, created, derivedBy, entry.Score, entry.Path)
+        if parent, known := byId[entry.Parent]; *changes && known {
+            for _, change := range historyChanges(parent.recipeFile, entry.recipeFile) {
+                fmt.Printf("%s    %s\n", indent, change)
+            }
+        }
+        for _, child := range children[entry.Id] {
+            show(child, depth + 1)
+        }
+    }
+    for _, root := range roots {
+        show(root, 0)
+    }
+}
+
+// runEdit handles "supershake edit", deriving a recipe from a saved one by
+// scaling it or swapping foods.
+func runEdit(args []string) {
+    fs := flag.NewFlagSet("supershake edit", flag.ExitOnError)
+    opts := newOptions(fs)
+    recipePath := fs.String("recipe", "", "saved recipe to start from")
+    scale := fs.Float64("scale", 1, "multiply every amount by this, e.g. 0.5 for half a batch")
+    var swaps stringList
+    fs.Var(&swaps, "swap", "use one food in place of another, at the same weight, as \"oldId=newId\" (repeatable)")
+    fs.Parse(args)
+
+    if *recipePath == "" || opts.saveRecipePath == "" || (*scale == 1 && len(swaps) == 0) {
+        fmt.Fprintln(os.Stderr, "Usage: supershake edit --recipe recipe.json (--scale factor | --swap oldId=newId) --save-recipe new.json")
+        os.Exit(2)
+    }
+    if *scale <= 0 {
+        fmt.Fprintln(os.Stderr, "--scale should be above 0")
+        os.Exit(2)
+    }
+    var parent string
+    quantities, err := readRecipeFile(*recipePath)
+    if err == nil {
+        parent, err = readRecipeId(*recipePath)
+    }
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(1)
+    }
+
+    nutrients, nutrientNameToId, allFoods := loadNutrientsAndFoods(opts)
+    problem := newProblem(opts, nutrients, nutrientNameToId, allFoods)
+
+    var derivedBy, notes []string
+    for _, swap := range swaps {
+        parts := strings.SplitN(swap, "=", 2)
+        var from, to int
+        if len(parts) == 2 {
+            from, err = strconv.Atoi(strings.TrimSpace(parts[0]))
+            if err == nil {
+                to, err = strconv.Atoi(strings.TrimSpace(parts[1]))
+            }
+        }
+        if len(parts) != 2 || err != nil {
+            fmt.Fprintf(os.Stderr, "Bad --swap %q, want oldId=newId\n", swap)
+            os.Exit(2)
+        }
+        grams, inRecipe := quantities[from]
+        if !inRecipe {
+            fmt.Fprintf(os.Stderr, "Food %d isn't in %s\n", from, *recipePath)
+            os.Exit(1)
+        }
+        delete(quantities, from)
+        quantities[to] += grams
+        notes = append(notes, fmt.Sprintf("%d for %d", to, from))
+    }
+    if len(swaps) > 0 {
+        derivedBy = append(derivedBy, "substituted")
+    }
+    if *scale != 1 {
+        for foodId, grams := range quantities {
+            quantities[foodId] = int(math.Round(float64(grams) * *scale))
+            if quantities[foodId] <= 0 {
+                delete(quantities, foodId)
+            }
+        }
+        derivedBy = append(derivedBy, "scaled")
+        notes = append(notes, "x" + strconv.FormatFloat(*scale, 'g', -1, 64))
+    }
+
+    recipe := NewRecipe(problem.foods, problem.nutrients)
+    for foodId, grams := range quantities {
+        food, exists := problem.foods[foodId]
+        if !exists {
+            fmt.Fprintf(os.Stderr, "No food %d\n", foodId)
+            os.Exit(1)
+        }
+        recipe.AddFood(problem.foods, &food, grams)
+    }
+    printRecipe(problem, recipe)
+
+    lineage := recipeLineage{parent, strings.Join(derivedBy, ", "), strings.Join(notes, ", ")}
+    id, err := writeRecipeFile(opts.saveRecipePath, opts.historyPath, problem, recipe, lineage)
+    if err != nil {
+        panic(err)
+    }
+    fmt.Printf("Saved recipe %s to %s\n", id, opts.saveRecipePath)
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+    "os"
+    "strings"
+)
+
+// Help text and common messages in other languages. Messages are looked up
+// by their English text, so anything without a translation just stays in
+// English. Nutrient and food names come from the dataset and aren't
+// translated here.
+
+var translations = map[string]map[string]string{
+    "es": {
+        "directory holding the extracted USDA SR26 files": "directorio con los archivos USDA SR26 extraídos",
+        "CSV of your own foods to use alongside the dataset": "CSV con tus propios alimentos para usar junto al conjunto de datos",
+        "directory holding the USDA Branded Food Products CSVs": "directorio con los CSV de la base de datos USDA Branded Food Products",
+        "another dataset in the SR26 format, e.g. SR Legacy (repeatable)": "otro conjunto de datos en formato SR26, p. ej. SR Legacy (repetible)",
+        "which source wins when a food is in several, highest first": "qué fuente gana cuando un alimento está en varias, la más prioritaria primero",
+        "also treat foods with the same description as duplicates": "considerar también duplicados los alimentos con la misma descripción",
+        "cache the parsed data in this file and load from it on later runs": "guardar los datos procesados en este archivo y cargarlos desde él en las siguientes ejecuciones",
+        "reparse the data files even if the snapshot is up to date": "volver a leer los archivos de datos aunque la instantánea esté al día",
+        "download and extract the dataset into --data-dir if it is missing": "descargar y extraer el conjunto de datos en --data-dir si falta",
+        "where --download fetches the dataset zip from": "de dónde descarga --download el zip del conjunto de datos",
+        "expected sha256 of the downloaded zip, needed unless supershake knows it, or skip to not verify it": "sha256 esperado del zip descargado, necesario salvo que supershake lo conozca, o skip para no verificarlo",
+        "your blender: personal, standard (hard foods need soaking) or high-power": "tu licuadora: personal, standard (los alimentos duros necesitan remojo) o high-power",
+        "leave out foods needing more than this many minutes of soaking or cooking (0 = no limit)": "excluir alimentos que necesitan más de estos minutos de remojo o cocción (0 = sin límite)",
+        "only use foods with this LanguaL facet, e.g. raw, fermented, or a factor description (repeatable)": "usar solo alimentos con esta faceta LanguaL, p. ej. raw, fermented, o una descripción de factor (repetible)",
+        "leave out foods with this LanguaL facet, e.g. animal-derived (repeatable)": "excluir alimentos con esta faceta LanguaL, p. ej. animal-derived (repetible)",
+        "report what each ingredient costs per point of score, with cheaper substitutes": "informar cuánto cuesta cada ingrediente por punto de puntuación, con sustitutos más baratos",
+        "write the data validation issues to this file as JSON": "escribir los problemas de validación de datos en este archivo como JSON",
+        "leave out foods that fail data validation, e.g. energy that doesn't match the macros": "excluir alimentos que no pasan la validación de datos, p. ej. energía que no cuadra con los macronutrientes",
+        "what --data-dir holds: sr26, or cnf for the Canadian Nutrient File CSVs": "qué contiene --data-dir: sr26, o cnf para los CSV del Canadian Nutrient File",
+        "Open Food Facts JSONL dump (optionally gzipped) to take packaged foods from": "volcado JSONL de Open Food Facts (puede estar comprimido con gzip) del que tomar alimentos envasados",
+        "YAML mapping describing a delimited nutrition file to take foods from, e.g. lab results (repeatable)": "mapeo YAML que describe un archivo de nutrientes delimitado del que tomar alimentos, p. ej. resultados de laboratorio (repetible)",
+        "optimize starting from this saved recipe, which becomes the new recipe's parent": "optimizar partiendo de esta receta guardada, que pasa a ser la receta madre de la nueva",
+        "file recording every saved recipe and its parent, for supershake history": "archivo que registra cada receta guardada y su receta madre, para supershake history",
+        "before optimizing, show the nutrients few of the allowed foods supply and what would help, then stop": "antes de optimizar, mostrar los nutrientes que aportan pocos de los alimentos permitidos y qué ayudaría, y terminar",
+        "YAML list of foods to keep out of recipes (default: the built in list, unless the file exists)": "lista YAML de alimentos que no deben entrar en las recetas (por defecto: la lista incorporada, salvo que exista el archivo)",
+        "only use foods this diet allows: vegan, vegetarian, pescatarian, kosher or halal (repeatable)": "usar solo alimentos que permite esta dieta: vegan, vegetarian, pescatarian, kosher o halal (repetible)",
+        "YAML of food id: tags, correcting the tags --diet works out from descriptions": "YAML de id de alimento: etiquetas, que corrige las etiquetas que --diet deduce de las descripciones",
+        "make a recipe that meets the targets with as few ingredients as possible in common with the last this many saved (0 = off)": "crear una receta que cumpla los objetivos con los menos ingredientes posibles en común con las últimas tantas guardadas (0 = desactivado)",
+        "seed for --surprise, to get the same recipe again (0 = random)": "semilla para --surprise, para obtener de nuevo la misma receta (0 = aleatoria)",
+        "file listing the only foods to use, one NDB id or description per line": "archivo con los únicos alimentos a usar, un id NDB o descripción por línea",
+        "file holding the passphrase to encrypt the history, intake log and shared recipes with (default $SUPERSHAKE_PASSPHRASE)": "archivo con la frase de contraseña para cifrar el historial, el registro de consumo y las recetas compartidas (por defecto $SUPERSHAKE_PASSPHRASE)",
+        "never consider recipes with more than this many µg of mercury from fish (0 = no cap)": "no considerar nunca recetas con más de estos µg de mercurio del pescado (0 = sin límite)",
+        "only use foods from this food group, by SR code or name (repeatable)": "solo usar alimentos de este grupo, por código o nombre SR (repetible)",
+        "leave out this food group, by SR code or name, instead of baby foods, breakfast cereals, beverages, fast foods and restaurant foods (repeatable, \"none\" to keep them all)": "excluir este grupo de alimentos, por código o nombre SR, en lugar de alimentos infantiles, cereales de desayuno, bebidas, comida rápida y de restaurante (repetible, \"none\" para conservarlos todos)",
+        "load the foods, report how many each filter leaves, then stop": "cargar los alimentos, informar cuántos deja cada filtro y parar",
+        "print a Nutrition Facts panel for the recipe split into this many servings (0 = none)": "imprimir una tabla de información nutricional para la receta dividida en este número de porciones (0 = ninguna)",
+        "how the Nutrition Facts panel rounds: exact, or fda for the FDA's labeling rules": "cómo redondea la tabla nutricional: exact, o fda para las reglas de etiquetado de la FDA",
+        "file of foods rejected with supershake reject, which recipes never use (\"\" for none)": "archivo de alimentos rechazados con supershake reject, que las recetas nunca usan (\"\" para ninguno)",
+        "CSV of supplement prices, to report which gaps are cheaper to close with a pill than with food": "CSV de precios de suplementos, para indicar qué carencias es más barato cubrir con una pastilla que con comida",
+        "also leave out the foods an elimination diet does: low-fodmap, nightshade-free or low-histamine, or change targets for a life stage: pregnancy, lactation, adolescent or older-adult, or a condition: ckd, hypertension or warfarin (repeatable)": "excluir también los alimentos que excluye una dieta de eliminación: low-fodmap, nightshade-free o low-histamine, o cambiar los objetivos para una etapa de la vida: pregnancy, lactation, adolescent u older-adult, o para una afección: ckd, hypertension o warfarin (repetible)",
+        "leave out foods that cost more than this per kg as purchased, by --prices (0 = no limit)": "excluir los alimentos que cuestan más que esto por kg comprado, según --prices (0 = sin límite)",
+        "never consider recipes that cost more than this, by --prices (0 = no cap)": "no considerar nunca recetas que cuesten más que esto, según --prices (0 = sin tope)",
+        "male or female, to work the targets out from the Dietary Reference Intakes with --age, --weight-kg and --height-cm": "male o female, para calcular los objetivos a partir de las ingestas dietéticas de referencia con --age, --weight-kg y --height-cm",
+        "age in years, for the DRI targets": "edad en años, para los objetivos DRI",
+        "body weight in kg, for the DRI targets and per kg ones like \"1.6 g/kg\"": "peso corporal en kg, para los objetivos DRI y los por kg como \"1.6 g/kg\"",
+        "height in cm, for the DRI targets": "altura en cm, para los objetivos DRI",
+        "activity level for the DRI energy target: sedentary, light, moderate, active or very-active": "nivel de actividad para el objetivo de energía DRI: sedentary, light, moderate, active o very-active",
+        "protein per kg of body weight for the DRI targets, 0.8 is the RDA": "proteína por kg de peso corporal para los objetivos DRI, 0.8 es la RDA",
+        "dietary pattern to report compliance with, like eat-lancet": "patrón dietético con el que informar del cumplimiento, como eat-lancet",
+        "YAML file of more dietary patterns, in the format of patterns.yaml": "archivo YAML con más patrones dietéticos, en el formato de patterns.yaml",
+        "optimize within the --pattern too, each food group out of its range counting this many times a missed target (0 = only report it)": "optimizar también dentro del --pattern, cada grupo de alimentos fuera de su rango cuenta estas veces un objetivo no alcanzado (0 = solo informar)",
+        "YAML file overriding or adding to the targets, a field at a time, in the format \"supershake targets defaults\" prints": "archivo YAML que cambia o añade objetivos, campo a campo, en el formato que imprime \"supershake targets defaults\"",
+        "record every step the optimizer tries to this gzipped JSON lines file, for \"supershake replay\"": "registrar cada paso que prueba el optimizador en este archivo JSON lines comprimido con gzip, para \"supershake replay\"",
+        "use the flags of this profile from the --profiles file, like me or kid": "usar las opciones de este perfil del archivo --profiles, como me o kid",
+        "YAML file of named profiles, each a set of flags for one person": "archivo YAML de perfiles con nombre, cada uno un conjunto de opciones para una persona",
+        "Couldn't find the USDA SR26 files. Download them from:": "No se encontraron los archivos USDA SR26. Descárgalos de:",
+        "Folder or zip holding them (drag it here, or leave blank to stop): ": "Carpeta o zip que los contiene (arrástralo aquí, o déjalo en blanco para salir): ",
+        "Found them. Next time pass --data-dir %q, or put them in %s\n": "Encontrados. La próxima vez pasa --data-dir %q, o ponlos en %s\n",
+        "%s is missing %s\n": "A %s le falta %s\n",
+        "split of energy between fat, protein and carbs to aim for, as percentages like 70/25/5, or keto, zone or high-protein": "reparto de la energía entre grasa, proteína y carbohidratos a buscar, como porcentajes tipo 70/25/5, o keto, zone o high-protein",
+        "percentage points each macro may be off from --macros without penalty": "puntos porcentuales que cada macronutriente puede desviarse de --macros sin penalización",
+        "how much each point a macro is off counts in the score (0 = only report it)": "cuánto cuenta en la puntuación cada punto que se desvía un macronutriente (0 = solo informarlo)",
+        "penalize any one food supplying more than this percentage of a nutrient's min, to hedge against bad data (0 = no limit)": "penalizar que un solo alimento aporte más de este porcentaje del mínimo de un nutriente, como protección frente a datos erróneos (0 = sin límite)",
+        "what a food supplying a whole min more than --max-share allows costs in the score": "lo que cuesta en la puntuación que un alimento aporte un mínimo entero más de lo que permite --max-share",
+        "make this nutrient's target hard, so a recipe outside it is never returned (repeatable)": "hace estricto el objetivo de este nutriente, nunca se devuelve una receta fuera de él (repetible)",
+        "the moves the optimizer tries, comma separated: step (a step of one food) and swap (a step from one food to another)": "los movimientos que prueba el optimizador, separados por comas: step (un paso de un alimento) y swap (un paso de un alimento a otro)",
+        "when the heap grows past this many MB, checkpoint the best recipe so far and restart from it (0 = no cap)": "cuando el heap supera estos MB, guarda la mejor receta hasta ahora y reinicia desde ella (0 = sin límite)",
+        "where --max-heap-mb writes the recipe it restarts from": "dónde --max-heap-mb escribe la receta desde la que reinicia",
+        "what going over a target's max costs: linear, steep (growing with the square of the excess) or hard (never returned)": "lo que cuesta superar el máximo de un objetivo: linear, steep (crece con el cuadrado del exceso) o hard (nunca se devuelve)",
+        "YAML file of derived nutrients, sums of others like \"Niacin + Tryptophan * 1000 / 60\", to use in targets": "archivo YAML de nutrientes derivados, sumas de otros como \"Niacin + Tryptophan * 1000 / 60\", para usar en objetivos",
+        "keep the ratio of two nutrients in a range, like \"Sodium, Na / Potassium, K=:1\" (repeatable)": "mantiene la proporción entre dos nutrientes en un rango, como \"Sodium, Na / Potassium, K=:1\" (repetible)",
+        "penalty per percent a --ratio is outside its range": "penalización por cada por ciento que un --ratio queda fuera de su rango",
+        "score the protein's amino acids together, by the limiting one: pdcaas or diaas, instead of a target each": "puntúa los aminoácidos de la proteína en conjunto, por el limitante: pdcaas o diaas, en vez de un objetivo para cada uno",
+        "penalty per hundredth the protein quality score is under 1": "penalización por cada centésima que la calidad de la proteína queda bajo 1",
+        "keep a nutrient under this percentage of the kcal, like \"Fatty acids, total saturated=10\" (repeatable)": "mantiene un nutriente por debajo de este porcentaje de las kcal, como \"Fatty acids, total saturated=10\" (repetible)",
+        "penalty per percentage point over an --energy-limit": "penalización por cada punto porcentual por encima de un --energy-limit",
+        "limit saturated fat and added sugar to 10% of kcal and trans fat to 1%, as dietary guidelines do": "limita la grasa saturada y el azúcar añadido al 10% de las kcal y la grasa trans al 1%, como las guías alimentarias",
+        "what you've already eaten today, a Cronometer export or a CSV of nutrient,amount, to take off the targets": "lo que ya has comido hoy, una exportación de Cronometer o un CSV de nutriente,cantidad, para descontarlo de los objetivos",
+        "let the recipe have a supplement from \"supershake targets supplements\", as name, name=servings or name=min:max servings (repeatable)": "permite que la receta lleve un suplemento de \"supershake targets supplements\", como nombre, nombre=raciones o nombre=mín:máx raciones (repetible)",
+        "YAML file of supplements to add to the built in ones, in the format \"supershake targets supplements\" prints": "archivo YAML de suplementos que se añaden a los incluidos, en el formato que imprime \"supershake targets supplements\"",
+        "CSV of food_id,nutrient,amount_per_100g,units adding anti-nutrients like oxalate, to give maxes with --target": "CSV de food_id,nutrient,amount_per_100g,units que añade antinutrientes como el oxalato, para ponerles máximos con --target",
+        "penalize a stimulant only past a threshold, as \"Caffeine=200mg:0.2\" for 0.2 per mg over 200 mg (repeatable)": "penalizar un estimulante solo por encima de un umbral, como \"Caffeine=200mg:0.2\" para 0.2 por mg por encima de 200 mg (repetible)",
+        "leave out foods above this NOVA processing class, 4 is ultra-processed (0 = no limit)": "excluir alimentos por encima de esta clase NOVA de procesamiento, 4 es ultraprocesado (0 = sin límite)",
+        "what to do with imputed values: zero, trust, discount:FACTOR or exclude-food": "qué hacer con los valores imputados: zero, trust, discount:FACTOR o exclude-food",
+        "the imputed value policy for one nutrient, as \"Nutrient=policy\" (repeatable)": "la política de valores imputados para un nutriente, como \"Nutriente=política\" (repetible)",
+        "load only the foods the optimizer may use, skipping the snapshot": "cargar solo los alimentos que puede usar el optimizador, sin usar la instantánea",
+        "grams added or removed per optimizer move": "gramos añadidos o quitados en cada paso del optimizador",
+        "optimize against the built in sample data, as \"supershake demo\" does": "optimizar con los datos de ejemplo incorporados, como hace \"supershake demo\"",
+        "write a CPU profile of the run to this file, for go tool pprof": "escribir un perfil de CPU de la ejecución en este archivo, para go tool pprof",
+        "never consider recipes heavier than this many grams (0 = no cap)": "no considerar nunca recetas de más de estos gramos (0 = sin límite)",
+        "rank ingredients by importance, sampling this many orderings (0 = off)": "ordenar los ingredientes por importancia, muestreando este número de órdenes (0 = desactivado)",
+        "cook a food first, as \"foodId=retentionCode[:yield]\" (repeatable)": "cocinar primero un alimento, como \"idAlimento=códigoRetención[:rendimiento]\" (repetible)",
+        "USDA nutrient retention factors for --cook (default retn06.txt in --data-dir)": "factores de retención de nutrientes del USDA para --cook (por defecto retn06.txt en --data-dir)",
+        "show the weight to buy, including refuse like peels and bones": "mostrar el peso a comprar, incluidos los desechos como cáscaras y huesos",
+        "list the studies behind the recipe's nutrient values": "listar los estudios detrás de los valores de nutrientes de la receta",
+        "how to optimize: hill (hill climbing) or lp (linear programming)": "cómo optimizar: hill (búsqueda local) o lp (programación lineal)",
+        "write the problem as a linear program (.lp or .mps) for an external solver, and stop": "escribir el problema como programa lineal (.lp o .mps) para un solver externo, y parar",
+        "rescore the recipe this many times with values drawn within their standard errors (0 = off)": "volver a puntuar la receta tantas veces con valores extraídos dentro de sus errores estándar (0 = desactivado)",
+        "save the optimized recipe as JSON, e.g. for supershake log": "guardar la receta optimizada como JSON, p. ej. para supershake log",
+        "override a target as \"Nutrient=min:max\", units optional (repeatable)": "sustituir un objetivo con \"Nutriente=mín:máx\", unidades opcionales (repetible)",
+        "maximize this nutrient, treating the targets as constraints": "maximizar este nutriente, tratando los objetivos como restricciones",
+        "minimize this nutrient, e.g. \"Energy, kcal\", or cost, treating the targets as constraints": "minimizar este nutriente, p. ej. \"Energy, kcal\", o cost, tratando los objetivos como restricciones",
+        "CSV of food prices, used by --minimize cost": "CSV de precios de alimentos, usado por --minimize cost",
+        "YAML file of targets to use instead of the defaults": "archivo YAML de objetivos a usar en lugar de los predeterminados",
+        "cap energy at this many kcal (0 = no cap)": "limitar la energía a estas kcal (0 = sin límite)",
+        "Unknown command %q\n": "Comando desconocido %q\n",
+        "File not found. Download the USDA SR26 database from:": "Archivo no encontrado. Descarga la base de datos USDA SR26 de:",
+        "Extract it and point --data-dir at the extracted files, or run with --download": "Extráela y apunta --data-dir a los archivos extraídos, o ejecuta con --download",
+        "Download failed:": "La descarga falló:",
+        "Bad --date %q, want YYYY-MM-DD\n": "--date %q no es válido, se espera AAAA-MM-DD\n",
+        "No food with id %d\n": "No hay ningún alimento con id %d\n",
+        "Loading": "Cargando",
+        "Reached local maxima": "Alcanzado un máximo local",
+    },
+    "de": {
+        "directory holding the extracted USDA SR26 files": "Verzeichnis mit den entpackten USDA-SR26-Dateien",
+        "CSV of your own foods to use alongside the dataset": "CSV mit eigenen Lebensmitteln, die zusätzlich zum Datensatz verwendet werden",
+        "directory holding the USDA Branded Food Products CSVs": "Verzeichnis mit den CSV-Dateien der USDA Branded Food Products Database",
+        "another dataset in the SR26 format, e.g. SR Legacy (repeatable)": "ein weiterer Datensatz im SR26-Format, z. B. SR Legacy (mehrfach angebbar)",
+        "which source wins when a food is in several, highest first": "welche Quelle gewinnt, wenn ein Lebensmittel in mehreren vorkommt, höchste zuerst",
+        "also treat foods with the same description as duplicates": "Lebensmittel mit gleicher Beschreibung ebenfalls als Duplikate behandeln",
+        "cache the parsed data in this file and load from it on later runs": "die eingelesenen Daten in dieser Datei zwischenspeichern und bei späteren Läufen daraus laden",
+        "reparse the data files even if the snapshot is up to date": "die Datendateien neu einlesen, auch wenn der Snapshot aktuell ist",
+        "download and extract the dataset into --data-dir if it is missing": "den Datensatz nach --data-dir herunterladen und entpacken, falls er fehlt",
+        "where --download fetches the dataset zip from": "woher --download das ZIP des Datensatzes lädt",
+        "expected sha256 of the downloaded zip, needed unless supershake knows it, or skip to not verify it": "erwarteter SHA-256 des heruntergeladenen ZIPs, nötig, sofern supershake ihn nicht kennt, oder skip, um ihn nicht zu prüfen",
+        "your blender: personal, standard (hard foods need soaking) or high-power": "dein Mixer: personal, standard (harte Lebensmittel müssen eingeweicht werden) oder high-power",
+        "leave out foods needing more than this many minutes of soaking or cooking (0 = no limit)": "Lebensmittel auslassen, die mehr als so viele Minuten Einweichen oder Kochen brauchen (0 = keine Grenze)",
+        "only use foods with this LanguaL facet, e.g. raw, fermented, or a factor description (repeatable)": "nur Lebensmittel mit dieser LanguaL-Facette verwenden, z. B. raw, fermented oder eine Faktorbeschreibung (wiederholbar)",
+        "leave out foods with this LanguaL facet, e.g. animal-derived (repeatable)": "Lebensmittel mit dieser LanguaL-Facette auslassen, z. B. animal-derived (wiederholbar)",
+        "report what each ingredient costs per point of score, with cheaper substitutes": "angeben, was jede Zutat pro Punktzahl kostet, mit günstigeren Alternativen",
+        "write the data validation issues to this file as JSON": "die Probleme der Datenprüfung als JSON in diese Datei schreiben",
+        "leave out foods that fail data validation, e.g. energy that doesn't match the macros": "Lebensmittel auslassen, die die Datenprüfung nicht bestehen, z. B. Energie, die nicht zu den Makros passt",
+        "what --data-dir holds: sr26, or cnf for the Canadian Nutrient File CSVs": "was --data-dir enthält: sr26, oder cnf für die CSV-Dateien des Canadian Nutrient File",
+        "Open Food Facts JSONL dump (optionally gzipped) to take packaged foods from": "JSONL-Dump von Open Food Facts (auch gzip-komprimiert), aus dem verpackte Lebensmittel übernommen werden",
+        "YAML mapping describing a delimited nutrition file to take foods from, e.g. lab results (repeatable)": "YAML-Zuordnung, die eine Nährwertdatei mit Trennzeichen beschreibt, aus der Lebensmittel übernommen werden, z. B. Laborergebnisse (wiederholbar)",
+        "optimize starting from this saved recipe, which becomes the new recipe's parent": "von diesem gespeicherten Rezept aus optimieren, das zum Elternrezept des neuen wird",
+        "file recording every saved recipe and its parent, for supershake history": "Datei, die jedes gespeicherte Rezept und sein Elternrezept festhält, für supershake history",
+        "before optimizing, show the nutrients few of the allowed foods supply and what would help, then stop": "vor dem Optimieren die Nährstoffe zeigen, die nur wenige der erlaubten Lebensmittel liefern, und was helfen würde, dann beenden",
+        "YAML list of foods to keep out of recipes (default: the built in list, unless the file exists)": "YAML-Liste der Lebensmittel, die nicht in Rezepte sollen (Standard: die eingebaute Liste, außer die Datei existiert)",
+        "only use foods this diet allows: vegan, vegetarian, pescatarian, kosher or halal (repeatable)": "nur Lebensmittel verwenden, die diese Ernährungsweise erlaubt: vegan, vegetarian, pescatarian, kosher oder halal (wiederholbar)",
+        "YAML of food id: tags, correcting the tags --diet works out from descriptions": "YAML mit Lebensmittel-ID: Tags, korrigiert die Tags, die --diet aus den Beschreibungen ableitet",
+        "make a recipe that meets the targets with as few ingredients as possible in common with the last this many saved (0 = off)": "ein Rezept erstellen, das die Ziele erfüllt und möglichst wenige Zutaten mit den letzten so vielen gespeicherten gemeinsam hat (0 = aus)",
+        "seed for --surprise, to get the same recipe again (0 = random)": "Startwert für --surprise, um dasselbe Rezept erneut zu erhalten (0 = zufällig)",
+        "file listing the only foods to use, one NDB id or description per line": "Datei mit den einzigen zu verwendenden Lebensmitteln, eine NDB-ID oder Beschreibung pro Zeile",
+        "file holding the passphrase to encrypt the history, intake log and shared recipes with (default $SUPERSHAKE_PASSPHRASE)": "Datei mit der Passphrase, mit der Verlauf, Verzehrprotokoll und geteilte Rezepte verschlüsselt werden (Standard: $SUPERSHAKE_PASSPHRASE)",
+        "never consider recipes with more than this many µg of mercury from fish (0 = no cap)": "nie Rezepte mit mehr als so vielen µg Quecksilber aus Fisch in Betracht ziehen (0 = keine Grenze)",
+        "only use foods from this food group, by SR code or name (repeatable)": "nur Lebensmittel dieser Lebensmittelgruppe verwenden, nach SR-Code oder Name (wiederholbar)",
+        "leave out this food group, by SR code or name, instead of baby foods, breakfast cereals, beverages, fast foods and restaurant foods (repeatable, \"none\" to keep them all)": "diese Lebensmittelgruppe weglassen, nach SR-Code oder Name, statt Babynahrung, Frühstückscerealien, Getränke, Fast Food und Restaurantessen (wiederholbar, \"none\" um alle zu behalten)",
+        "load the foods, report how many each filter leaves, then stop": "Lebensmittel laden, melden, wie viele jeder Filter übrig lässt, dann aufhören",
+        "print a Nutrition Facts panel for the recipe split into this many servings (0 = none)": "eine Nährwerttabelle für das Rezept, aufgeteilt in so viele Portionen, ausgeben (0 = keine)",
+        "how the Nutrition Facts panel rounds: exact, or fda for the FDA's labeling rules": "wie die Nährwerttabelle rundet: exact, oder fda für die Kennzeichnungsregeln der FDA",
+        "file of foods rejected with supershake reject, which recipes never use (\"\" for none)": "Datei der mit supershake reject abgelehnten Lebensmittel, die Rezepte nie verwenden (\"\" für keine)",
+        "CSV of supplement prices, to report which gaps are cheaper to close with a pill than with food": "CSV mit Preisen für Nahrungsergänzungsmittel, um zu zeigen, welche Lücken sich günstiger mit einer Tablette als mit Essen schließen lassen",
+        "also leave out the foods an elimination diet does: low-fodmap, nightshade-free or low-histamine, or change targets for a life stage: pregnancy, lactation, adolescent or older-adult, or a condition: ckd, hypertension or warfarin (repeatable)": "auch die Lebensmittel weglassen, die eine Eliminationsdiät weglässt: low-fodmap, nightshade-free oder low-histamine, oder die Ziele für eine Lebensphase ändern: pregnancy, lactation, adolescent oder older-adult, oder für eine Erkrankung: ckd, hypertension oder warfarin (wiederholbar)",
+        "leave out foods that cost more than this per kg as purchased, by --prices (0 = no limit)": "Lebensmittel weglassen, die pro kg im Einkauf mehr als dies kosten, laut --prices (0 = keine Grenze)",
+        "never consider recipes that cost more than this, by --prices (0 = no cap)": "nie Rezepte in Betracht ziehen, die mehr als dies kosten, laut --prices (0 = keine Obergrenze)",
+        "male or female, to work the targets out from the Dietary Reference Intakes with --age, --weight-kg and --height-cm": "male oder female, um die Ziele aus den Referenzwerten für die Nährstoffzufuhr mit --age, --weight-kg und --height-cm zu berechnen",
+        "age in years, for the DRI targets": "Alter in Jahren, für die DRI-Ziele",
+        "body weight in kg, for the DRI targets and per kg ones like \"1.6 g/kg\"": "Körpergewicht in kg, für die DRI-Ziele und solche pro kg wie \"1.6 g/kg\"",
+        "height in cm, for the DRI targets": "Körpergröße in cm, für die DRI-Ziele",
+        "activity level for the DRI energy target: sedentary, light, moderate, active or very-active": "Aktivitätsniveau für das DRI-Energieziel: sedentary, light, moderate, active oder very-active",
+        "protein per kg of body weight for the DRI targets, 0.8 is the RDA": "Protein pro kg Körpergewicht für die DRI-Ziele, 0.8 ist die RDA",
+        "dietary pattern to report compliance with, like eat-lancet": "Ernährungsmuster, dessen Einhaltung berichtet wird, etwa eat-lancet",
+        "YAML file of more dietary patterns, in the format of patterns.yaml": "YAML-Datei mit weiteren Ernährungsmustern, im Format von patterns.yaml",
+        "optimize within the --pattern too, each food group out of its range counting this many times a missed target (0 = only report it)": "auch innerhalb des --pattern optimieren, jede Lebensmittelgruppe außerhalb ihres Bereichs zählt so oft wie ein verfehltes Ziel (0 = nur berichten)",
+        "YAML file overriding or adding to the targets, a field at a time, in the format \"supershake targets defaults\" prints": "YAML-Datei, die Ziele feldweise ändert oder ergänzt, im Format, das \"supershake targets defaults\" ausgibt",
+        "record every step the optimizer tries to this gzipped JSON lines file, for \"supershake replay\"": "jeden Schritt, den der Optimierer versucht, in dieser gzip-komprimierten JSON-Lines-Datei aufzeichnen, für \"supershake replay\"",
+        "use the flags of this profile from the --profiles file, like me or kid": "die Optionen dieses Profils aus der --profiles-Datei verwenden, etwa me oder kid",
+        "YAML file of named profiles, each a set of flags for one person": "YAML-Datei mit benannten Profilen, jedes ein Satz Optionen für eine Person",
+        "Couldn't find the USDA SR26 files. Download them from:": "Die USDA-SR26-Dateien wurden nicht gefunden. Lade sie herunter von:",
+        "Folder or zip holding them (drag it here, or leave blank to stop): ": "Ordner oder Zip-Datei mit den Dateien (hierher ziehen, oder leer lassen zum Beenden): ",
+        "Found them. Next time pass --data-dir %q, or put them in %s\n": "Gefunden. Gib beim nächsten Mal --data-dir %q an, oder lege sie in %s ab\n",
+        "%s is missing %s\n": "In %s fehlt %s\n",
+        "split of energy between fat, protein and carbs to aim for, as percentages like 70/25/5, or keto, zone or high-protein": "angestrebte Aufteilung der Energie auf Fett, Protein und Kohlenhydrate, als Prozente wie 70/25/5, oder keto, zone oder high-protein",
+        "percentage points each macro may be off from --macros without penalty": "Prozentpunkte, um die jeder Makronährstoff ohne Strafe von --macros abweichen darf",
+        "how much each point a macro is off counts in the score (0 = only report it)": "wie stark jeder Punkt Abweichung eines Makronährstoffs in die Bewertung eingeht (0 = nur melden)",
+        "penalize any one food supplying more than this percentage of a nutrient's min, to hedge against bad data (0 = no limit)": "bestrafen, wenn ein einzelnes Lebensmittel mehr als diesen Prozentsatz des Minimums eines Nährstoffs liefert, zur Absicherung gegen fehlerhafte Daten (0 = keine Grenze)",
+        "what a food supplying a whole min more than --max-share allows costs in the score": "was es in der Bewertung kostet, wenn ein Lebensmittel ein ganzes Minimum mehr liefert, als --max-share erlaubt",
+        "make this nutrient's target hard, so a recipe outside it is never returned (repeatable)": "macht das Ziel dieses Nährstoffs hart, ein Rezept außerhalb davon wird nie ausgegeben (wiederholbar)",
+        "the moves the optimizer tries, comma separated: step (a step of one food) and swap (a step from one food to another)": "die Züge, die der Optimierer probiert, durch Kommas getrennt: step (ein Schritt eines Lebensmittels) und swap (ein Schritt von einem Lebensmittel zu einem anderen)",
+        "when the heap grows past this many MB, checkpoint the best recipe so far and restart from it (0 = no cap)": "wenn der Heap über so viele MB wächst, das bisher beste Rezept sichern und von dort neu starten (0 = keine Grenze)",
+        "where --max-heap-mb writes the recipe it restarts from": "wohin --max-heap-mb das Rezept schreibt, von dem es neu startet",
+        "what going over a target's max costs: linear, steep (growing with the square of the excess) or hard (never returned)": "was das Überschreiten des Maximums eines Ziels kostet: linear, steep (wächst mit dem Quadrat des Überschusses) oder hard (nie ausgegeben)",
+        "YAML file of derived nutrients, sums of others like \"Niacin + Tryptophan * 1000 / 60\", to use in targets": "YAML-Datei abgeleiteter Nährstoffe, Summen anderer wie \"Niacin + Tryptophan * 1000 / 60\", für Ziele",
+        "keep the ratio of two nutrients in a range, like \"Sodium, Na / Potassium, K=:1\" (repeatable)": "hält das Verhältnis zweier Nährstoffe in einem Bereich, wie \"Sodium, Na / Potassium, K=:1\" (wiederholbar)",
+        "penalty per percent a --ratio is outside its range": "Strafe pro Prozent, das ein --ratio außerhalb seines Bereichs liegt",
+        "score the protein's amino acids together, by the limiting one: pdcaas or diaas, instead of a target each": "bewertet die Aminosäuren des Proteins gemeinsam, nach der limitierenden: pdcaas oder diaas, statt je eines Ziels",
+        "penalty per hundredth the protein quality score is under 1": "Strafe pro Hundertstel, das die Proteinqualität unter 1 liegt",
+        "keep a nutrient under this percentage of the kcal, like \"Fatty acids, total saturated=10\" (repeatable)": "hält einen Nährstoff unter diesem Anteil der kcal, wie \"Fatty acids, total saturated=10\" (wiederholbar)",
+        "penalty per percentage point over an --energy-limit": "Strafe pro Prozentpunkt über einem --energy-limit",
+        "limit saturated fat and added sugar to 10% of kcal and trans fat to 1%, as dietary guidelines do": "begrenzt gesättigte Fette und zugesetzten Zucker auf 10% der kcal und Transfette auf 1%, wie Ernährungsrichtlinien",
+        "what you've already eaten today, a Cronometer export or a CSV of nutrient,amount, to take off the targets": "was du heute schon gegessen hast, ein Cronometer-Export oder eine CSV mit Nährstoff,Menge, wird von den Zielen abgezogen",
+        "let the recipe have a supplement from \"supershake targets supplements\", as name, name=servings or name=min:max servings (repeatable)": "erlaubt dem Rezept ein Nahrungsergänzungsmittel aus \"supershake targets supplements\", als Name, Name=Portionen oder Name=min:max Portionen (wiederholbar)",
+        "YAML file of supplements to add to the built in ones, in the format \"supershake targets supplements\" prints": "YAML-Datei mit Nahrungsergänzungsmitteln zusätzlich zu den eingebauten, im Format, das \"supershake targets supplements\" ausgibt",
+        "CSV of food_id,nutrient,amount_per_100g,units adding anti-nutrients like oxalate, to give maxes with --target": "CSV mit food_id,nutrient,amount_per_100g,units, das Antinährstoffe wie Oxalat hinzufügt, um ihnen mit --target Höchstwerte zu geben",
+        "penalize a stimulant only past a threshold, as \"Caffeine=200mg:0.2\" for 0.2 per mg over 200 mg (repeatable)": "ein Stimulans erst ab einer Schwelle bestrafen, wie \"Caffeine=200mg:0.2\" für 0.2 pro mg über 200 mg (wiederholbar)",
+        "leave out foods above this NOVA processing class, 4 is ultra-processed (0 = no limit)": "Lebensmittel über dieser NOVA-Verarbeitungsstufe auslassen, 4 ist hochverarbeitet (0 = keine Grenze)",
+        "what to do with imputed values: zero, trust, discount:FACTOR or exclude-food": "was mit imputierten Werten geschieht: zero, trust, discount:FAKTOR oder exclude-food",
+        "the imputed value policy for one nutrient, as \"Nutrient=policy\" (repeatable)": "die Regel für imputierte Werte eines Nährstoffs, als \"Nährstoff=Regel\" (mehrfach angebbar)",
+        "load only the foods the optimizer may use, skipping the snapshot": "nur die Lebensmittel laden, die der Optimierer verwenden darf, ohne Snapshot",
+        "grams added or removed per optimizer move": "Gramm, die pro Optimierungsschritt hinzugefügt oder entfernt werden",
+        "optimize against the built in sample data, as \"supershake demo\" does": "mit den eingebauten Beispieldaten optimieren, wie es \"supershake demo\" tut",
+        "write a CPU profile of the run to this file, for go tool pprof": "ein CPU-Profil des Laufs in diese Datei schreiben, für go tool pprof",
+        "never consider recipes heavier than this many grams (0 = no cap)": "nie Rezepte schwerer als so viele Gramm betrachten (0 = keine Grenze)",
+        "rank ingredients by importance, sampling this many orderings (0 = off)": "Zutaten nach Wichtigkeit ordnen, mit so vielen Stichproben-Reihenfolgen (0 = aus)",
+        "cook a food first, as \"foodId=retentionCode[:yield]\" (repeatable)": "ein Lebensmittel zuerst garen, als \"LebensmittelID=Retentionscode[:Ausbeute]\" (mehrfach angebbar)",
+        "USDA nutrient retention factors for --cook (default retn06.txt in --data-dir)": "USDA-Nährstoff-Retentionsfaktoren für --cook (Standard: retn06.txt in --data-dir)",
+        "show the weight to buy, including refuse like peels and bones": "das Einkaufsgewicht anzeigen, inklusive Abfall wie Schalen und Knochen",
+        "list the studies behind the recipe's nutrient values": "die Studien hinter den Nährwerten des Rezepts auflisten",
+        "how to optimize: hill (hill climbing) or lp (linear programming)": "wie optimiert wird: hill (Bergsteigen) oder lp (lineare Optimierung)",
+        "write the problem as a linear program (.lp or .mps) for an external solver, and stop": "das Problem als lineares Programm (.lp oder .mps) für einen externen Solver schreiben und beenden",
+        "rescore the recipe this many times with values drawn within their standard errors (0 = off)": "das Rezept so oft mit Werten innerhalb ihrer Standardfehler neu bewerten (0 = aus)",
+        "save the optimized recipe as JSON, e.g. for supershake log": "das optimierte Rezept als JSON speichern, z. B. für supershake log",
+        "override a target as \"Nutrient=min:max\", units optional (repeatable)": "ein Ziel als \"Nährstoff=min:max\" überschreiben, Einheiten optional (mehrfach angebbar)",
+        "maximize this nutrient, treating the targets as constraints": "diesen Nährstoff maximieren, mit den Zielen als Nebenbedingungen",
+        "minimize this nutrient, e.g. \"Energy, kcal\", or cost, treating the targets as constraints": "diesen Nährstoff minimieren, z. B. \"Energy, kcal\", oder cost, mit den Zielen als Nebenbedingungen",
+        "CSV of food prices, used by --minimize cost": "CSV mit Lebensmittelpreisen, für --minimize cost",
+        "YAML file of targets to use instead of the defaults": "YAML-Datei mit Zielen, die statt der Standardwerte gelten",
+        "cap energy at this many kcal (0 = no cap)": "Energie auf so viele kcal begrenzen (0 = keine Grenze)",
+        "Unknown command %q\n": "Unbekannter Befehl %q\n",
+        "File not found. Download the USDA SR26 database from:": "Datei nicht gefunden. Lade die USDA-SR26-Datenbank herunter von:",
+        "Extract it and point --data-dir at the extracted files, or run with --download": "Entpacke sie und richte --data-dir auf die entpackten Dateien, oder starte mit --download",
+        "Download failed:": "Download fehlgeschlagen:",
+        "Bad --date %q, want YYYY-MM-DD\n": "Ungültiges --date %q, erwartet JJJJ-MM-TT\n",
+        "No food with id %d\n": "Kein Lebensmittel mit der ID %d\n",
+        "Loading": "Lade",
+        "Reached local maxima": "Lokales Maximum erreicht",
+    },
+    "fr": {
+        "directory holding the extracted USDA SR26 files": "répertoire contenant les fichiers USDA SR26 extraits",
+        "CSV of your own foods to use alongside the dataset": "CSV de vos propres aliments à utiliser en plus du jeu de données",
+        "directory holding the USDA Branded Food Products CSVs": "répertoire contenant les CSV de la base USDA Branded Food Products",
+        "another dataset in the SR26 format, e.g. SR Legacy (repeatable)": "un autre jeu de données au format SR26, p. ex. SR Legacy (répétable)",
+        "which source wins when a food is in several, highest first": "quelle source l'emporte quand un aliment figure dans plusieurs, la plus prioritaire d'abord",
+        "also treat foods with the same description as duplicates": "considérer aussi comme doublons les aliments de même description",
+        "cache the parsed data in this file and load from it on later runs": "mettre en cache les données lues dans ce fichier et les y recharger lors des exécutions suivantes",
+        "reparse the data files even if the snapshot is up to date": "relire les fichiers de données même si l'instantané est à jour",
+        "download and extract the dataset into --data-dir if it is missing": "télécharger et extraire le jeu de données dans --data-dir s'il manque",
+        "where --download fetches the dataset zip from": "d'où --download télécharge le zip du jeu de données",
+        "expected sha256 of the downloaded zip, needed unless supershake knows it, or skip to not verify it": "sha256 attendu du zip téléchargé, nécessaire sauf si supershake le connaît, ou skip pour ne pas le vérifier",
+        "your blender: personal, standard (hard foods need soaking) or high-power": "votre blender : personal, standard (les aliments durs doivent tremper) ou high-power",
+        "leave out foods needing more than this many minutes of soaking or cooking (0 = no limit)": "exclure les aliments demandant plus de ce nombre de minutes de trempage ou de cuisson (0 = pas de limite)",
+        "only use foods with this LanguaL facet, e.g. raw, fermented, or a factor description (repeatable)": "n'utiliser que les aliments ayant cette facette LanguaL, p. ex. raw, fermented, ou une description de facteur (répétable)",
+        "leave out foods with this LanguaL facet, e.g. animal-derived (repeatable)": "exclure les aliments ayant cette facette LanguaL, p. ex. animal-derived (répétable)",
+        "report what each ingredient costs per point of score, with cheaper substitutes": "indiquer le coût de chaque ingrédient par point de score, avec des substituts moins chers",
+        "write the data validation issues to this file as JSON": "écrire les problèmes de validation des données dans ce fichier en JSON",
+        "leave out foods that fail data validation, e.g. energy that doesn't match the macros": "exclure les aliments qui échouent à la validation des données, p. ex. une énergie qui ne correspond pas aux macros",
+        "what --data-dir holds: sr26, or cnf for the Canadian Nutrient File CSVs": "ce que contient --data-dir : sr26, ou cnf pour les CSV du Fichier canadien sur les éléments nutritifs",
+        "Open Food Facts JSONL dump (optionally gzipped) to take packaged foods from": "export JSONL d'Open Food Facts (éventuellement compressé en gzip) d'où prendre des produits emballés",
+        "YAML mapping describing a delimited nutrition file to take foods from, e.g. lab results (repeatable)": "correspondance YAML décrivant un fichier nutritionnel délimité d'où prendre des aliments, p. ex. des résultats de laboratoire (répétable)",
+        "optimize starting from this saved recipe, which becomes the new recipe's parent": "optimiser à partir de cette recette enregistrée, qui devient la recette parente de la nouvelle",
+        "file recording every saved recipe and its parent, for supershake history": "fichier qui enregistre chaque recette sauvegardée et sa recette parente, pour supershake history",
+        "before optimizing, show the nutrients few of the allowed foods supply and what would help, then stop": "avant d'optimiser, montrer les nutriments que peu des aliments autorisés apportent et ce qui aiderait, puis s'arrêter",
+        "YAML list of foods to keep out of recipes (default: the built in list, unless the file exists)": "liste YAML des aliments à écarter des recettes (par défaut : la liste intégrée, sauf si le fichier existe)",
+        "only use foods this diet allows: vegan, vegetarian, pescatarian, kosher or halal (repeatable)": "n'utiliser que les aliments permis par ce régime : vegan, vegetarian, pescatarian, kosher ou halal (répétable)",
+        "YAML of food id: tags, correcting the tags --diet works out from descriptions": "YAML id d'aliment : étiquettes, corrigeant les étiquettes que --diet déduit des descriptions",
+        "make a recipe that meets the targets with as few ingredients as possible in common with the last this many saved (0 = off)": "créer une recette qui atteint les objectifs avec le moins d'ingrédients possible en commun avec les dernières recettes enregistrées, en ce nombre (0 = désactivé)",
+        "seed for --surprise, to get the same recipe again (0 = random)": "graine pour --surprise, pour obtenir à nouveau la même recette (0 = aléatoire)",
+        "file listing the only foods to use, one NDB id or description per line": "fichier listant les seuls aliments à utiliser, un id NDB ou une description par ligne",
+        "file holding the passphrase to encrypt the history, intake log and shared recipes with (default $SUPERSHAKE_PASSPHRASE)": "fichier contenant la phrase secrète pour chiffrer l'historique, le journal de consommation et les recettes partagées (par défaut $SUPERSHAKE_PASSPHRASE)",
+        "never consider recipes with more than this many µg of mercury from fish (0 = no cap)": "ne jamais considérer de recettes avec plus de ce nombre de µg de mercure provenant du poisson (0 = pas de limite)",
+        "only use foods from this food group, by SR code or name (repeatable)": "n'utiliser que les aliments de ce groupe, par code ou nom SR (répétable)",
+        "leave out this food group, by SR code or name, instead of baby foods, breakfast cereals, beverages, fast foods and restaurant foods (repeatable, \"none\" to keep them all)": "exclure ce groupe d'aliments, par code ou nom SR, au lieu des aliments pour bébés, céréales du petit-déjeuner, boissons, restauration rapide et plats de restaurant (répétable, \"none\" pour tous les garder)",
+        "load the foods, report how many each filter leaves, then stop": "charger les aliments, indiquer combien chaque filtre en laisse, puis s'arrêter",
+        "print a Nutrition Facts panel for the recipe split into this many servings (0 = none)": "afficher un tableau de valeurs nutritionnelles pour la recette divisée en ce nombre de portions (0 = aucun)",
+        "how the Nutrition Facts panel rounds: exact, or fda for the FDA's labeling rules": "comment le tableau nutritionnel arrondit : exact, ou fda pour les règles d'étiquetage de la FDA",
+        "file of foods rejected with supershake reject, which recipes never use (\"\" for none)": "fichier des aliments rejetés avec supershake reject, que les recettes n'utilisent jamais (\"\" pour aucun)",
+        "CSV of supplement prices, to report which gaps are cheaper to close with a pill than with food": "CSV des prix des compléments, pour indiquer quels manques il est moins cher de combler par un comprimé que par des aliments",
+        "also leave out the foods an elimination diet does: low-fodmap, nightshade-free or low-histamine, or change targets for a life stage: pregnancy, lactation, adolescent or older-adult, or a condition: ckd, hypertension or warfarin (repeatable)": "exclure aussi les aliments qu'exclut un régime d'éviction : low-fodmap, nightshade-free ou low-histamine, ou changer les objectifs pour une étape de la vie : pregnancy, lactation, adolescent ou older-adult, ou pour une affection : ckd, hypertension ou warfarin (répétable)",
+        "leave out foods that cost more than this per kg as purchased, by --prices (0 = no limit)": "exclure les aliments qui coûtent plus que ceci par kg à l'achat, selon --prices (0 = pas de limite)",
+        "never consider recipes that cost more than this, by --prices (0 = no cap)": "ne jamais envisager de recettes coûtant plus que ceci, selon --prices (0 = pas de plafond)",
+        "male or female, to work the targets out from the Dietary Reference Intakes with --age, --weight-kg and --height-cm": "male ou female, pour calculer les objectifs à partir des apports nutritionnels de référence avec --age, --weight-kg et --height-cm",
+        "age in years, for the DRI targets": "âge en années, pour les objectifs DRI",
+        "body weight in kg, for the DRI targets and per kg ones like \"1.6 g/kg\"": "poids corporel en kg, pour les objectifs DRI et ceux par kg comme \"1.6 g/kg\"",
+        "height in cm, for the DRI targets": "taille en cm, pour les objectifs DRI",
+        "activity level for the DRI energy target: sedentary, light, moderate, active or very-active": "niveau d'activité pour l'objectif énergétique DRI : sedentary, light, moderate, active ou very-active",
+        "protein per kg of body weight for the DRI targets, 0.8 is the RDA": "protéines par kg de poids corporel pour les objectifs DRI, 0.8 est l'ANR",
+        "dietary pattern to report compliance with, like eat-lancet": "modèle alimentaire dont rapporter le respect, comme eat-lancet",
+        "YAML file of more dietary patterns, in the format of patterns.yaml": "fichier YAML d'autres modèles alimentaires, au format de patterns.yaml",
+        "optimize within the --pattern too, each food group out of its range counting this many times a missed target (0 = only report it)": "optimiser aussi dans le --pattern, chaque groupe d'aliments hors de sa plage compte autant de fois qu'un objectif manqué (0 = seulement le rapporter)",
+        "YAML file overriding or adding to the targets, a field at a time, in the format \"supershake targets defaults\" prints": "fichier YAML qui modifie ou ajoute des objectifs, champ par champ, au format qu'affiche \"supershake targets defaults\"",
+        "record every step the optimizer tries to this gzipped JSON lines file, for \"supershake replay\"": "enregistrer chaque pas essayé par l'optimiseur dans ce fichier JSON lines compressé en gzip, pour \"supershake replay\"",
+        "use the flags of this profile from the --profiles file, like me or kid": "utiliser les options de ce profil du fichier --profiles, comme me ou kid",
+        "YAML file of named profiles, each a set of flags for one person": "fichier YAML de profils nommés, chacun un ensemble d'options pour une personne",
+        "Couldn't find the USDA SR26 files. Download them from:": "Fichiers USDA SR26 introuvables. Téléchargez-les depuis :",
+        "Folder or zip holding them (drag it here, or leave blank to stop): ": "Dossier ou zip qui les contient (glissez-le ici, ou laissez vide pour arrêter) : ",
+        "Found them. Next time pass --data-dir %q, or put them in %s\n": "Trouvés. La prochaine fois, passez --data-dir %q, ou placez-les dans %s\n",
+        "%s is missing %s\n": "Il manque %[2]s dans %[1]s\n",
+        "split of energy between fat, protein and carbs to aim for, as percentages like 70/25/5, or keto, zone or high-protein": "répartition de l'énergie entre lipides, protéines et glucides à viser, en pourcentages comme 70/25/5, ou keto, zone ou high-protein",
+        "percentage points each macro may be off from --macros without penalty": "points de pourcentage dont chaque macronutriment peut s'écarter de --macros sans pénalité",
+        "how much each point a macro is off counts in the score (0 = only report it)": "combien compte dans le score chaque point d'écart d'un macronutriment (0 = seulement le signaler)",
+        "penalize any one food supplying more than this percentage of a nutrient's min, to hedge against bad data (0 = no limit)": "pénaliser un aliment qui apporte à lui seul plus de ce pourcentage du minimum d'un nutriment, pour se prémunir contre des données erronées (0 = pas de limite)",
+        "what a food supplying a whole min more than --max-share allows costs in the score": "ce que coûte dans le score un aliment qui apporte un minimum entier de plus que ne le permet --max-share",
+        "make this nutrient's target hard, so a recipe outside it is never returned (repeatable)": "rend strict l'objectif de ce nutriment, une recette hors de celui-ci n'est jamais renvoyée (répétable)",
+        "the moves the optimizer tries, comma separated: step (a step of one food) and swap (a step from one food to another)": "les mouvements que l'optimiseur essaie, séparés par des virgules : step (un pas d'un aliment) et swap (un pas d'un aliment vers un autre)",
+        "when the heap grows past this many MB, checkpoint the best recipe so far and restart from it (0 = no cap)": "quand le tas dépasse ce nombre de Mo, sauvegarde la meilleure recette jusqu'ici et redémarre depuis elle (0 = pas de limite)",
+        "where --max-heap-mb writes the recipe it restarts from": "où --max-heap-mb écrit la recette depuis laquelle il redémarre",
+        "what going over a target's max costs: linear, steep (growing with the square of the excess) or hard (never returned)": "ce que coûte le dépassement du maximum d'un objectif : linear, steep (croît avec le carré de l'excès) ou hard (jamais renvoyée)",
+        "YAML file of derived nutrients, sums of others like \"Niacin + Tryptophan * 1000 / 60\", to use in targets": "fichier YAML de nutriments dérivés, sommes d'autres comme \"Niacin + Tryptophan * 1000 / 60\", à utiliser dans les objectifs",
+        "keep the ratio of two nutrients in a range, like \"Sodium, Na / Potassium, K=:1\" (repeatable)": "garde le rapport entre deux nutriments dans une plage, comme \"Sodium, Na / Potassium, K=:1\" (répétable)",
+        "penalty per percent a --ratio is outside its range": "pénalité par pour cent qu'un --ratio est hors de sa plage",
+        "score the protein's amino acids together, by the limiting one: pdcaas or diaas, instead of a target each": "note les acides aminés de la protéine ensemble, selon le limitant : pdcaas ou diaas, au lieu d'un objectif chacun",
+        "penalty per hundredth the protein quality score is under 1": "pénalité par centième où la qualité des protéines est sous 1",
+        "keep a nutrient under this percentage of the kcal, like \"Fatty acids, total saturated=10\" (repeatable)": "garde un nutriment sous ce pourcentage des kcal, comme \"Fatty acids, total saturated=10\" (répétable)",
+        "penalty per percentage point over an --energy-limit": "pénalité par point de pourcentage au-dessus d'un --energy-limit",
+        "limit saturated fat and added sugar to 10% of kcal and trans fat to 1%, as dietary guidelines do": "limite les graisses saturées et le sucre ajouté à 10% des kcal et les graisses trans à 1%, comme les recommandations alimentaires",
+        "what you've already eaten today, a Cronometer export or a CSV of nutrient,amount, to take off the targets": "ce que vous avez déjà mangé aujourd'hui, un export Cronometer ou un CSV nutriment,quantité, à déduire des objectifs",
+        "let the recipe have a supplement from \"supershake targets supplements\", as name, name=servings or name=min:max servings (repeatable)": "autorise la recette à inclure un complément de \"supershake targets supplements\", sous la forme nom, nom=portions ou nom=min:max portions (répétable)",
+        "YAML file of supplements to add to the built in ones, in the format \"supershake targets supplements\" prints": "fichier YAML de compléments à ajouter à ceux intégrés, au format qu’affiche \"supershake targets supplements\"",
+        "CSV of food_id,nutrient,amount_per_100g,units adding anti-nutrients like oxalate, to give maxes with --target": "CSV food_id,nutrient,amount_per_100g,units ajoutant des antinutriments comme l’oxalate, pour leur fixer des maximums avec --target",
+        "penalize a stimulant only past a threshold, as \"Caffeine=200mg:0.2\" for 0.2 per mg over 200 mg (repeatable)": "pénaliser un stimulant seulement au-delà d’un seuil, comme \"Caffeine=200mg:0.2\" pour 0.2 par mg au-delà de 200 mg (répétable)",
+        "leave out foods above this NOVA processing class, 4 is ultra-processed (0 = no limit)": "exclure les aliments au-dessus de cette classe NOVA de transformation, 4 est ultra-transformé (0 = pas de limite)",
+        "what to do with imputed values: zero, trust, discount:FACTOR or exclude-food": "que faire des valeurs imputées : zero, trust, discount:FACTEUR ou exclude-food",
+        "the imputed value policy for one nutrient, as \"Nutrient=policy\" (repeatable)": "la règle des valeurs imputées pour un nutriment, sous la forme \"Nutriment=règle\" (répétable)",
+        "load only the foods the optimizer may use, skipping the snapshot": "charger uniquement les aliments que l'optimiseur peut utiliser, sans l'instantané",
+        "grams added or removed per optimizer move": "grammes ajoutés ou retirés à chaque pas de l'optimiseur",
+        "optimize against the built in sample data, as \"supershake demo\" does": "optimiser avec les données d'exemple intégrées, comme le fait \"supershake demo\"",
+        "write a CPU profile of the run to this file, for go tool pprof": "écrire un profil CPU de l'exécution dans ce fichier, pour go tool pprof",
+        "never consider recipes heavier than this many grams (0 = no cap)": "ne jamais envisager de recettes de plus de ce nombre de grammes (0 = sans limite)",
+        "rank ingredients by importance, sampling this many orderings (0 = off)": "classer les ingrédients par importance en échantillonnant ce nombre d'ordres (0 = désactivé)",
+        "cook a food first, as \"foodId=retentionCode[:yield]\" (repeatable)": "cuire d'abord un aliment, sous la forme \"idAliment=codeRétention[:rendement]\" (répétable)",
+        "USDA nutrient retention factors for --cook (default retn06.txt in --data-dir)": "facteurs de rétention des nutriments de l'USDA pour --cook (par défaut retn06.txt dans --data-dir)",
+        "show the weight to buy, including refuse like peels and bones": "afficher le poids à acheter, déchets comme les épluchures et les os compris",
+        "list the studies behind the recipe's nutrient values": "lister les études derrière les valeurs nutritionnelles de la recette",
+        "how to optimize: hill (hill climbing) or lp (linear programming)": "comment optimiser : hill (recherche locale) ou lp (programmation linéaire)",
+        "write the problem as a linear program (.lp or .mps) for an external solver, and stop": "écrire le problème sous forme de programme linéaire (.lp ou .mps) pour un solveur externe, puis s'arrêter",
+        "rescore the recipe this many times with values drawn within their standard errors (0 = off)": "réévaluer la recette autant de fois avec des valeurs tirées dans leurs erreurs types (0 = désactivé)",
+        "save the optimized recipe as JSON, e.g. for supershake log": "enregistrer la recette optimisée en JSON, p. ex. pour supershake log",
+        "override a target as \"Nutrient=min:max\", units optional (repeatable)": "remplacer un objectif par \"Nutriment=min:max\", unités facultatives (répétable)",
+        "maximize this nutrient, treating the targets as constraints": "maximiser ce nutriment, en traitant les objectifs comme des contraintes",
+        "minimize this nutrient, e.g. \"Energy, kcal\", or cost, treating the targets as constraints": "minimiser ce nutriment, p. ex. \"Energy, kcal\", ou cost, en traitant les objectifs comme des contraintes",
+        "CSV of food prices, used by --minimize cost": "CSV des prix des aliments, utilisé par --minimize cost",
+        "YAML file of targets to use instead of the defaults": "fichier YAML d'objectifs à utiliser à la place des valeurs par défaut",
+        "cap energy at this many kcal (0 = no cap)": "plafonner l'énergie à ce nombre de kcal (0 = pas de plafond)",
+        "Unknown command %q\n": "Commande inconnue %q\n",
+        "File not found. Download the USDA SR26 database from:": "Fichier introuvable. Téléchargez la base de données USDA SR26 depuis :",
+        "Extract it and point --data-dir at the extracted files, or run with --download": "Extrayez-la et faites pointer --data-dir vers les fichiers extraits, ou lancez avec --download",
+        "Download failed:": "Échec du téléchargement :",
+        "Bad --date %q, want YYYY-MM-DD\n": "--date %q invalide, format attendu AAAA-MM-JJ\n",
+        "No food with id %d\n": "Aucun aliment avec l'id %d\n",
+        "Loading": "Chargement",
+        "Reached local maxima": "Maximum local atteint",
+    },
+}
+
+// detectLanguage picks the language from SUPERSHAKE_LANG, then the usual
+// locale variables, so "es_ES.UTF-8" means Spanish.
+func detectLanguage() string {
+    for _, name := range []string{"SUPERSHAKE_LANG", "LC_ALL", "LC_MESSAGES", "LANG"} {
+        value := os.Getenv(name)
+        if value == "" {
+            continue
+        }
+        language := strings.ToLower(value)
+        if i := strings.IndexAny(language, "_.@-"); i >= 0 {
+            language = language[:i]
+        }
+        return language
+    }
+    return "en"
+}
+
+var language = detectLanguage()
+
+// tr translates a message into the user's language, if there's a
+// translation for it.
+func tr(message string) string {
+    if translated, exists := translations[language][message]; exists {
+        return translated
+    }
+    return message
+}
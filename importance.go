@@ -0,0 +1,62 @@
+package main
+
+import (
+    "fmt"
+    "math/rand"
+    "sort"
+)
+
+// shapleyValues estimates how much each ingredient of recipe contributes to
+// its score. Ingredients are added to an empty recipe in a random order and
+// each one is credited with the score improvement it made; averaged over
+// enough orders that's the Shapley value. The values add up to the
+// difference between the empty recipe's score and this one's.
+func shapleyValues(problem *Problem, recipe *Recipe, samples int, rng *rand.Rand) map[int]float64 {
+    foodIds := recipe.FoodIdsByGrams()
+    values := make(map[int]float64, len(foodIds))
+    if samples <= 0 {
+        return values
+    }
+
+    for sample := 0; sample < samples; sample++ {
+        partial := NewRecipe(problem.foods, problem.nutrients)
+        previousScore := partial.Score(problem, false)
+        for _, i := range rng.Perm(len(foodIds)) {
+            food := problem.foods[foodIds[i]]
+            partial.AddFood(problem.foods, &food, recipe.foodQuantities[food.id])
+            score := partial.Score(problem, false)
+            values[food.id] += previousScore - score
+            previousScore = score
+        }
+    }
+
+    for foodId := range values {
+        values[foodId] /= float64(samples)
+    }
+    return values
+}
+
+// printImportance ranks the ingredients of recipe by Shapley value, most
+// important first.
+func printImportance(problem *Problem, recipe *Recipe, samples int) {
+    values := shapleyValues(problem, recipe, samples, rand.New(rand.NewSource(1)))
+    foodIds := recipe.FoodIdsByGrams()
+    sort.SliceStable(foodIds, func(i, j int) bool {
+        return values[foodIds[i]] > values[foodIds[j]]
+    })
+
+    total := float64(0)
+    for _, value := range values {
+        total += value
+    }
+
+    fmt.Printf("INGREDIENT IMPORTANCE (approximate Shapley values over %d orderings)\n", samples)
+    for _, foodId := range foodIds {
+        share := float64(0)
+        if total != 0 {
+            share = values[foodId] / total * 100
+        }
+        food := problem.foods[foodId]
+        fmt.Printf("%8.2f %5.1f%%  %s of %s\n", values[foodId], share, food.gramsString(recipe.foodQuantities[foodId]), food.description)
+    }
+}
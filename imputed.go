@@ -0,0 +1,151 @@
+package main
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+)
+
+// SR26 values with no data points behind them were calculated or imputed
+// rather than analysed. Some are way off: heart of palm, raw versus heart of
+// palm, canned differ 10x in potassium. What to do with them is a policy,
+// set for every nutrient with --imputed and for single nutrients with
+// --imputed-for:
+//
+//     zero          count them as 0, the original behaviour and the default
+//     trust         use them as they are
+//     discount:0.5  use them scaled by a factor
+//     exclude-food  leave out every food with one
+
+type imputedPolicy struct {
+    kind string // "zero", "trust", "discount" or "exclude-food"
+    factor float64 // for discount
+}
+
+func parseImputedPolicy(text string) (imputedPolicy, error) {
+    text = strings.TrimSpace(text)
+    switch text {
+    case "zero", "trust", "exclude-food":
+        return imputedPolicy{kind: text}, nil
+    }
+    if strings.HasPrefix(text, "discount:") {
+        factor, err := strconv.ParseFloat(strings.TrimPrefix(text, "discount:"), 64)
+        if err != nil || factor < 0 || factor > 1 {
+            return imputedPolicy{}, fmt.Errorf("bad discount factor in %q, want 0 to 1", text)
+        }
+        return imputedPolicy{"discount", factor}, nil
+    }
+    return imputedPolicy{}, fmt.Errorf("unknown imputed value policy %q, want zero, trust, discount:FACTOR or exclude-food", text)
+}
+
+func (policy imputedPolicy) String() string {
+    if policy.kind == "discount" {
+        return fmt.Sprintf("discount:%g", policy.factor)
+    }
+    return policy.kind
+}
+
+// pastTense is what happened to the values, for reports.
+func (policy imputedPolicy) pastTense() string {
+    switch policy.kind {
+    case "trust":
+        return "used as is"
+    case "discount":
+        return fmt.Sprintf("scaled by %g", policy.factor)
+    case "exclude-food":
+        return "excluded"
+    }
+    return "zeroed"
+}
+
+// imputedPolicies is the global policy and any per nutrient ones.
+type imputedPolicies struct {
+    global imputedPolicy
+    byNutrient map[int]imputedPolicy // nutrient id -> policy
+}
+
+func (policies imputedPolicies) forId(id int) imputedPolicy {
+    if policy, exists := policies.byNutrient[id]; exists {
+        return policy
+    }
+    return policies.global
+}
+
+// forNutrient is the policy for a target's nutrient, or for the first part
+// of a derived one.
+func (policies imputedPolicies) forNutrient(nutrientNameToId map[string]int, nutrient string) imputedPolicy {
+    if parts, exists := derivedNutrients[nutrient]; exists {
+        nutrient = parts[0].nutrient
+    }
+    return policies.forId(nutrientNameToId[nutrient])
+}
+
+// newImputedPolicies reads --imputed and the --imputed-for flags, which look
+// like "Potassium, K=trust".
+func newImputedPolicies(opts *Options, nutrientNameToId map[string]int) (imputedPolicies, error) {
+    global, err := parseImputedPolicy(opts.imputedPolicy)
+    if err != nil {
+        return imputedPolicies{}, fmt.Errorf("--imputed: %v", err)
+    }
+    policies := imputedPolicies{global, make(map[int]imputedPolicy)}
+    for _, spec := range opts.imputedFor {
+        equals := strings.LastIndex(spec, "=")
+        if equals < 0 {
+            return imputedPolicies{}, fmt.Errorf("--imputed-for %q should look like \"Nutrient=policy\"", spec)
+        }
+        nutrient := strings.TrimSpace(spec[:equals])
+        id, exists := nutrientNameToId[nutrient]
+        if !exists {
+            return imputedPolicies{}, fmt.Errorf("--imputed-for %q: no nutrient %q", spec, nutrient)
+        }
+        policy, err := parseImputedPolicy(spec[equals + 1:])
+        if err != nil {
+            return imputedPolicies{}, fmt.Errorf("--imputed-for %q: %v", spec, err)
+        }
+        policies.byNutrient[id] = policy
+    }
+    return policies, nil
+}
+
+// applyImputedPolicy changes the foods' imputed values as the policies say,
+// dropping foods that are excluded, and returns how many were. Foods are
+// copied before they are changed, as the same map may be cached.
+func applyImputedPolicy(policies imputedPolicies, foods map[int]Food) int {
+    excluded := 0
+    for id, food := range foods {
+        var nutrients []NutrientInFood
+        exclude := false
+        for i, nutrientInFood := range food.nutrients {
+            if !nutrientInFood.imputed {
+                continue
+            }
+            policy := policies.forId(nutrientInFood.nutrient.id)
+            if policy.kind == "trust" {
+                continue
+            }
+            if policy.kind == "exclude-food" {
+                exclude = true
+                break
+            }
+            if nutrients == nil {
+                nutrients = make([]NutrientInFood, len(food.nutrients))
+                copy(nutrients, food.nutrients)
+            }
+            if policy.kind == "discount" {
+                nutrients[i].amountPerG *= policy.factor
+            } else {
+                nutrients[i].amountPerG = 0
+            }
+        }
+        if exclude {
+            delete(foods, id)
+            excluded++
+            continue
+        }
+        if nutrients != nil {
+            food.nutrients = nutrients
+            foods[id] = food
+        }
+    }
+    return excluded
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+    "bytes"
+    "encoding/csv"
+    "flag"
+    "fmt"
+    "io"
+    "math"
+    "os"
+    "sort"
+    "strconv"
+    "time"
+)
+
+// The intake log is a CSV of what was actually eaten, one row per food per
+// day:
+//
+//     date,food_id,grams,description
+//     2024-05-01,11457,90,"Spinach, raw"
+
+const dateFormat = "2006-01-02"
+
+var intakeLogHeader = []string{"date", "food_id", "grams", "description"}
+
+type intakeEntry struct {
+    date string
+    foodId int
+    grams int
+    description string
+}
+
+func readIntakeLog(path string, passphrase string) ([]intakeEntry, error) {
+    data, err := readStoreFile(path, passphrase)
+    if os.IsNotExist(err) {
+        return nil, nil
+    } else if err != nil {
+        return nil, err
+    }
+
+    reader := csv.NewReader(bytes.NewReader(data))
+    reader.Comment = '#'
+    reader.FieldsPerRecord = len(intakeLogHeader)
+
+    var entries []intakeEntry
+    for line := 1; ; line++ {
+        record, err := reader.Read()
+        if err == io.EOF {
+            break
+        } else if err != nil {
+            return nil, fmt.Errorf("%s: %v", path, err)
+        }
+        if line == 1 && record[0] == intakeLogHeader[0] {
+            continue
+        }
+        if _, err := time.Parse(dateFormat, record[0]); err != nil {
+            return nil, fmt.Errorf("%s line %d: bad date %q", path, line, record[0])
+        }
+        foodId, err := strconv.Atoi(record[1])
+        if err != nil {
+            return nil, fmt.Errorf("%s line %d: bad food id %q", path, line, record[1])
+        }
+        grams, err := strconv.Atoi(record[2])
+        if err != nil {
+            return nil, fmt.Errorf("%s line %d: bad grams %q", path, line, record[2])
+        }
+        entries = append(entries, intakeEntry{record[0], foodId, grams, record[3]})
+    }
+    return entries, nil
+}
+
+func appendIntakeLog(path string, passphrase string, entries []intakeEntry) error {
+    _, err := os.Stat(path)
+    isNew := os.IsNotExist(err)
+
+    var rows bytes.Buffer
+    writer := csv.NewWriter(&rows)
+    if isNew {
+        writer.Write(intakeLogHeader)
+    }
+    for _, entry := range entries {
+        writer.Write([]string{entry.date, strconv.Itoa(entry.foodId), strconv.Itoa(entry.grams), entry.description})
+    }
+    writer.Flush()
+    if err := writer.Error(); err != nil {
+        return err
+    }
+    return appendStoreFile(path, passphrase, rows.Bytes())
+}
+
+// runLog handles "supershake log", which records a day's intake,
+// "supershake log report", which compares recent intake with the targets, and
+// "supershake log import", which reads other trackers' exports, and
+// "supershake log weekly", which writes up a week of it.
+func runLog(args []string) {
+    if len(args) > 0 && args[0] == "report" {
+        runLogReport(args[1:])
+        return
+    }
+    if len(args) > 0 && args[0] == "import" {
+        runLogImport(args[1:])
+        return
+    }
+    if len(args) > 0 && args[0] == "weekly" {
+        runLogWeekly(args[1:])
+        return
+    }
+
+    fs := flag.NewFlagSet("supershake log", flag.ExitOnError)
+    opts := newOptions(fs)
+    logPath := fs.String("log", "intake_log.csv", "intake log file")
+    date := fs.String("date", time.Now().Format(dateFormat), "day the food was eaten, YYYY-MM-DD")
+    recipePath := fs.String("recipe", "", "log every food in this saved recipe")
+    foodId := fs.Int("food", 0, "log a single food by id")
+    grams := fs.Int("grams", 0, "grams of --food")
+    amount := fs.String("amount", "", "amount of --food in grams or a household measure, e.g. \"2 tbsp\"")
+    fs.Parse(args)
+
+    if _, err := time.Parse(dateFormat, *date); err != nil {
+        fmt.Fprintf(os.Stderr, tr("Bad --date %q, want YYYY-MM-DD\n"), *date)
+        os.Exit(2)
+    }
+
+    quantities := make(map[int]int)
+    if *recipePath != "" {
+        fromRecipe, err := readRecipeFile(*recipePath)
+        if err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            os.Exit(1)
+        }
+        for id, g := range fromRecipe {
+            quantities[id] += g
+        }
+    }
+    if *foodId != 0 && *grams <= 0 && *amount == "" {
+        fmt.Fprintln(os.Stderr, "--food needs --grams or --amount")
+        os.Exit(2)
+    }
+    if *foodId == 0 && len(quantities) == 0 {
+        fmt.Fprintln(os.Stderr, "Usage: supershake log (--recipe recipe.json | --food id (--grams g | --amount \"2 tbsp\")) [--date YYYY-MM-DD]")
+        os.Exit(2)
+    }
+
+    // Log whatever was eaten, excluded from recipes or not
+    _, _, allFoods := loadNutrientsAndFoods(opts)
+    if *foodId != 0 {
+        foodGrams := *grams
+        if *amount != "" {
+            food, exists := allFoods[*foodId]
+            if !exists {
+                fmt.Fprintf(os.Stderr, tr("No food with id %d\n"), *foodId)
+                os.Exit(1)
+            }
+            count, unit, err := parseAmount(*amount)
+            if err == nil {
+                var converted float64
+                converted, err = food.gramsFor(count, unit)
+                foodGrams = int(math.Round(converted))
+            }
+            if err != nil {
+                fmt.Fprintln(os.Stderr, err)
+                os.Exit(1)
+            }
+        }
+        quantities[*foodId] += foodGrams
+    }
+    foodIds := make([]int, 0, len(quantities))
+    for id := range quantities {
+        foodIds = append(foodIds, id)
+    }
+    sort.Ints(foodIds)
+
+    var entries []intakeEntry
+    for _, id := range foodIds {
+        food, exists := allFoods[id]
+        if !exists {
+            fmt.Fprintf(os.Stderr, tr("No food with id %d\n"), id)
+            os.Exit(1)
+        }
+        entries = append(entries, intakeEntry{*date, id, quantities[id], food.description})
+    }
+    if err := appendIntakeLog(*logPath, opts.passphrase(), entries); err != nil {
+        panic(err)
+    }
+    for _, entry := range entries {
+        fmt.Printf("%s: %d grams of %s\n", entry.date, entry.grams, entry.description)
+    }
+}
+
+// intakeAverage is the average daily intake over the days in [from, to] that
+// have anything logged. It also returns how many days that was.
+func intakeAverage(problem *Problem, entries []intakeEntry, from string, to string) (*Recipe, int) {
+    recipe := NewRecipe(problem.foods, problem.nutrients)
+    days := make(map[string]bool)
+    for _, entry := range entries {
+        // Dates are YYYY-MM-DD, so they compare as strings
+        if entry.date < from || entry.date > to {
+            continue
+        }
+        food, exists := problem.foods[entry.foodId]
+        if !exists {
+            continue
+        }
+        recipe.AddFood(problem.foods, &food, entry.grams)
+        days[entry.date] = true
+    }
+    if len(days) > 0 {
+        for nutrientId := range recipe.nutrientTotals {
+            recipe.nutrientTotals[nutrientId] /= float64(len(days))
+        }
+        // Rounded, so the grams won't quite add up to the totals
+        for foodId, grams := range recipe.foodQuantities {
+            recipe.foodQuantities[foodId] = int(math.Round(float64(grams) / float64(len(days))))
+        }
+    }
+    return recipe, len(days)
+}
+
+func runLogReport(args []string) {
+    fs := flag.NewFlagSet("supershake log report", flag.ExitOnError)
+    opts := newOptions(fs)
+    logPath := fs.String("log", "intake_log.csv", "intake log file")
+    date := fs.String("date", time.Now().Format(dateFormat), "last day of the report, YYYY-MM-DD")
+    window := fs.Int("days", 7, "days in the rolling average")
+    trendDays := fs.Int("trend", 14, "days of rolling averages to show in the trend")
+    fs.Parse(args)
+
+    end, err := time.Parse(dateFormat, *date)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, tr("Bad --date %q, want YYYY-MM-DD\n"), *date)
+        os.Exit(2)
+    }
+    entries, err := readIntakeLog(*logPath, opts.passphrase())
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(1)
+    }
+
+    nutrients, nutrientNameToId, allFoods := loadNutrientsAndFoods(opts)
+    problem := newProblem(opts, nutrients, nutrientNameToId, allFoods)
+
+    windowStart := func(day time.Time) string {
+        return day.AddDate(0, 0, -(*window - 1)).Format(dateFormat)
+    }
+
+    fmt.Printf("TREND (%d-day rolling average)\n", *window)
+    for i := *trendDays - 1; i >= 0; i-- {
+        day := end.AddDate(0, 0, -i)
+        average, days := intakeAverage(problem, entries, windowStart(day), day.Format(dateFormat))
+        if days == 0 {
+            fmt.Printf("%s  nothing logged\n", day.Format(dateFormat))
+            continue
+        }
+        met := 0
+        for _, target := range problem.targets {
+            if target.isMet(average.amountOf(nutrientNameToId, target.nutrient)) {
+                met++
+            }
+        }
+        fmt.Printf("%s  %d/%d targets met, %d days logged\n", day.Format(dateFormat), met, len(problem.targets), days)
+    }
+
+    average, days := intakeAverage(problem, entries, windowStart(end), *date)
+    fmt.Printf("\nAVERAGE DAILY INTAKE %s to %s (%d of %d days logged)\n", windowStart(end), *date, days, *window)
+    if days == 0 {
+        return
+    }
+    for _, target := range problem.targets {
+        amount := average.amountOf(nutrientNameToId, target.nutrient)
+        status := "ok"
+        if !target.isMet(amount) {
+            if amount < target.min {
+                status = "LOW"
+            } else {
+                status = "HIGH"
+            }
+        }
+        fmt.Printf("%-4s %.2f %s (want %s)\n", status, amount, target.nutrient, target.rangeString())
+    }
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+    "encoding/csv"
+    "flag"
+    "fmt"
+    "io"
+    "math"
+    "os"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Other trackers' exports, read into the intake log. Each export format is
+// the header names that hold the date, the food name and the amount eaten.
+// Food names are matched to foods with the fuzzy matcher, or through a map
+// file for names it gets wrong.
+//
+// Cronometer's servings export has a row per food. MyFitnessPal's own
+// export only has meal totals, so its format is for the per-food diary
+// exports people make with browser extensions.
+
+type intakeExportFormat struct {
+    date []string
+    food []string
+    amount []string
+    units []string // a separate units column, if the format has one
+}
+
+var intakeExportFormats = map[string]intakeExportFormat{
+    "cronometer": {
+        date: []string{"Day", "Date"},
+        food: []string{"Food Name"},
+        amount: []string{"Amount"},
+    },
+    "mfp": {
+        date: []string{"Date"},
+        food: []string{"Food Name", "Food", "Name"},
+        amount: []string{"Quantity", "Amount", "Serving Size"},
+        units: []string{"Unit", "Units"},
+    },
+}
+
+var importDateFormats = []string{dateFormat, "01/02/2006", "1/2/2006", "2006/01/02"}
+
+// Grams in each weight unit trackers write amounts in
+var importWeightUnits = map[string]float64{
+    "g": 1, "gram": 1, "grams": 1,
+    "kg": 1000,
+    "mg": 0.001,
+    "oz": 28.349523125, "ounce": 28.349523125, "ounces": 28.349523125,
+    "lb": 453.59237, "lbs": 453.59237, "pound": 453.59237, "pounds": 453.59237,
+}
+
+type importedRow struct {
+    line int
+    date string
+    name string
+    amount float64
+    unit string // a weight or a household measure, converted once the food is known
+}
+
+func findColumn(header []string, names []string) int {
+    for _, name := range names {
+        for i, column := range header {
+            if strings.EqualFold(strings.TrimSpace(column), name) {
+                return i
+            }
+        }
+    }
+    return -1
+}
+
+func parseImportDate(value string) (string, error) {
+    // Some exports add the time of day after the date
+    value = strings.Fields(strings.TrimSpace(value) + " ")[0]
+    for _, layout := range importDateFormats {
+        if day, err := time.Parse(layout, value); err == nil {
+            return day.Format(dateFormat), nil
+        }
+    }
+    return "", fmt.Errorf("unrecognized date %q", value)
+}
+
+// readIntakeExport reads the rows of an export. Rows it can't read are
+// skipped and described in the returned warnings.
+func readIntakeExport(r io.Reader, format intakeExportFormat) ([]importedRow, []string, error) {
+    reader := csv.NewReader(r)
+    reader.FieldsPerRecord = -1
+    header, err := reader.Read()
+    if err != nil {
+        return nil, nil, err
+    }
+    dateColumn := findColumn(header, format.date)
+    foodColumn := findColumn(header, format.food)
+    amountColumn := findColumn(header, format.amount)
+    unitsColumn := -1
+    if format.units != nil {
+        unitsColumn = findColumn(header, format.units)
+    }
+    if dateColumn < 0 || foodColumn < 0 || amountColumn < 0 {
+        return nil, nil, fmt.Errorf("export needs %s, %s and %s columns", format.date[0], format.food[0], format.amount[0])
+    }
+
+    var rows []importedRow
+    var warnings []string
+    for line := 2; ; line++ {
+        record, err := reader.Read()
+        if err == io.EOF {
+            break
+        } else if err != nil {
+            return nil, nil, err
+        }
+        if len(record) <= dateColumn || len(record) <= foodColumn || len(record) <= amountColumn {
+            warnings = append(warnings, fmt.Sprintf("line %d: too few columns", line))
+            continue
+        }
+        date, err := parseImportDate(record[dateColumn])
+        if err != nil {
+            warnings = append(warnings, fmt.Sprintf("line %d: %v", line, err))
+            continue
+        }
+
+        amountText := record[amountColumn]
+        if unitsColumn >= 0 && unitsColumn < len(record) {
+            amountText += " " + record[unitsColumn]
+        }
+        fields := strings.SplitN(strings.TrimSpace(amountText), " ", 2)
+        if len(fields) != 2 {
+            warnings = append(warnings, fmt.Sprintf("line %d: can't read amount %q", line, amountText))
+            continue
+        }
+        amount, err := strconv.ParseFloat(strings.ReplaceAll(fields[0], ",", ""), 64)
+        if err != nil {
+            warnings = append(warnings, fmt.Sprintf("line %d: can't read amount %q", line, amountText))
+            continue
+        }
+        rows = append(rows, importedRow{line, date, strings.TrimSpace(record[foodColumn]), amount, strings.TrimSpace(fields[1])})
+    }
+    return rows, warnings, nil
+}
+
+// readFoodMap reads "name,food_id" rows that override the fuzzy matcher.
+func readFoodMap(path string) (map[string]int, error) {
+    foodMap := make(map[string]int)
+    if path == "" {
+        return foodMap, nil
+    }
+    file, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+    reader := csv.NewReader(file)
+    reader.Comment = '#'
+    reader.FieldsPerRecord = 2
+    records, err := reader.ReadAll()
+    if err != nil {
+        return nil, fmt.Errorf("%s: %v", path, err)
+    }
+    for i, record := range records {
+        id, err := strconv.Atoi(strings.TrimSpace(record[1]))
+        if err != nil {
+            return nil, fmt.Errorf("%s line %d: bad food id %q", path, i + 1, record[1])
+        }
+        foodMap[strings.ToLower(strings.TrimSpace(record[0]))] = id
+    }
+    return foodMap, nil
+}
+
+// runLogImport handles "supershake log import".
+func runLogImport(args []string) {
+    fs := flag.NewFlagSet("supershake log import", flag.ExitOnError)
+    opts := newOptions(fs)
+    logPath := fs.String("log", "intake_log.csv", "intake log file")
+    formatName := fs.String("format", "cronometer", "export format: cronometer or mfp")
+    exportPath := fs.String("file", "", "export CSV to import")
+    mapPath := fs.String("map", "", "CSV of name,food_id for names the matcher gets wrong")
+    minMatch := fs.Float64("min-match", 0.5, "skip names matching no food at least this well (0 to 1)")
+    dryRun := fs.Bool("dry-run", false, "show the matches without writing the log")
+    fs.Parse(args)
+
+    format, known := intakeExportFormats[*formatName]
+    if !known || *exportPath == "" {
+        fmt.Fprintln(os.Stderr, "Usage: supershake log import --format (cronometer | mfp) --file export.csv [flags]")
+        os.Exit(2)
+    }
+
+    file, err := os.Open(*exportPath)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(1)
+    }
+    rows, warnings, err := readIntakeExport(file, format)
+    file.Close()
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "%s: %v\n", *exportPath, err)
+        os.Exit(1)
+    }
+    foodMap, err := readFoodMap(*mapPath)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(1)
+    }
+
+    _, _, allFoods := loadNutrientsAndFoods(opts)
+    matcher := newFoodMatcher(allFoods)
+
+    // Match each distinct name once, and show the match so bad ones can be
+    // put in the map file
+    type nameMatch struct {
+        food Food
+        score float64
+    }
+    matches := make(map[string]nameMatch)
+    var names []string
+    for _, row := range rows {
+        if _, done := matches[row.name]; done {
+            continue
+        }
+        if id, mapped := foodMap[strings.ToLower(row.name)]; mapped {
+            food, exists := allFoods[id]
+            if !exists {
+                fmt.Fprintf(os.Stderr, "Map file gives %q food id %d, which doesn't exist\n", row.name, id)
+                os.Exit(1)
+            }
+            matches[row.name] = nameMatch{food, 1}
+        } else {
+            food, score := matcher.match(row.name)
+            matches[row.name] = nameMatch{food, score}
+        }
+        names = append(names, row.name)
+    }
+    sort.Strings(names)
+    for _, name := range names {
+        match := matches[name]
+        if match.score < *minMatch {
+            fmt.Printf("  no match   %s\n", name)
+        } else {
+            fmt.Printf("  %.2f       %s -> %d %s\n", match.score, name, match.food.id, match.food.description)
+        }
+    }
+
+    var entries []intakeEntry
+    for _, row := range rows {
+        match := matches[row.name]
+        if match.score < *minMatch {
+            warnings = append(warnings, fmt.Sprintf("line %d: no food matches %q", row.line, row.name))
+            continue
+        }
+        converted, err := match.food.gramsFor(row.amount, row.unit)
+        if err != nil {
+            warnings = append(warnings, fmt.Sprintf("line %d: %v", row.line, err))
+            continue
+        }
+        grams := int(math.Round(converted))
+        if grams <= 0 {
+            continue
+        }
+        entries = append(entries, intakeEntry{row.date, match.food.id, grams, match.food.description})
+    }
+    for _, warning := range warnings {
+        fmt.Println("Skipped", warning)
+    }
+
+    if *dryRun {
+        fmt.Printf("Would import %d entries\n", len(entries))
+        return
+    }
+    if err := appendIntakeLog(*logPath, opts.passphrase(), entries); err != nil {
+        panic(err)
+    }
+    fmt.Printf("Imported %d entries into %s\n", len(entries), *logPath)
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+    "bytes"
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "strings"
+    "time"
+)
+
+// Optimizing takes a while, so besides POST /optimize, which holds the
+// connection open, the server takes jobs:
+//
+//     POST /jobs        start an optimization, answering {"job": id} straight
+//                       away. A JSON body of {"callback_url": "https://..."}
+//                       has the finished report POSTed there.
+//     GET  /jobs/<id>   the job's report, "running" until it's done, or
+//                       "interrupted" if the server stopped before it was.
+//                       With hard targets it can be "infeasible" instead.
+//
+// Callbacks need --webhook-secret. Each one is signed with it, the header
+// X-Supershake-Signature being "sha256=" and the hex HMAC-SHA256 of the
+// body, for the receiver to check the report really came from us.
+
+// How many times a callback is tried, waiting longer each time
+const webhookAttempts = 3
+
+type jobReport struct {
+    Job string `json:"job"`
+    Status string `json:"status"` // running, done, infeasible or interrupted
+    Error string `json:"error,omitempty"` // why it's infeasible
+    Score float64 `json:"score,omitempty"`
+    Recipe json.RawMessage `json:"recipe,omitempty"` // as --save-recipe writes it
+    Nutrients []jobNutrient `json:"nutrients,omitempty"`
+}
+
+type jobNutrient struct {
+    Nutrient string `json:"nutrient"`
+    Amount float64 `json:"amount"`
+    Min float64 `json:"min"`
+    Max float64 `json:"max,omitempty"`
+    Met bool `json:"met"`
+}
+
+func (s *server) handleJobs(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "POST to start a job", http.StatusMethodNotAllowed)
+        return
+    }
+    var request struct {
+        CallbackURL string `json:"callback_url"`
+    }
+    data, err := io.ReadAll(io.LimitReader(r.Body, maxShareBytes))
+    if err == nil && len(bytes.TrimSpace(data)) > 0 {
+        err = json.Unmarshal(data, &request)
+    }
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    if request.CallbackURL != "" {
+        if s.webhookSecret == "" {
+            http.Error(w, "callbacks need the server started with --webhook-secret", http.StatusBadRequest)
+            return
+        }
+        callback, err := url.Parse(request.CallbackURL)
+        if err != nil || (callback.Scheme != "http" && callback.Scheme != "https") || callback.Host == "" {
+            http.Error(w, "callback_url should be an http or https URL", http.StatusBadRequest)
+            return
+        }
+    }
+
+    report := jobReport{Job: newShareToken(), Status: "running"}
+    if err := s.putJob(report); err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    s.jobsLock.Lock()
+    s.running[report.Job] = true
+    s.jobsLock.Unlock()
+    go s.runJob(report.Job, request.CallbackURL)
+
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusAccepted)
+    json.NewEncoder(w).Encode(jobReport{Job: report.Job, Status: report.Status})
+}
+
+func (s *server) handleJob(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        http.Error(w, "GET only", http.StatusMethodNotAllowed)
+        return
+    }
+    id := strings.TrimPrefix(r.URL.Path, "/jobs/")
+    data, err := s.store.get(storeJobs, id)
+    if err == errNotStored {
+        http.NotFound(w, r)
+        return
+    }
+    var report jobReport
+    if err == nil {
+        err = json.Unmarshal(data, &report)
+    }
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    s.jobsLock.Lock()
+    if report.Status == "running" && !s.running[id] {
+        report.Status = "interrupted"
+    }
+    s.jobsLock.Unlock()
+    writePrivateJSON(w, report)
+}
+
+func (s *server) putJob(report jobReport) error {
+    data, err := json.Marshal(report)
+    if err != nil {
+        return err
+    }
+    return s.store.put(storeJobs, report.Job, data)
+}
+
+// runJob optimizes, records the report and sends it to the callback.
+func (s *server) runJob(id string, callbackURL string) {
+    var done jobReport
+    if recipe, err := s.optimize(); err != nil {
+        done = jobReport{Job: id, Status: "infeasible", Error: err.Error()}
+    } else {
+        done = newJobReport(s.problem, id, recipe)
+    }
+
+    if err := s.putJob(done); err != nil {
+        fmt.Printf("Job %s: couldn't keep the report: %v\n", id, err)
+    }
+    s.jobsLock.Lock()
+    delete(s.running, id)
+    s.jobsLock.Unlock()
+
+    if callbackURL != "" {
+        body, err := json.Marshal(done)
+        if err != nil { panic(err) }
+        if err := sendWebhook(callbackURL, s.webhookSecret, id, body); err != nil {
+            fmt.Printf("Job %s: callback to %s failed: %v\n", id, callbackURL, err)
+        }
+    }
+}
+
+// newJobReport is the report of a finished job.
+func newJobReport(problem *Problem, id string, recipe *Recipe) jobReport {
+    done := jobReport{Job: id, Status: "done", Score: recipe.Score(problem, false)}
+    recipeData, err := recipeJSON(problem, recipe)
+    if err != nil { panic(err) }
+    done.Recipe = recipeData
+    for _, target := range problem.targets {
+        amount := recipe.amountOf(problem.nutrientNameToId, target.nutrient)
+        done.Nutrients = append(done.Nutrients, jobNutrient{target.nutrient, amount, target.min, target.max, target.isMet(amount)})
+    }
+    return done
+}
+
+// webhookSignature is the X-Supershake-Signature for body.
+func webhookSignature(secret string, body []byte) string {
+    mac := hmac.New(sha256.New, []byte(secret))
+    mac.Write(body)
+    return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// sendWebhook POSTs body to callbackURL, retrying a few times unless the
+// receiver says the request itself is bad.
+func sendWebhook(callbackURL string, secret string, jobId string, body []byte) error {
+    client := &http.Client{Timeout: 30 * time.Second}
+    var lastErr error
+    for attempt := 0; attempt < webhookAttempts; attempt++ {
+        if attempt > 0 {
+            time.Sleep(time.Duration(attempt * attempt) * 5 * time.Second)
+        }
+        request, err := http.NewRequest("POST", callbackURL, bytes.NewReader(body))
+        if err != nil {
+            return err
+        }
+        request.Header.Set("Content-Type", "application/json")
+        request.Header.Set("User-Agent", "supershake")
+        request.Header.Set("X-Supershake-Job", jobId)
+        request.Header.Set("X-Supershake-Signature", webhookSignature(secret, body))
+        response, err := client.Do(request)
+        if err != nil {
+            lastErr = err
+            continue
+        }
+        response.Body.Close()
+        if response.StatusCode < 300 {
+            return nil
+        }
+        lastErr = fmt.Errorf("%s answered %s", callbackURL, response.Status)
+        if response.StatusCode < 500 {
+            return lastErr
+        }
+    }
+    return lastErr
+}
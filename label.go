@@ -0,0 +1,228 @@
+package main
+
+import (
+    "bufio"
+    "encoding/csv"
+    "fmt"
+    "io"
+    "regexp"
+    "strconv"
+    "strings"
+)
+
+// A nutrition facts label, entered as rows of "name,value":
+//
+//     name,Acme Whey Isolate
+//     serving,1 scoop (30 g)
+//     Calories,120
+//     Protein,24 g
+//     Calcium,10%
+//
+// Values are per serving. Percentages are read as %DV.
+
+type labelNutrient struct {
+    label string
+    description string // as in NUTR_DEF.txt
+    dailyValue float64 // FDA 2016 daily value for adults, 0 if there is none
+    dvUnits string
+}
+
+// In the order they appear on a US label
+var labelNutrients = []labelNutrient{
+    {"Calories", "Energy, kcal", 0, "kcal"},
+    {"Total Fat", "Total lipid (fat)", 78, "g"},
+    {"Saturated Fat", "Fatty acids, total saturated", 20, "g"},
+    {"Trans Fat", "Fatty acids, total trans", 0, "g"},
+    {"Cholesterol", "Cholesterol", 300, "mg"},
+    {"Sodium", "Sodium, Na", 2300, "mg"},
+    {"Total Carbohydrate", "Carbohydrate, by difference", 275, "g"},
+    {"Dietary Fiber", "Fiber, total dietary", 28, "g"},
+    {"Total Sugars", "Sugars, total", 0, "g"},
+    {"Protein", "Protein", 50, "g"},
+    {"Vitamin D", "Vitamin D (D2 + D3)", 20, "µg"},
+    {"Calcium", "Calcium, Ca", 1300, "mg"},
+    {"Iron", "Iron, Fe", 18, "mg"},
+    {"Potassium", "Potassium, K", 4700, "mg"},
+    {"Vitamin A", "Vitamin A, RAE", 900, "µg"},
+    {"Vitamin C", "Vitamin C, total ascorbic acid", 90, "mg"},
+    {"Vitamin E", "Vitamin E (alpha-tocopherol)", 15, "mg"},
+    {"Vitamin K", "Vitamin K (phylloquinone)", 120, "µg"},
+    {"Thiamin", "Thiamin", 1.2, "mg"},
+    {"Riboflavin", "Riboflavin", 1.3, "mg"},
+    {"Niacin", "Niacin", 16, "mg"},
+    {"Vitamin B6", "Vitamin B-6", 1.7, "mg"},
+    {"Folate", "Folate, food", 400, "µg"},
+    {"Folic Acid", "Folic acid", 0, "µg"},
+    {"Vitamin B12", "Vitamin B-12", 2.4, "µg"},
+    {"Pantothenic Acid", "Pantothenic acid", 5, "mg"},
+    {"Phosphorus", "Phosphorus, P", 1250, "mg"},
+    {"Magnesium", "Magnesium, Mg", 420, "mg"},
+    {"Zinc", "Zinc, Zn", 11, "mg"},
+    {"Selenium", "Selenium, Se", 55, "µg"},
+    {"Copper", "Copper, Cu", 0.9, "mg"},
+    {"Manganese", "Manganese, Mn", 2.3, "mg"},
+    {"Choline", "Choline, total", 550, "mg"},
+}
+
+func findLabelNutrient(name string, nutrientNameToId map[string]int) (labelNutrient, bool) {
+    for _, ln := range labelNutrients {
+        if strings.EqualFold(ln.label, name) || strings.EqualFold(ln.description, name) {
+            return ln, true
+        }
+    }
+    // Anything else in NUTR_DEF.txt can be given by its exact description,
+    // just without a daily value to go with it
+    if _, exists := nutrientNameToId[name]; exists {
+        return labelNutrient{name, name, 0, ""}, true
+    }
+    return labelNutrient{}, false
+}
+
+var servingGramsPattern = regexp.MustCompile(`([0-9.]+)\s*g\b`)
+
+// parseServingGrams reads "30 g", "30g" or "1 scoop (30 g)".
+func parseServingGrams(serving string) (float64, error) {
+    match := servingGramsPattern.FindStringSubmatch(serving)
+    if match == nil {
+        return 0, fmt.Errorf("serving size %q has no weight in grams", serving)
+    }
+    grams, err := strconv.ParseFloat(match[1], 64)
+    if err != nil || grams <= 0 {
+        return 0, fmt.Errorf("bad serving size %q", serving)
+    }
+    return grams, nil
+}
+
+// labelToCustomFood turns label rows into a food with amounts per 100g in the
+// units NUTR_DEF.txt uses for each nutrient.
+func labelToCustomFood(rows [][]string, nutrients map[int]Nutrient, nutrientNameToId map[string]int) (*CustomFood, error) {
+    food := &CustomFood{per100g: make(map[string]float64)}
+    servingGrams := float64(0)
+    var problems []string
+
+    for _, row := range rows {
+        if len(row) < 2 || strings.TrimSpace(row[0]) == "" {
+            continue
+        }
+        key := strings.TrimSpace(row[0])
+        value := strings.TrimSpace(row[1])
+
+        switch strings.ToLower(key) {
+        case "name":
+            food.description = value
+            continue
+        case "serving", "serving size":
+            grams, err := parseServingGrams(value)
+            if err != nil {
+                return nil, err
+            }
+            servingGrams = grams
+            continue
+        }
+
+        if value == "" {
+            continue
+        }
+        ln, found := findLabelNutrient(key, nutrientNameToId)
+        if !found {
+            problems = append(problems, fmt.Sprintf("unknown nutrient %q", key))
+            continue
+        }
+        nutrientId, exists := nutrientNameToId[ln.description]
+        if !exists {
+            problems = append(problems, fmt.Sprintf("%s is not in the nutrient database", ln.description))
+            continue
+        }
+        nutrient := nutrients[nutrientId]
+
+        var amount float64
+        var units string
+        if strings.HasSuffix(strings.ToUpper(value), "%DV") || strings.HasSuffix(value, "%") {
+            number := strings.TrimSuffix(strings.TrimSuffix(strings.ToUpper(value), "DV"), "%")
+            percent, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+            if err != nil {
+                problems = append(problems, fmt.Sprintf("bad percentage %q for %s", value, key))
+                continue
+            }
+            if ln.dailyValue == 0 {
+                problems = append(problems, fmt.Sprintf("%s has no daily value, give an amount instead of %s", key, value))
+                continue
+            }
+            amount = ln.dailyValue * percent / 100
+            units = ln.dvUnits
+        } else {
+            parsed, parsedUnits, err := parseAmount(value)
+            if err != nil {
+                problems = append(problems, fmt.Sprintf("%s: %v", key, err))
+                continue
+            }
+            amount = parsed
+            units = parsedUnits
+            if units == "" {
+                // Calories are usually written without a unit
+                units = nutrient.units
+            }
+        }
+
+        converted, err := convertNutrientUnits(amount, units, nutrient.units, nutrient.description)
+        if err != nil {
+            problems = append(problems, fmt.Sprintf("%s: %v", key, err))
+            continue
+        }
+        food.per100g[nutrient.description] = converted
+    }
+
+    if food.description == "" {
+        problems = append(problems, "label has no name row")
+    }
+    if servingGrams == 0 {
+        problems = append(problems, "label has no serving row")
+    }
+    if len(problems) > 0 {
+        return nil, fmt.Errorf("could not read label:\n  %s", strings.Join(problems, "\n  "))
+    }
+
+    for description, amount := range food.per100g {
+        food.per100g[description] = amount * 100 / servingGrams
+    }
+    return food, nil
+}
+
+func readLabelFile(r io.Reader) ([][]string, error) {
+    reader := csv.NewReader(r)
+    reader.FieldsPerRecord = -1
+    reader.TrimLeadingSpace = true
+    reader.Comment = '#'
+    return reader.ReadAll()
+}
+
+// promptForLabel asks for the label one line at a time, in label order.
+func promptForLabel(in io.Reader, out io.Writer, nutrientNameToId map[string]int) [][]string {
+    scanner := bufio.NewScanner(in)
+    ask := func(question string) string {
+        fmt.Fprint(out, question)
+        if !scanner.Scan() {
+            return ""
+        }
+        return strings.TrimSpace(scanner.Text())
+    }
+
+    rows := [][]string{
+        {"name", ask("Food name: ")},
+        {"serving", ask("Serving size (e.g. \"1 scoop (30 g)\"): ")},
+    }
+    fmt.Fprintln(out, "Per serving, give an amount with units or a %DV. Leave blank to skip.")
+    for _, ln := range labelNutrients {
+        if _, exists := nutrientNameToId[ln.description]; !exists {
+            continue
+        }
+        hint := ln.dvUnits
+        if ln.dailyValue != 0 {
+            hint += " or %DV"
+        }
+        if answer := ask(fmt.Sprintf("  %s (%s): ", ln.label, hint)); answer != "" {
+            rows = append(rows, []string{ln.label, answer})
+        }
+    }
+    return rows
+}
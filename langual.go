@@ -0,0 +1,152 @@
+package main
+
+import (
+    "fmt"
+    "strings"
+)
+
+// SR26 indexes its foods with LanguaL, a thesaurus describing food by facets:
+// where it comes from (B), how it was heat treated (F), how it was preserved
+// (J) and so on. LANGUAL.txt gives each food's factor codes and LANGDESC.txt
+// describes them. --facet and --without-facet filter on those instead of
+// guessing from the food descriptions.
+
+// facetAliases are short names for facets that take several factors to
+// describe. Each matches factors with the given code prefix whose
+// description contains one of the words. Anything else given to --facet is
+// matched against the factor descriptions as is.
+var facetAliases = map[string]struct {
+    codePrefix string
+    words []string
+}{
+    "raw": {"F", []string{"not heat-treated"}},
+    "cooked": {"F", []string{"fully heat-treated", "partially heat-treated"}},
+    "fermented": {"H", []string{"fermented"}},
+    "frozen": {"J", []string{"frozen"}},
+    "canned": {"J", []string{"canned", "sterilized"}},
+    "animal-derived": {"B", []string{"animal", "mammal", "bird", "fish", "shellfish", "mollusc", "crustacean",
+        "cattle", "cow", "pig", "swine", "sheep", "goat", "chicken", "hen", "turkey", "duck", "sardine", "salmon", "tuna"}},
+}
+
+// langual is the factors read from LANGUAL.txt and LANGDESC.txt.
+type langual struct {
+    factors map[int][]string // food id -> factor codes
+    descriptions map[string]string // factor code -> description
+}
+
+// readLangual reads the LanguaL files in each data directory that has them,
+// returning an error if none does.
+func readLangual(dataDirs []string) (*langual, error) {
+    index := &langual{factors: make(map[int][]string), descriptions: make(map[string]string)}
+    found := false
+    for _, dataDir := range dataDirs {
+        if !dataFileExists(dataDir, "LANGUAL.txt") || !dataFileExists(dataDir, "LANGDESC.txt") {
+            continue
+        }
+        found = true
+
+        descriptions := openUSDAFile(dataDir, "LANGDESC.txt")
+        for record := descriptions.next(); record != nil; record = descriptions.next() {
+            code, err := descriptions.text(record, 0)
+            if err != nil { descriptions.skip(err); continue }
+            description, err := descriptions.text(record, 1)
+            if err != nil { descriptions.skip(err); continue }
+            index.descriptions[code] = description
+        }
+        descriptions.close()
+
+        factors := openUSDAFile(dataDir, "LANGUAL.txt")
+        for record := factors.next(); record != nil; record = factors.next() {
+            foodId, err := factors.textInt(record, 0)
+            if err != nil { factors.skip(err); continue }
+            code, err := factors.text(record, 1)
+            if err != nil { factors.skip(err); continue }
+            index.factors[foodId] = append(index.factors[foodId], code)
+        }
+        factors.close()
+    }
+    if !found {
+        return nil, fmt.Errorf("--facet needs LANGUAL.txt and LANGDESC.txt in --data-dir")
+    }
+    return index, nil
+}
+
+// facetCodes returns the factor codes a facet name stands for.
+func (index *langual) facetCodes(facet string) (map[string]bool, error) {
+    prefix, words := "", []string{strings.ToLower(facet)}
+    if alias, exists := facetAliases[strings.ToLower(facet)]; exists {
+        prefix, words = alias.codePrefix, alias.words
+    }
+    codes := make(map[string]bool)
+    for code, description := range index.descriptions {
+        if !strings.HasPrefix(code, prefix) {
+            continue
+        }
+        description = strings.ToLower(description)
+        for _, word := range words {
+            if strings.Contains(description, word) {
+                codes[code] = true
+                break
+            }
+        }
+    }
+    if len(codes) == 0 {
+        return nil, fmt.Errorf("no LanguaL factor matches facet %q", facet)
+    }
+    return codes, nil
+}
+
+func (index *langual) hasAny(foodId int, codes map[string]bool) bool {
+    for _, code := range index.factors[foodId] {
+        if codes[code] {
+            return true
+        }
+    }
+    return false
+}
+
+// filterByFacets keeps the foods with every --facet and none of the
+// --without-facet. Foods LanguaL doesn't cover, like custom and branded
+// foods, can't be shown to have a facet, so --facet leaves them out unless
+// they're custom foods, which are always kept.
+func filterByFacets(opts *Options, foods map[int]Food) (map[int]Food, error) {
+    if len(opts.facets) == 0 && len(opts.withoutFacets) == 0 {
+        return foods, nil
+    }
+    index, err := readLangual(append([]string{opts.dataDir}, opts.extraDataDirs...))
+    if err != nil {
+        return nil, err
+    }
+    var wanted, unwanted []map[string]bool
+    for _, facet := range opts.facets {
+        codes, err := index.facetCodes(facet)
+        if err != nil {
+            return nil, err
+        }
+        wanted = append(wanted, codes)
+    }
+    for _, facet := range opts.withoutFacets {
+        codes, err := index.facetCodes(facet)
+        if err != nil {
+            return nil, err
+        }
+        unwanted = append(unwanted, codes)
+    }
+
+    kept := make(map[int]Food, len(foods))
+    for id, food := range foods {
+        keep := true
+        if food.foodGroup != customFoodGroup {
+            for _, codes := range wanted {
+                keep = keep && index.hasAny(id, codes)
+            }
+            for _, codes := range unwanted {
+                keep = keep && !index.hasAny(id, codes)
+            }
+        }
+        if keep {
+            kept[id] = food
+        }
+    }
+    return kept, nil
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+)
+
+// The problem as a linear program, for solving or inspecting with external
+// tools like HiGHS or Gurobi. There's one variable per food, in grams.
+//
+// With an objective (--maximize/--minimize) the targets are plain
+// constraints. Otherwise Score's target penalties are modelled with a
+// shortfall and an excess variable per target, which come out exactly as
+// calcPenalty's times the target's weight, and so do the
+// dihydrophylloquinone and --pattern-weight penalties. Hard targets' bounds
+// are plain constraints too, and --above-max steep stays linear. The mass penalty is linear without its cap,
+// stimulants are penalized linearly past their threshold, or kept under it
+// when crossing it costs a jump, as caffeine's does by default, and the
+// number of foods isn't linear and is left out, as are --macros shares,
+// which are ratios, --ratio, --protein-quality, --energy-limit and
+// --max-share.
+
+// Under a stimulant's threshold, e.g. 19.5 of caffeine's 20mg, with room
+// for rounding the solution to whole grams
+const lpStimulantCap = 0.975
+
+type lpTerm struct {
+    variable int
+    coef float64
+}
+
+type lpConstraint struct {
+    name string
+    terms []lpTerm
+    sense byte // 'G' for >=, 'L' for <=
+    rhs float64
+}
+
+type linearProgram struct {
+    name string
+    maximize bool
+    variables []string // all >= 0; the mass cap is a constraint, which bounds each food too
+    objective []float64 // coefficient of each variable
+    constraints []lpConstraint
+    foodIds []int // the first len(foodIds) variables are these foods
+}
+
+// lpName makes a nutrient usable as an LP or MPS name, which can't have
+// spaces or most punctuation, or start with a digit.
+func lpName(s string) string {
+    var name strings.Builder
+    name.WriteString("n_")
+    for _, r := range s {
+        if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+            name.WriteRune(r)
+        } else {
+            name.WriteRune('_')
+        }
+    }
+    return name.String()
+}
+
+func (lp *linearProgram) addVariable(name string, objective float64) int {
+    lp.variables = append(lp.variables, name)
+    lp.objective = append(lp.objective, objective)
+    return len(lp.variables) - 1
+}
+
+// buildLinearProgram sets problem out as a linear program.
+func buildLinearProgram(problem *Problem) *linearProgram {
+    lp := &linearProgram{name: "supershake", maximize: problem.objective != "" && !problem.minimizing}
+    foodIds := make([]int, 0, len(problem.foods))
+    for id := range problem.foods {
+        foodIds = append(foodIds, id)
+    }
+    sort.Ints(foodIds)
+    lp.foodIds = foodIds
+
+    massCap := float64(problem.opts.maxMassG)
+    for _, id := range foodIds {
+        lp.addVariable(fmt.Sprintf("f%d", id), 0)
+    }
+
+    // amount of a target's nutrient, as terms over the food variables
+    amountTerms := func(nutrient string) []lpTerm {
+        var terms []lpTerm
+        for i, id := range foodIds {
+            food := problem.foods[id]
+            if coef := food.perGramOf(problem.nutrientNameToId, nutrient); coef != 0 {
+                terms = append(terms, lpTerm{i, coef})
+            }
+        }
+        return terms
+    }
+
+    if problem.objective != "" {
+        for i, id := range foodIds {
+            if problem.objective == costObjective {
+                food := problem.foods[id]
+                lp.objective[i] = problem.prices[id] * float64(food.purchasedGrams(100)) / 100
+            } else {
+                food := problem.foods[id]
+                lp.objective[i] = food.perGramOf(problem.nutrientNameToId, problem.objective)
+            }
+        }
+        for _, target := range problem.targets {
+            terms := amountTerms(target.nutrient)
+            if target.min > 0 {
+                lp.constraints = append(lp.constraints, lpConstraint{lpName(target.nutrient) + "_min", terms, 'G', target.min})
+            }
+            if target.max > 0 {
+                lp.constraints = append(lp.constraints, lpConstraint{lpName(target.nutrient) + "_max", terms, 'L', target.max})
+            }
+        }
+    } else {
+        for _, target := range problem.targets {
+            terms := amountTerms(target.nutrient)
+            name := lpName(target.nutrient)
+            if target.min > 0 && target.hard {
+                lp.constraints = append(lp.constraints, lpConstraint{name + "_min", terms, 'G', target.min})
+            } else if target.min > 0 {
+                // amount + min/100 * short >= min, so short is the shortfall
+                // penalty
+                short := lp.addVariable(name + "_short", target.penaltyWeight())
+                constraint := append(append([]lpTerm{}, terms...), lpTerm{short, target.min / 100})
+                lp.constraints = append(lp.constraints, lpConstraint{name + "_min", constraint, 'G', target.min})
+            }
+            if target.max > 0 {
+                // amount - (max - midpoint)/100 * excess <= midpoint
+                midpoint := target.min + (target.max - target.min) / 2
+                excess := lp.addVariable(name + "_excess", target.penaltyWeight())
+                constraint := append(append([]lpTerm{}, terms...), lpTerm{excess, -(target.max - midpoint) / 100})
+                lp.constraints = append(lp.constraints, lpConstraint{name + "_mid", constraint, 'L', midpoint})
+                if problem.maxIsHard(target) {
+                    lp.constraints = append(lp.constraints, lpConstraint{name + "_max", terms, 'L', target.max})
+                }
+            }
+        }
+        for i, id := range foodIds {
+            food := problem.foods[id]
+            lp.objective[i] = food.perGramOf(problem.nutrientNameToId, "Dihydrophylloquinone")
+            if massCap == 0 {
+                lp.objective[i] += 10.0 / 3000
+            }
+        }
+        if pattern := problem.pattern; pattern != nil && problem.opts.patternWeight > 0 {
+            weight := problem.opts.patternWeight
+            for _, component := range pattern.components {
+                var terms []lpTerm
+                for i, id := range foodIds {
+                    if component.foodGroups[problem.foods[id].foodGroup] {
+                        terms = append(terms, lpTerm{i, 1})
+                    }
+                }
+                name := lpName("pattern " + component.name)
+                if component.min > 0 {
+                    short := lp.addVariable(name + "_short", weight)
+                    constraint := append(append([]lpTerm{}, terms...), lpTerm{short, component.min / 100})
+                    lp.constraints = append(lp.constraints, lpConstraint{name + "_min", constraint, 'G', component.min})
+                }
+                if component.max > 0 {
+                    excess := lp.addVariable(name + "_excess", weight)
+                    constraint := append(append([]lpTerm{}, terms...), lpTerm{excess, -component.max / 100})
+                    lp.constraints = append(lp.constraints, lpConstraint{name + "_max", constraint, 'L', component.max})
+                }
+            }
+        }
+        for _, limit := range problem.stimulants {
+            terms := amountTerms(limit.nutrient)
+            if len(terms) == 0 || limit.per == 0 {
+                continue
+            }
+            name := lpName(limit.nutrient)
+            if limit.from == limit.over {
+                // amount - excess <= over, so excess is what's past it
+                excess := lp.addVariable(name + "_excess", limit.per)
+                constraint := append(append([]lpTerm{}, terms...), lpTerm{excess, -1})
+                lp.constraints = append(lp.constraints, lpConstraint{name + "_over", constraint, 'L', limit.over})
+            } else {
+                lp.constraints = append(lp.constraints, lpConstraint{name + "_cap", terms, 'L', limit.over * lpStimulantCap})
+            }
+        }
+    }
+
+    if massCap > 0 {
+        terms := make([]lpTerm, len(foodIds))
+        for i := range foodIds {
+            terms[i] = lpTerm{i, 1}
+        }
+        lp.constraints = append(lp.constraints, lpConstraint{"mass", terms, 'L', massCap})
+    }
+    if mercuryCap := problem.opts.maxMercuryUg; mercuryCap > 0 {
+        var terms []lpTerm
+        for i, id := range foodIds {
+            if ppm := problem.mercury[id]; ppm > 0 {
+                terms = append(terms, lpTerm{i, ppm})
+            }
+        }
+        if len(terms) > 0 {
+            lp.constraints = append(lp.constraints, lpConstraint{"mercury", terms, 'L', mercuryCap})
+        }
+    }
+    if costCap := problem.opts.maxCost; costCap > 0 {
+        var terms []lpTerm
+        for i, id := range foodIds {
+            if price := problem.prices[id]; price > 0 {
+                // Priced as purchased, so per gram eaten it's more with
+                // refuse, as in purchasedGrams
+                if food := problem.foods[id]; food.refusePct > 0 && food.refusePct < 100 {
+                    price /= 1 - food.refusePct / 100
+                }
+                terms = append(terms, lpTerm{i, price})
+            }
+        }
+        if len(terms) > 0 {
+            lp.constraints = append(lp.constraints, lpConstraint{"cost", terms, 'L', costCap})
+        }
+    }
+    return lp
+}
+
+// writeTerms writes a sum of terms in LP format, a few to a line.
+func (lp *linearProgram) writeTerms(w *bufio.Writer, terms []lpTerm) {
+    for i, term := range terms {
+        if i > 0 && i % 6 == 0 {
+            w.WriteString("\n   ")
+        }
+        sign := "+"
+        coef := term.coef
+        if coef < 0 {
+            sign, coef = "-", -coef
+        }
+        fmt.Fprintf(w, " %s %.10g %s", sign, coef, lp.variables[term.variable])
+    }
+}
+
+// writeLP writes the CPLEX LP format.
+func (lp *linearProgram) writeLP(out io.Writer, problem *Problem) error {
+    w := bufio.NewWriter(out)
+    fmt.Fprintf(w, "\\ %s: %d foods, %d targets\n", lp.name, len(lp.foodIds), len(problem.targets))
+    for i, id := range lp.foodIds {
+        fmt.Fprintf(w, "\\ %s = grams of %s\n", lp.variables[i], problem.foods[id].description)
+    }
+    if lp.maximize {
+        w.WriteString("Maximize\n obj:")
+    } else {
+        w.WriteString("Minimize\n obj:")
+    }
+    var objective []lpTerm
+    for i, coef := range lp.objective {
+        if coef != 0 {
+            objective = append(objective, lpTerm{i, coef})
+        }
+    }
+    lp.writeTerms(w, objective)
+    w.WriteString("\nSubject To\n")
+    for _, constraint := range lp.constraints {
+        fmt.Fprintf(w, " %s:", constraint.name)
+        lp.writeTerms(w, constraint.terms)
+        sense := ">="
+        if constraint.sense == 'L' {
+            sense = "<="
+        }
+        fmt.Fprintf(w, " %s %.10g\n", sense, constraint.rhs)
+    }
+    w.WriteString("End\n")
+    return w.Flush()
+}
+
+// writeMPS writes free format MPS.
+func (lp *linearProgram) writeMPS(out io.Writer) error {
+    w := bufio.NewWriter(out)
+    fmt.Fprintf(w, "NAME %s\n", lp.name)
+    if lp.maximize {
+        w.WriteString("OBJSENSE\n    MAX\n")
+    }
+    w.WriteString("ROWS\n N obj\n")
+    for _, constraint := range lp.constraints {
+        fmt.Fprintf(w, " %c %s\n", constraint.sense, constraint.name)
+    }
+
+    // MPS goes column by column
+    columns := make([][]string, len(lp.variables))
+    for i, coef := range lp.objective {
+        if coef != 0 {
+            columns[i] = append(columns[i], fmt.Sprintf("obj %.10g", coef))
+        }
+    }
+    for _, constraint := range lp.constraints {
+        for _, term := range constraint.terms {
+            columns[term.variable] = append(columns[term.variable], fmt.Sprintf("%s %.10g", constraint.name, term.coef))
+        }
+    }
+    w.WriteString("COLUMNS\n")
+    for i, entries := range columns {
+        for _, entry := range entries {
+            fmt.Fprintf(w, "    %s %s\n", lp.variables[i], entry)
+        }
+    }
+    w.WriteString("RHS\n")
+    for _, constraint := range lp.constraints {
+        fmt.Fprintf(w, "    rhs %s %.10g\n", constraint.name, constraint.rhs)
+    }
+    w.WriteString("ENDATA\n")
+    return w.Flush()
+}
+
+// exportLinearProgram writes the problem to path, as MPS if it ends in .mps
+// and LP otherwise.
+func exportLinearProgram(path string, problem *Problem) error {
+    file, err := os.Create(path)
+    if err != nil {
+        return err
+    }
+    lp := buildLinearProgram(problem)
+    if strings.EqualFold(filepath.Ext(path), ".mps") {
+        err = lp.writeMPS(file)
+    } else {
+        err = lp.writeLP(file, problem)
+    }
+    if closeErr := file.Close(); err == nil {
+        err = closeErr
+    }
+    return err
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+    "fmt"
+    "math"
+    "strconv"
+    "strings"
+)
+
+// --macros asks for a split of energy between fat, protein and carbs, as
+// fat/protein/carb percentages like 70/25/5 or by name:
+//
+//     keto           70/25/5
+//     zone           30/30/40
+//     high-protein   25/40/35, for a cut
+//
+// Each macro's energy is its grams times Atwater's 9 kcal/g for fat and 4 for
+// protein and carbs. Carbs are net of fiber, as keto counts them. A macro
+// more than --macro-tolerance percentage points from its share costs a
+// point of score for each point further, times --macro-weight, so 10 points
+// off is like a target a tenth short. Shares aren't linear in the grams, so
+// the linear program (lp.go) leaves them out.
+
+var namedMacros = map[string]string{
+    "keto": "70/25/5",
+    "zone": "30/30/40",
+    "high-protein": "25/40/35",
+}
+
+var macroNames = [3]string{"fat", "protein", "carb"}
+
+// kcal per g of each macro, fat, protein and carb
+var macroKcalPerG = [3]float64{9, 4, 4}
+
+type macroRatios struct {
+    name string // as given, for reports
+    percent [3]float64 // fat, protein and carb shares of energy, adding up to 100
+}
+
+// parseMacros reads --macros, nil when it isn't given.
+func parseMacros(opts *Options) (*macroRatios, error) {
+    if opts.macros == "" {
+        return nil, nil
+    }
+    if opts.macroTolerance < 0 || opts.macroWeight < 0 {
+        return nil, fmt.Errorf("--macro-tolerance and --macro-weight can't be negative")
+    }
+    spec := opts.macros
+    if named, exists := namedMacros[strings.ToLower(spec)]; exists {
+        spec = named
+    }
+    parts := strings.Split(spec, "/")
+    if len(parts) != 3 {
+        return nil, fmt.Errorf("--macros %q: want fat/protein/carb percentages like 70/25/5, or keto, zone or high-protein", opts.macros)
+    }
+    ratios := &macroRatios{name: opts.macros}
+    total := float64(0)
+    for i, part := range parts {
+        percent, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(part, "%")), 64)
+        if err != nil || percent < 0 {
+            return nil, fmt.Errorf("--macros %q: bad %s percentage %q", opts.macros, macroNames[i], part)
+        }
+        ratios.percent[i] = percent
+        total += percent
+    }
+    if math.Abs(total - 100) > 0.5 {
+        return nil, fmt.Errorf("--macros %q adds up to %g%%, not 100%%", opts.macros, total)
+    }
+    return ratios, nil
+}
+
+// macroKcal is the kcal from fat, protein and carbs, less fiber, by
+// Atwater's factors, given the grams of each nutrient. It's what --macros,
+// --energy-limit and the composition summary go by rather than the foods'
+// energy values, which are often imputed.
+func macroKcal(grams func(nutrient string) float64) [3]float64 {
+    carbs := grams("Carbohydrate, by difference") - grams("Fiber, total dietary")
+    amounts := [3]float64{grams("Total lipid (fat)"), grams("Protein"), math.Max(carbs, 0)}
+    var kcal [3]float64
+    for i := range amounts {
+        kcal[i] = amounts[i] * macroKcalPerG[i]
+    }
+    return kcal
+}
+
+// recipeKcal is the recipe's macroKcal in total.
+func recipeKcal(problem *Problem, recipe *Recipe) float64 {
+    total := float64(0)
+    for _, kcal := range macroKcal(func(nutrient string) float64 { return recipe.amountOf(problem.nutrientNameToId, nutrient) }) {
+        total += kcal
+    }
+    return total
+}
+
+// macroShares are the percentages of the recipe's macro energy from fat,
+// protein and carbs, all 0 for a recipe without any.
+func (recipe *Recipe) macroShares(problem *Problem) [3]float64 {
+    kcal := macroKcal(func(nutrient string) float64 { return recipe.amountOf(problem.nutrientNameToId, nutrient) })
+    total := kcal[0] + kcal[1] + kcal[2]
+    var shares [3]float64
+    if total > 0 {
+        for i := range kcal {
+            shares[i] = kcal[i] / total * 100
+        }
+    }
+    return shares
+}
+
+// macroPenalty is the --macros part of the score.
+func (recipe *Recipe) macroPenalty(problem *Problem, verbose bool) float64 {
+    ratios := problem.macros
+    if ratios == nil || problem.opts.macroWeight == 0 {
+        return 0
+    }
+    shares := recipe.macroShares(problem)
+    penalty := float64(0)
+    for i := range shares {
+        if off := math.Abs(shares[i] - ratios.percent[i]) - problem.opts.macroTolerance; off > 0 {
+            penalty += off
+        }
+    }
+    penalty *= problem.opts.macroWeight
+    if verbose { fmt.Printf("Penalty for the %s macros: %f\n", ratios.name, penalty) }
+    return penalty
+}
+
+func printMacros(problem *Problem, recipe *Recipe) {
+    ratios := problem.macros
+    shares := recipe.macroShares(problem)
+    fmt.Printf("MACROS %s, fat/protein/carb by energy, within %g points\n", ratios.name, problem.opts.macroTolerance)
+    for i, name := range macroNames {
+        status := "ok"
+        if off := shares[i] - ratios.percent[i]; math.Abs(off) > problem.opts.macroTolerance {
+            status = fmt.Sprintf("%+.1f points", off)
+        }
+        fmt.Printf("  %-8s %5.1f%%  want %g%%  %s\n", name, shares[i], ratios.percent[i], status)
+    }
+}
@@ -1,14 +1,14 @@
 package main
 
 import (
-    "bufio"
-    "encoding/csv"
+    "flag"
     "fmt"
-    "io"
     "math"
     "os"
+    "path/filepath"
     "regexp"
     "runtime/pprof"
+    "sort"
     "strconv"
     "strings"
 )
@@ -17,11 +17,16 @@ type Nutrient struct {
     id int
     units string
     description string
+    datasetDescription string // what NUTR_DEF.txt called it, when that had to be changed
 }
 
 type NutrientInFood struct {
     nutrient Nutrient
     amountPerG float64
+    sourceCode int // NUT_DATA.txt's Src_Cd, how the value was arrived at, 0 if unknown
+    imputed bool // no data points behind the value, see applyImputedPolicy
+    dataPoints int // analyses behind the value
+    stdErrorPerG float64 // standard error of amountPerG, 0 if not given
 }
 
 type Food struct {
@@ -30,6 +35,17 @@ type Food struct {
     description string
     manufacturer string
     nutrients []NutrientInFood
+    measures []Measure // household measures from WEIGHT.txt, in SR26 order
+    refusePct float64 // share of the food as purchased that isn't eaten, e.g. peels
+    refuseDescription string
+    cooking *Cooking // set by --cook, nil for foods eaten as they come
+}
+
+// Measure is a household measure of a food, e.g. 1 "cup, chopped" is 67g.
+type Measure struct {
+    amount float64
+    description string
+    grams float64
 }
 
 func (food *Food) PrintNutrients(numGrams int) {
@@ -47,74 +63,21 @@ type Recipe struct {
     foodQuantities map[int]int // food id -> number of grams
 }
 
-func makeUSDADataReader(filename string) (*os.File, *csv.Reader) {
-    inputFile, err := os.Open(filename)
-    if err != nil { 
-      fmt.Println("File not found. Download the USDA SR26 database from:")
-      fmt.Println("https://www.ars.usda.gov/SP2UserFiles/Place/12354500/Data/SR26/dnload/sr26.zip")
-      fmt.Println("Extract it and put this file next to the extracted files")
-      panic(err) 
-    }
-
-    bufferedReader := bufio.NewReader(inputFile)
-
-    csvReader := csv.NewReader(bufferedReader)
-    csvReader.Comma = '^'
-    csvReader.LazyQuotes = true
-    csvReader.TrailingComma = true
-
-    return inputFile, csvReader
-}
-
-func assertStringHasTwiddles(input string) {
-    if input[0] != byte('~') || input[len(input) - 1] != byte('~') {
-        panic("Expected twiddles in string: " + input)
-    }
-}
-
-func stripTwiddles(input string) string {
-    return input[1:len(input) - 1]
-}
-
-func getNutrientsAndFoods() (map[int]Nutrient, map[string]int, map[int]Food) {
-    foodDescriptionFile, foodDescriptionReader := makeUSDADataReader("FOOD_DES.txt")
-    nutrientDefinitionFile, nutrientDefinitionReader := makeUSDADataReader("NUTR_DEF.txt")
-    foodDataFile, foodDataReader := makeUSDADataReader("NUT_DATA.txt")
-
-    // close inputFile on exit and check for its returned error
-    defer func() {
-        if err := foodDescriptionFile.Close(); err != nil {
-            panic(err)
-        }
-        if err := nutrientDefinitionFile.Close(); err != nil {
-            panic(err)
-        }
-        if err := foodDataFile.Close(); err != nil {
-            panic(err)
-        }
-    }()
+func getNutrients(dataDir string) (map[int]Nutrient, map[string]int) {
+    nutrientDefinitions := openUSDAFile(dataDir, "NUTR_DEF.txt")
+    defer nutrientDefinitions.close()
 
     nutrients := make(map[int]Nutrient, 150)
     nutrientNameToId := make(map[string]int, 150)
-    foods := make(map[int]Food, 5000)
 
     // Read from NUTR_DEF.txt
-    for {
-        record, err := nutrientDefinitionReader.Read()
-        if err == io.EOF {
-            break
-        } else if err != nil {
-            panic(err)
-        }
-
-        assertStringHasTwiddles(record[0])
-        assertStringHasTwiddles(record[1])
-        assertStringHasTwiddles(record[3])
-
-        id, err := strconv.Atoi(stripTwiddles(record[0]))
-        if err != nil { panic(err) }
-        units := stripTwiddles(record[1])
-        description := stripTwiddles(record[3])
+    for record := nutrientDefinitions.next(); record != nil; record = nutrientDefinitions.next() {
+        id, err := nutrientDefinitions.textInt(record, 0)
+        if err != nil { nutrientDefinitions.skip(err); continue }
+        units, err := nutrientDefinitions.text(record, 1)
+        if err != nil { nutrientDefinitions.skip(err); continue }
+        description, err := nutrientDefinitions.text(record, 3)
+        if err != nil { nutrientDefinitions.skip(err); continue }
 
         // Drop the \d:\d entries but keep three-letter abbreviated ones
         matched, err := regexp.MatchString("^\\d+:\\d+", description)
@@ -127,16 +90,10 @@ func getNutrientsAndFoods() (map[int]Nutrient, map[string]int, map[int]Food) {
           }
         }
 
-        // Correction of duplicate description field
-        if id == 208 {
-            description = "Energy, kcal"
-        } else if id == 268 {
-            description = "Energy, kJ"
-        }
-
         _, exists := nutrients[id]
         if exists {
-            panic("nutrient already in nutrients map")
+            nutrientDefinitions.skip(nutrientDefinitions.fieldError(record, 0, "nutrient %d is already defined", id))
+            continue
         }
 
         n := Nutrient{}
@@ -146,154 +103,99 @@ func getNutrientsAndFoods() (map[int]Nutrient, map[string]int, map[int]Food) {
         //fmt.Printf("%s - %s\n", description, units)
 
         nutrients[id] = n
+    }
 
-        nutrientNameToId[description] = id
+    disambiguateNutrients(nutrients)
+    for id, nutrient := range nutrients {
+        nutrientNameToId[nutrient.description] = id
     }
 
-    // Read from FOOD_DES.txt
-    for {
-        record, err := foodDescriptionReader.Read()
-        if err == io.EOF {
-            break
-        } else if err != nil {
-            panic(err)
-        }
+    return nutrients, nutrientNameToId
+}
 
-        assertStringHasTwiddles(record[0])
-        assertStringHasTwiddles(record[1])
-        assertStringHasTwiddles(record[2])
+// disambiguateNutrients renames nutrients that share a description, which
+// would otherwise collide in nutrientNameToId. SR26 has two "Energy"s, which
+// become "Energy, kcal" and "Energy, kJ". Units are appended first, and the id
+// as well if that isn't enough.
+func disambiguateNutrients(nutrients map[int]Nutrient) {
+    byDescription := make(map[string][]int)
+    for id, nutrient := range nutrients {
+        byDescription[nutrient.description] = append(byDescription[nutrient.description], id)
+    }
 
-        ndb, err := strconv.Atoi(stripTwiddles(record[0]))
-        if err != nil { panic(err) }
-        foodGroup := stripTwiddles(record[1])
-        description := stripTwiddles(record[2])
-        manufacturer := stripTwiddles(record[5])
-
-        if foodGroup == "0300" || // baby foods
-           foodGroup == "0800" || // breakfast cereals
-           foodGroup == "1400" || // beverages
-           foodGroup == "2100" || // fast foods
-           foodGroup == "3600" { // restaurant foods
+    for description, ids := range byDescription {
+        if len(ids) < 2 {
             continue
         }
+        unitsUsed := make(map[string]int)
+        for _, id := range ids {
+            unitsUsed[nutrients[id].units]++
+        }
+        for _, id := range ids {
+            nutrient := nutrients[id]
+            nutrient.datasetDescription = description
+            nutrient.description = description + ", " + nutrient.units
+            if unitsUsed[nutrient.units] > 1 {
+                nutrient.description = fmt.Sprintf("%s (%d)", nutrient.description, id)
+            }
+            nutrients[id] = nutrient
+        }
+    }
+}
 
-        if strings.Contains(description, "Lemonade") ||
-           strings.Contains(description, "Ice cream") ||
-           strings.Contains(description, "dehydrated flakes") ||
-           strings.Contains(description, "Alcoholic beverage") ||
-           strings.Contains(description, "freeze-dried") ||
-           strings.Contains(description, "Celery flakes") ||
-           strings.Contains(description, "dehydrated") ||
-           strings.Contains(description, "Candies") ||
-           strings.Contains(description, "Tea,") ||
-           //strings.Contains(strings.ToLower(description), " dried") ||
-
-           // Meat
-           strings.Contains(strings.ToLower(description), "beef,") || 
-           strings.Contains(strings.ToLower(description), "pork,") || 
-           strings.Contains(strings.ToLower(description), "pork skins,") || 
-           strings.Contains(strings.ToLower(description), "chicken,") || 
-           strings.Contains(strings.ToLower(description), "smelt,") || 
-           strings.Contains(strings.ToLower(description), "salmon,") || 
-           strings.Contains(strings.ToLower(description), "fish,") || 
-           strings.Contains(strings.ToLower(description), "mutton,") || 
-           strings.Contains(strings.ToLower(description), "turkey,") || 
-           strings.Contains(strings.ToLower(description), "trout,") || 
-           strings.Contains(strings.ToLower(description), "lamb,") || 
-           strings.Contains(strings.ToLower(description), "caribou,") || 
-           strings.Contains(strings.ToLower(description), " meat,") || 
-
-           // manufactured, likely to contain additives
-           strings.Contains(strings.ToLower(description), "liver cheese,") ||
-           strings.Contains(description, "surimi") ||
-           strings.Contains(strings.ToLower(description), "big franks,") || 
-           strings.Contains(description, "MORNINGSTAR") ||
-           strings.Contains(description, "Meat extender") ||
-           strings.Contains(description, "with low-calorie sweeteners") ||
-           strings.Contains(description, "instant breakfast powder") ||
-           strings.Contains(description, "Orange-flavor drink") ||
-           strings.Contains(description, "Fruit-flavored drink") ||
-           strings.Contains(description, "Leavening agents") ||
-           strings.Contains(description, "Reddi Wip") ||
-           strings.Contains(description, "Frozen novelties") ||
-
-           // added nutrients
-           strings.Contains(description, "Formulated bar,") ||
-           strings.Contains(strings.ToLower(description), " acid,") ||
-           strings.Contains(strings.ToLower(description), " added ") ||
-           strings.Contains(strings.ToLower(description), " supplement") ||
-           strings.Contains(strings.ToLower(description), " fortified") ||
-           strings.Contains(description, "Soy protein isolate") ||
-           strings.Contains(description, "Soy protein concentrate") ||
-
-           // hard to put in a shake
-           //strings.Contains(description, " bran") ||
-           //strings.Contains(description, " meal") ||
-           //strings.Contains(description, " flour") ||
-           //strings.Contains(description, "Wheat germ") ||
-           strings.Contains(description, "PAM cooking spray") ||  // srsly wtf
-
-           // animals
-           strings.Contains(strings.ToLower(description), " seal,") ||
-           strings.Contains(description, "Seal,") ||
-
-           // access
-           strings.Contains(description, "Egg Mix, USDA Commodity") ||
-           strings.Contains(description, "Game meat") ||
-           strings.Contains(description, "Butterbur, canned") ||
-
-           // too expensive
-           strings.Contains(strings.ToLower(description), "mollusks") ||
-           strings.Contains(description, "Spices,") ||
-
-           // body parts I probably won't eat
-           strings.Contains(strings.ToLower(description), " brain") ||
-           strings.Contains(strings.ToLower(description), " liver ") ||
-           strings.Contains(strings.ToLower(description), " liver,") ||
-           strings.Contains(strings.ToLower(description), " kidney") ||
-           strings.Contains(strings.ToLower(description), " lungs,") ||
-
-           // requires significant work to clean
-           strings.Contains(strings.ToLower(description), " chitterlings") ||
-           strings.Contains(strings.ToLower(description), " intestine") ||
-
-           // High-mercury fish
-           strings.Contains(strings.ToLower(description), " mackerel,") ||
-           strings.Contains(strings.ToLower(description), " marlin,") ||
-           strings.Contains(strings.ToLower(description), " orange roughy,") ||
-           strings.Contains(strings.ToLower(description), " shark,") ||
-           strings.Contains(strings.ToLower(description), " swordfish,") ||
-           strings.Contains(strings.ToLower(description), " tilefish,") ||
-           strings.Contains(strings.ToLower(description), " tuna,") ||
-           strings.Contains(strings.ToLower(description), " bluefish,") ||
-           strings.Contains(strings.ToLower(description), " grouper,") ||
-           strings.Contains(strings.ToLower(description), " sea bass") ||
-           strings.Contains(strings.ToLower(description), " bass,") ||
-           strings.Contains(strings.ToLower(description), " carp,") ||
-           strings.Contains(strings.ToLower(description), " cod,") ||
-           strings.Contains(strings.ToLower(description), " croaker,") ||
-           strings.Contains(strings.ToLower(description), " halibut,") ||
-           strings.Contains(strings.ToLower(description), " jacksmelt,") ||
-           strings.Contains(strings.ToLower(description), " lobster,") ||
-           strings.Contains(strings.ToLower(description), " mahi mahi,") ||
-           strings.Contains(strings.ToLower(description), " monkfish,") ||
-           strings.Contains(strings.ToLower(description), " perch,") ||
-           strings.Contains(strings.ToLower(description), " sablefish,") ||
-           strings.Contains(strings.ToLower(description), " skate,") ||
-           strings.Contains(strings.ToLower(description), " snapper,") ||
-           strings.Contains(strings.ToLower(description), " weakfish,") || 
-           strings.Contains(strings.ToLower(description), " whale,") {
-
-            continue
+// renamedNutrients describes each nutrient disambiguateNutrients renamed.
+func renamedNutrients(nutrients map[int]Nutrient) []string {
+    var renamed []string
+    for _, id := range sortedNutrientIds(nutrients) {
+        nutrient := nutrients[id]
+        if nutrient.datasetDescription != "" {
+            renamed = append(renamed, fmt.Sprintf("%d %q is now %q", id, nutrient.datasetDescription, nutrient.description))
         }
+    }
+    return renamed
+}
 
-        if manufacturer == "Campbell Soup Co." {
-            continue
+func sortedNutrientIds(nutrients map[int]Nutrient) []int {
+    ids := make([]int, 0, len(nutrients))
+    for id := range nutrients {
+        ids = append(ids, id)
+    }
+    sort.Ints(ids)
+    return ids
+}
+
+// getFoods reads FOOD_DES.txt and NUT_DATA.txt, linking each food's
+// nutrients to the definitions in nutrients. When keep is given, only the
+// foods it accepts are kept, and NUT_DATA.txt rows for the rest are passed
+// over without being parsed.
+func getFoods(dataDir string, nutrients map[int]Nutrient, keep func(*Food) bool) map[int]Food {
+    foodDescriptions := openUSDAFile(dataDir, "FOOD_DES.txt")
+
+    foods := make(map[int]Food, 5000)
+
+    // Read from FOOD_DES.txt
+    for record := foodDescriptions.next(); record != nil; record = foodDescriptions.next() {
+        ndb, err := foodDescriptions.textInt(record, 0)
+        if err != nil { foodDescriptions.skip(err); continue }
+        foodGroup, err := foodDescriptions.text(record, 1)
+        if err != nil { foodDescriptions.skip(err); continue }
+        description, err := foodDescriptions.text(record, 2)
+        if err != nil { foodDescriptions.skip(err); continue }
+        manufacturer, err := foodDescriptions.text(record, 5)
+        if err != nil { foodDescriptions.skip(err); continue }
+        refuseDescription, err := foodDescriptions.text(record, 7)
+        if err != nil { foodDescriptions.skip(err); continue }
+        // Blank when there's no refuse
+        refusePct := float64(0)
+        if len(record) > 8 && record[8] != "" {
+            refusePct, err = foodDescriptions.float(record, 8)
+            if err != nil { foodDescriptions.skip(err); continue }
         }
 
         _, exists := foods[ndb]
         if exists {
-            panic("ndb already in foods map")
+            foodDescriptions.skip(foodDescriptions.fieldError(record, 0, "food %d is already defined", ndb))
+            continue
         }
 
         f := Food{}
@@ -301,37 +203,45 @@ func getNutrientsAndFoods() (map[int]Nutrient, map[string]int, map[int]Food) {
         f.foodGroup = foodGroup
         f.description = description
         f.manufacturer = manufacturer
+        f.refusePct = refusePct
+        f.refuseDescription = refuseDescription
 
+        if keep != nil && !keep(&f) {
+            continue
+        }
         foods[ndb] = f
     }
+    foodDescriptions.close()
+
+    foodData := openUSDAFile(dataDir, "NUT_DATA.txt")
+    defer foodData.close()
 
     // Read from NUT_DATA.txt
-    for {
-        record, err := foodDataReader.Read()
-        if err == io.EOF {
-            break
-        } else if err != nil {
-            panic(err)
+    for record := foodData.next(); record != nil; record = foodData.next() {
+        ndb, err := foodData.textInt(record, 0)
+        if err != nil { foodData.skip(err); continue }
+        if _, exists := foods[ndb]; !exists {
+            continue
         }
-
-        assertStringHasTwiddles(record[0])
-        assertStringHasTwiddles(record[1])
-
-        ndb, err := strconv.Atoi(stripTwiddles(record[0]))
-        if err != nil { panic(err) }
-        nutrientId, err := strconv.Atoi(stripTwiddles(record[1]))
-        if err != nil { panic(err) }
-        nutrientAmount64, err := strconv.ParseFloat(record[2], 64)
-        if err != nil { panic(err) }
-        numDataPoints, err := strconv.Atoi(record[3])
-        if err != nil { panic(err) }
-
-        // Including this because of the strangeness seen with heart of palm, raw
-        // versus heart of palm, canned with respect to potassium (10x variance)
-        // If the number of data points is 0, the value was calculated or imputed.
-        if numDataPoints == 0 {
-            // Assume they are wrong
-            nutrientAmount64 = float64(0)
+        nutrientId, err := foodData.textInt(record, 1)
+        if err != nil { foodData.skip(err); continue }
+        nutrientAmount64, err := foodData.float(record, 2)
+        if err != nil { foodData.skip(err); continue }
+        numDataPoints, err := foodData.int(record, 3)
+        if err != nil { foodData.skip(err); continue }
+        sourceCode := 0
+        if sourceText, err := foodData.text(record, 5); err == nil && sourceText != "" {
+            sourceCode, err = strconv.Atoi(sourceText)
+            if err != nil { foodData.skip(foodData.fieldError(record, 5, "expected a source code, got %q", sourceText)); continue }
+        }
+        // If the number of data points is 0, the value was calculated or
+        // imputed. It's kept as is here and dealt with by --imputed.
+        imputed := numDataPoints == 0
+        // Blank when there weren't enough data points for one
+        stdError := float64(0)
+        if len(record) > 4 && record[4] != "" {
+            stdError, err = foodData.float(record, 4)
+            if err != nil { foodData.skip(err); continue }
         }
 
         _, exists := nutrients[nutrientId]
@@ -344,6 +254,10 @@ func getNutrientsAndFoods() (map[int]Nutrient, map[string]int, map[int]Food) {
         nif.nutrient = nutrients[nutrientId]
         // divide by 100 because this measurement is for 100g
         nif.amountPerG = nutrientAmount64 / 100
+        nif.sourceCode = sourceCode
+        nif.imputed = imputed
+        nif.dataPoints = numDataPoints
+        nif.stdErrorPerG = stdError / 100
 
         food, exists := foods[ndb]
         if !exists {
@@ -353,7 +267,115 @@ func getNutrientsAndFoods() (map[int]Nutrient, map[string]int, map[int]Food) {
         foods[ndb] = food
     }
 
-    return nutrients, nutrientNameToId, foods
+    addMeasures(dataDir, foods)
+    return foods
+}
+
+// addMeasures reads WEIGHT.txt into the foods' household measures.
+func addMeasures(dataDir string, foods map[int]Food) {
+    weights := openUSDAFile(dataDir, "WEIGHT.txt")
+    defer weights.close()
+
+    for record := weights.next(); record != nil; record = weights.next() {
+        ndb, err := weights.textInt(record, 0)
+        if err != nil { weights.skip(err); continue }
+        amount, err := weights.float(record, 2)
+        if err != nil { weights.skip(err); continue }
+        description, err := weights.text(record, 3)
+        if err != nil { weights.skip(err); continue }
+        grams, err := weights.float(record, 4)
+        if err != nil { weights.skip(err); continue }
+        if amount <= 0 || grams <= 0 {
+            continue
+        }
+
+        food, exists := foods[ndb]
+        if !exists {
+            continue
+        }
+        food.measures = append(food.measures, Measure{amount, description, grams})
+        foods[ndb] = food
+    }
+}
+
+// filterFoods returns the foods that may go into a recipe.
+func filterFoods(opts *Options, allFoods map[int]Food) map[int]Food {
+    foods := make(map[int]Food, len(allFoods))
+    for id, food := range allFoods {
+        if keepFood(opts, &food) {
+            foods[id] = food
+        }
+    }
+    foods, err := filterByFacets(opts, foods)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(2)
+    }
+    reportOnlyFoods(opts, allFoods, foods)
+    return foods
+}
+
+// keepFood reports whether a food may go into a recipe. Custom foods always
+// may, unless --only-foods leaves them out or they've been rejected.
+func keepFood(opts *Options, food *Food) bool {
+    return exclusionRule(opts, food) == ""
+}
+
+// Names for the rules keepFood applies, for saying what left a food out
+const (
+    ruleOnlyFoods = "--only-foods"
+    ruleRejected = "--rejected"
+    ruleFoodGroup = "--food-group/--without-food-group"
+    ruleDiet = "--diet"
+    ruleMaxNova = "--max-nova"
+    ruleBlender = "--blender"
+    ruleMaxPrep = "--max-prep-min"
+    ruleMaxPrice = "--max-price-per-kg"
+    ruleExclusionList = "the exclusion list"
+    rulePreset = "--preset"
+)
+
+// exclusionRule is the first rule that keeps food out of recipes, "" if
+// none does.
+func exclusionRule(opts *Options, food *Food) string {
+    if whitelist := opts.onlyFoods(); whitelist != nil && !whitelist.allows(food) {
+        return ruleOnlyFoods
+    }
+    if rejected := opts.rejected(); rejected != nil && rejected.rejects(food) {
+        return ruleRejected
+    }
+    if food.foodGroup == customFoodGroup {
+        return ""
+    }
+    if !opts.foodGroupFilter().allows(food.foodGroup) {
+        return ruleFoodGroup
+    }
+    if rule := dietRule(opts, food); rule != "" {
+        return rule
+    }
+    if opts.maxNova > 0 && novaClass(food) > opts.maxNova {
+        return ruleMaxNova
+    }
+    if !canBlend(opts.blender, food) {
+        return ruleBlender
+    }
+    if opts.maxPrepMinutes > 0 && !isCooked(opts, food.id) && prepMinutes(food) > opts.maxPrepMinutes {
+        return ruleMaxPrep
+    }
+    if overPrice(opts, food) {
+        return ruleMaxPrice
+    }
+    if rule := opts.exclusions().match(food); rule != nil {
+        name := ruleExclusionList
+        if rule.preset != "" {
+            name = rulePreset + " " + rule.preset
+        }
+        if rule.reason != "" {
+            return name + " (" + rule.reason + ")"
+        }
+        return name
+    }
+    return ""
 }
 
 func calcPenalty(nutrientName string, amount, min, max float64, verbose bool) float64 {
@@ -525,225 +547,38 @@ func (recipe *Recipe) Clone(allFoods map[int]Food, allNutrients map[int]Nutrient
     return newRecipe
 }
 
-func (recipe *Recipe) calculatePenaltyForNutrient(nutrientNameToId map[string]int, nutrientName string, 
-        min, max float64, verbose bool) float64 {
-
-    nutrientId := nutrientNameToId[nutrientName]
-    amount := recipe.nutrientTotals[nutrientId]
-    return calcPenalty(nutrientName, amount, min, max, verbose)
-}
-
-
-func (recipe *Recipe) Score(nutrients map[int]Nutrient, allFoods map[int]Food, nutrientNameToId map[string]int, verbose bool) float64 {
+func (recipe *Recipe) Score(problem *Problem, verbose bool) float64 {
     // For each nutrient, assign a penalty of up to 100, scaled by
     // amount of nutrient that is missing.
     // That is, 100 = none of the nutrient, 0 = suffient amount
     // Assign 100 if nutrient is above recommended intake
 
-    // 145 lbs = 65kg
-
-    // Not reported nutrients
-    // Biotin
-    // Chloride
-    // Chromium
-    // Iodine - 150ug <= Iodine <= 1100ug
-    // Molybdenum <= 10mg
-
-    // Reported nutrients not used
-
-    // Alanine - nonessential amino acid
-    // Arginine - nonessential amino acid
-    // Aspartic acid - nonessential amino acid
-    // Beta-sitosterol - phytosterol
-    // Betaine
-    // Campesterol - phytosterol
-    // Carotene, beta
-    // Carotene, alpha
-    // Cholesterol
-    // Cryptoxanthin, beta
-    // Fatty acids
-    // Fluoride
-    // Folic acid - covered by Folate, DFE
-    // Fructose
-    // Galactose
-    // Glucose (dextrose)
-    // Glutamic acid - nonessential amino acid
-    // Glycine - nonessential amino acid
-    // Hydroxyproline
-    // Lactose
-    // Lycopene
-    // Menaquinone-4
-    // Phytosterols
-    // Proline - nonessential amino acid
-    // Retinol
-    // Serine - nonessential amino acid
-    // Starch
-    // Stigmasterol - phytosterol
-    // Sucrose
-    // Sugars, total
-    // Theobromine
-    // Tocopherol, beta
-    // Tocopherol, delta
-    // Tocopherol, gamma
-    // Tocotrienol, alpha
-    // Tocotrienol, beta
-    // Tocotrienol, delta
-    // Tocotrienol, gamma
-    // Total lipid (fat)
-    // Vitamin D (D2 + D3)
-    // Vitamin D2 (ergocalciferol)
-    // Vitamin D3 (cholecalciferol)
-    // Water
-    // Omega-6 (18:3 n-6 c,c,c)
+    nutrientNameToId := problem.nutrientNameToId
+    opts := problem.opts
 
-    recipe.AssertConsistency(allFoods)
+    recipe.AssertConsistency(problem.foods)
     penalty := float64(0)
 
-    // Need some fat, and not too concerned about excess intake given my build,
-    // but let's not go crazy with it.
-    penalty += recipe.calculatePenaltyForNutrient(nutrientNameToId, "Total lipid (fat)", 60, 300, verbose)
-
-    // 2700 kcal recommended for men
-    penalty += recipe.calculatePenaltyForNutrient(nutrientNameToId, "Energy, kcal", 2700, 10000, verbose)
-
-    // 51g <= protein <= 3510g (?!)
-    // 51g is recommended minimum
-    // 0.82 g/lb is the upper limit of useful protein intake
-    // http://mennohenselmans.com/the-myth-of-1glb-optimal-protein-intake-for-bodybuilders/
-    // 145 * 0.7 = 101.5
-    penalty += recipe.calculatePenaltyForNutrient(nutrientNameToId, "Protein", 101.5, 3510, verbose)
-
-    // 38g <= Fiber, total dietary
-    penalty += recipe.calculatePenaltyForNutrient(nutrientNameToId, "Fiber, total dietary", 38, 0, verbose)
-
-    // 1000mg <= Calcium, Ca <= 2500mg
-    penalty += recipe.calculatePenaltyForNutrient(nutrientNameToId, "Calcium, Ca", 1000, 2500, verbose)
-
-    // 8mg <= Iron, Fe <= 45mg
-    penalty += recipe.calculatePenaltyForNutrient(nutrientNameToId, "Iron, Fe", 8, 45, verbose)
-
-    // 400mg <= Magnesium, Mg
-    penalty += recipe.calculatePenaltyForNutrient(nutrientNameToId, "Magnesium, Mg", 400, 0, verbose)
-
-    // 700mg <= Phosphorus, P <= 4000mg
-    penalty += recipe.calculatePenaltyForNutrient(nutrientNameToId, "Phosphorus, P", 700, 4000, verbose)
-
-    // 4700mg <= Potassium, K
-    penalty += recipe.calculatePenaltyForNutrient(nutrientNameToId, "Potassium, K", 4700, 0, verbose)
-
-    // 1500mg <= Sodium, Na <= 2300mg
-    penalty += recipe.calculatePenaltyForNutrient(nutrientNameToId, "Sodium, Na", 1500, 2300, verbose)
-
-    // 11mg <= Zinc, Zn <= 40mg
-    penalty += recipe.calculatePenaltyForNutrient(nutrientNameToId, "Zinc, Zn", 11, 40, verbose)
-
-    // 0.9mg <= Copper, Cu <= 10mg
-    penalty += recipe.calculatePenaltyForNutrient(nutrientNameToId, "Copper, Cu", 0.9, 10, verbose)
-
-    // 2.3mg <= Manganese, Mn <= 11mg
-    penalty += recipe.calculatePenaltyForNutrient(nutrientNameToId, "Manganese, Mn", 2.3, 11, verbose)
-
-    // 55ug <= Selenium, Se <= 400ug
-    penalty += recipe.calculatePenaltyForNutrient(nutrientNameToId, "Selenium, Se", 55, 400, verbose)
-
-    // 900ug <= Vitamin A, RAE <= 1500ug
-    penalty += recipe.calculatePenaltyForNutrient(nutrientNameToId, "Vitamin A, RAE", 900, 1500, verbose)
-
-    // 15mg <= Vitamin E (alpha-tocopherol) <= 1000mg
-    penalty += recipe.calculatePenaltyForNutrient(nutrientNameToId, "Vitamin E (alpha-tocopherol)", 15, 1000, verbose)
-
-    // 10000ug <= Lutein and 2000ug <= zeaxanthin OR 12000ug <= Lutein + zeaxanthin
-    penalty += recipe.calculatePenaltyForNutrient(nutrientNameToId, "Lutein + zeaxanthin", 12000, 0, verbose)
-
-    // 90mg <= Vitamin C, total ascorbic acid <= 2000mg
-    penalty += recipe.calculatePenaltyForNutrient(nutrientNameToId, "Vitamin C, total ascorbic acid", 90, 2000, verbose)
-
-    // 1.2mg <= Thiamin
-    penalty += recipe.calculatePenaltyForNutrient(nutrientNameToId, "Thiamin", 1.2, 0, verbose)
-
-    // 1.3mg <= Riboflavin
-    penalty += recipe.calculatePenaltyForNutrient(nutrientNameToId, "Riboflavin", 1.3, 0, verbose)
-
-    // 16mg <= Niacin <= 35mg
-    penalty += recipe.calculatePenaltyForNutrient(nutrientNameToId, "Niacin", 16, 35, verbose)
-
-    // 5mg <= Pantothenic acid
-    penalty += recipe.calculatePenaltyForNutrient(nutrientNameToId, "Pantothenic acid", 5, 0, verbose)
-
-    // 1.3mg <= Vitamin B-6 <= 100mg
-    penalty += recipe.calculatePenaltyForNutrient(nutrientNameToId, "Vitamin B-6", 1.3, 100, verbose)
-
-    // 2.4ug <= Vitamin B-12
-    penalty += recipe.calculatePenaltyForNutrient(nutrientNameToId, "Vitamin B-12", 2.4, 0, verbose)
-
-    // 550mg <= Choline, total <= 3500mg
-    penalty += recipe.calculatePenaltyForNutrient(nutrientNameToId, "Choline, total", 550, 3500, verbose)
-
-    // 120ug <= Vitamin K (phylloquinone)
-    penalty += recipe.calculatePenaltyForNutrient(nutrientNameToId, "Vitamin K (phylloquinone)", 120, 0, verbose)
-
-    // 1.95g <= Lysine
-    penalty += recipe.calculatePenaltyForNutrient(nutrientNameToId, "Lysine", 1.95, 0, verbose)
-
-    // 2.535g <= Leucine
-    penalty += recipe.calculatePenaltyForNutrient(nutrientNameToId, "Leucine", 2.535, 0, verbose)
-
-    // 0.65g <= Methionine
-    penalty += recipe.calculatePenaltyForNutrient(nutrientNameToId, "Methionine", 0.65, 0, verbose)
-
-    // 0.26g <= Cystine
-    penalty += recipe.calculatePenaltyForNutrient(nutrientNameToId, "Cystine", 0.26, 0, verbose)
-
-    // 1.69g <= Valine
-    penalty += recipe.calculatePenaltyForNutrient(nutrientNameToId, "Valine", 1.69, 0, verbose)
-
-    // 0.65g <= Histidine
-    penalty += recipe.calculatePenaltyForNutrient(nutrientNameToId, "Histidine", 0.65, 0, verbose)
-
-    // 0.26g <= Tryptophan
-    penalty += recipe.calculatePenaltyForNutrient(nutrientNameToId, "Tryptophan", 0.26, 0, verbose)
-
-    // 0.975g <= Threonine
-    penalty += recipe.calculatePenaltyForNutrient(nutrientNameToId, "Threonine", 0.975, 0, verbose)
-
-    // 1.3g <= Isoleucine
-    penalty += recipe.calculatePenaltyForNutrient(nutrientNameToId, "Isoleucine", 1.3, 0, verbose)
-
-    // 1.6g <= 18:3 n-3 c,c,c (ALA)   // Omega-3
-    penalty += recipe.calculatePenaltyForNutrient(nutrientNameToId, "18:3 n-3 c,c,c (ALA)", 1.6, 0, verbose)
-
-    // 1.6g <= 20:5 n-3 (EPA)      // Omega-3
-    penalty += recipe.calculatePenaltyForNutrient(nutrientNameToId, "20:5 n-3 (EPA)", 1.6, 0, verbose)
-
-    // 1.6g <= 22:6 n-3 (DHA)      // Omega-3
-    penalty += recipe.calculatePenaltyForNutrient(nutrientNameToId, "22:6 n-3 (DHA)", 1.6, 0, verbose)
-
-    // half water from food
-    // 64 fl oz recommended daily
-    // 32 fl oz = 946 grams
-    penalty += recipe.calculatePenaltyForNutrient(nutrientNameToId, "Water", 946, 0, verbose)
-
-    // 1.625g <= Phenylalanine + Tyrosine
-    amountPhenylalanine, exists := recipe.nutrientTotals[nutrientNameToId["Phenylalanine"]]
-    if !exists { amountPhenylalanine = 0 }
-    amountTyrosine, exists := recipe.nutrientTotals[nutrientNameToId["Tyrosine"]]
-    if !exists { amountTyrosine = 0 }
-    pt := amountPhenylalanine + amountTyrosine
-    penalty += calcPenalty("Phenylalanine + Tyrosine", pt, 1.625, 0, verbose)
-
-    // Folate DFE
-    // 400 <= Folate, DFE <= 1000
-    foodFolate := recipe.nutrientTotals[nutrientNameToId["Folate, food"]]
-    folicAcid := recipe.nutrientTotals[nutrientNameToId["Folic acid"]]
-    folateDFE := foodFolate + (1.7 * folicAcid)
-    penalty += calcPenalty("Folate", folateDFE, 400, 1000, verbose)
+    if problem.objective != "" {
+        penalty += recipe.objectiveScore(problem, verbose)
+    } else {
+        for _, target := range problem.targets {
+            amount := recipe.amountOf(nutrientNameToId, target.nutrient)
+            penalty += (calcPenalty(target.nutrient, amount, target.min, target.max, verbose) + aboveMaxPenalty(opts, target, amount, verbose)) * target.penaltyWeight()
+        }
+    }
 
     // Caffeine should be reduced
-    if recipe.nutrientTotals[nutrientNameToId["Caffeine"]] > 20 {
-        caffeinePenalty := (recipe.nutrientTotals[nutrientNameToId["Caffeine"]] - 5)
-        if verbose { fmt.Printf("Penalty for caffeine: %f\n", caffeinePenalty) }
-        penalty += caffeinePenalty
-    }
+    penalty += recipe.stimulantPenalty(problem, verbose)
+
+    penalty += recipe.samenessPenalty(problem, verbose)
+    penalty += recipe.patternPenalty(problem, verbose)
+    penalty += recipe.macroPenalty(problem, verbose)
+    penalty += recipe.diversityPenalty(problem, verbose)
+    penalty += recipe.ratioPenalty(problem, verbose)
+    penalty += recipe.proteinQualityPenalty(problem, verbose)
+    penalty += recipe.energyLimitPenalty(problem, verbose)
+    penalty += recipe.hardTargetPenalty(problem, verbose)
 
     // Dihydrophylloquinone is linked to low bone density
     penalty += recipe.nutrientTotals[nutrientNameToId["Dihydrophylloquinone"]]
@@ -759,16 +594,42 @@ func (recipe *Recipe) Score(nutrients map[int]Nutrient, allFoods map[int]Food, n
     if verbose { fmt.Printf("Penalty for num foods: %f\n", numFoodsPenalty) }
     penalty += numFoodsPenalty
 
-    // Penalize more matter
+    // Penalize more matter, unless the mass is capped outright, in which case
+    // the search never proposes a recipe over the cap
+    if opts.maxMassG == 0 {
+        massPenalty := math.Min(float64(recipe.Mass()) / 3000, 1) * 10
+        if verbose { fmt.Printf("Penalty for mass: %f\n", massPenalty) }
+        penalty += massPenalty
+    }
+
+    if problem.perturb != nil {
+        penalty = problem.perturb(recipe, penalty)
+    }
+    return penalty
+}
+
+func (recipe *Recipe) Mass() int {
     totalMass := int(0)
     for _, grams := range recipe.foodQuantities {
         totalMass += grams
     }
-    massPenalty := math.Min(float64(totalMass) / 3000, 1) * 10
-    if verbose { fmt.Printf("Penalty for mass: %f\n", massPenalty) }
-    penalty += massPenalty
+    return totalMass
+}
 
-    return penalty
+// FoodIdsByGrams lists the foods in the recipe, largest quantity first.
+func (recipe *Recipe) FoodIdsByGrams() []int {
+    foodIds := make([]int, 0, len(recipe.foodQuantities))
+    for foodId := range recipe.foodQuantities {
+        foodIds = append(foodIds, foodId)
+    }
+    sort.Slice(foodIds, func(i, j int) bool {
+        a, b := foodIds[i], foodIds[j]
+        if recipe.foodQuantities[a] != recipe.foodQuantities[b] {
+            return recipe.foodQuantities[a] > recipe.foodQuantities[b]
+        }
+        return a < b
+    })
+    return foodIds
 }
 
 func (recipe *Recipe) PrintTotalNutrients(allNutrients map[int]Nutrient) {
@@ -780,95 +641,508 @@ func (recipe *Recipe) PrintTotalNutrients(allNutrients map[int]Nutrient) {
 
 // ===========================================================================
 
+// Options holds everything that can be changed from the command line.
+type Options struct {
+    source string // what dataDir holds, sourceSR26 or sourceCNF
+    dataDir string
+    customFoodsPath string
+    extraDataDirs stringList // more datasets in the SR26 format
+    tableMappings stringList // mapping files for other delimited nutrition files
+    brandedDir string // the Branded Food Products Database CSVs, "" for none
+    offDumpPath string // an Open Food Facts JSONL dump, "" for none
+    sourcePriority string // comma separated source kinds, highest priority first
+    dedupDescriptions bool // treat foods with the same description as duplicates
+    snapshotPath string // gob snapshot of the parsed data, "" to always parse
+    rebuildCache bool
+    download bool // fetch the dataset if the data files are missing
+    downloadURL string
+    downloadSHA256 string
+    stepSize int
+    maxMassG int // hard cap on recipe mass, 0 means use the soft mass penalty
+    maxMercuryUg float64 // hard cap on the mercury from fish, 0 for none
+    shapleySamples int // orderings sampled for the ingredient importance report, 0 for none
+    monteCarloSamples int // draws for the data uncertainty report, 0 for none
+    saveRecipePath string // write the optimized recipe here as JSON
+    startRecipePath string // optimize from this saved recipe, "" to start empty
+    historyPath string // every saved recipe is recorded here
+    passphraseFile string // holds the passphrase personal files are encrypted with, "" for $SUPERSHAKE_PASSPHRASE
+    surprise int // make a recipe unlike the last this many in historyPath, 0 for off
+    surpriseSeed int64 // picks the foods --surprise starts from, 0 for the time
+    lowMemory bool // only load nutrient data for foods that pass the filters
+    targetOverrides stringList // "Nutrient=min:max", replacing or adding to the default targets
+    maximize string // nutrient to maximize, with the targets as constraints, "" to hit the targets
+    minimize string // likewise, but as little as possible, e.g. "Energy, kcal", or "cost"
+    pricesPath string // CSV of food prices, for --minimize cost
+    foodPrices map[int]float64 // read from pricesPath when first needed
+    maxPricePerKg float64 // leave out foods that cost more than this per kg as purchased, 0 for no limit
+    maxCost float64 // hard cap on what a recipe costs, 0 for none
+    supplementPricesPath string // CSV of supplement prices, for the cost of deficiencies report, "" for none
+    pattern string // dietary pattern to report compliance with, see patterns.go, "" for none
+    patternsPath string // YAML of more patterns, "" for just the built in ones
+    patternWeight float64 // how much keeping to the pattern counts in the score, 0 to only report it
+    macros string // fat/protein/carb shares of energy, or a name, see macros.go, "" for none
+    macroTolerance float64 // percentage points a macro may be off by without penalty
+    macroWeight float64 // how much being off counts in the score, 0 to only report it
+    maxShare float64 // percent of any target's min one food may supply without penalty, 0 for no limit
+    maxShareWeight float64 // what supplying all of a min more than that costs
+    validationReportPath string // write the data validation issues here as JSON
+    quarantine bool // leave foods that fail validation out of recipes
+    exclusionsPath string // YAML list of foods kept out of recipes
+    exclusionList *exclusionList // read from exclusionsPath when first needed
+    presets stringList // built in exclusion lists to add, see presets.go
+    onlyFoodsPath string // file of the only foods recipes may use, by id or description
+    foodWhitelist *foodWhitelist // read from onlyFoodsPath when first needed
+    rejectedPath string // foods never to use again, see reject.go, "" for none
+    rejectedFoods *rejectedFoods // read from rejectedPath when first needed
+    foodGroups stringList // the only food groups to use, by code or name, see food_groups.go
+    withoutFoodGroups stringList // food groups to leave out, replacing defaultWithoutFoodGroups
+    groupFilter *foodGroupFilter // resolved from foodGroups and withoutFoodGroups when first needed
+    dryRun bool // report how many foods each filter leaves, then stop
+    tracePath string // record every step the hill climber tries here, "" for none
+    moves string // comma separated move generators the hill climber tries, see moves.go
+    maxHeapMB int // restart from a checkpoint when the heap grows past this, 0 for no cap, see watchdog.go
+    checkpointPath string // where the watchdog writes the best recipe before restarting
+    profile string // whose flags from profilesPath to use, "" for none
+    profilesPath string
+    diets stringList // --diet names, each ruling out foods tagged with what it doesn't eat
+    dietTagsPath string // YAML of food id: tags, replacing the tags worked out for those foods
+    dietTags map[int][]string // read from dietTagsPath when first needed
+    spotlight bool // only show the nutrients few allowed foods supply
+    costReport bool // report each ingredient's cost per point of score
+    nutritionFacts int // print a Nutrition Facts panel for this many servings, 0 for none
+    labelRounding string // "exact" or "fda", see nutrition_facts.go
+    cook stringList // "foodId=retentionCode[:yield]", foods cooked before blending
+    retentionPath string // USDA retention factors, "" for retn06.txt in dataDir
+    purchasedWeight bool // print foods by weight as purchased, refuse included
+    citations bool // list the studies behind the recipe's values
+    exportLPPath string // write the problem as a linear program here instead of optimizing
+    solver string // "hill" to hill climb, "lp" to solve the linear program
+    maxNova int // leave out foods more processed than this NOVA class, 0 for no limit
+    blender string // "personal", "standard" or "high-power", see texture.go
+    maxPrepMinutes int // leave out foods that take longer than this to prepare, 0 for no limit
+    facets stringList // LanguaL facets foods must have, see langual.go
+    withoutFacets stringList // and facets they mustn't
+    imputedPolicy string // what to do with values that have no data points, see imputed.go
+    imputedFor stringList // "Nutrient=policy", overriding imputedPolicy for one nutrient
+    subjectTo string // YAML file of targets to use instead of the defaults
+    derivedPath string // YAML file of derived nutrients to add to derived.yaml's, "" for none
+    targetsPath string // YAML file of changes to the targets, in the format of targets.yaml, "" for none
+    baselinePath string // what's already been eaten today, taken off the targets, see baseline.go
+    supplements stringList // "name", "name=servings" or "name=min:max", see supplements.go
+    supplementsPath string // YAML file of supplements to add to supplements.yaml's, "" for none
+    antiNutrientsPath string // CSV of oxalate, phytate and the like per food, see anti_nutrients.go
+    stimulants stringList // "Nutrient=over:per", changing a stimulant limit, see stimulants.go
+    maxKcal float64 // caps "Energy, kcal", 0 for no cap
+    hardTargets stringList // nutrients whose targets recipes must be inside, see hard.go
+    aboveMax string // what being over a max costs: linear, steep or hard, see above_max.go
+    ratios stringList // --ratio, "Nutrient / Nutrient=min:max", see ratios.go
+    ratioWeight float64 // multiplies the --ratio penalties
+    proteinQuality string // "pdcaas" or "diaas" to score amino acids as a whole, "" for separate targets, see protein_quality.go
+    proteinQualityWeight float64
+    energyLimits stringList // --energy-limit, "Nutrient=percent" of kcal at most, see energy_limits.go
+    energyLimitWeight float64 // penalty per percentage point over an energy limit
+    dga bool // add the Dietary Guidelines' sugar and fat limits
+    sex string // with age, weightKg and heightCm, works the targets out from the DRIs, see dri.go
+    age int
+    weightKg float64
+    heightCm float64
+    activity string // a key of activityFactors
+    proteinGPerKg float64
+}
+
+// stringList is a flag that can be given more than once.
+type stringList []string
+
+func (list *stringList) String() string {
+    return strings.Join(*list, ",")
+}
+
+func (list *stringList) Set(value string) error {
+    *list = append(*list, value)
+    return nil
+}
+
+// newOptions registers the options shared by every command on fs.
+func newOptions(fs *flag.FlagSet) *Options {
+    opts := &Options{}
+    fs.StringVar(&opts.dataDir, "data-dir", ".", tr("directory holding the extracted USDA SR26 files"))
+    fs.StringVar(&opts.source, "source", sourceSR26, tr("what --data-dir holds: sr26, or cnf for the Canadian Nutrient File CSVs"))
+    fs.StringVar(&opts.customFoodsPath, "custom-foods", "custom_foods.csv", tr("CSV of your own foods to use alongside the dataset"))
+    fs.Var(&opts.extraDataDirs, "extra-data-dir", tr("another dataset in the SR26 format, e.g. SR Legacy (repeatable)"))
+    fs.Var(&opts.tableMappings, "table", tr("YAML mapping describing a delimited nutrition file to take foods from, e.g. lab results (repeatable)"))
+    fs.StringVar(&opts.brandedDir, "branded-dir", "", tr("directory holding the USDA Branded Food Products CSVs"))
+    fs.StringVar(&opts.offDumpPath, "off-dump", "", tr("Open Food Facts JSONL dump (optionally gzipped) to take packaged foods from"))
+    fs.StringVar(&opts.sourcePriority, "source-priority", strings.Join(sourceKinds, ","), tr("which source wins when a food is in several, highest first"))
+    fs.BoolVar(&opts.dedupDescriptions, "dedup-descriptions", false, tr("also treat foods with the same description as duplicates"))
+    fs.StringVar(&opts.snapshotPath, "snapshot", "", tr("cache the parsed data in this file and load from it on later runs"))
+    fs.BoolVar(&opts.rebuildCache, "rebuild-cache", false, tr("reparse the data files even if the snapshot is up to date"))
+    fs.BoolVar(&opts.download, "download", false, tr("download and extract the dataset into --data-dir if it is missing"))
+    fs.StringVar(&opts.downloadURL, "download-url", sr26URL, tr("where --download fetches the dataset zip from"))
+    fs.StringVar(&opts.downloadSHA256, "download-sha256", "", tr("expected sha256 of the downloaded zip, needed unless supershake knows it, or skip to not verify it"))
+    fs.IntVar(&opts.maxNova, "max-nova", 3, tr("leave out foods above this NOVA processing class, 4 is ultra-processed (0 = no limit)"))
+    fs.StringVar(&opts.blender, "blender", "high-power", tr("your blender: personal, standard (hard foods need soaking) or high-power"))
+    fs.IntVar(&opts.maxPrepMinutes, "max-prep-min", 0, tr("leave out foods needing more than this many minutes of soaking or cooking (0 = no limit)"))
+    fs.Var(&opts.facets, "facet", tr("only use foods with this LanguaL facet, e.g. raw, fermented, or a factor description (repeatable)"))
+    fs.Var(&opts.withoutFacets, "without-facet", tr("leave out foods with this LanguaL facet, e.g. animal-derived (repeatable)"))
+    fs.StringVar(&opts.imputedPolicy, "imputed", "zero", tr("what to do with imputed values: zero, trust, discount:FACTOR or exclude-food"))
+    fs.Var(&opts.imputedFor, "imputed-for", tr("the imputed value policy for one nutrient, as \"Nutrient=policy\" (repeatable)"))
+    fs.StringVar(&opts.validationReportPath, "validation-report", "", tr("write the data validation issues to this file as JSON"))
+    fs.BoolVar(&opts.quarantine, "quarantine", false, tr("leave out foods that fail data validation, e.g. energy that doesn't match the macros"))
+    fs.BoolVar(&opts.lowMemory, "low-memory", false, tr("load only the foods the optimizer may use, skipping the snapshot"))
+    fs.IntVar(&opts.stepSize, "step-g", 5, tr("grams added or removed per optimizer move"))
+    fs.IntVar(&opts.maxMassG, "max-mass-g", 0, tr("never consider recipes heavier than this many grams (0 = no cap)"))
+    fs.Float64Var(&opts.maxMercuryUg, "max-mercury-ug", defaultMaxMercuryUg, tr("never consider recipes with more than this many µg of mercury from fish (0 = no cap)"))
+    fs.IntVar(&opts.shapleySamples, "shapley", 0, tr("rank ingredients by importance, sampling this many orderings (0 = off)"))
+    fs.Var(&opts.cook, "cook", tr("cook a food first, as \"foodId=retentionCode[:yield]\" (repeatable)"))
+    fs.StringVar(&opts.retentionPath, "retention-file", "", tr("USDA nutrient retention factors for --cook (default retn06.txt in --data-dir)"))
+    fs.BoolVar(&opts.purchasedWeight, "purchased-weight", false, tr("show the weight to buy, including refuse like peels and bones"))
+    fs.BoolVar(&opts.citations, "citations", false, tr("list the studies behind the recipe's nutrient values"))
+    fs.StringVar(&opts.solver, "solver", "hill", tr("how to optimize: hill (hill climbing) or lp (linear programming)"))
+    fs.StringVar(&opts.exportLPPath, "export-lp", "", tr("write the problem as a linear program (.lp or .mps) for an external solver, and stop"))
+    fs.IntVar(&opts.monteCarloSamples, "monte-carlo", 0, tr("rescore the recipe this many times with values drawn within their standard errors (0 = off)"))
+    fs.StringVar(&opts.saveRecipePath, "save-recipe", "", tr("save the optimized recipe as JSON, e.g. for supershake log"))
+    fs.StringVar(&opts.startRecipePath, "start-recipe", "", tr("optimize starting from this saved recipe, which becomes the new recipe's parent"))
+    fs.StringVar(&opts.passphraseFile, "passphrase-file", "", tr("file holding the passphrase to encrypt the history, intake log and shared recipes with (default $SUPERSHAKE_PASSPHRASE)"))
+    fs.StringVar(&opts.historyPath, "history", "recipe_history.jsonl", tr("file recording every saved recipe and its parent, for supershake history"))
+    fs.IntVar(&opts.surprise, "surprise", 0, tr("make a recipe that meets the targets with as few ingredients as possible in common with the last this many saved (0 = off)"))
+    fs.Int64Var(&opts.surpriseSeed, "surprise-seed", 0, tr("seed for --surprise, to get the same recipe again (0 = random)"))
+    fs.Var(&opts.targetOverrides, "target", tr("override a target as \"Nutrient=min:max\", units optional (repeatable)"))
+    fs.StringVar(&opts.maximize, "maximize", "", tr("maximize this nutrient, treating the targets as constraints"))
+    fs.StringVar(&opts.minimize, "minimize", "", tr("minimize this nutrient, e.g. \"Energy, kcal\", or cost, treating the targets as constraints"))
+    fs.StringVar(&opts.pricesPath, "prices", "prices.csv", tr("CSV of food prices, used by --minimize cost"))
+    fs.Float64Var(&opts.maxPricePerKg, "max-price-per-kg", 0, tr("leave out foods that cost more than this per kg as purchased, by --prices (0 = no limit)"))
+    fs.Float64Var(&opts.maxCost, "max-cost", 0, tr("never consider recipes that cost more than this, by --prices (0 = no cap)"))
+    fs.StringVar(&opts.supplementPricesPath, "supplement-prices", "", tr("CSV of supplement prices, to report which gaps are cheaper to close with a pill than with food"))
+    fs.StringVar(&opts.pattern, "pattern", "", tr("dietary pattern to report compliance with, like eat-lancet"))
+    fs.StringVar(&opts.patternsPath, "patterns", "", tr("YAML file of more dietary patterns, in the format of patterns.yaml"))
+    fs.Float64Var(&opts.patternWeight, "pattern-weight", 0, tr("optimize within the --pattern too, each food group out of its range counting this many times a missed target (0 = only report it)"))
+    fs.StringVar(&opts.macros, "macros", "", tr("split of energy between fat, protein and carbs to aim for, as percentages like 70/25/5, or keto, zone or high-protein"))
+    fs.Float64Var(&opts.macroTolerance, "macro-tolerance", 5, tr("percentage points each macro may be off from --macros without penalty"))
+    fs.Float64Var(&opts.macroWeight, "macro-weight", 1, tr("how much each point a macro is off counts in the score (0 = only report it)"))
+    fs.Float64Var(&opts.maxShare, "max-share", 0, tr("penalize any one food supplying more than this percentage of a nutrient's min, to hedge against bad data (0 = no limit)"))
+    fs.Float64Var(&opts.maxShareWeight, "max-share-weight", 10, tr("what a food supplying a whole min more than --max-share allows costs in the score"))
+    fs.StringVar(&opts.exclusionsPath, "exclusions", defaultExclusionsPath, tr("YAML list of foods to keep out of recipes (default: the built in list, unless the file exists)"))
+    fs.Var(&opts.presets, "preset", tr("also leave out the foods an elimination diet does: low-fodmap, nightshade-free or low-histamine, or change targets for a life stage: pregnancy, lactation, adolescent or older-adult, or a condition: ckd, hypertension or warfarin (repeatable)"))
+    fs.StringVar(&opts.onlyFoodsPath, "only-foods", "", tr("file listing the only foods to use, one NDB id or description per line"))
+    fs.StringVar(&opts.rejectedPath, "rejected", defaultRejectedPath, tr("file of foods rejected with supershake reject, which recipes never use (\"\" for none)"))
+    fs.Var(&opts.foodGroups, "food-group", tr("only use foods from this food group, by SR code or name (repeatable)"))
+    fs.Var(&opts.withoutFoodGroups, "without-food-group", tr("leave out this food group, by SR code or name, instead of baby foods, breakfast cereals, beverages, fast foods and restaurant foods (repeatable, \"none\" to keep them all)"))
+    fs.BoolVar(&opts.dryRun, "dry-run", false, tr("load the foods, report how many each filter leaves, then stop"))
+    fs.StringVar(&opts.profile, "profile", "", tr("use the flags of this profile from the --profiles file, like me or kid"))
+    fs.StringVar(&opts.profilesPath, "profiles", defaultProfilesPath, tr("YAML file of named profiles, each a set of flags for one person"))
+    fs.StringVar(&opts.tracePath, "trace", "", tr("record every step the optimizer tries to this gzipped JSON lines file, for \"supershake replay\""))
+    fs.StringVar(&opts.moves, "moves", "step", tr("the moves the optimizer tries, comma separated: step (a step of one food) and swap (a step from one food to another)"))
+    fs.IntVar(&opts.maxHeapMB, "max-heap-mb", 0, tr("when the heap grows past this many MB, checkpoint the best recipe so far and restart from it (0 = no cap)"))
+    fs.StringVar(&opts.checkpointPath, "checkpoint", "supershake-checkpoint.json", tr("where --max-heap-mb writes the recipe it restarts from"))
+    fs.Var(&opts.diets, "diet", tr("only use foods this diet allows: vegan, vegetarian, pescatarian, kosher or halal (repeatable)"))
+    fs.StringVar(&opts.dietTagsPath, "diet-tags", "", tr("YAML of food id: tags, correcting the tags --diet works out from descriptions"))
+    fs.BoolVar(&opts.spotlight, "spotlight", false, tr("before optimizing, show the nutrients few of the allowed foods supply and what would help, then stop"))
+    fs.IntVar(&opts.nutritionFacts, "nutrition-facts", 0, tr("print a Nutrition Facts panel for the recipe split into this many servings (0 = none)"))
+    fs.StringVar(&opts.labelRounding, "label-rounding", labelRoundingExact, tr("how the Nutrition Facts panel rounds: exact, or fda for the FDA's labeling rules"))
+    fs.BoolVar(&opts.costReport, "cost-report", false, tr("report what each ingredient costs per point of score, with cheaper substitutes"))
+    fs.StringVar(&opts.subjectTo, "subject-to", "", tr("YAML file of targets to use instead of the defaults"))
+    fs.StringVar(&opts.derivedPath, "derived", "", tr("YAML file of derived nutrients, sums of others like \"Niacin + Tryptophan * 1000 / 60\", to use in targets"))
+    fs.StringVar(&opts.targetsPath, "targets", "", tr("YAML file overriding or adding to the targets, a field at a time, in the format \"supershake targets defaults\" prints"))
+    fs.Var(&opts.supplements, "supplement", tr("let the recipe have a supplement from \"supershake targets supplements\", as name, name=servings or name=min:max servings (repeatable)"))
+    fs.StringVar(&opts.supplementsPath, "supplements-file", "", tr("YAML file of supplements to add to the built in ones, in the format \"supershake targets supplements\" prints"))
+    fs.Var(&opts.stimulants, "stimulant", tr("penalize a stimulant only past a threshold, as \"Caffeine=200mg:0.2\" for 0.2 per mg over 200 mg (repeatable)"))
+    fs.StringVar(&opts.antiNutrientsPath, "anti-nutrients", "", tr("CSV of food_id,nutrient,amount_per_100g,units adding anti-nutrients like oxalate, to give maxes with --target"))
+    fs.StringVar(&opts.baselinePath, "baseline", "", tr("what you've already eaten today, a Cronometer export or a CSV of nutrient,amount, to take off the targets"))
+    fs.Float64Var(&opts.maxKcal, "max-kcal", 0, tr("cap energy at this many kcal (0 = no cap)"))
+    fs.Var(&opts.hardTargets, "hard", tr("make this nutrient's target hard, so a recipe outside it is never returned (repeatable)"))
+    fs.StringVar(&opts.aboveMax, "above-max", aboveMaxSteep, tr("what going over a target's max costs: linear, steep (growing with the square of the excess) or hard (never returned)"))
+    fs.Var(&opts.ratios, "ratio", tr("keep the ratio of two nutrients in a range, like \"Sodium, Na / Potassium, K=:1\" (repeatable)"))
+    fs.Float64Var(&opts.ratioWeight, "ratio-weight", 1, tr("penalty per percent a --ratio is outside its range"))
+    fs.StringVar(&opts.proteinQuality, "protein-quality", "", tr("score the protein's amino acids together, by the limiting one: pdcaas or diaas, instead of a target each"))
+    fs.Float64Var(&opts.proteinQualityWeight, "protein-quality-weight", 1, tr("penalty per hundredth the protein quality score is under 1"))
+    fs.Var(&opts.energyLimits, "energy-limit", tr("keep a nutrient under this percentage of the kcal, like \"Fatty acids, total saturated=10\" (repeatable)"))
+    fs.Float64Var(&opts.energyLimitWeight, "energy-limit-weight", 10, tr("penalty per percentage point over an --energy-limit"))
+    fs.BoolVar(&opts.dga, "dga", false, tr("limit saturated fat and added sugar to 10% of kcal and trans fat to 1%, as dietary guidelines do"))
+    fs.StringVar(&opts.sex, "sex", "", tr("male or female, to work the targets out from the Dietary Reference Intakes with --age, --weight-kg and --height-cm"))
+    fs.IntVar(&opts.age, "age", 0, tr("age in years, for the DRI targets"))
+    fs.Float64Var(&opts.weightKg, "weight-kg", 0, tr("body weight in kg, for the DRI targets and per kg ones like \"1.6 g/kg\""))
+    fs.Float64Var(&opts.heightCm, "height-cm", 0, tr("height in cm, for the DRI targets"))
+    fs.StringVar(&opts.activity, "activity", "light", tr("activity level for the DRI energy target: sedentary, light, moderate, active or very-active"))
+    fs.Float64Var(&opts.proteinGPerKg, "protein-g-per-kg", 0.8, tr("protein per kg of body weight for the DRI targets, 0.8 is the RDA"))
+    return opts
+}
+
 func main () {
-    fmt.Println("Loading")
-    STEPSIZE := int(5)
+    // supershake [command] [flags]; no command means optimize
+    args := os.Args[1:]
+    command := ""
+    if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+        command, args = args[0], args[1:]
+    }
+
+    switch command {
+    case "":
+        runOptimize(args)
+    case "demo":
+        runDemo(args)
+    case "fixture":
+        runFixture(args)
+    case "foods":
+        runFoods(args)
+    case "robustness":
+        runRobustness(args)
+    case "log":
+        runLog(args)
+    case "targets":
+        runTargets(args)
+    case "serve":
+        runServe(args)
+    case "history":
+        runHistory(args)
+    case "edit":
+        runEdit(args)
+    case "week":
+        runWeek(args)
+    case "encrypt", "decrypt":
+        runCrypt(command, args)
+    case "why-excluded":
+        runWhyExcluded(args)
+    case "reject":
+        runReject(args)
+    case "selftest":
+        runSelftest(args)
+    case "replay":
+        runReplay(args)
+    case "profiles":
+        runProfiles(args)
+    case "batch":
+        runBatch(args)
+    default:
+        fmt.Fprintf(os.Stderr, tr("Unknown command %q\n"), command)
+        os.Exit(2)
+    }
+}
 
-    f, err := os.Create("cpuProfile")
+func runOptimize(args []string) {
+    fs := flag.NewFlagSet("supershake", flag.ExitOnError)
+    opts := newOptions(fs)
+    demo := fs.Bool("demo", false, tr("optimize against the built in sample data, as \"supershake demo\" does"))
+    cpuProfile := fs.String("cpuprofile", "", tr("write a CPU profile of the run to this file, for go tool pprof"))
+    fs.Parse(args)
+    applyProfile(fs, opts)
+    if *demo {
+        useDemoData(fs, opts)
+    }
+
+    if *cpuProfile != "" {
+        f, err := os.Create(*cpuProfile)
+        if err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            os.Exit(2)
+        }
+        pprof.StartCPUProfile(f)
+        defer pprof.StopCPUProfile()
+    }
+
+    optimizeAndPrint(opts)
+}
+
+// runDemo optimizes against the small fixture dataset that ships with the
+// source, so there is something to look at before downloading SR26.
+func runDemo(args []string) {
+    fs := flag.NewFlagSet("supershake demo", flag.ExitOnError)
+    opts := newOptions(fs)
+    fs.Parse(args)
+    applyProfile(fs, opts)
+    useDemoData(fs, opts)
+    optimizeAndPrint(opts)
+}
+
+// useDemoData points opts at the sample data, unless --data-dir was given.
+func useDemoData(fs *flag.FlagSet, opts *Options) {
+    given := make(map[string]bool)
+    fs.Visit(func(f *flag.Flag) {
+        given[f.Name] = true
+    })
+    if !given["data-dir"] {
+        fs.Set("data-dir", findDemoDataDir())
+    }
+    if !given["max-mass-g"] {
+        // A shake you could actually drink, rather than the soft mass penalty
+        fs.Set("max-mass-g", "2500")
+    }
+    if !given["imputed"] {
+        // The sample energy values have no data points behind them, so
+        // zeroing them would leave every demo recipe at 0 kcal
+        fs.Set("imputed", "trust")
+    }
+
+    fmt.Println("Demo: optimizing against the bundled sample data in", opts.dataDir)
+    fmt.Println("The sample foods are synthetic, so don't shop from this recipe.")
+}
+
+// findDemoDataDir looks for testdata/fixture next to the working directory,
+// then next to the executable, then uses the copy built into the binary.
+func findDemoDataDir() string {
+    demoDir := filepath.Join("testdata", "fixture")
+    if _, err := os.Stat(demoDir); err == nil {
+        return demoDir
+    }
+    if executable, err := os.Executable(); err == nil {
+        candidate := filepath.Join(filepath.Dir(executable), demoDir)
+        if _, err := os.Stat(candidate); err == nil {
+            return candidate
+        }
+    }
+    dir, err := extractEmbeddedFixture()
     if err != nil {
-        panic(err)
-    }
-    pprof.StartCPUProfile(f)
-    defer pprof.StopCPUProfile()
-
-    allNutrients, nutrientNameToId, allFoods := getNutrientsAndFoods()
-
-    bestRecipeEver := NewRecipe(allFoods, allNutrients)
-    bestScoreEver := bestRecipeEver.Score(allNutrients, allFoods, nutrientNameToId, false)
-
-    for bestScoreEver > 0 {
-        fmt.Println(bestRecipeEver.foodQuantities)
-        fmt.Println("Best score ever", bestScoreEver)
-
-        var bestRecipeThisRound *Recipe
-        bestScoreThisRound := bestScoreEver 
-
-        // Start from the best ever
-        // This one moves around the search space, testing the options
-        // it must be cloned into bestRecipeThisRound!
-        currentRecipe := bestRecipeEver.Clone(allFoods, allNutrients)    
-
-        for _, food := range allFoods {
-            var newScore float64
-
-            /*if !currentRecipe.Equals(bestRecipeEver, allFoods) {
-                fmt.Println(bestRecipeEver)
-                fmt.Println(currentRecipe)
-                panic("did not undo all steps")
-            }*/
-
-            // try removing 
-            if currentRecipe.HasFood(&food) {
-                currentRecipe.RemoveFood(allFoods, &food, STEPSIZE)
-                newScore = currentRecipe.Score(allNutrients, allFoods, nutrientNameToId, false)
-                if newScore < bestScoreThisRound {
-                    // Better, woo!
-                    bestRecipeThisRound = currentRecipe.Clone(allFoods, allNutrients)
-                    bestScoreThisRound = newScore
-                }
-                // always undo
-                currentRecipe.AddFood(allFoods, &food, STEPSIZE)
-            }
+        fmt.Fprintln(os.Stderr, "Couldn't unpack the sample data:", err)
+        os.Exit(1)
+    }
+    return dir
+}
 
-            // =================================
+func optimizeAndPrint(opts *Options) {
+    if opts.dryRun {
+        runDryRun(opts)
+        return
+    }
+    if opts.spotlight {
+        runSpotlight(opts)
+        return
+    }
+    if err := checkLabelRounding(opts.labelRounding); err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(2)
+    }
+    fmt.Println(tr("Loading"))
+    problem := loadProblem(opts)
+    var supplements []supplementPrice
+    if opts.supplementPricesPath != "" {
+        var err error
+        supplements, err = readSupplementPrices(opts.supplementPricesPath, problem.nutrients, problem.nutrientNameToId)
+        if err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            os.Exit(1)
+        }
+    }
+    if opts.exportLPPath != "" {
+        if err := exportLinearProgram(opts.exportLPPath, problem); err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            os.Exit(1)
+        }
+        fmt.Println("Wrote", opts.exportLPPath)
+        return
+    }
 
-            // try adding 
-            currentRecipe.AddFood(allFoods, &food, STEPSIZE)
-            newScore = currentRecipe.Score(allNutrients, allFoods, nutrientNameToId, false)
-            if newScore < bestScoreThisRound {
-                // Better, woo!
-                bestRecipeThisRound = currentRecipe.Clone(allFoods, allNutrients)
-                bestScoreThisRound = newScore
-            }
-            // always undo
-            currentRecipe.RemoveFood(allFoods, &food, STEPSIZE)
-        }
-
-        if bestRecipeThisRound == nil {
-            // We never got a chance to set bestRecipeThisRound,
-            // which means we found nothing better than bestRecipeEver
-
-            fmt.Println("Reached local maxima")
-            fmt.Println(bestRecipeEver)
-            bestRecipeEver.Score(allNutrients, allFoods, nutrientNameToId, true)
-            for foodId, grams := range bestRecipeEver.foodQuantities {
-                food := allFoods[foodId]
-                fmt.Printf("%d grams of %s\n", grams, food.description)
-                food.PrintNutrients(grams)
-                fmt.Println("\n")
-            }
-            fmt.Println("TOTAL NUTRIENTS")
-            bestRecipeEver.PrintTotalNutrients(allNutrients)
-            break
-        } else {
-            if bestScoreThisRound > bestScoreEver {
-                panic("wtf")
+    start := NewRecipe(problem.foods, problem.nutrients)
+    lineage := recipeLineage{derivedBy: "optimized"}
+    if opts.startRecipePath != "" {
+        if opts.solver != "hill" {
+            fmt.Fprintln(os.Stderr, "--start-recipe needs --solver hill, the LP solver always starts from nothing")
+            os.Exit(2)
+        }
+        quantities, err := readRecipeFile(opts.startRecipePath)
+        if err == nil {
+            lineage.parent, err = readRecipeId(opts.startRecipePath)
+        }
+        if err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            os.Exit(1)
+        }
+        for foodId, grams := range quantities {
+            food, exists := problem.foods[foodId]
+            if !exists {
+                fmt.Fprintf(os.Stderr, "%s: food %d isn't one the optimizer may use\n", opts.startRecipePath, foodId)
+                os.Exit(1)
             }
-            // Done trying all the foods
-            bestRecipeEver = bestRecipeThisRound
-            bestScoreEver = bestScoreThisRound
+            start.AddFood(problem.foods, &food, grams)
         }
+        lineage.derivedBy = "re-optimized"
+    }
+    if opts.surprise > 0 {
+        if opts.solver != "hill" || opts.startRecipePath != "" {
+            fmt.Fprintln(os.Stderr, "--surprise needs --solver hill and picks its own start, so no --start-recipe")
+            os.Exit(2)
+        }
+        seed := surpriseSeed(opts)
+        var err error
+        start, err = surpriseStart(problem, seed)
+        if err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            os.Exit(1)
+        }
+        lineage.derivedBy = "surprise"
+        lineage.note = fmt.Sprintf("seed %d", seed)
+    }
+
+    if opts.tracePath != "" {
+        if opts.solver != "hill" {
+            fmt.Fprintln(os.Stderr, "--trace needs --solver hill, the LP solver doesn't take steps")
+            os.Exit(2)
+        }
+        trace, err := newTrace(opts.tracePath, os.Args[1:], opts.stepSize, start)
+        if err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            os.Exit(1)
+        }
+        problem.trace = trace
     }
-}
 
+    var bestRecipe *Recipe
+    switch opts.solver {
+    case "hill":
+        problem.watchdog = startHeapWatchdog(opts)
+        bestRecipe, _ = optimize(problem, start, true)
+        if problem.trace != nil {
+            if err := problem.trace.Close(); err != nil {
+                fmt.Fprintln(os.Stderr, "--trace:", err)
+                os.Exit(1)
+            }
+            fmt.Println("Wrote the search to", opts.tracePath)
+        }
+        fmt.Println(tr("Reached local maxima"))
+    case "lp":
+        var err error
+        bestRecipe, err = solveWithLP(problem)
+        if err != nil {
+            fmt.Fprintln(os.Stderr, "LP solver:", err)
+            os.Exit(1)
+        }
+    default:
+        fmt.Fprintf(os.Stderr, "Unknown --solver %q, want hill or lp\n", opts.solver)
+        os.Exit(2)
+    }
 
+    if err := bestRecipe.feasible(problem); err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(1)
+    }
+    printRecipe(problem, bestRecipe)
+    if opts.nutritionFacts > 0 {
+        fmt.Println()
+        printNutritionFacts(problem, bestRecipe, opts.nutritionFacts, opts.labelRounding)
+    }
+    if problem.objective == costObjective {
+        printCostComparison(problem, bestRecipe)
+    }
+    if opts.costReport {
+        fmt.Println()
+        printCostReport(problem, bestRecipe)
+    }
+    if opts.supplementPricesPath != "" {
+        fmt.Println()
+        printDeficiencyCosts(problem, bestRecipe, supplements)
+    }
+    if opts.shapleySamples > 0 {
+        fmt.Println()
+        printImportance(problem, bestRecipe, opts.shapleySamples)
+    }
+    if opts.monteCarloSamples > 0 {
+        fmt.Println()
+        printUncertainty(problem, bestRecipe, opts.monteCarloSamples)
+    }
+    if opts.saveRecipePath != "" {
+        id, err := writeRecipeFile(opts.saveRecipePath, opts.historyPath, problem, bestRecipe, lineage)
+        if err != nil {
+            fmt.Fprintln(os.Stderr, "--save-recipe:", err)
+            os.Exit(1)
+        }
+        fmt.Printf("Saved recipe %s to %s\n", id, opts.saveRecipePath)
+    }
+}
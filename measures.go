@@ -0,0 +1,102 @@
+package main
+
+import (
+    "fmt"
+    "math"
+    "strings"
+)
+
+// Spellings of household units, mapped to how WEIGHT.txt writes them.
+var measureSynonyms = map[string]string{
+    "cups": "cup",
+    "tablespoon": "tbsp", "tablespoons": "tbsp", "tbs": "tbsp", "tbl": "tbsp",
+    "teaspoon": "tsp", "teaspoons": "tsp",
+    "pieces": "piece",
+    "slices": "slice",
+}
+
+// measureMatches reports whether a WEIGHT.txt description like
+// "cup, chopped" or "tbsp" is the unit someone typed.
+func measureMatches(description string, unit string) bool {
+    description = strings.ToLower(description)
+    return description == unit ||
+        strings.HasPrefix(description, unit + ",") ||
+        strings.HasPrefix(description, unit + " ")
+}
+
+// gramsFor converts an amount in a household unit, e.g. 2.5 "tbsp", to grams
+// using the food's measures. Weights are converted directly.
+func (food *Food) gramsFor(amount float64, unit string) (float64, error) {
+    unit = canonicalUnit(unit)
+    if scale, isMass := massUnits[unit]; isMass {
+        return amount * scale, nil
+    }
+    if scale, isWeight := importWeightUnits[strings.ToLower(unit)]; isWeight {
+        return amount * scale, nil
+    }
+
+    unit = strings.ToLower(strings.TrimSuffix(unit, "."))
+    if synonym, exists := measureSynonyms[unit]; exists {
+        unit = synonym
+    }
+    for _, measure := range food.measures {
+        if measureMatches(measure.description, unit) {
+            return amount / measure.amount * measure.grams, nil
+        }
+    }
+
+    var known []string
+    for _, measure := range food.measures {
+        known = append(known, measure.description)
+    }
+    if len(known) == 0 {
+        return 0, fmt.Errorf("%s has no household measures, give grams", food.description)
+    }
+    return 0, fmt.Errorf("%s has no %q measure, try one of: %s", food.description, unit, strings.Join(known, "; "))
+}
+
+// householdAmount describes grams of the food in the first of its measures
+// that gives a sensible count, e.g. "2.5 tbsp". It's "" when none does.
+func (food *Food) householdAmount(grams int) string {
+    for _, measure := range food.measures {
+        count := float64(grams) / measure.grams * measure.amount
+        // Quarters are as fine as anyone measures
+        rounded := math.Round(count * 4) / 4
+        if rounded >= 0.5 && rounded <= 10 {
+            return fmt.Sprintf("%g %s", rounded, measure.description)
+        }
+    }
+    return ""
+}
+
+// gramsString is "90 grams", with a household measure after it when the
+// food has one that fits.
+func (food *Food) gramsString(grams int) string {
+    if household := food.householdAmount(grams); household != "" {
+        return fmt.Sprintf("%d grams (%s)", grams, household)
+    }
+    return fmt.Sprintf("%d grams", grams)
+}
+
+// purchasedGrams is how much of the food to buy to end up with grams of it
+// to eat, once the refuse (peels, bones, stems) is taken off.
+func (food *Food) purchasedGrams(grams int) int {
+    if food.refusePct <= 0 || food.refusePct >= 100 {
+        return grams
+    }
+    return int(math.Ceil(float64(grams) / (1 - food.refusePct / 100)))
+}
+
+// purchasedString is gramsString for the weight as purchased, e.g.
+// "125 grams as purchased (90 grams without the large stems and roots, 28%)".
+func (food *Food) purchasedString(grams int) string {
+    purchased := food.purchasedGrams(grams)
+    if purchased == grams {
+        return food.gramsString(grams)
+    }
+    refuse := "refuse"
+    if food.refuseDescription != "" {
+        refuse = strings.ToLower(food.refuseDescription)
+    }
+    return fmt.Sprintf("%d grams as purchased (%d grams without the %s, %g%%)", purchased, grams, refuse, food.refusePct)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+    _ "embed"
+    "encoding/csv"
+    "fmt"
+    "strconv"
+    "strings"
+)
+
+// Fish are limited by the mercury they'd add, from the FDA's measurements in
+// mercury.csv, rather than kept out by name. --max-mercury-ug caps a
+// recipe's total like --max-mass-g caps its weight. Fish the table has no
+// species for count as having none.
+
+//go:embed mercury.csv
+var mercuryCSV string
+
+// The EPA's reference dose is 0.1 µg per kg of body weight a day, 7 µg for
+// 70 kg
+const defaultMaxMercuryUg = 7
+
+// Only the finfish and shellfish group is matched, "bass" shouldn't find
+// "Calabash"
+const fishFoodGroup = "1500"
+
+type mercurySpecies struct {
+    words []string
+    ppm float64 // µg per g
+}
+
+var mercuryTable []mercurySpecies
+
+func loadMercuryTable() ([]mercurySpecies, error) {
+    if mercuryTable != nil {
+        return mercuryTable, nil
+    }
+    reader := csv.NewReader(strings.NewReader(mercuryCSV))
+    reader.Comment = '#'
+    records, err := reader.ReadAll()
+    if err != nil {
+        return nil, fmt.Errorf("mercury.csv: %v", err)
+    }
+    var table []mercurySpecies
+    for _, record := range records[1:] {
+        ppm, err := strconv.ParseFloat(record[1], 64)
+        if err != nil {
+            return nil, fmt.Errorf("mercury.csv: bad ppm for %s: %v", record[0], err)
+        }
+        table = append(table, mercurySpecies{descriptionWords(record[0]), ppm})
+    }
+    mercuryTable = table
+    return mercuryTable, nil
+}
+
+func descriptionWords(text string) []string {
+    return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+        return !(r >= 'a' && r <= 'z')
+    })
+}
+
+// sameWord compares a word from a description with one from the table,
+// allowing for plurals: sardines, clams, anchovies.
+func sameWord(word, species string) bool {
+    return word == species || word == species + "s" || word == species + "es" ||
+        (strings.HasSuffix(species, "y") && word == species[:len(species) - 1] + "ies")
+}
+
+// matches reports whether the species' words come one after another in
+// words.
+func (species *mercurySpecies) matches(words []string) bool {
+    for start := 0; start + len(species.words) <= len(words); start++ {
+        match := true
+        for i, speciesWord := range species.words {
+            match = match && sameWord(words[start + i], speciesWord)
+        }
+        if match {
+            return true
+        }
+    }
+    return false
+}
+
+// mercuryPpm is the mercury in food, in µg per g, from the most specific
+// species that matches it.
+func mercuryPpm(food *Food, table []mercurySpecies) float64 {
+    if food.foodGroup != fishFoodGroup {
+        return 0
+    }
+    words := descriptionWords(food.description)
+    best := -1
+    ppm := float64(0)
+    for _, species := range table {
+        if len(species.words) > best && species.matches(words) {
+            best, ppm = len(species.words), species.ppm
+        }
+    }
+    return ppm
+}
+
+// mercuryByFood is the mercury in each food that has any, in µg per g.
+func mercuryByFood(foods map[int]Food) (map[int]float64, error) {
+    table, err := loadMercuryTable()
+    if err != nil {
+        return nil, err
+    }
+    mercury := make(map[int]float64)
+    for id, food := range foods {
+        if ppm := mercuryPpm(&food, table); ppm > 0 {
+            mercury[id] = ppm
+        }
+    }
+    return mercury, nil
+}
+
+// mercuryUg is the mercury in the recipe, in µg.
+func (recipe *Recipe) mercuryUg(problem *Problem) float64 {
+    total := float64(0)
+    for foodId, grams := range recipe.foodQuantities {
+        total += problem.mercury[foodId] * float64(grams)
+    }
+    return total
+}
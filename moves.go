@@ -0,0 +1,210 @@
+package main
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+)
+
+// The hill climber (optimize.go) tries moves from a recipe and takes the one
+// that helps most, until none helps. Which moves it tries comes from move
+// generators, picked with --moves, one or more of:
+//
+//     step   add or take away a step of each food, the default
+//     swap   move a step from each food in the recipe to each other food,
+//            which can get out of a local minimum where the mass or cost
+//            cap stops any one food being added
+//
+// A search that wants other neighborhoods, group rebalancing say, doesn't
+// need to touch the optimizer. Add a file with a MoveGenerator and register
+// it:
+//
+//     func init() {
+//         RegisterMoveGenerator("rebalance", rebalanceMoves{})
+//     }
+//
+// and run with --moves step,rebalance. Whatever the moves, the optimizer
+// only takes ones within --max-mass-g, --max-mercury-ug, --max-cost, hard
+// targets and required foods, so generators needn't check them.
+
+// A Step changes the grams of one food, up or down.
+type Step struct {
+    FoodId int
+    Grams int
+}
+
+// A Move is one or more steps tried together, each of a different food.
+type Move []Step
+
+// MoveGenerator yields the moves to try from recipe. The optimizer tries
+// each move as it's yielded, leaving recipe as it was by the time yield
+// returns. step is --step-g.
+type MoveGenerator interface {
+    Moves(problem *Problem, recipe *Recipe, step int, yield func(Move))
+}
+
+var moveGenerators = map[string]MoveGenerator{
+    "step": stepMoves{},
+    "swap": swapMoves{},
+}
+
+// RegisterMoveGenerator makes generator available to --moves as name.
+func RegisterMoveGenerator(name string, generator MoveGenerator) {
+    if _, exists := moveGenerators[name]; exists {
+        panic("two move generators named " + name)
+    }
+    moveGenerators[name] = generator
+}
+
+// findMoveGenerators looks up --moves.
+func findMoveGenerators(opts *Options) ([]MoveGenerator, error) {
+    var generators []MoveGenerator
+    for _, name := range strings.Split(opts.moves, ",") {
+        name = strings.TrimSpace(name)
+        generator, exists := moveGenerators[name]
+        if !exists {
+            var names []string
+            for name := range moveGenerators {
+                names = append(names, name)
+            }
+            sort.Strings(names)
+            return nil, fmt.Errorf("--moves: no move generator %q, want any of %s", name, strings.Join(names, ", "))
+        }
+        generators = append(generators, generator)
+    }
+    return generators, nil
+}
+
+type stepMoves struct{}
+
+func (stepMoves) Moves(problem *Problem, recipe *Recipe, step int, yield func(Move)) {
+    for _, food := range problem.foods {
+        if problem.isSupplement(food.id) {
+            continue
+        }
+        if recipe.HasFood(&food) {
+            yield(Move{{food.id, -step}})
+        }
+        yield(Move{{food.id, step}})
+    }
+}
+
+type swapMoves struct{}
+
+func (swapMoves) Moves(problem *Problem, recipe *Recipe, step int, yield func(Move)) {
+    // Yielded moves change foodQuantities, so go over a copy
+    var inRecipe []int
+    for foodId := range recipe.foodQuantities {
+        inRecipe = append(inRecipe, foodId)
+    }
+    for _, from := range inRecipe {
+        if problem.isSupplement(from) {
+            continue
+        }
+        for to := range problem.foods {
+            if to != from && !problem.isSupplement(to) {
+                yield(Move{{from, -step}, {to, step}})
+            }
+        }
+    }
+}
+
+func (move Move) apply(allFoods map[int]Food, recipe *Recipe) {
+    for _, step := range move {
+        food := allFoods[step.FoodId]
+        if step.Grams > 0 {
+            recipe.AddFood(allFoods, &food, step.Grams)
+        } else {
+            recipe.RemoveFood(allFoods, &food, -step.Grams)
+        }
+    }
+}
+
+func (move Move) undo(allFoods map[int]Food, recipe *Recipe) {
+    for i := len(move) - 1; i >= 0; i-- {
+        food := allFoods[move[i].FoodId]
+        if move[i].Grams > 0 {
+            recipe.RemoveFood(allFoods, &food, move[i].Grams)
+        } else {
+            recipe.AddFood(allFoods, &food, -move[i].Grams)
+        }
+    }
+}
+
+func (move Move) adds() bool {
+    for _, step := range move {
+        if step.Grams > 0 {
+            return true
+        }
+    }
+    return false
+}
+
+func (move Move) removes() bool {
+    for _, step := range move {
+        if step.Grams < 0 {
+            return true
+        }
+    }
+    return false
+}
+
+// describe is like "-5g of Eggs, +5g of Tofu".
+func (move Move) describe(allFoods map[int]Food) string {
+    var steps []string
+    for _, step := range move {
+        steps = append(steps, fmt.Sprintf("%+dg of %s", step.Grams, allFoods[step.FoodId].description))
+    }
+    return strings.Join(steps, ", ")
+}
+
+// canTry is whether recipe can take move without leaving the caps, or going
+// under a required food's grams. The rest is checked once it's taken, by
+// allowedAfter.
+func (problem *Problem) canTry(recipe *Recipe, move Move) bool {
+    if len(move) == 0 {
+        return false
+    }
+    opts := problem.opts
+    mass, mercury := 0, float64(0)
+    for _, step := range move {
+        if _, exists := problem.foods[step.FoodId]; !exists || step.Grams == 0 {
+            return false
+        }
+        grams := recipe.foodQuantities[step.FoodId]
+        if step.Grams < 0 && (grams == 0 || grams + step.Grams < problem.required[step.FoodId]) {
+            return false
+        }
+        mass += step.Grams
+        mercury += problem.mercury[step.FoodId] * float64(step.Grams)
+    }
+    if !move.adds() {
+        return true
+    }
+    if opts.maxMassG > 0 && recipe.Mass() + mass > opts.maxMassG {
+        return false
+    }
+    if opts.maxMercuryUg > 0 && recipe.mercuryUg(problem) + mercury > opts.maxMercuryUg {
+        return false
+    }
+    return true
+}
+
+// allowedAfter is whether recipe, having just taken move, is still within
+// the cost cap and hard maxes, and meets as many hard mins as the
+// hardMinsMet it had before. The cost cap, with refuse, is easiest checked
+// after.
+func (problem *Problem) allowedAfter(recipe *Recipe, move Move, hard bool, hardMinsMet int) bool {
+    if move.adds() {
+        if problem.opts.maxCost > 0 && recipe.cost(problem) > problem.opts.maxCost {
+            return false
+        }
+        if hard && recipe.overHardMax(problem) {
+            return false
+        }
+    }
+    if hard && move.removes() && recipe.hardMinsMet(problem) < hardMinsMet {
+        return false
+    }
+    return true
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+    "strings"
+)
+
+// NOVA sorts foods by how processed they are:
+//
+//     1  unprocessed or minimally processed: fruit, vegetables, eggs, milk
+//     2  processed culinary ingredients: oils, butter, sugar, salt
+//     3  processed foods: canned vegetables, cheese, cured fish
+//     4  ultra-processed: formulated bars, flavoured drinks, whipped toppings
+//
+// SR26 doesn't record it, so it's guessed from the description, falling back
+// to a class for the food group. --max-nova drops foods above a class.
+
+// novaKeywords are checked in order against the lower case description, the
+// first match deciding the class.
+var novaKeywords = []struct {
+    keyword string
+    class int
+}{
+    // Ultra-processed, most of which used to be excluded one by one
+    {"reddi wip", 4},
+    {"pam cooking spray", 4},
+    {"whipped topping", 4},
+    {"frozen novelties", 4},
+    {"formulated bar", 4},
+    {"instant breakfast", 4},
+    {"flavored drink", 4},
+    {"flavor drink", 4},
+    {"low-calorie sweeteners", 4},
+    {"morningstar", 4},
+    {"meat extender", 4},
+    {"surimi", 4},
+    {"big franks", 4},
+    {"liver cheese", 4},
+    {"imitation", 4},
+    {"nondairy", 4},
+    {"margarine", 4},
+    {"candies", 4},
+    {"ice cream", 4},
+
+    // Minimally processed, though their groups aren't
+    {"cocoa, dry powder", 1},
+
+    // Processed
+    {"canned", 3},
+    {"cheese", 3},
+    {"smoked", 3},
+    {"cured", 3},
+    {"pickled", 3},
+    {"salted", 3},
+    {"in syrup", 3},
+    {", sweetened", 3},
+    {"bread", 3},
+    {"tofu", 3},
+
+    // Culinary ingredients
+    {"oil,", 2},
+    {"butter,", 2},
+    {"lard", 2},
+    {"sugars,", 2},
+    {"syrups,", 2},
+    {"honey", 2},
+    {"salt,", 2},
+    {"vinegar", 2},
+    {"cornstarch", 2},
+}
+
+// novaFoodGroups is the class for each SR26 food group when no keyword
+// matches.
+var novaFoodGroups = map[string]int{
+    "0100": 1, // dairy and egg
+    "0200": 1, // spices and herbs
+    "0300": 4, // baby foods
+    "0400": 2, // fats and oils
+    "0500": 1, // poultry
+    "0600": 4, // soups, sauces and gravies
+    "0700": 4, // sausages and luncheon meats
+    "0800": 4, // breakfast cereals
+    "0900": 1, // fruits
+    "1000": 1, // pork
+    "1100": 1, // vegetables
+    "1200": 1, // nuts and seeds
+    "1300": 1, // beef
+    "1400": 4, // beverages
+    "1500": 1, // finfish and shellfish
+    "1600": 1, // legumes
+    "1700": 1, // lamb, veal and game
+    "1800": 4, // baked products
+    "1900": 4, // sweets
+    "2000": 1, // cereal grains and pasta
+    "2100": 4, // fast foods
+    "2200": 4, // meals, entrees and side dishes
+    "2500": 4, // snacks
+    "3500": 1, // American Indian/Alaska Native foods
+    "3600": 4, // restaurant foods
+}
+
+// novaClass guesses a food's NOVA class. Foods that match nothing, like
+// branded foods, are taken as ultra-processed.
+func novaClass(food *Food) int {
+    description := strings.ToLower(food.description)
+    for _, rule := range novaKeywords {
+        if strings.Contains(description, rule.keyword) {
+            return rule.class
+        }
+    }
+    if class, exists := novaFoodGroups[food.foodGroup]; exists {
+        return class
+    }
+    return 4
+}
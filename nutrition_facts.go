@@ -0,0 +1,186 @@
+package main
+
+import (
+    "fmt"
+    "math"
+    "strconv"
+)
+
+// --nutrition-facts N prints a US Nutrition Facts panel for the recipe
+// split into N servings, in label order with %DV. --label-rounding fda
+// rounds it the way 21 CFR 101.9 says a label must, e.g. under 0.5 g of fat
+// is 0 g and calories go to the nearest 5 or 10, for anyone selling their
+// shake who needs label-compliant numbers. The default, exact, prints the
+// amounts as computed.
+//
+// %DV is worked out from the unrounded amounts, as the FDA asks.
+
+const (
+    labelRoundingExact = "exact"
+    labelRoundingFDA = "fda"
+)
+
+// fdaIncrements are what vitamin and mineral amounts round to, in the
+// label's units, from the FDA's labeling guide
+var fdaIncrements = map[string]float64{
+    "Vitamin D": 0.1,
+    "Calcium": 10,
+    "Iron": 0.1,
+    "Potassium": 10,
+    "Vitamin A": 10,
+    "Vitamin C": 1,
+    "Vitamin E": 0.1,
+    "Vitamin K": 1,
+    "Thiamin": 0.01,
+    "Riboflavin": 0.01,
+    "Niacin": 0.1,
+    "Vitamin B6": 0.01,
+    "Folate": 1,
+    "Folic Acid": 1,
+    "Vitamin B12": 0.01,
+    "Pantothenic Acid": 0.01,
+    "Phosphorus": 10,
+    "Magnesium": 1,
+    "Zinc": 0.1,
+    "Selenium": 0.1,
+    "Copper": 0.01,
+    "Manganese": 0.01,
+    "Choline": 1,
+}
+
+func roundTo(amount float64, increment float64) float64 {
+    return math.Round(amount / increment) * increment
+}
+
+// fdaRoundAmount rounds a label amount, returning the text to print. Some
+// small amounts are declared as "less than".
+func fdaRoundAmount(label string, amount float64, units string) string {
+    format := func(value float64) string {
+        return fmt.Sprintf("%g%s", trimFloat(value), units)
+    }
+    switch label {
+    case "Calories":
+        switch {
+        case amount < 5:
+            return "0"
+        case amount <= 50:
+            return format(roundTo(amount, 5))
+        }
+        return format(roundTo(amount, 10))
+    case "Total Fat", "Saturated Fat", "Trans Fat":
+        switch {
+        case amount < 0.5:
+            return format(0)
+        case amount < 5:
+            return format(roundTo(amount, 0.5))
+        }
+        return format(math.Round(amount))
+    case "Cholesterol":
+        switch {
+        case amount < 2:
+            return format(0)
+        case amount <= 5:
+            return "<" + format(5)
+        }
+        return format(roundTo(amount, 5))
+    case "Sodium", "Potassium":
+        switch {
+        case amount < 5:
+            return format(0)
+        case amount <= 140:
+            return format(roundTo(amount, 5))
+        }
+        return format(roundTo(amount, 10))
+    case "Total Carbohydrate", "Dietary Fiber", "Total Sugars", "Protein":
+        switch {
+        case amount < 0.5:
+            return format(0)
+        case amount < 1:
+            return "<" + format(1)
+        }
+        return format(math.Round(amount))
+    }
+    if increment, exists := fdaIncrements[label]; exists {
+        return format(roundTo(amount, increment))
+    }
+    return format(amount)
+}
+
+// fdaRoundDV rounds a %DV: vitamins and minerals under 2% are 0, to the
+// nearest 2% up to 10%, 5% up to 50% and 10% above; the rest to the
+// nearest 1%.
+func fdaRoundDV(label string, percent float64) float64 {
+    switch label {
+    case "Total Fat", "Saturated Fat", "Cholesterol", "Sodium", "Total Carbohydrate", "Dietary Fiber", "Protein":
+        return math.Round(percent)
+    }
+    switch {
+    case percent < 2:
+        return 0
+    case percent <= 10:
+        return roundTo(percent, 2)
+    case percent <= 50:
+        return roundTo(percent, 5)
+    }
+    return roundTo(percent, 10)
+}
+
+// trimFloat drops the float noise rounding leaves, like the 4 in
+// 0.30000000000000004.
+func trimFloat(amount float64) float64 {
+    trimmed, _ := strconv.ParseFloat(strconv.FormatFloat(amount, 'g', 6, 64), 64)
+    return trimmed
+}
+
+// checkLabelRounding makes sure --label-rounding is one we know.
+func checkLabelRounding(rounding string) error {
+    if rounding != labelRoundingExact && rounding != labelRoundingFDA {
+        return fmt.Errorf("unknown --label-rounding %q, want %s or %s", rounding, labelRoundingExact, labelRoundingFDA)
+    }
+    return nil
+}
+
+func printNutritionFacts(problem *Problem, recipe *Recipe, servings int, rounding string) {
+    fda := rounding == labelRoundingFDA
+    servingG := float64(recipe.Mass()) / float64(servings)
+    if fda {
+        servingG = math.Round(servingG)
+    }
+
+    fmt.Println("Nutrition Facts")
+    fmt.Printf("%d servings per recipe\n", servings)
+    fmt.Printf("Serving size %gg\n", trimFloat(servingG))
+    fmt.Printf("%-24s %12s %8s\n", "", "Amount", "%DV")
+    for _, ln := range labelNutrients {
+        id, exists := problem.nutrientNameToId[ln.description]
+        if !exists {
+            continue
+        }
+        nutrient := problem.nutrients[id]
+        amount := recipe.nutrientTotals[id] / float64(servings)
+        units := nutrient.units
+        if ln.dvUnits != "" && ln.dvUnits != "kcal" {
+            if converted, err := convertNutrientUnits(amount, units, ln.dvUnits, ln.description); err == nil {
+                amount, units = converted, ln.dvUnits
+            }
+        }
+        if ln.label == "Calories" {
+            units = ""
+        }
+
+        text := fmt.Sprintf("%.4g%s", amount, units)
+        if fda {
+            text = fdaRoundAmount(ln.label, amount, units)
+        }
+        dv := ""
+        if ln.dailyValue > 0 {
+            percent := amount / ln.dailyValue * 100
+            if fda {
+                dv = fmt.Sprintf("%g%%", trimFloat(fdaRoundDV(ln.label, percent)))
+            } else {
+                dv = fmt.Sprintf("%.1f%%", percent)
+            }
+        }
+        fmt.Printf("%-24s %12s %8s\n", ln.label, text, dv)
+    }
+}
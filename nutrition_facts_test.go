@@ -0,0 +1,68 @@
+package main
+
+import "testing"
+
+func TestFDARoundAmount(t *testing.T) {
+    for _, test := range []struct {
+        label string
+        amount float64
+        units string
+        want string
+    }{
+        {"Calories", 4.9, "", "0"},
+        {"Calories", 47.4, "", "45"},
+        {"Calories", 50, "", "50"},
+        {"Calories", 51, "", "50"},
+        {"Calories", 256, "", "260"},
+        {"Total Fat", 0.49, "g", "0g"},
+        {"Total Fat", 2.3, "g", "2.5g"},
+        {"Saturated Fat", 4.74, "g", "4.5g"},
+        {"Total Fat", 5.5, "g", "6g"},
+        {"Cholesterol", 1.9, "mg", "0mg"},
+        {"Cholesterol", 3, "mg", "<5mg"},
+        {"Cholesterol", 12.6, "mg", "15mg"},
+        {"Sodium", 4, "mg", "0mg"},
+        {"Sodium", 137, "mg", "135mg"},
+        {"Sodium", 141, "mg", "140mg"},
+        {"Potassium", 1234, "mg", "1230mg"},
+        {"Protein", 0.4, "g", "0g"},
+        {"Dietary Fiber", 0.7, "g", "<1g"},
+        {"Total Carbohydrate", 27.5, "g", "28g"},
+        {"Vitamin D", 2.34, "mcg", "2.3mcg"},
+        {"Calcium", 1304, "mg", "1300mg"},
+        {"Thiamin", 0.3, "mg", "0.3mg"},
+        {"Riboflavin", 1.234, "mg", "1.23mg"},
+        {"Iron", 0.1 * 3, "mg", "0.3mg"},
+        {"Added Sugars", 1.234, "g", "1.234g"},
+    } {
+        if got := fdaRoundAmount(test.label, test.amount, test.units); got != test.want {
+            t.Errorf("%s %g%s rounds to %q, want %q", test.label, test.amount, test.units, got, test.want)
+        }
+    }
+}
+
+func TestFDARoundDV(t *testing.T) {
+    for _, test := range []struct {
+        label string
+        percent float64
+        want float64
+    }{
+        {"Total Fat", 0.4, 0},
+        {"Total Fat", 12.5, 13},
+        {"Sodium", 1.2, 1},
+        {"Protein", 99.6, 100},
+        {"Vitamin C", 1.9, 0},
+        {"Vitamin C", 2.9, 2},
+        {"Iron", 7.1, 8},
+        {"Calcium", 10, 10},
+        {"Calcium", 12.4, 10},
+        {"Calcium", 12.6, 15},
+        {"Zinc", 50, 50},
+        {"Zinc", 54, 50},
+        {"Zinc", 156, 160},
+    } {
+        if got := fdaRoundDV(test.label, test.percent); got != test.want {
+            t.Errorf("%s %g%% rounds to %g%%, want %g%%", test.label, test.percent, got, test.want)
+        }
+    }
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+    "fmt"
+)
+
+// With --maximize or --minimize the optimizer stops aiming for the middle of
+// every target and instead pushes one nutrient as high or as low as it will
+// go, with the targets as constraints: anywhere inside a range is as good as
+// anywhere else, and leaving it costs far more than the objective can make
+// up. Minimizing energy gives the lowest calorie shake that still meets
+// everything else.
+
+// constraintWeight is what a 1% constraint violation costs, against 100
+// for each multiple of the objective's scale.
+const constraintWeight = 1000
+
+// constraintViolation is how far outside its range amount is, in percent of
+// the bound it crossed.
+func constraintViolation(amount, min, max float64) float64 {
+    if amount < min {
+        return (min - amount) / min * 100
+    }
+    if max != 0 && amount > max {
+        return (amount - max) / max * 100
+    }
+    return 0
+}
+
+// objectiveScale is what the objective is measured against, so a gram of
+// protein and a microgram of selenium both land near 100: the objective's own
+// minimum if it has one, otherwise its units as they come. Cost is in whole
+// units of money.
+func objectiveScale(targets []Target, objective string) float64 {
+    for _, target := range targets {
+        if target.nutrient == objective && target.min > 0 {
+            return target.min
+        }
+    }
+    return 1
+}
+
+// isBounded reports whether something stops the objective growing forever:
+// a mass or energy cap, or a maximum on the objective itself.
+func isBounded(opts *Options, targets []Target, objective string) bool {
+    if opts.maxMassG > 0 {
+        return true
+    }
+    for _, target := range targets {
+        if target.max > 0 && (target.nutrient == objective || target.nutrient == energyNutrient) {
+            return true
+        }
+    }
+    return false
+}
+
+// objectiveScore is the target part of Score with an objective: constraint
+// violations, less the objective when maximizing, so it goes negative once
+// the constraints are met, or plus it when minimizing.
+func (recipe *Recipe) objectiveScore(problem *Problem, verbose bool) float64 {
+    score := float64(0)
+    for _, target := range problem.targets {
+        amount := recipe.amountOf(problem.nutrientNameToId, target.nutrient)
+        violation := constraintViolation(amount, target.min, target.max)
+        if verbose && violation > 0 {
+            fmt.Printf("Constraint %s (%s) broken by %.1f%% (have %f)\n", target.nutrient, target.rangeString(), violation, amount)
+        }
+        score += violation * constraintWeight
+    }
+
+    amount := recipe.objectiveAmount(problem)
+    objective := amount / problem.objectiveScale * 100
+    if problem.minimizing {
+        if verbose { fmt.Printf("Minimizing %s: %f, costing %f\n", problem.objective, amount, objective) }
+        return score + objective
+    }
+    if verbose { fmt.Printf("Maximizing %s: %f, worth %f\n", problem.objective, amount, objective) }
+    return score - objective
+}
+
+// withoutMinimum drops the objective's own minimum when minimizing it,
+// otherwise the answer would just be that minimum. Its maximum stays.
+func withoutMinimum(targets []Target, objective string) []Target {
+    var kept []Target
+    for _, target := range targets {
+        if target.nutrient == objective {
+            if target.max == 0 {
+                continue
+            }
+            target.min = 0
+        }
+        kept = append(kept, target)
+    }
+    return kept
+}
+
+// objectiveAmount is how much of the objective the recipe has.
+func (recipe *Recipe) objectiveAmount(problem *Problem) float64 {
+    if problem.objective == costObjective {
+        return recipe.cost(problem)
+    }
+    return recipe.amountOf(problem.nutrientNameToId, problem.objective)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+    "bufio"
+    "fmt"
+    "os"
+    "strconv"
+    "strings"
+)
+
+// --only-foods limits recipes to the foods listed in a file, say what one
+// store sells or what's already in the kitchen. Each line is an NDB id or a
+// food's full description, in any case; blank lines and lines starting with
+// # are skipped. Listed foods still go through the other rules.
+
+type foodWhitelist struct {
+    path string
+    lines []string // as written, to report the ones that match nothing
+    ids map[int]bool
+    descriptions map[string]bool // lower case
+}
+
+func readFoodWhitelist(path string) (*foodWhitelist, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+
+    whitelist := &foodWhitelist{path: path, ids: make(map[int]bool), descriptions: make(map[string]bool)}
+    scanner := bufio.NewScanner(f)
+    for scanner.Scan() {
+        line := strings.TrimSpace(scanner.Text())
+        if line == "" || strings.HasPrefix(line, "#") {
+            continue
+        }
+        whitelist.lines = append(whitelist.lines, line)
+        if id, err := strconv.Atoi(line); err == nil {
+            whitelist.ids[id] = true
+        } else {
+            whitelist.descriptions[strings.ToLower(line)] = true
+        }
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, err
+    }
+    if len(whitelist.lines) == 0 {
+        return nil, fmt.Errorf("%s lists no foods", path)
+    }
+    return whitelist, nil
+}
+
+func (whitelist *foodWhitelist) allows(food *Food) bool {
+    return whitelist.ids[food.id] || whitelist.descriptions[strings.ToLower(food.description)]
+}
+
+// onlyFoods is the --only-foods list, read the first time it's needed, nil
+// without one. A bad file exits.
+func (opts *Options) onlyFoods() *foodWhitelist {
+    if opts.onlyFoodsPath == "" {
+        return nil
+    }
+    if opts.foodWhitelist == nil {
+        whitelist, err := readFoodWhitelist(opts.onlyFoodsPath)
+        if err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            os.Exit(2)
+        }
+        opts.foodWhitelist = whitelist
+    }
+    return opts.foodWhitelist
+}
+
+// reportOnlyFoods says which --only-foods lines didn't end up in kept, and
+// why when it's known, so a typo doesn't quietly shrink the list.
+func reportOnlyFoods(opts *Options, allFoods map[int]Food, kept map[int]Food) {
+    whitelist := opts.onlyFoods()
+    if whitelist == nil {
+        return
+    }
+    matched := make(map[string]bool, len(whitelist.lines))
+    for id, food := range allFoods {
+        if !whitelist.allows(&food) {
+            continue
+        }
+        line := strings.ToLower(food.description)
+        if whitelist.ids[id] {
+            line = strconv.Itoa(id)
+        }
+        matched[line] = true
+        if _, isKept := kept[id]; isKept {
+            continue
+        }
+        rule := exclusionRule(opts, &food)
+        if rule == "" {
+            rule = ruleFacets
+        }
+        fmt.Printf("%s: %s is left out by %s\n", whitelist.path, food.description, rule)
+    }
+    for _, line := range whitelist.lines {
+        if !matched[strings.ToLower(line)] {
+            fmt.Printf("%s: %q matches no food that may be used\n", whitelist.path, line)
+        }
+    }
+    fmt.Printf("Using %d foods from %s\n", len(kept), whitelist.path)
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Open Food Facts nutriment keys (per 100g) and the NUTR_DEF.txt nutrient
+// each one corresponds to. OFF stores everything in grams apart from energy.
+var openFoodFactsNutrients = []struct {
+    key string
+    id int
+    description string
+    units string
+}{
+    {"energy-kcal_100g", 208, "Energy, kcal", "kcal"},
+    {"energy-kj_100g", 268, "Energy, kJ", "kJ"},
+    {"proteins_100g", 203, "Protein", "g"},
+    {"fat_100g", 204, "Total lipid (fat)", "g"},
+    {"saturated-fat_100g", 606, "Fatty acids, total saturated", "g"},
+    {"trans-fat_100g", 605, "Fatty acids, total trans", "g"},
+    {"cholesterol_100g", 601, "Cholesterol", "g"},
+    {"carbohydrates_100g", 205, "Carbohydrate, by difference", "g"},
+    {"sugars_100g", 269, "Sugars, total", "g"},
+    {"fiber_100g", 291, "Fiber, total dietary", "g"},
+    {"sodium_100g", 307, "Sodium, Na", "g"},
+    {"potassium_100g", 306, "Potassium, K", "g"},
+    {"calcium_100g", 301, "Calcium, Ca", "g"},
+    {"iron_100g", 303, "Iron, Fe", "g"},
+    {"magnesium_100g", 304, "Magnesium, Mg", "g"},
+    {"phosphorus_100g", 305, "Phosphorus, P", "g"},
+    {"zinc_100g", 309, "Zinc, Zn", "g"},
+    {"copper_100g", 312, "Copper, Cu", "g"},
+    {"manganese_100g", 315, "Manganese, Mn", "g"},
+    {"selenium_100g", 317, "Selenium, Se", "g"},
+    {"vitamin-a_100g", 320, "Vitamin A, RAE", "g"},
+    {"vitamin-c_100g", 401, "Vitamin C, total ascorbic acid", "g"},
+    {"vitamin-d_100g", 328, "Vitamin D (D2 + D3)", "g"},
+    {"vitamin-e_100g", 323, "Vitamin E (alpha-tocopherol)", "g"},
+    {"vitamin-k_100g", 430, "Vitamin K (phylloquinone)", "g"},
+    {"vitamin-b1_100g", 404, "Thiamin", "g"},
+    {"vitamin-b2_100g", 405, "Riboflavin", "g"},
+    {"vitamin-pp_100g", 406, "Niacin", "g"},
+    {"vitamin-b6_100g", 415, "Vitamin B-6", "g"},
+    {"folates_100g", 432, "Folate, food", "g"},
+    {"vitamin-b12_100g", 418, "Vitamin B-12", "g"},
+    {"pantothenic-acid_100g", 410, "Pantothenic acid", "g"},
+    {"choline_100g", 421, "Choline, total", "g"},
+    {"caffeine_100g", 262, "Caffeine", "g"},
+}
+
+const openFoodFactsURL = "https://world.openfoodfacts.org/api/v2/product/%s.json"
+
+type openFoodFactsProduct struct {
+    Code string `json:"code"`
+    Status int `json:"status"`
+    Product openFoodFactsFields `json:"product"`
+}
+
+// openFoodFactsFields are the fields of a product we use, as the API and
+// the JSONL dump both have them.
+type openFoodFactsFields struct {
+    Code string `json:"code"`
+    ProductName string `json:"product_name"`
+    Brands string `json:"brands"`
+    Nutriments map[string]interface{} `json:"nutriments"`
+    ServingQuantity interface{} `json:"serving_quantity"` // grams, a number or a string
+    ServingSize string `json:"serving_size"`
+}
+
+func validBarcode(barcode string) bool {
+    if len(barcode) < 8 || len(barcode) > 14 {
+        return false
+    }
+    for _, r := range barcode {
+        if r < '0' || r > '9' {
+            return false
+        }
+    }
+    return true
+}
+
+// lookupBarcode fetches a product from Open Food Facts, keeping a copy of
+// the response in cacheDir so the same barcode is only fetched once.
+func lookupBarcode(barcode string, cacheDir string) (*openFoodFactsProduct, error) {
+    if !validBarcode(barcode) {
+        return nil, fmt.Errorf("%q is not a barcode", barcode)
+    }
+
+    cachePath := filepath.Join(cacheDir, barcode + ".json")
+    body, err := os.ReadFile(cachePath)
+    if err != nil {
+        client := &http.Client{Timeout: 30 * time.Second}
+        request, err := http.NewRequest("GET", fmt.Sprintf(openFoodFactsURL, barcode), nil)
+        if err != nil {
+            return nil, err
+        }
+        // OFF asks API users to identify themselves
+        request.Header.Set("User-Agent", "supershake - https://github.com/cyounkins/supershake")
+        response, err := client.Do(request)
+        if err != nil {
+            return nil, err
+        }
+        defer response.Body.Close()
+        if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusNotFound {
+            return nil, fmt.Errorf("Open Food Facts returned %s", response.Status)
+        }
+        body, err = io.ReadAll(response.Body)
+        if err != nil {
+            return nil, err
+        }
+        if response.StatusCode == http.StatusOK {
+            if err := os.MkdirAll(cacheDir, 0755); err == nil {
+                os.WriteFile(cachePath, body, 0644)
+            }
+        }
+    }
+
+    product := &openFoodFactsProduct{}
+    if err := json.Unmarshal(body, product); err != nil {
+        return nil, fmt.Errorf("reading Open Food Facts response: %v", err)
+    }
+    if product.Status != 1 {
+        return nil, fmt.Errorf("barcode %s is not in Open Food Facts", barcode)
+    }
+    return product, nil
+}
+
+// openFoodFactsNumber reads a nutriment value, which OFF sends as either a
+// number or a string depending on the product.
+func openFoodFactsNumber(value interface{}) (float64, bool) {
+    switch v := value.(type) {
+    case float64:
+        return v, true
+    case string:
+        parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+        return parsed, err == nil
+    }
+    return 0, false
+}
+
+// openFoodFactsAmounts maps OFF nutriments onto the nutrients we know by
+// their USDA ids, converting into NUTR_DEF.txt units, per 100g. Nutrients
+// missing from the dataset are dropped.
+func openFoodFactsAmounts(product *openFoodFactsFields, nutrients map[int]Nutrient) (map[int]float64, error) {
+    amounts := make(map[int]float64)
+    for _, off := range openFoodFactsNutrients {
+        value, exists := product.Nutriments[off.key]
+        if !exists {
+            continue
+        }
+        amount, ok := openFoodFactsNumber(value)
+        if !ok {
+            continue
+        }
+        nutrient, exists := nutrients[off.id]
+        if !exists {
+            continue
+        }
+        converted, err := convertNutrientUnits(amount, off.units, nutrient.units, nutrient.description)
+        if err != nil {
+            return nil, fmt.Errorf("%s: %v", off.key, err)
+        }
+        amounts[off.id] = converted
+    }
+    return amounts, nil
+}
+
+// openFoodFactsDescription is the product name and brands, as the label
+// would have them.
+func openFoodFactsDescription(product *openFoodFactsFields, code string) string {
+    description := strings.TrimSpace(product.ProductName)
+    if brands := strings.TrimSpace(product.Brands); brands != "" {
+        description += ", " + brands
+    }
+    if description == "" {
+        description = "Open Food Facts " + code
+    }
+    return description
+}
+
+// openFoodFactsToCustomFood turns a looked up product into a custom food.
+func openFoodFactsToCustomFood(product *openFoodFactsProduct, nutrients map[int]Nutrient) (*CustomFood, error) {
+    food := &CustomFood{per100g: make(map[string]float64)}
+    food.description = openFoodFactsDescription(&product.Product, product.Code)
+
+    amounts, err := openFoodFactsAmounts(&product.Product, nutrients)
+    if err != nil {
+        return nil, err
+    }
+    for id, amount := range amounts {
+        food.per100g[nutrients[id].description] = amount
+    }
+
+    if len(food.per100g) == 0 {
+        return nil, fmt.Errorf("Open Food Facts has no usable nutrient values for %s", product.Code)
+    }
+    return food, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "io"
+    "path/filepath"
+    "strconv"
+)
+
+// Open Food Facts publishes its whole database as a JSONL dump, one product
+// per line with the same fields the API has:
+//
+//     https://static.openfoodfacts.org/data/openfoodfacts-products.jsonl.gz
+//
+// --off-dump reads one, gzipped or not, as a source of packaged foods. The
+// full dump is millions of products, so most people will want a country's
+// slice of it. A product's id is its barcode, which doesn't collide with
+// SR26's five digit ids. Products with no nutrients we know are left out,
+// as are ones without a barcode.
+
+// offFoodGroup is the food group Open Food Facts products go in. They're
+// branded products, so NOVA and the blender treat them the same.
+const offFoodGroup = brandedFoodGroup
+
+type openFoodFactsSource struct {
+    path string
+}
+
+func (source openFoodFactsSource) Name() string {
+    return "Open Food Facts dump " + source.path
+}
+
+func (source openFoodFactsSource) LoadNutrients() (map[int]Nutrient, error) {
+    return nil, nil
+}
+
+func (source openFoodFactsSource) LoadFoods(nutrients map[int]Nutrient) (map[int]Food, error) {
+    file, err := openDataFile(filepath.Dir(source.path), filepath.Base(source.path))
+    if err != nil {
+        fmt.Println("Open Food Facts dump not found. Download it from https://world.openfoodfacts.org/data")
+        return nil, err
+    }
+    defer file.Close()
+
+    nutrientIds := sortedNutrientIds(nutrients)
+    foods := make(map[int]Food, 100000)
+    decoder := json.NewDecoder(file)
+    for count := 1; ; count++ {
+        var product openFoodFactsFields
+        err := decoder.Decode(&product)
+        if err == io.EOF {
+            break
+        } else if err != nil {
+            return nil, fmt.Errorf("%s, product %d: %v", source.path, count, err)
+        }
+        if !validBarcode(product.Code) {
+            continue
+        }
+        id, err := strconv.Atoi(product.Code)
+        // Barcodes padded with zeros could land on an SR26 id
+        if err != nil || id < 100000 {
+            continue
+        }
+        amounts, err := openFoodFactsAmounts(&product, nutrients)
+        if err != nil || len(amounts) == 0 {
+            continue
+        }
+
+        food := Food{id: id, foodGroup: offFoodGroup, description: openFoodFactsDescription(&product, product.Code), manufacturer: product.Brands}
+        for _, nutrientId := range nutrientIds {
+            if amount, exists := amounts[nutrientId]; exists {
+                // divide by 100 because this measurement is for 100g
+                food.nutrients = append(food.nutrients, NutrientInFood{nutrient: nutrients[nutrientId], amountPerG: amount / 100, sourceCode: sourceLabel})
+            }
+        }
+        if grams, ok := openFoodFactsNumber(product.ServingQuantity); ok && grams > 0 {
+            description := "serving"
+            if product.ServingSize != "" {
+                description += " (" + product.ServingSize + ")"
+            }
+            food.measures = append(food.measures, Measure{1, description, grams})
+        }
+        foods[id] = food
+    }
+    return foods, nil
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "runtime/debug"
+)
+
+// Problem is what the optimizer works on: the foods it may use, the
+// nutrients they are measured in, and the targets recipes are scored against.
+type Problem struct {
+    nutrients map[int]Nutrient
+    nutrientNameToId map[string]int
+    foods map[int]Food
+    targets []Target
+    targetSources map[string]string // nutrient -> where its target came from
+    objective string // nutrient being maximized or minimized, "" when hitting the targets
+    minimizing bool
+    objectiveScale float64
+    prices map[int]float64 // food id -> price per gram as purchased
+    imputedPolicies imputedPolicies
+    recentIngredients []map[int]bool // with --surprise, the foods in each recent recipe
+    mercury map[int]float64 // food id -> µg of mercury per g, for fish
+    required map[int]int // food id -> grams the optimizer may not go below
+    pattern *dietaryPattern // --pattern, nil for none
+    macros *macroRatios // --macros, nil for none
+    contributions contributionMatrix // with --max-share, nil otherwise
+    ratios []nutrientRatio // --ratio
+    proteinQuality *proteinQuality // --protein-quality, nil for none
+    energyLimits []energyLimit // --energy-limit and --dga
+    supplements map[int]supplementDose // --supplement, by food id
+    stimulants []stimulantLimit // targets.yaml's stimulants:, --targets and --stimulant
+    moveGenerators []MoveGenerator // --moves
+    perturb func(recipe *Recipe, score float64) float64 // adds noise to every score in the chaos test, nil normally
+    trace *optimizerTrace // --trace, nil normally
+    watchdog *heapWatchdog // --max-heap-mb, nil normally
+    opts *Options
+}
+
+// loadProblem loads the data and applies the food filters.
+func loadProblem(opts *Options) *Problem {
+    if err := checkBlender(opts.blender); err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(2)
+    }
+    if err := checkDiets(opts); err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(2)
+    }
+    if opts.lowMemory {
+        // Excluded foods never get their nutrients read, and whatever the
+        // parsing allocated goes back to the OS before optimizing
+        ensureDataFiles(opts)
+        keep := func(food *Food) bool { return keepFood(opts, food) }
+        nutrients, nutrientNameToId, foods := loadSource(newFilteredFoodSource(opts, keep))
+        debug.FreeOSMemory()
+        foods = validateFoods(opts, nutrients, nutrientNameToId, foods)
+        return newProblem(opts, nutrients, nutrientNameToId, filterFoods(opts, foods))
+    }
+
+    allNutrients, nutrientNameToId, allFoods := loadNutrientsAndFoods(opts)
+    allFoods = validateFoods(opts, allNutrients, nutrientNameToId, allFoods)
+    return newProblem(opts, allNutrients, nutrientNameToId, filterFoods(opts, allFoods))
+}
+
+// newProblem sets up a problem over foods with the effective targets,
+// exiting if the target flags don't make sense.
+func newProblem(opts *Options, nutrients map[int]Nutrient, nutrientNameToId map[string]int, foods map[int]Food) *Problem {
+    // Moves of no grams never change the recipe, so optimizing would spin
+    if opts.stepSize <= 0 {
+        fmt.Fprintf(os.Stderr, "--step-g %d: want at least 1 gram\n", opts.stepSize)
+        os.Exit(2)
+    }
+    // A negative cap would turn off both the cap and the mass penalty
+    if opts.maxMassG < 0 {
+        fmt.Fprintf(os.Stderr, "--max-mass-g %d can't be negative, give 0 for no cap\n", opts.maxMassG)
+        os.Exit(2)
+    }
+    targets, targetSources, err := effectiveTargets(opts, nutrients, nutrientNameToId)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(2)
+    }
+    if opts.proteinQuality != "" {
+        targets = withoutAminoAcidTargets(targets, targetSources)
+    }
+    objective, minimizing := opts.maximize, false
+    if opts.minimize != "" {
+        if opts.maximize != "" {
+            fmt.Fprintln(os.Stderr, "Give --maximize or --minimize, not both")
+            os.Exit(2)
+        }
+        objective, minimizing = opts.minimize, true
+    }
+    policies, err := newImputedPolicies(opts, nutrientNameToId)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(2)
+    }
+    if excluded := applyImputedPolicy(policies, foods); excluded > 0 {
+        fmt.Printf("Excluded %d foods with imputed values\n", excluded)
+    }
+    if err := cookFoods(opts, nutrientNameToId, foods); err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(2)
+    }
+    pattern, err := findPattern(opts)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(2)
+    }
+    macros, err := parseMacros(opts)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(2)
+    }
+    ratios, err := parseRatios(opts, nutrients, nutrientNameToId)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(2)
+    }
+    generators, err := findMoveGenerators(opts)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(2)
+    }
+    if err := checkAboveMax(opts); err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(2)
+    }
+    if opts.maxShare < 0 || opts.maxShareWeight < 0 {
+        fmt.Fprintln(os.Stderr, "--max-share and --max-share-weight can't be negative")
+        os.Exit(2)
+    }
+    prices := opts.prices()
+    if opts.maxCost > 0 && len(pricedFoods(foods, prices)) == 0 {
+        fmt.Fprintf(os.Stderr, "No foods have prices in %s, so --max-cost has nothing to limit\n", opts.pricesPath)
+        os.Exit(2)
+    }
+    scale := objectiveScale(targets, objective)
+    if objective == costObjective {
+        if !minimizing {
+            fmt.Fprintln(os.Stderr, "Cost can only be minimized")
+            os.Exit(2)
+        }
+        // Unpriced foods would look free
+        foods = pricedFoods(foods, prices)
+        if len(foods) == 0 {
+            fmt.Fprintf(os.Stderr, "No foods have prices in %s, so there's no cost to minimize\n", opts.pricesPath)
+            os.Exit(2)
+        }
+        fmt.Printf("Minimizing cost over the %d foods with prices\n", len(foods))
+    } else if objective != "" {
+        if !isTargetNutrient(nutrientNameToId, objective) {
+            fmt.Fprintf(os.Stderr, "No nutrient %q to optimize\n", objective)
+            os.Exit(2)
+        }
+        if minimizing {
+            targets = withoutMinimum(targets, objective)
+        } else if !isBounded(opts, targets, objective) {
+            fmt.Fprintf(os.Stderr, "--maximize %q has nothing to stop it, give --max-kcal, --max-mass-g or a max for it\n", objective)
+            os.Exit(2)
+        }
+    }
+    stimulants, err := findStimulants(opts, nutrients, nutrientNameToId)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(2)
+    }
+    limits, err := parseEnergyLimits(opts, nutrientNameToId)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(2)
+    }
+    var supplements map[int]supplementDose
+    var required map[int]int
+    if foods != nil {
+        supplements, required, err = addSupplements(opts, nutrients, nutrientNameToId, foods, prices)
+        if err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            os.Exit(2)
+        }
+    }
+    if objective == costObjective {
+        for foodId, dose := range supplements {
+            if prices[foodId] == 0 {
+                fmt.Fprintf(os.Stderr, "--supplement %s has no price, so would look free when minimizing cost\n", dose.name)
+                os.Exit(2)
+            }
+        }
+    }
+    if len(supplements) > 0 {
+        generators = append(generators, supplementMoves{})
+    }
+    quality, err := newProteinQuality(opts, nutrientNameToId, foods)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(2)
+    }
+    mercury, err := mercuryByFood(foods)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(2)
+    }
+    var contributions contributionMatrix
+    if opts.maxShare > 0 {
+        contributions = newContributionMatrix(nutrientNameToId, foods, targets)
+    }
+    return &Problem{
+        objective: objective,
+        minimizing: minimizing,
+        objectiveScale: scale,
+        prices: prices,
+        imputedPolicies: policies,
+        nutrients: nutrients,
+        nutrientNameToId: nutrientNameToId,
+        foods: foods,
+        targets: targets,
+        targetSources: targetSources,
+        mercury: mercury,
+        pattern: pattern,
+        macros: macros,
+        contributions: contributions,
+        ratios: ratios,
+        proteinQuality: quality,
+        energyLimits: limits,
+        supplements: supplements,
+        stimulants: stimulants,
+        required: required,
+        moveGenerators: generators,
+        opts: opts,
+    }
+}
+
+// optimize hill-climbs from start, taking the move that improves the score
+// most, by default adding or removing one step of one food, until no move
+// does (see moves.go).
+func optimize(problem *Problem, start *Recipe, progress bool) (*Recipe, float64) {
+    return optimizeContext(context.Background(), problem, start, progress)
+}
+
+// optimizeContext is optimize stopping early, with the best recipe so far,
+// once ctx is done.
+func optimizeContext(ctx context.Context, problem *Problem, start *Recipe, progress bool) (*Recipe, float64) {
+    allFoods := problem.foods
+    allNutrients := problem.nutrients
+    opts := problem.opts
+    STEPSIZE := opts.stepSize
+
+    bestRecipeEver := problem.withRequired(start).Clone(allFoods, allNutrients)
+    bestScoreEver := bestRecipeEver.Score(problem, false)
+
+    // A perfect score is 0 when hitting targets; with an objective there's
+    // no perfect score, so that runs until no step helps
+    trace := problem.trace
+    hard := problem.hasHardTargets()
+    round := 0
+    for (bestScoreEver > 0 || problem.objective != "") && ctx.Err() == nil {
+        round++
+        if progress {
+            fmt.Println(bestRecipeEver.foodQuantities)
+            fmt.Println("Best score ever", bestScoreEver)
+        }
+
+        var bestRecipeThisRound *Recipe
+        bestScoreThisRound := bestScoreEver 
+        var bestMoveThisRound Move
+
+        // Start from the best ever
+        // This one moves around the search space, testing the options
+        // it must be cloned into bestRecipeThisRound!
+        currentRecipe := bestRecipeEver.Clone(allFoods, allNutrients)    
+        hardMinsMet := 0
+        if hard {
+            hardMinsMet = currentRecipe.hardMinsMet(problem)
+        }
+
+        try := func(move Move) {
+            // unless it leaves a cap, or goes under a required food
+            if !problem.canTry(currentRecipe, move) {
+                return
+            }
+            move.apply(allFoods, currentRecipe)
+            // always undo
+            defer move.undo(allFoods, currentRecipe)
+            // or over the cost cap or a hard max, or back under a hard min
+            if !problem.allowedAfter(currentRecipe, move, hard, hardMinsMet) {
+                return
+            }
+            newScore := currentRecipe.Score(problem, false)
+            if trace != nil {
+                trace.move(round, move, newScore)
+            }
+            if newScore < bestScoreThisRound {
+                // Better, woo!
+                bestRecipeThisRound = currentRecipe.Clone(allFoods, allNutrients)
+                bestScoreThisRound = newScore
+                bestMoveThisRound = move
+            }
+        }
+        for _, generator := range problem.moveGenerators {
+            generator.Moves(problem, currentRecipe, STEPSIZE, try)
+        }
+
+        if bestRecipeThisRound == nil {
+            // We never got a chance to set bestRecipeThisRound,
+            // which means we found nothing better than bestRecipeEver
+            break
+        }
+        if trace != nil {
+            trace.accept(round, bestMoveThisRound, bestScoreThisRound)
+        }
+        if bestScoreThisRound > bestScoreEver {
+            panic("wtf")
+        }
+        // Done trying all the foods
+        bestRecipeEver = bestRecipeThisRound
+        bestScoreEver = bestScoreThisRound
+        if problem.watchdog.overCap() {
+            problem.watchdog.restart(problem, bestRecipeEver)
+        }
+    }
+
+    if trace != nil {
+        trace.finish(round, bestScoreEver)
+    }
+    return bestRecipeEver, bestScoreEver
+}
+
+func printRecipe(problem *Problem, recipe *Recipe) {
+    fmt.Println(recipe)
+    recipe.Score(problem, true)
+    for foodId, grams := range recipe.foodQuantities {
+        food := problem.foods[foodId]
+        amount := food.gramsString(grams)
+        if problem.opts.purchasedWeight {
+            amount = food.purchasedString(grams)
+        }
+        fmt.Printf("%s of %s%s%s\n", amount, food.description, food.cookedString(grams), soakNote(problem.opts.blender, &food))
+        food.PrintNutrients(grams)
+        fmt.Print("\n\n")
+    }
+    printPreparation(problem, recipe)
+    fmt.Println("TOTAL NUTRIENTS")
+    recipe.PrintTotalNutrients(problem.nutrients)
+    if mercury := recipe.mercuryUg(problem); mercury > 0 {
+        fmt.Printf("Mercury: %.2f µg", mercury)
+        if problem.opts.maxMercuryUg > 0 {
+            fmt.Printf(" (cap %g µg)", problem.opts.maxMercuryUg)
+        }
+        fmt.Println()
+    }
+    if problem.opts.maxCost > 0 {
+        fmt.Printf("Cost: %.2f (cap %g)\n", recipe.cost(problem), problem.opts.maxCost)
+    }
+    fmt.Println()
+    if len(recipe.foodQuantities) > 0 {
+        printComposition(problem, recipe)
+        fmt.Println()
+    }
+    if problem.pattern != nil {
+        printPatternCompliance(problem, recipe)
+        fmt.Println()
+    }
+    if problem.macros != nil {
+        printMacros(problem, recipe)
+        fmt.Println()
+    }
+    if problem.contributions != nil {
+        printSourceDiversity(problem, recipe)
+        fmt.Println()
+    }
+    if len(problem.ratios) > 0 {
+        printRatios(problem, recipe)
+        fmt.Println()
+    }
+    if problem.proteinQuality != nil {
+        printProteinQuality(problem, recipe)
+        fmt.Println()
+    }
+    if len(problem.energyLimits) > 0 {
+        printEnergyLimits(problem, recipe)
+        fmt.Println()
+    }
+    printConfidence(problem, recipe)
+    if problem.opts.citations {
+        fmt.Println()
+        printCitations(problem, recipe)
+    }
+}
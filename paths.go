@@ -0,0 +1,125 @@
+package main
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "runtime"
+    "strings"
+)
+
+// When --data-dir is left as "." and the dataset isn't there, it's looked
+// for where people tend to put it: next to the binary, in the per-user data
+// directory for the OS, and in Downloads, each as is or in an sr26 folder
+// (a zip counts, see datafiles.go). Failing that, someone at a terminal is
+// asked where it is, rather than left with an error.
+
+// userDataDir is where this OS keeps per-user application data, with a
+// supershake folder, or "" if there's no home directory.
+func userDataDir() string {
+    home, _ := os.UserHomeDir()
+    switch runtime.GOOS {
+    case "windows":
+        for _, variable := range []string{"LOCALAPPDATA", "APPDATA"} {
+            if dir := os.Getenv(variable); dir != "" {
+                return filepath.Join(dir, "supershake")
+            }
+        }
+    case "darwin":
+        if home != "" {
+            return filepath.Join(home, "Library", "Application Support", "supershake")
+        }
+    default:
+        if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+            return filepath.Join(dir, "supershake")
+        }
+        if home != "" {
+            return filepath.Join(home, ".local", "share", "supershake")
+        }
+    }
+    return ""
+}
+
+// standardDataDirs are the places looked in for the dataset, best first.
+func standardDataDirs() []string {
+    var bases []string
+    if executable, err := os.Executable(); err == nil {
+        bases = append(bases, filepath.Dir(executable))
+    }
+    if dir := userDataDir(); dir != "" {
+        bases = append(bases, dir)
+    }
+    if home, err := os.UserHomeDir(); err == nil {
+        bases = append(bases, filepath.Join(home, "Downloads"))
+    }
+    var dirs []string
+    for _, base := range bases {
+        dirs = append(dirs, base, filepath.Join(base, "sr26"))
+    }
+    return dirs
+}
+
+// findStandardDataDir is the first standard place with the whole dataset,
+// or "".
+func findStandardDataDir() string {
+    for _, dir := range standardDataDirs() {
+        if len(missingDataFiles(dir)) == 0 {
+            return dir
+        }
+    }
+    return ""
+}
+
+func isInteractive() bool {
+    info, err := os.Stdin.Stat()
+    return err == nil && info.Mode() & os.ModeCharDevice != 0
+}
+
+// cleanPromptPath undoes what pasting or dragging a folder into a terminal
+// adds: quotes from Windows' "Copy as path", backslashed spaces from the
+// macOS Terminal, and a leading ~. A data file's path means its folder.
+func cleanPromptPath(text string) string {
+    text = strings.TrimSpace(text)
+    if len(text) >= 2 && (text[0] == '"' || text[0] == '\'') && text[len(text) - 1] == text[0] {
+        text = text[1:len(text) - 1]
+    }
+    if runtime.GOOS != "windows" {
+        text = strings.ReplaceAll(text, "\\ ", " ")
+    }
+    if text == "~" || strings.HasPrefix(text, "~/") || strings.HasPrefix(text, "~\\") {
+        if home, err := os.UserHomeDir(); err == nil {
+            text = filepath.Join(home, text[1:])
+        }
+    }
+    if info, err := os.Stat(text); err == nil && !info.IsDir() && !strings.EqualFold(filepath.Ext(text), ".zip") {
+        text = filepath.Dir(text)
+    }
+    return text
+}
+
+// promptForDataDir asks where the dataset is until it's found, returning ""
+// if the answer is blank.
+func promptForDataDir(in io.Reader, out io.Writer) string {
+    reader := bufio.NewReader(in)
+    fmt.Fprintln(out, tr("Couldn't find the USDA SR26 files. Download them from:"))
+    fmt.Fprintln(out, sr26URL)
+    for {
+        fmt.Fprint(out, tr("Folder or zip holding them (drag it here, or leave blank to stop): "))
+        line, err := reader.ReadString('\n')
+        dir := cleanPromptPath(line)
+        if dir == "" {
+            return ""
+        }
+        missing := missingDataFiles(dir)
+        if len(missing) == 0 {
+            fmt.Fprintf(out, tr("Found them. Next time pass --data-dir %q, or put them in %s\n"), dir, userDataDir())
+            return dir
+        }
+        fmt.Fprintf(out, tr("%s is missing %s\n"), dir, strings.Join(missing, ", "))
+        if err != nil {
+            return ""
+        }
+    }
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+    _ "embed"
+    "fmt"
+    "os"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// A dietary pattern, like EAT-Lancet's planetary health diet, is a range of
+// grams a day for each of a few food groups. --pattern reports how well a
+// recipe keeps to one next to the nutrient score, and with --pattern-weight
+// the optimizer keeps to it too, each component out of range costing like a
+// missed target, times the weight:
+//
+//   - under its min, (min - grams) / min * 100
+//   - over its max, (grams - max) / max * 100
+//
+// Compliance is 100% less the mean of those, down to 0.
+
+//go:embed patterns.yaml
+var patternsYAML string
+
+type patternComponent struct {
+    name string
+    foodGroups map[string]bool
+    min float64 // grams, 0 for no min
+    max float64 // grams, 0 for no max
+}
+
+type dietaryPattern struct {
+    name string
+    description string
+    components []patternComponent
+}
+
+// parsePatterns reads patterns in the patterns.yaml format.
+func parsePatterns(data string, source string) (map[string]*dietaryPattern, error) {
+    document, err := parseYAML(data)
+    if err != nil {
+        return nil, fmt.Errorf("%s: %v", source, err)
+    }
+    root, _ := document.(map[string]interface{})
+    entries, ok := root["patterns"].(map[string]interface{})
+    if !ok {
+        return nil, fmt.Errorf("%s: expected a patterns: map", source)
+    }
+    patterns := make(map[string]*dietaryPattern, len(entries))
+    for name, entry := range entries {
+        fields, _ := entry.(map[string]interface{})
+        list, _ := fields["components"].([]interface{})
+        if len(list) == 0 {
+            return nil, fmt.Errorf("%s: pattern %s has no components", source, name)
+        }
+        pattern := &dietaryPattern{name: strings.ToLower(name)}
+        pattern.description, _ = fields["description"].(string)
+        for i, item := range list {
+            component, err := parsePatternComponent(item)
+            if err != nil {
+                return nil, fmt.Errorf("%s: pattern %s component %d: %v", source, name, i + 1, err)
+            }
+            pattern.components = append(pattern.components, component)
+        }
+        patterns[pattern.name] = pattern
+    }
+    return patterns, nil
+}
+
+func parsePatternComponent(item interface{}) (patternComponent, error) {
+    component := patternComponent{foodGroups: make(map[string]bool)}
+    fields, ok := item.(map[string]interface{})
+    if !ok {
+        return component, fmt.Errorf("not a map")
+    }
+    component.name, _ = fields["name"].(string)
+    if component.name == "" {
+        return component, fmt.Errorf("no name")
+    }
+    groups, isList := fields["food_groups"].([]interface{})
+    if !isList {
+        groups = []interface{}{fields["food_groups"]}
+    }
+    for _, group := range groups {
+        text, _ := group.(string)
+        code, err := resolveFoodGroup(text)
+        if err != nil {
+            return component, err
+        }
+        component.foodGroups[code] = true
+    }
+    for key, bound := range map[string]*float64{"min": &component.min, "max": &component.max} {
+        text, given := fields[key].(string)
+        if !given {
+            continue
+        }
+        grams, err := strconv.ParseFloat(text, 64)
+        if err != nil || grams < 0 {
+            return component, fmt.Errorf("bad %s %q", key, text)
+        }
+        *bound = grams
+    }
+    if component.min == 0 && component.max == 0 {
+        return component, fmt.Errorf("%s has no min or max", component.name)
+    }
+    if component.max > 0 && component.min > component.max {
+        return component, fmt.Errorf("%s has its min over its max", component.name)
+    }
+    return component, nil
+}
+
+// loadPatterns is the built in patterns and any in path.
+func loadPatterns(path string) (map[string]*dietaryPattern, error) {
+    patterns, err := parsePatterns(patternsYAML, "patterns.yaml")
+    if err != nil {
+        return nil, err
+    }
+    if path == "" {
+        return patterns, nil
+    }
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    extra, err := parsePatterns(string(data), path)
+    if err != nil {
+        return nil, err
+    }
+    for name, pattern := range extra {
+        patterns[name] = pattern
+    }
+    return patterns, nil
+}
+
+// findPattern is the --pattern, or a --preset's, nil when there isn't one.
+func findPattern(opts *Options) (*dietaryPattern, error) {
+    name := opts.pattern
+    if name == "" {
+        var err error
+        if name, err = presetPattern(opts); err != nil {
+            return nil, err
+        }
+    }
+    if name == "" {
+        if opts.patternWeight != 0 {
+            return nil, fmt.Errorf("--pattern-weight needs a --pattern")
+        }
+        return nil, nil
+    }
+    if opts.patternWeight < 0 {
+        return nil, fmt.Errorf("--pattern-weight can't be negative")
+    }
+    patterns, err := loadPatterns(opts.patternsPath)
+    if err != nil {
+        return nil, err
+    }
+    pattern, exists := patterns[strings.ToLower(name)]
+    if !exists {
+        var names []string
+        for name := range patterns {
+            names = append(names, name)
+        }
+        sort.Strings(names)
+        return nil, fmt.Errorf("unknown --pattern %q, want one of %s", name, strings.Join(names, ", "))
+    }
+    return pattern, nil
+}
+
+// grams is how much of the component's food groups recipe has.
+func (component *patternComponent) grams(problem *Problem, recipe *Recipe) int {
+    grams := 0
+    for foodId, quantity := range recipe.foodQuantities {
+        if component.foodGroups[problem.foods[foodId].foodGroup] {
+            grams += quantity
+        }
+    }
+    return grams
+}
+
+// penalty is how far out of range grams is, 0 when in it.
+func (component *patternComponent) penalty(grams int) float64 {
+    amount := float64(grams)
+    if amount < component.min {
+        return (component.min - amount) / component.min * 100
+    }
+    if component.max > 0 && amount > component.max {
+        return (amount - component.max) / component.max * 100
+    }
+    return 0
+}
+
+// compliance is the percentage of the pattern recipe keeps to.
+func (pattern *dietaryPattern) compliance(problem *Problem, recipe *Recipe) float64 {
+    total := float64(0)
+    for i := range pattern.components {
+        component := &pattern.components[i]
+        total += component.penalty(component.grams(problem, recipe))
+    }
+    compliance := 100 - total / float64(len(pattern.components))
+    if compliance < 0 {
+        return 0
+    }
+    return compliance
+}
+
+// patternPenalty is the --pattern-weight part of the score.
+func (recipe *Recipe) patternPenalty(problem *Problem, verbose bool) float64 {
+    pattern := problem.pattern
+    if pattern == nil || problem.opts.patternWeight == 0 {
+        return 0
+    }
+    total := float64(0)
+    for i := range pattern.components {
+        component := &pattern.components[i]
+        total += component.penalty(component.grams(problem, recipe))
+    }
+    penalty := total * problem.opts.patternWeight
+    if verbose { fmt.Printf("Penalty for the %s pattern: %f\n", pattern.name, penalty) }
+    return penalty
+}
+
+func (component *patternComponent) rangeString() string {
+    switch {
+    case component.min > 0 && component.max > 0:
+        return fmt.Sprintf("%g-%gg", component.min, component.max)
+    case component.min > 0:
+        return fmt.Sprintf("%gg+", component.min)
+    }
+    return fmt.Sprintf("up to %gg", component.max)
+}
+
+func printPatternCompliance(problem *Problem, recipe *Recipe) {
+    pattern := problem.pattern
+    fmt.Printf("PATTERN %s, %.0f%% compliant\n", pattern.name, pattern.compliance(problem, recipe))
+    if pattern.description != "" {
+        fmt.Printf("  %s\n", pattern.description)
+    }
+    for i := range pattern.components {
+        component := &pattern.components[i]
+        grams := component.grams(problem, recipe)
+        status := "ok"
+        if amount := float64(grams); amount < component.min {
+            status = fmt.Sprintf("%.0fg short", component.min - amount)
+        } else if component.max > 0 && amount > component.max {
+            status = fmt.Sprintf("%.0fg over", amount - component.max)
+        }
+        fmt.Printf("  %-24s %6dg  %-12s %s\n", component.name, grams, component.rangeString(), status)
+    }
+}
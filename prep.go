@@ -0,0 +1,118 @@
+package main
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+)
+
+// Some foods can't go straight from the package into the blender: dry beans
+// have to be soaked and boiled, flax ground so it isn't passed whole. The
+// recipe lists what has to be done and how far ahead, and --max-prep-min
+// leaves out foods that take too long.
+
+type prepStep struct {
+    step string
+    minutes int
+}
+
+// prepRules are checked in order against the lower case description, the
+// first match giving the steps.
+var prepRules = []struct {
+    keyword string
+    steps []prepStep
+}{
+    // Canned and cooked foods are already done
+    {"canned", nil},
+    {"cooked", nil},
+    {"boiled", nil},
+    {"mature seeds, raw", []prepStep{{"soak in plenty of water", 8 * 60}, {"drain, then boil until soft", 60}}},
+    {"lentils, raw", []prepStep{{"rinse, then simmer until soft", 25}}},
+    {"steel cut", []prepStep{{"soak overnight", 8 * 60}}},
+    {"oats", []prepStep{{"soak in water or milk", 15}}},
+    {"flaxseed", []prepStep{{"grind", 2}}},
+    {"chia", []prepStep{{"soak in water until it gels", 15}}},
+    {"quinoa, uncooked", []prepStep{{"rinse, then simmer", 20}}},
+    {"rice, brown", []prepStep{{"simmer", 45}}},
+    {"rice, white", []prepStep{{"simmer", 20}}},
+    {"barley", []prepStep{{"simmer", 45}}},
+    {"potatoes, flesh and skin, raw", []prepStep{{"boil", 20}}},
+}
+
+// prepSteps returns what has to be done to a food before blending, nil for
+// nothing.
+func prepSteps(food *Food) []prepStep {
+    if food.cooking != nil {
+        // --cook says how it's prepared
+        return nil
+    }
+    description := strings.ToLower(food.description)
+    for _, rule := range prepRules {
+        if strings.Contains(description, rule.keyword) {
+            return rule.steps
+        }
+    }
+    return nil
+}
+
+// isCooked reports whether --cook covers the food, which is decided before
+// the retention factors are applied.
+func isCooked(opts *Options, foodId int) bool {
+    for _, spec := range opts.cook {
+        if id, _, _, err := parseCookSpec(spec); err == nil && id == foodId {
+            return true
+        }
+    }
+    return false
+}
+
+// prepMinutes is how long a food's preparation takes in all.
+func prepMinutes(food *Food) int {
+    total := 0
+    for _, step := range prepSteps(food) {
+        total += step.minutes
+    }
+    return total
+}
+
+func minutesString(minutes int) string {
+    if minutes < 60 {
+        return fmt.Sprintf("%d min", minutes)
+    }
+    if minutes % 60 == 0 {
+        return fmt.Sprintf("%d h", minutes / 60)
+    }
+    return fmt.Sprintf("%d h %d min", minutes / 60, minutes % 60)
+}
+
+// printPreparation lists the recipe's foods that need preparing, longest
+// first, since those have to be started first.
+func printPreparation(problem *Problem, recipe *Recipe) {
+    var foodIds []int
+    for foodId := range recipe.foodQuantities {
+        food := problem.foods[foodId]
+        if len(prepSteps(&food)) > 0 {
+            foodIds = append(foodIds, foodId)
+        }
+    }
+    if len(foodIds) == 0 {
+        return
+    }
+    sort.Slice(foodIds, func(i, j int) bool {
+        a, b := problem.foods[foodIds[i]], problem.foods[foodIds[j]]
+        if prepMinutes(&a) != prepMinutes(&b) {
+            return prepMinutes(&a) > prepMinutes(&b)
+        }
+        return foodIds[i] < foodIds[j]
+    })
+
+    fmt.Println("PREPARATION")
+    for _, foodId := range foodIds {
+        food := problem.foods[foodId]
+        fmt.Printf("%s, start %s ahead:\n", food.description, minutesString(prepMinutes(&food)))
+        for i, step := range prepSteps(&food) {
+            fmt.Printf("  %d. %s (%s)\n", i + 1, step.step, minutesString(step.minutes))
+        }
+    }
+    fmt.Println()
+}
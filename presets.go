@@ -0,0 +1,266 @@
+package main
+
+import (
+    _ "embed"
+    "fmt"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// Presets are built in exclusion lists for elimination diets, low-FODMAP,
+// nightshade-free and low-histamine, kept in presets.yaml. --preset turns
+// one on for a run, and a presets: list in the --exclusions file turns them
+// on for good:
+//
+//     presets: [low-fodmap]
+//     exclusions:
+//       - ...
+//
+// Their rules go after the list's own, and say which preset they came from
+// when they leave a food out.
+//
+// Life-stage presets, pregnancy, lactation, adolescent and older-adult,
+// change targets instead. They go on top of the defaults or the DRIs for
+// the body stats given (see dri.go), before --targets and --target, each
+// target they list having its min, max or both replaced, or moved by add:
+// for the extra energy and protein. Some are for one sex or ages only, and
+// saying otherwise with --sex or --age is an error.
+//
+// Clinical presets, ckd, hypertension and warfarin, change targets the same
+// way, mostly capping nutrients. One can also name a dietary pattern, which
+// is reported as --pattern would, unless --pattern gives another.
+
+//go:embed presets.yaml
+var presetsYAML string
+
+type preset struct {
+    description string
+    rules []exclusion
+    sex string // who a life-stage preset is for, "" for anyone
+    minAge, maxAge int // 0 for no limit
+    targets []presetTarget
+    pattern string // a dietary pattern for --pattern, "" for none
+}
+
+// presetTarget is how a life-stage preset changes one target.
+type presetTarget struct {
+    nutrient string
+    sex string // "" for both
+    min, max, add float64
+    hasMin, hasMax bool
+    notes string
+}
+
+// builtinPresets is presets.yaml, parsed the first time it's needed.
+var builtinPresets map[string]preset
+
+func presets() (map[string]preset, error) {
+    if builtinPresets != nil {
+        return builtinPresets, nil
+    }
+    document, err := parseYAML(presetsYAML)
+    if err != nil {
+        return nil, fmt.Errorf("presets.yaml: %v", err)
+    }
+    root, _ := document.(map[string]interface{})
+    entries, _ := root["presets"].(map[string]interface{})
+    parsed := make(map[string]preset, len(entries))
+    for name, entry := range entries {
+        fields, _ := entry.(map[string]interface{})
+        list, _ := fields["exclusions"].([]interface{})
+        rules, err := parseExclusionRules(list, "preset " + name)
+        if err != nil {
+            return nil, fmt.Errorf("presets.yaml: %v", err)
+        }
+        for i := range rules {
+            rules[i].preset = name
+        }
+        found := preset{rules: rules}
+        found.description, _ = fields["description"].(string)
+        found.sex, _ = fields["sex"].(string)
+        found.pattern, _ = fields["pattern"].(string)
+        if ages, given := fields["ages"].(string); given {
+            if found.minAge, found.maxAge, err = parseAgeRange(ages); err != nil {
+                return nil, fmt.Errorf("presets.yaml: preset %s: %v", name, err)
+            }
+        }
+        targetList, _ := fields["targets"].([]interface{})
+        for i, item := range targetList {
+            target, err := parsePresetTarget(item)
+            if err != nil {
+                return nil, fmt.Errorf("presets.yaml: preset %s target %d: %v", name, i + 1, err)
+            }
+            found.targets = append(found.targets, target)
+        }
+        parsed[name] = found
+    }
+    builtinPresets = parsed
+    return builtinPresets, nil
+}
+
+// parseAgeRange reads "14-18" or "71+".
+func parseAgeRange(text string) (int, int, error) {
+    if from, open := strings.CutSuffix(text, "+"); open {
+        min, err := strconv.Atoi(from)
+        return min, 0, err
+    }
+    from, to, _ := strings.Cut(text, "-")
+    min, err := strconv.Atoi(from)
+    if err != nil {
+        return 0, 0, fmt.Errorf("bad ages %q", text)
+    }
+    max, err := strconv.Atoi(to)
+    if err != nil || max < min {
+        return 0, 0, fmt.Errorf("bad ages %q", text)
+    }
+    return min, max, nil
+}
+
+func parsePresetTarget(item interface{}) (presetTarget, error) {
+    var target presetTarget
+    fields, _ := item.(map[string]interface{})
+    target.nutrient, _ = fields["nutrient"].(string)
+    if target.nutrient == "" {
+        return target, fmt.Errorf("no nutrient")
+    }
+    target.sex, _ = fields["sex"].(string)
+    target.notes, _ = fields["notes"].(string)
+    for key, value := range map[string]*float64{"min": &target.min, "max": &target.max, "add": &target.add} {
+        text, given := fields[key].(string)
+        if !given {
+            continue
+        }
+        number, err := strconv.ParseFloat(text, 64)
+        if err != nil {
+            return target, fmt.Errorf("%s: bad %s %q", target.nutrient, key, text)
+        }
+        *value = number
+        target.hasMin = target.hasMin || key == "min"
+        target.hasMax = target.hasMax || key == "max"
+    }
+    return target, nil
+}
+
+func isLifeStagePreset(name string) (bool, error) {
+    all, err := presets()
+    if err != nil {
+        return false, err
+    }
+    return len(all[strings.ToLower(strings.TrimSpace(name))].targets) > 0, nil
+}
+
+// presetTargets applies the life-stage presets among opts.presets to
+// targets, noting each one changed in sources. stats is nil for the
+// defaults, which are a man's.
+func presetTargets(targets []Target, sources map[string]string, opts *Options, stats *bodyStats) ([]Target, error) {
+    all, err := presets()
+    if err != nil {
+        return nil, err
+    }
+    sex := "male"
+    if stats != nil {
+        sex = stats.sex
+    }
+    for _, name := range opts.presets {
+        name = strings.ToLower(strings.TrimSpace(name))
+        found, exists := all[name]
+        if !exists || len(found.targets) == 0 {
+            continue // an exclusion preset, or unknown, which exclusions.go reports
+        }
+        if found.sex != "" {
+            if stats != nil && stats.sex != found.sex {
+                return nil, fmt.Errorf("--preset %s is for %s, not --sex %s", name, found.sex, stats.sex)
+            }
+            sex = found.sex
+        }
+        if stats != nil && (stats.age < found.minAge || found.maxAge > 0 && stats.age > found.maxAge) {
+            return nil, fmt.Errorf("--preset %s is for ages %s, not --age %d", name, found.agesString(), stats.age)
+        }
+
+        for _, change := range found.targets {
+            if change.sex != "" && change.sex != sex {
+                continue
+            }
+            target := Target{nutrient: change.nutrient}
+            for _, existing := range targets {
+                if existing.nutrient == change.nutrient {
+                    target = existing
+                }
+            }
+            if change.hasMin {
+                target.min = change.min
+            }
+            if change.hasMax {
+                target.max = change.max
+            }
+            target.min += change.add
+            if target.max > 0 {
+                target.max += change.add
+            }
+            if target.max > 0 && target.max < target.min {
+                return nil, fmt.Errorf("--preset %s leaves %s with its max under its min", name, change.nutrient)
+            }
+            target.notes = change.notes
+            targets = setTarget(targets, target)
+            sources[change.nutrient] = rulePreset + " " + name
+        }
+    }
+    return targets, nil
+}
+
+// presetPattern is the pattern the first --preset naming one names, "" if
+// none do.
+func presetPattern(opts *Options) (string, error) {
+    all, err := presets()
+    if err != nil {
+        return "", err
+    }
+    for _, name := range opts.presets {
+        if found := all[strings.ToLower(strings.TrimSpace(name))]; found.pattern != "" {
+            return found.pattern, nil
+        }
+    }
+    return "", nil
+}
+
+func (found preset) agesString() string {
+    if found.maxAge == 0 {
+        return fmt.Sprintf("%d+", found.minAge)
+    }
+    return fmt.Sprintf("%d-%d", found.minAge, found.maxAge)
+}
+
+func presetNames(all map[string]preset) []string {
+    var names []string
+    for name := range all {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// addPreset adds a preset's rules to the list, once however often it's
+// asked for.
+func (list *exclusionList) addPreset(name string) error {
+    name = strings.ToLower(strings.TrimSpace(name))
+    all, err := presets()
+    if err != nil {
+        return err
+    }
+    found, exists := all[name]
+    if !exists {
+        return fmt.Errorf("unknown preset %q, want one of %s", name, strings.Join(presetNames(all), ", "))
+    }
+    if len(found.targets) > 0 {
+        return nil // a life-stage preset, see presetTargets
+    }
+    for _, added := range list.presets {
+        if added == name {
+            return nil
+        }
+    }
+    list.presets = append(list.presets, name)
+    list.exclusions = append(list.exclusions, found.rules...)
+    return nil
+}
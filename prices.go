@@ -0,0 +1,159 @@
+package main
+
+import (
+    "encoding/csv"
+    "fmt"
+    "io"
+    "os"
+    "strconv"
+    "strings"
+)
+
+// Prices live in a CSV file, one row per food:
+//
+//     food_id,price,grams,description
+//     11463,3.49,283,"Spinach, frozen, chopped or leaf, unprepared"
+//
+// price is what grams of the food cost as purchased, refuse included, so a
+// price per kg is grams 1000. The description is only there for people.
+//
+// Besides --minimize cost, prices let --max-price-per-kg leave out foods
+// that cost too much to buy, and --max-cost cap what a recipe costs.
+
+// costObjective is the --minimize value that minimizes the recipe's price,
+// the Stigler diet problem.
+const costObjective = "cost"
+
+// readPrices reads a prices file as food id -> price per gram as
+// purchased. A missing file is the same as an empty one.
+func readPrices(path string) (map[int]float64, error) {
+    prices := make(map[int]float64)
+    file, err := os.Open(path)
+    if os.IsNotExist(err) {
+        return prices, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+
+    reader := csv.NewReader(file)
+    reader.FieldsPerRecord = -1
+    header, err := reader.Read()
+    if err == io.EOF {
+        return prices, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("%s: %v", path, err)
+    }
+    if len(header) < 3 || strings.TrimSpace(header[0]) != "food_id" {
+        return nil, fmt.Errorf("%s: expected a food_id,price,grams header", path)
+    }
+
+    for {
+        record, err := reader.Read()
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            return nil, fmt.Errorf("%s: %v", path, err)
+        }
+        line, _ := reader.FieldPos(0)
+        if len(record) < 3 {
+            return nil, fmt.Errorf("%s line %d: expected food_id,price,grams", path, line)
+        }
+        foodId, err := strconv.Atoi(strings.TrimSpace(record[0]))
+        if err != nil {
+            return nil, fmt.Errorf("%s line %d: bad food_id %q", path, line, record[0])
+        }
+        price, err := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+        if err != nil || price < 0 {
+            return nil, fmt.Errorf("%s line %d: bad price %q", path, line, record[1])
+        }
+        grams, err := strconv.ParseFloat(strings.TrimSpace(record[2]), 64)
+        if err != nil || grams <= 0 {
+            return nil, fmt.Errorf("%s line %d: bad grams %q", path, line, record[2])
+        }
+        if _, exists := prices[foodId]; exists {
+            return nil, fmt.Errorf("%s line %d: food %d is priced twice", path, line, foodId)
+        }
+        prices[foodId] = price / grams
+    }
+    return prices, nil
+}
+
+// prices is the --prices file, read the first time it's needed. A bad file
+// exits.
+func (opts *Options) prices() map[int]float64 {
+    if opts.foodPrices == nil {
+        prices, err := readPrices(opts.pricesPath)
+        if err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            os.Exit(2)
+        }
+        opts.foodPrices = prices
+    }
+    return opts.foodPrices
+}
+
+// overPrice reports whether food costs more per kg as purchased than
+// --max-price-per-kg. Foods without a price never are.
+func overPrice(opts *Options, food *Food) bool {
+    if opts.maxPricePerKg <= 0 {
+        return false
+    }
+    price, priced := opts.prices()[food.id]
+    return priced && price * 1000 > opts.maxPricePerKg
+}
+
+// pricedFoods is the foods that have a price.
+func pricedFoods(foods map[int]Food, prices map[int]float64) map[int]Food {
+    priced := make(map[int]Food, len(prices))
+    for id, food := range foods {
+        if _, exists := prices[id]; exists {
+            priced[id] = food
+        }
+    }
+    return priced
+}
+
+// cost is what the recipe's foods cost to buy, refuse included. Foods
+// without a price count as free.
+func (recipe *Recipe) cost(problem *Problem) float64 {
+    total := float64(0)
+    for foodId, grams := range recipe.foodQuantities {
+        food := problem.foods[foodId]
+        total += problem.prices[foodId] * float64(food.purchasedGrams(grams))
+    }
+    return total
+}
+
+// missedTargets counts the targets the recipe falls outside of.
+func (recipe *Recipe) missedTargets(problem *Problem) int {
+    missed := 0
+    for _, target := range problem.targets {
+        if !target.isMet(recipe.amountOf(problem.nutrientNameToId, target.nutrient)) {
+            missed++
+        }
+    }
+    return missed
+}
+
+// printCostComparison sets the cheapest recipe against what the usual
+// penalty scoring picks from the same foods, to show what the savings cost
+// in nutrition.
+func printCostComparison(problem *Problem, cheapest *Recipe) {
+    penaltyProblem := *problem
+    penaltyProblem.objective = ""
+    penaltyRecipe, _ := optimize(&penaltyProblem, NewRecipe(problem.foods, problem.nutrients), false)
+
+    fmt.Println()
+    fmt.Println("COST COMPARISON")
+    fmt.Printf("%-14s %8s %8s %8s\n", "", "cost", "grams", "missed")
+    for _, row := range []struct {
+        name string
+        recipe *Recipe
+    }{{"cheapest", cheapest}, {"penalty-based", penaltyRecipe}} {
+        fmt.Printf("%-14s %8.2f %8d %5d/%d\n", row.name, row.recipe.cost(problem), row.recipe.Mass(), row.recipe.missedTargets(problem), len(problem.targets))
+    }
+}
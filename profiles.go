@@ -0,0 +1,278 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "os"
+    "sort"
+    "strings"
+)
+
+// Profiles are named sets of flags for each person a household blends for,
+// kept in a YAML file (--profiles, profiles.yaml by default). Any flag can go
+// in one, named without its dashes, with a list for repeatable flags:
+//
+//     profiles:
+//       me:
+//         targets: my_targets.yaml
+//       spouse:
+//         sex: female
+//         age: 34
+//         weight-kg: 60
+//         height-cm: 165
+//         preset: [low-fodmap]
+//       kid:
+//         subject-to: kid_targets.yaml
+//         exclusions: kid_exclusions.yaml
+//
+// --profile picks one for a run. "supershake profiles" optimizes a recipe
+// for each, or with --shared a base recipe for everyone plus each person's
+// add-ons. Flags given on the command line win over a profile's.
+//
+// A profile can say as much about someone's health as their history, a
+// pregnancy or kidney disease preset say, so the file can be encrypted like
+// the history is, with "supershake encrypt profiles.yaml", and is then read
+// with the same passphrase.
+
+const defaultProfilesPath = "profiles.yaml"
+
+type profile struct {
+    name string
+    flags map[string][]string
+}
+
+// readProfiles reads the profiles file, decrypting it with passphrase if
+// it's encrypted, in name order.
+func readProfiles(path string, passphrase string) ([]profile, error) {
+    data, err := readStoreFile(path, passphrase)
+    if err != nil {
+        return nil, err
+    }
+    document, err := parseYAML(string(data))
+    if err != nil {
+        return nil, fmt.Errorf("%s: %v", path, err)
+    }
+    root, _ := document.(map[string]interface{})
+    entries, ok := root["profiles"].(map[string]interface{})
+    if !ok {
+        return nil, fmt.Errorf("%s: expected a profiles: map", path)
+    }
+    var profiles []profile
+    for name, entry := range entries {
+        fields, ok := entry.(map[string]interface{})
+        if !ok && entry != nil {
+            return nil, fmt.Errorf("%s: profile %s is not a map of flags", path, name)
+        }
+        p := profile{name: name, flags: make(map[string][]string)}
+        for key, value := range fields {
+            items, isList := value.([]interface{})
+            if !isList {
+                items = []interface{}{value}
+            }
+            for _, item := range items {
+                text, _ := item.(string)
+                p.flags[key] = append(p.flags[key], text)
+            }
+        }
+        profiles = append(profiles, p)
+    }
+    sort.Slice(profiles, func(i, j int) bool { return profiles[i].name < profiles[j].name })
+    return profiles, nil
+}
+
+// apply sets the profile's flags in fs, apart from those given on the
+// command line.
+func (p *profile) apply(fs *flag.FlagSet) error {
+    given := make(map[string]bool)
+    fs.Visit(func(f *flag.Flag) {
+        given[f.Name] = true
+    })
+    var names []string
+    for name := range p.flags {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    for _, name := range names {
+        name = strings.TrimLeft(name, "-")
+        if name == "profile" || name == "profiles" {
+            return fmt.Errorf("profile %s: can't set --%s", p.name, name)
+        }
+        if fs.Lookup(name) == nil {
+            return fmt.Errorf("profile %s: no flag --%s", p.name, name)
+        }
+        if given[name] {
+            continue
+        }
+        for _, value := range p.flags[name] {
+            if err := fs.Set(name, value); err != nil {
+                return fmt.Errorf("profile %s: --%s %s: %v", p.name, name, value, err)
+            }
+        }
+    }
+    return nil
+}
+
+// applyProfile applies --profile, if given, exiting if it can't.
+func applyProfile(fs *flag.FlagSet, opts *Options) {
+    if opts.profile == "" {
+        return
+    }
+    profiles, err := readProfiles(opts.profilesPath, opts.passphrase())
+    if err != nil {
+        fmt.Fprintln(os.Stderr, "--profile:", err)
+        os.Exit(2)
+    }
+    for i := range profiles {
+        if profiles[i].name == opts.profile {
+            if err := profiles[i].apply(fs); err != nil {
+                fmt.Fprintln(os.Stderr, err)
+                os.Exit(2)
+            }
+            return
+        }
+    }
+    var names []string
+    for _, p := range profiles {
+        names = append(names, p.name)
+    }
+    fmt.Fprintf(os.Stderr, "No profile %q in %s, want one of %s\n", opts.profile, opts.profilesPath, strings.Join(names, ", "))
+    os.Exit(2)
+}
+
+// sharedProblem is what a recipe everyone has must work within: the foods
+// every profile may have, and for each nutrient every profile targets, the
+// smallest min and max, so the base is no more than anyone needs.
+func sharedProblem(problems []*Problem) *Problem {
+    shared := *problems[0]
+    shared.foods = make(map[int]Food)
+    for id, food := range problems[0].foods {
+        everyone := true
+        for _, problem := range problems[1:] {
+            if _, exists := problem.foods[id]; !exists {
+                everyone = false
+            }
+        }
+        if everyone {
+            shared.foods[id] = food
+        }
+    }
+
+    shared.targets = nil
+    for _, target := range problems[0].targets {
+        everyone := true
+        for _, problem := range problems[1:] {
+            found := false
+            for _, other := range problem.targets {
+                if other.nutrient != target.nutrient {
+                    continue
+                }
+                found = true
+                if other.min < target.min {
+                    target.min = other.min
+                }
+                if other.max > 0 && (target.max == 0 || other.max < target.max) {
+                    target.max = other.max
+                }
+            }
+            everyone = everyone && found
+        }
+        if everyone {
+            if target.max > 0 && target.max < target.min {
+                target.max = target.min
+            }
+            shared.targets = append(shared.targets, target)
+        }
+    }
+    shared.pattern = nil
+    shared.required = nil
+    return &shared
+}
+
+func printFoodList(problem *Problem, recipe *Recipe, base *Recipe) {
+    for _, foodId := range recipe.FoodIdsByGrams() {
+        food := problem.foods[foodId]
+        grams := recipe.foodQuantities[foodId]
+        if base != nil {
+            grams -= base.foodQuantities[foodId]
+        }
+        if grams > 0 {
+            fmt.Printf("  %s of %s\n", food.gramsString(grams), food.description)
+        }
+    }
+}
+
+// runProfiles handles "supershake profiles".
+func runProfiles(args []string) {
+    fs := flag.NewFlagSet("supershake profiles", flag.ExitOnError)
+    opts := newOptions(fs)
+    shared := fs.Bool("shared", false, "optimize one base recipe for everyone, then each profile's add-ons")
+    fs.Parse(args)
+    if opts.profile != "" {
+        fmt.Fprintln(os.Stderr, "supershake profiles optimizes every profile, leave out --profile")
+        os.Exit(2)
+    }
+    profiles, err := readProfiles(opts.profilesPath, opts.passphrase())
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(2)
+    }
+    if len(profiles) == 0 {
+        fmt.Fprintf(os.Stderr, "No profiles in %s\n", opts.profilesPath)
+        os.Exit(2)
+    }
+
+    // Each profile gets its own options, from the same command line
+    var problems []*Problem
+    fmt.Println(tr("Loading"))
+    for i := range profiles {
+        profileFlags := flag.NewFlagSet("supershake profiles", flag.ExitOnError)
+        profileOpts := newOptions(profileFlags)
+        profileFlags.Bool("shared", false, "")
+        profileFlags.Parse(args)
+        if err := profiles[i].apply(profileFlags); err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            os.Exit(2)
+        }
+        problems = append(problems, loadProblem(profileOpts))
+    }
+
+    if !*shared {
+        for i, problem := range problems {
+            recipe, score := optimize(problem, NewRecipe(problem.foods, problem.nutrients), false)
+            if err := recipe.feasible(problem); err != nil {
+                fmt.Printf("\nPROFILE %s: %v\n", profiles[i].name, err)
+                continue
+            }
+            fmt.Printf("\nPROFILE %s, score %.2f\n", profiles[i].name, score)
+            printFoodList(problem, recipe, nil)
+        }
+        return
+    }
+
+    everyone := sharedProblem(problems)
+    base, baseScore := optimize(everyone, NewRecipe(everyone.foods, everyone.nutrients), false)
+    fmt.Printf("\nSHARED BASE, %d g each, score %.2f against what everyone needs\n", base.Mass(), baseScore)
+    printFoodList(problems[0], base, nil)
+    for i, problem := range problems {
+        // Everyone has all of the base, plus whatever brings it up to
+        // their own targets
+        personal := *problem
+        personal.required = base.foodQuantities
+        start := NewRecipe(problem.foods, problem.nutrients)
+        for foodId, grams := range base.foodQuantities {
+            food := problem.foods[foodId]
+            start.AddFood(problem.foods, &food, grams)
+        }
+        baseAlone := start.Score(&personal, false)
+        recipe, score := optimize(&personal, start, false)
+        if err := recipe.feasible(&personal); err != nil {
+            fmt.Printf("\nADD-ONS FOR %s: %v\n", profiles[i].name, err)
+            continue
+        }
+        fmt.Printf("\nADD-ONS FOR %s, score %.2f, %.2f with the base alone\n", profiles[i].name, score, baseAlone)
+        if recipe.Mass() == base.Mass() {
+            fmt.Println("  none, the base is as good as it gets")
+        }
+        printFoodList(problem, recipe, base)
+    }
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+    "fmt"
+    "math"
+)
+
+// --protein-quality scores the recipe's protein as a whole instead of an
+// amino acid at a time, the way PDCAAS and DIAAS score a food: each
+// indispensable amino acid's digestible mg per g of protein, over what a
+// reference pattern needs, and the lowest of those, the limiting amino
+// acid's, is the score. Being short of 1 costs (1 - score) * 100 *
+// --protein-quality-weight. A recipe of grains, short of lysine, and
+// legumes, short of methionine, scores better than either, so the
+// optimizer goes for complementary proteins.
+//
+//     pdcaas   FAO/WHO 1991's pattern for preschool children
+//     diaas    FAO 2013's pattern for older children and adults
+//
+// Digestibility is one factor per food group, true fecal protein
+// digestibility as PDCAAS has it, so with diaas it's only an approximation
+// of per amino acid ileal digestibility. The amino acid targets are
+// dropped, unless they're from --target, as the score covers them. The
+// score isn't linear, so the linear program (lp.go) leaves it out.
+
+// The indispensable amino acids, sulfur and aromatic ones counted together
+var qualityAminoAcids = []struct {
+    name string
+    parts []string
+}{
+    {"Histidine", []string{"Histidine"}},
+    {"Isoleucine", []string{"Isoleucine"}},
+    {"Leucine", []string{"Leucine"}},
+    {"Lysine", []string{"Lysine"}},
+    {"Methionine + Cystine", []string{"Methionine", "Cystine"}},
+    {"Phenylalanine + Tyrosine", []string{"Phenylalanine", "Tyrosine"}},
+    {"Threonine", []string{"Threonine"}},
+    {"Tryptophan", []string{"Tryptophan"}},
+    {"Valine", []string{"Valine"}},
+}
+
+// mg of each of qualityAminoAcids needed per g of protein
+var aminoAcidPatterns = map[string][]float64{
+    "pdcaas": {19, 28, 66, 58, 25, 63, 34, 11, 35},
+    "diaas": {16, 30, 61, 48, 23, 41, 25, 6.6, 40},
+}
+
+// Protein digestibility by SR26 food group, from PDCAAS tables
+var groupDigestibility = map[string]float64{
+    "0100": 0.96, // milk 0.95, egg 0.97
+    "0500": 0.94,
+    "0700": 0.94,
+    "1000": 0.94,
+    "1300": 0.94,
+    "1500": 0.94,
+    "1700": 0.94,
+    "1200": 0.88,
+    "1600": 0.85, // beans 0.78 to soy protein 0.95
+    "1800": 0.88,
+    "2000": 0.86, // rice 0.88, oats 0.86, whole wheat 0.86
+    "0800": 0.86,
+    "1100": 0.80,
+    "0900": 0.80,
+}
+
+// For groups not above, and custom foods
+const defaultDigestibility = 0.85
+
+type proteinQuality struct {
+    name string
+    pattern []float64
+    perGram map[int][]float64 // food id -> g of each digestible amino acid per g of food
+}
+
+// newProteinQuality sets up --protein-quality, nil when it isn't given.
+func newProteinQuality(opts *Options, nutrientNameToId map[string]int, foods map[int]Food) (*proteinQuality, error) {
+    if opts.proteinQuality == "" {
+        return nil, nil
+    }
+    pattern, exists := aminoAcidPatterns[opts.proteinQuality]
+    if !exists {
+        return nil, fmt.Errorf("--protein-quality %q: want pdcaas or diaas", opts.proteinQuality)
+    }
+    if opts.proteinQualityWeight < 0 {
+        return nil, fmt.Errorf("--protein-quality-weight can't be negative")
+    }
+    quality := &proteinQuality{opts.proteinQuality, pattern, make(map[int][]float64, len(foods))}
+    for id, food := range foods {
+        digestibility, known := groupDigestibility[food.foodGroup]
+        if !known {
+            digestibility = defaultDigestibility
+        }
+        amounts := make([]float64, len(qualityAminoAcids))
+        for i, aminoAcid := range qualityAminoAcids {
+            for _, part := range aminoAcid.parts {
+                amounts[i] += food.perGramOf(nutrientNameToId, part) * digestibility
+            }
+        }
+        quality.perGram[id] = amounts
+    }
+    return quality, nil
+}
+
+// withoutAminoAcidTargets drops the targets the protein quality score
+// covers, apart from ones set with --target.
+func withoutAminoAcidTargets(targets []Target, sources map[string]string) []Target {
+    covered := make(map[string]bool)
+    for _, aminoAcid := range qualityAminoAcids {
+        covered[aminoAcid.name] = true
+        for _, part := range aminoAcid.parts {
+            covered[part] = true
+        }
+    }
+    var kept []Target
+    for _, target := range targets {
+        if !covered[target.nutrient] || sources[target.nutrient] == targetSourceFlag {
+            kept = append(kept, target)
+        }
+    }
+    return kept
+}
+
+// proteinScore is the recipe's amino acid score, and the index of its
+// limiting amino acid in qualityAminoAcids, -1 for a recipe without
+// protein.
+func (recipe *Recipe) proteinScore(problem *Problem) (float64, int) {
+    quality := problem.proteinQuality
+    protein := recipe.nutrientTotals[problem.nutrientNameToId["Protein"]]
+    if protein <= 0 {
+        return 0, -1
+    }
+    amounts := make([]float64, len(qualityAminoAcids))
+    for foodId, grams := range recipe.foodQuantities {
+        for i, perGram := range quality.perGram[foodId] {
+            amounts[i] += perGram * float64(grams)
+        }
+    }
+    score, limiting := math.Inf(1), -1
+    for i, amount := range amounts {
+        // g of amino acid to mg per g of protein
+        ratio := amount * 1000 / protein / quality.pattern[i]
+        if ratio < score {
+            score, limiting = ratio, i
+        }
+    }
+    return score, limiting
+}
+
+// proteinQualityPenalty is the --protein-quality part of the score.
+func (recipe *Recipe) proteinQualityPenalty(problem *Problem, verbose bool) float64 {
+    if problem.proteinQuality == nil {
+        return 0
+    }
+    score, limiting := recipe.proteinScore(problem)
+    penalty := math.Max(1 - score, 0) * 100 * problem.opts.proteinQualityWeight
+    if verbose && limiting >= 0 {
+        fmt.Printf("Penalty for protein quality %.2f, limited by %s: %f\n", score, qualityAminoAcids[limiting].name, penalty)
+    }
+    return penalty
+}
+
+func printProteinQuality(problem *Problem, recipe *Recipe) {
+    score, limiting := recipe.proteinScore(problem)
+    if limiting < 0 {
+        fmt.Println("PROTEIN QUALITY: no protein")
+        return
+    }
+    shown := score
+    if problem.proteinQuality.name == "pdcaas" {
+        // PDCAAS is truncated at 1, DIAAS isn't
+        shown = math.Min(score, 1)
+    }
+    fmt.Printf("PROTEIN QUALITY: %s %.2f, limited by %s\n", problem.proteinQuality.name, shown, qualityAminoAcids[limiting].name)
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+)
+
+// SR26 says how each value in NUT_DATA.txt was arrived at (SRC_CD.txt), and
+// for analysed values which studies they came from: DATSRCLN.txt links a
+// food and nutrient to DATA_SRC.txt's citations.
+
+// Src_Cd values
+const (
+    sourceUnknown = 0
+    sourceAnalytical = 1
+    sourceCalculated = 4
+    sourceLabel = 5
+    sourceAggregated = 6
+    sourceAssumedZero = 7
+    sourceLabelCalculated = 8
+    sourceManufacturerCalculated = 9
+    sourceAggregatedAnalytical = 11
+    sourceManufacturerAnalytical = 12
+    sourceLiterature = 13
+)
+
+// Confidence levels, most trustworthy first
+var confidenceLevels = []string{"analytical", "label", "calculated", "assumed zero", "unknown"}
+
+// confidence groups a source code into one of confidenceLevels.
+func confidence(sourceCode int) string {
+    switch sourceCode {
+    case sourceAnalytical, sourceAggregatedAnalytical, sourceManufacturerAnalytical, sourceLiterature:
+        return "analytical"
+    case sourceLabel, sourceLabelCalculated:
+        return "label"
+    case sourceCalculated, sourceAggregated, sourceManufacturerCalculated:
+        return "calculated"
+    case sourceAssumedZero:
+        return "assumed zero"
+    }
+    return "unknown"
+}
+
+// printConfidence flags the targets whose totals in recipe rest on anything
+// less than analysed values, with how much of each total came from where,
+// and how many of the recipe's foods had an imputed value, which --imputed
+// may have changed.
+func printConfidence(problem *Problem, recipe *Recipe) {
+    fmt.Println("DATA CONFIDENCE")
+    flagged := 0
+    for _, target := range problem.targets {
+        parts := []derivedPart{{target.nutrient, 1}}
+        if derived, exists := derivedNutrients[target.nutrient]; exists {
+            parts = derived
+        }
+
+        byLevel := make(map[string]float64)
+        total := float64(0)
+        imputed := 0
+        for foodId, grams := range recipe.foodQuantities {
+            food := problem.foods[foodId]
+            for _, part := range parts {
+                id, exists := problem.nutrientNameToId[part.nutrient]
+                if !exists {
+                    continue
+                }
+                for _, nutrientInFood := range food.nutrients {
+                    if nutrientInFood.nutrient.id != id {
+                        continue
+                    }
+                    if nutrientInFood.imputed {
+                        imputed++
+                    }
+                    amount := nutrientInFood.amountPerG * float64(grams) * part.factor
+                    byLevel[confidence(nutrientInFood.sourceCode)] += amount
+                    total += amount
+                }
+            }
+        }
+        if imputed == 0 && byLevel["analytical"] == total {
+            continue
+        }
+        flagged++
+
+        var shares []string
+        for _, level := range confidenceLevels {
+            if total > 0 && byLevel[level] > 0 {
+                shares = append(shares, fmt.Sprintf("%.0f%% %s", byLevel[level] / total * 100, level))
+            }
+        }
+        if imputed > 0 {
+            policy := problem.imputedPolicies.forNutrient(problem.nutrientNameToId, target.nutrient)
+            shares = append(shares, fmt.Sprintf("imputed for %d foods, which were %s", imputed, policy.pastTense()))
+        }
+        fmt.Printf("  %s: %s\n", target.nutrient, strings.Join(shares, ", "))
+    }
+    if flagged == 0 {
+        fmt.Println("  every target is from analysed values")
+    }
+}
+
+// citation is one row of DATA_SRC.txt.
+type citation struct {
+    authors string
+    title string
+    year string
+    journal string
+}
+
+func (c citation) String() string {
+    text := c.title
+    if c.authors != "" {
+        text = strings.TrimSuffix(c.authors, ".") + ". " + text
+    }
+    if c.journal != "" {
+        text += ". " + c.journal
+    }
+    if c.year != "" {
+        text += fmt.Sprintf(" (%s)", c.year)
+    }
+    return text
+}
+
+// printCitations lists the studies behind the recipe's foods, from
+// DATSRCLN.txt and DATA_SRC.txt, which are only read when asked for.
+func printCitations(problem *Problem, recipe *Recipe) {
+    dataDir := problem.opts.dataDir
+    for _, name := range []string{"DATSRCLN.txt", "DATA_SRC.txt"} {
+        if !dataFileExists(dataDir, name) {
+            fmt.Printf("No %s in %s, so no citations\n", name, dataDir)
+            return
+        }
+    }
+
+    // food id -> data source ids
+    sourcesByFood := make(map[int]map[string]bool)
+    links := openUSDAFile(dataDir, "DATSRCLN.txt")
+    for record := links.next(); record != nil; record = links.next() {
+        ndb, err := links.textInt(record, 0)
+        if err != nil { links.skip(err); continue }
+        if _, inRecipe := recipe.foodQuantities[ndb]; !inRecipe {
+            continue
+        }
+        sourceId, err := links.text(record, 2)
+        if err != nil { links.skip(err); continue }
+        if sourcesByFood[ndb] == nil {
+            sourcesByFood[ndb] = make(map[string]bool)
+        }
+        sourcesByFood[ndb][sourceId] = true
+    }
+    links.close()
+
+    citations := make(map[string]citation)
+    sources := openUSDAFile(dataDir, "DATA_SRC.txt")
+    for record := sources.next(); record != nil; record = sources.next() {
+        id, err := sources.text(record, 0)
+        if err != nil { sources.skip(err); continue }
+        fields := make([]string, 5)
+        for i := 1; i < 5; i++ {
+            fields[i], err = sources.text(record, i)
+            if err != nil { break }
+        }
+        if err != nil { sources.skip(err); continue }
+        citations[id] = citation{fields[1], fields[2], fields[3], fields[4]}
+    }
+    sources.close()
+
+    fmt.Println("CITATIONS")
+    for _, foodId := range recipe.FoodIdsByGrams() {
+        ids := make([]string, 0, len(sourcesByFood[foodId]))
+        for id := range sourcesByFood[foodId] {
+            ids = append(ids, id)
+        }
+        if len(ids) == 0 {
+            continue
+        }
+        sort.Strings(ids)
+        fmt.Println(problem.foods[foodId].description)
+        for _, id := range ids {
+            if c, exists := citations[id]; exists {
+                fmt.Printf("  [%s] %s\n", id, c)
+            } else {
+                fmt.Printf("  [%s] not in DATA_SRC.txt\n", id)
+            }
+        }
+    }
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+    "fmt"
+    "strings"
+)
+
+// --ratio asks for one nutrient's amount over another's to be in a range,
+// like --target's:
+//
+//     --ratio "Omega-6 / Omega-3=:4"
+//     --ratio "Sodium, Na / Potassium, K=:1"
+//     --ratio "Calcium, Ca / Magnesium, Mg=1.5:2.5"
+//     --ratio "Zinc, Zn / Copper, Cu=8:12"
+//
+// The ratio is of the recipe's totals, each in its nutrient's units, so
+// mg over mg for minerals. A ratio outside its range costs the percentage
+// it's outside by, as a target's shortfall does, times --ratio-weight. A
+// recipe with none of the denominator but some of the numerator is as far
+// out as can be, and costs 100. Ratios aren't linear in the grams, so the
+// linear program (lp.go) leaves them out.
+
+type nutrientRatio struct {
+    numerator string
+    denominator string
+    min float64
+    max float64 // 0 for no max
+}
+
+// parseRatios reads the --ratio flags.
+func parseRatios(opts *Options, nutrients map[int]Nutrient, nutrientNameToId map[string]int) ([]nutrientRatio, error) {
+    if opts.ratioWeight < 0 {
+        return nil, fmt.Errorf("--ratio-weight can't be negative")
+    }
+    var ratios []nutrientRatio
+    for _, spec := range opts.ratios {
+        equals := strings.LastIndex(spec, "=")
+        if equals < 0 {
+            return nil, fmt.Errorf("--ratio %q should look like \"Nutrient / Nutrient=min:max\"", spec)
+        }
+        names, _ := splitExpression(spec[:equals], " / ")
+        if len(names) != 2 {
+            return nil, fmt.Errorf("--ratio %q should look like \"Nutrient / Nutrient=min:max\"", spec)
+        }
+        for i := range names {
+            names[i] = strings.Trim(strings.TrimSpace(names[i]), "\"")
+            if !isTargetNutrient(nutrientNameToId, names[i]) {
+                return nil, fmt.Errorf("--ratio %q: no nutrient %q", spec, names[i])
+            }
+        }
+        ratio := nutrientRatio{numerator: names[0], denominator: names[1]}
+        bounds := strings.SplitN(spec[equals + 1:], ":", 2)
+        values := make([]float64, 2)
+        for i, bound := range bounds {
+            amount, err := parseTargetBound(bound, "", "", 0)
+            if err != nil || amount < 0 {
+                return nil, fmt.Errorf("--ratio %q: bad bound %q", spec, bound)
+            }
+            values[i] = amount
+        }
+        ratio.min, ratio.max = values[0], values[1]
+        if ratio.min == 0 && ratio.max == 0 {
+            return nil, fmt.Errorf("--ratio %q needs a min or a max", spec)
+        }
+        if ratio.max != 0 && ratio.max < ratio.min {
+            return nil, fmt.Errorf("--ratio %q: max is below min", spec)
+        }
+        ratios = append(ratios, ratio)
+    }
+    return ratios, nil
+}
+
+func (ratio nutrientRatio) String() string {
+    return ratio.numerator + " / " + ratio.denominator
+}
+
+func (ratio nutrientRatio) rangeString() string {
+    switch {
+    case ratio.max == 0:
+        return fmt.Sprintf("at least %g", ratio.min)
+    case ratio.min == 0:
+        return fmt.Sprintf("at most %g", ratio.max)
+    case ratio.min == ratio.max:
+        return fmt.Sprintf("%g", ratio.min)
+    }
+    return fmt.Sprintf("%g to %g", ratio.min, ratio.max)
+}
+
+// value is recipe's ratio, and false if it has none of the denominator.
+func (ratio nutrientRatio) value(problem *Problem, recipe *Recipe) (float64, bool) {
+    denominator := recipe.amountOf(problem.nutrientNameToId, ratio.denominator)
+    if denominator <= 0 {
+        return 0, false
+    }
+    return recipe.amountOf(problem.nutrientNameToId, ratio.numerator) / denominator, true
+}
+
+// percentOutside is how far recipe's ratio is outside the range, as a
+// percentage of the bound it's past.
+func (ratio nutrientRatio) percentOutside(problem *Problem, recipe *Recipe) float64 {
+    value, defined := ratio.value(problem, recipe)
+    if !defined {
+        if recipe.amountOf(problem.nutrientNameToId, ratio.numerator) > 0 {
+            return 100
+        }
+        return 0
+    }
+    if value < ratio.min {
+        return (ratio.min - value) / ratio.min * 100
+    }
+    if ratio.max > 0 && value > ratio.max {
+        return (value - ratio.max) / ratio.max * 100
+    }
+    return 0
+}
+
+// ratioPenalty is the --ratio part of the score.
+func (recipe *Recipe) ratioPenalty(problem *Problem, verbose bool) float64 {
+    penalty := float64(0)
+    for _, ratio := range problem.ratios {
+        outside := ratio.percentOutside(problem, recipe) * problem.opts.ratioWeight
+        if verbose && outside > 0 { fmt.Printf("Penalty for %s outside %s: %f\n", ratio, ratio.rangeString(), outside) }
+        penalty += outside
+    }
+    return penalty
+}
+
+func printRatios(problem *Problem, recipe *Recipe) {
+    fmt.Println("RATIOS")
+    for _, ratio := range problem.ratios {
+        shown := "none of the second"
+        if value, defined := ratio.value(problem, recipe); defined {
+            shown = fmt.Sprintf("%.2f", value)
+        }
+        status := "ok"
+        if outside := ratio.percentOutside(problem, recipe); outside > 0 {
+            status = fmt.Sprintf("%.0f%% out", outside)
+        }
+        fmt.Printf("  %-40s %s  want %s  %s\n", ratio, shown, ratio.rangeString(), status)
+    }
+}
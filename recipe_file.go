@@ -0,0 +1,129 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "time"
+)
+
+// Recipes are saved as JSON so they can be logged, shared or edited by hand:
+//
+//     {"foods": [{"id": 11457, "grams": 90, "description": "Spinach, raw"}]}
+//
+// The description, and purchased_grams for foods with refuse like peels or
+// bones, are only there for people; the id and grams are what get read back.
+//
+// Saved recipes also get an id, and one derived from another (re-optimized
+// with --start-recipe, or scaled or substituted by "supershake edit") names
+// it as its parent. Every save goes in the --history file too, for
+// "supershake history" to show how a shake evolved.
+
+type recipeFile struct {
+    Id string `json:"id,omitempty"`
+    Parent string `json:"parent,omitempty"`
+    DerivedBy string `json:"derived_by,omitempty"` // optimized, re-optimized, scaled, substituted
+    Note string `json:"note,omitempty"` // what was done, e.g. the scale factor
+    Created string `json:"created,omitempty"`
+    Foods []recipeFileFood `json:"foods"`
+}
+
+// recipeLineage is where a recipe being saved came from.
+type recipeLineage struct {
+    parent string // the parent's id, "" for none
+    derivedBy string
+    note string
+}
+
+type recipeFileFood struct {
+    Id int `json:"id"`
+    Grams int `json:"grams"`
+    Description string `json:"description,omitempty"`
+    PurchasedGrams int `json:"purchased_grams,omitempty"` // with the refuse, for shopping
+}
+
+// writeRecipeFile saves recipe with a new id, recording it in the history
+// file unless historyPath is "". It returns the id.
+func writeRecipeFile(path string, historyPath string, problem *Problem, recipe *Recipe, lineage recipeLineage) (string, error) {
+    file := newRecipeFile(problem, recipe)
+    file.Id = newShareToken()
+    file.Parent, file.DerivedBy, file.Note = lineage.parent, lineage.derivedBy, lineage.note
+    file.Created = time.Now().Format(time.RFC3339)
+    data, err := json.MarshalIndent(file, "", "  ")
+    if err != nil {
+        return "", err
+    }
+    if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+        return "", err
+    }
+    if historyPath != "" {
+        entry := historyEntry{recipeFile: file, Path: path, Score: recipe.Score(problem, false)}
+        if err := appendHistory(historyPath, problem.opts.passphrase(), entry); err != nil {
+            return "", err
+        }
+    }
+    return file.Id, nil
+}
+
+func newRecipeFile(problem *Problem, recipe *Recipe) recipeFile {
+    file := recipeFile{}
+    for _, foodId := range recipe.FoodIdsByGrams() {
+        food := problem.foods[foodId]
+        grams := recipe.foodQuantities[foodId]
+        entry := recipeFileFood{foodId, grams, food.description, 0}
+        if purchased := food.purchasedGrams(grams); purchased != grams {
+            entry.PurchasedGrams = purchased
+        }
+        file.Foods = append(file.Foods, entry)
+    }
+    return file
+}
+
+// recipeJSON is the recipe as it's saved, without an id.
+func recipeJSON(problem *Problem, recipe *Recipe) ([]byte, error) {
+    data, err := json.MarshalIndent(newRecipeFile(problem, recipe), "", "  ")
+    if err != nil {
+        return nil, err
+    }
+    return append(data, '\n'), nil
+}
+
+// readRecipeFile reads a saved recipe as food id -> grams.
+func readRecipeFile(path string) (map[int]int, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    return parseRecipeFile(data, path)
+}
+
+// readRecipeId reads a saved recipe's id, "" for recipes saved before they
+// had one.
+func readRecipeId(path string) (string, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return "", err
+    }
+    var file recipeFile
+    if err := json.Unmarshal(data, &file); err != nil {
+        return "", fmt.Errorf("%s: %v", path, err)
+    }
+    return file.Id, nil
+}
+
+// parseRecipeFile reads a saved recipe out of data, name saying where it
+// came from in errors.
+func parseRecipeFile(data []byte, path string) (map[int]int, error) {
+    var file recipeFile
+    if err := json.Unmarshal(data, &file); err != nil {
+        return nil, fmt.Errorf("%s: %v", path, err)
+    }
+    quantities := make(map[int]int, len(file.Foods))
+    for _, food := range file.Foods {
+        if food.Grams <= 0 {
+            return nil, fmt.Errorf("%s: food %d has %d grams", path, food.Id, food.Grams)
+        }
+        quantities[food.Id] += food.Grams
+    }
+    return quantities, nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+    "bufio"
+    "flag"
+    "fmt"
+    "os"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// "supershake reject <food>..." marks foods as never to be suggested again,
+// say after a recipe came back with something you won't drink. They're
+// kept in the --rejected file, which every later run leaves out, custom
+// foods included. "supershake reject --undo <food>" takes them off again,
+// and with no foods it lists them.
+//
+// The file has a line per food, its NDB id then its description for
+// whoever reads it; only the id counts, so it can be edited by hand.
+
+const defaultRejectedPath = "rejected_foods.txt"
+
+type rejectedFoods struct {
+    path string
+    descriptions map[int]string // by id
+}
+
+// readRejectedFoods reads the rejections in path, none if it isn't there.
+func readRejectedFoods(path string) (*rejectedFoods, error) {
+    rejected := &rejectedFoods{path: path, descriptions: make(map[int]string)}
+    f, err := os.Open(path)
+    if os.IsNotExist(err) {
+        return rejected, nil
+    } else if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+
+    scanner := bufio.NewScanner(f)
+    for lineNumber := 1; scanner.Scan(); lineNumber++ {
+        line := strings.TrimSpace(scanner.Text())
+        if line == "" || strings.HasPrefix(line, "#") {
+            continue
+        }
+        fields := strings.SplitN(line, " ", 2)
+        id, err := strconv.Atoi(fields[0])
+        if err != nil {
+            return nil, fmt.Errorf("%s:%d: %q should start with a food id", path, lineNumber, line)
+        }
+        rejected.descriptions[id] = ""
+        if len(fields) > 1 {
+            rejected.descriptions[id] = strings.TrimSpace(fields[1])
+        }
+    }
+    return rejected, scanner.Err()
+}
+
+func (rejected *rejectedFoods) write() error {
+    ids := make([]int, 0, len(rejected.descriptions))
+    for id := range rejected.descriptions {
+        ids = append(ids, id)
+    }
+    sort.Ints(ids)
+    var out strings.Builder
+    out.WriteString("# Foods supershake won't suggest again, see \"supershake reject\"\n")
+    for _, id := range ids {
+        fmt.Fprintf(&out, "%d %s\n", id, rejected.descriptions[id])
+    }
+    return os.WriteFile(rejected.path, []byte(out.String()), 0644)
+}
+
+func (rejected *rejectedFoods) rejects(food *Food) bool {
+    _, exists := rejected.descriptions[food.id]
+    return exists
+}
+
+// rejected is the --rejected list, read the first time it's needed, nil
+// with --rejected "". A bad file exits.
+func (opts *Options) rejected() *rejectedFoods {
+    if opts.rejectedPath == "" {
+        return nil
+    }
+    if opts.rejectedFoods == nil {
+        rejected, err := readRejectedFoods(opts.rejectedPath)
+        if err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            os.Exit(2)
+        }
+        opts.rejectedFoods = rejected
+    }
+    return opts.rejectedFoods
+}
+
+// runReject handles "supershake reject".
+func runReject(args []string) {
+    fs := flag.NewFlagSet("supershake reject", flag.ExitOnError)
+    opts := newOptions(fs)
+    undo := fs.Bool("undo", false, "take the foods off the list, so recipes may use them again")
+    fs.Parse(args)
+    rejected := opts.rejected()
+    if rejected == nil {
+        fmt.Fprintln(os.Stderr, "supershake reject needs a --rejected file")
+        os.Exit(2)
+    }
+
+    if fs.NArg() == 0 {
+        if len(rejected.descriptions) == 0 {
+            fmt.Printf("No foods rejected in %s\n", rejected.path)
+            return
+        }
+        ids := make([]int, 0, len(rejected.descriptions))
+        for id := range rejected.descriptions {
+            ids = append(ids, id)
+        }
+        sort.Ints(ids)
+        fmt.Printf("Rejected in %s:\n", rejected.path)
+        for _, id := range ids {
+            fmt.Printf("  %d %s\n", id, rejected.descriptions[id])
+        }
+        return
+    }
+
+    fmt.Println(tr("Loading"))
+    _, _, allFoods := loadNutrientsAndFoods(opts)
+    for _, name := range fs.Args() {
+        food, found := findFood(allFoods, name)
+        if !found {
+            if id, err := strconv.Atoi(name); err == nil && *undo {
+                // Foods from data that's no longer loaded can still go
+                food = Food{id: id, description: rejected.descriptions[id]}
+            } else {
+                fmt.Fprintf(os.Stderr, "No food matches %q\n", name)
+                os.Exit(1)
+            }
+        }
+        if *undo {
+            if !rejected.rejects(&food) {
+                fmt.Printf("%d %s wasn't rejected\n", food.id, food.description)
+                continue
+            }
+            delete(rejected.descriptions, food.id)
+            fmt.Printf("Recipes may use %d %s again\n", food.id, food.description)
+        } else {
+            rejected.descriptions[food.id] = food.description
+            fmt.Printf("Rejected %d %s, recipes won't use it again\n", food.id, food.description)
+        }
+    }
+    if err := rejected.write(); err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(1)
+    }
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+    "fmt"
+    "path/filepath"
+    "strconv"
+    "strings"
+)
+
+// Foods can be cooked before they go in the blender. USDA's Table of
+// Nutrient Retention Factors (retn06.txt, in the same ^ and ~ format as SR26)
+// says how much of each nutrient survives a way of cooking a kind of food:
+//
+//     ~3002~^~1100~^~VEG, GREENS, BOILED~^~401~^~Vitamin C~^55
+//
+// is 55% of the vitamin C left in boiled greens. A cooked food is still
+// weighed raw in recipes; its nutrients per raw gram are scaled by the
+// retention factors, and the yield (cooked weight over raw weight) moves
+// water in or out.
+
+const retentionFile = "retn06.txt"
+
+// Cooking is how one food is cooked.
+type Cooking struct {
+    retentionCode int
+    description string
+    factors map[int]float64 // nutrient id -> share retained, 1 when missing
+    yield float64
+}
+
+// readRetentionFactors reads retn06.txt as retention code -> cooking, with no
+// yield set.
+func readRetentionFactors(path string) (map[int]*Cooking, error) {
+    if _, err := findDataFile(filepath.Dir(path), filepath.Base(path)); err != nil {
+        return nil, err
+    }
+    factors := openUSDAFile(filepath.Dir(path), filepath.Base(path))
+    defer factors.close()
+
+    cookings := make(map[int]*Cooking)
+    for record := factors.next(); record != nil; record = factors.next() {
+        code, err := factors.textInt(record, 0)
+        if err != nil { factors.skip(err); continue }
+        description, err := factors.text(record, 2)
+        if err != nil { factors.skip(err); continue }
+        nutrientId, err := factors.textInt(record, 3)
+        if err != nil { factors.skip(err); continue }
+        factor, err := factors.float(record, 5)
+        if err != nil { factors.skip(err); continue }
+
+        cooking, exists := cookings[code]
+        if !exists {
+            cooking = &Cooking{retentionCode: code, description: description, factors: make(map[int]float64)}
+            cookings[code] = cooking
+        }
+        cooking.factors[nutrientId] = factor / 100
+    }
+    return cookings, nil
+}
+
+// parseCookSpec reads a --cook flag, "foodId=retentionCode" with an optional
+// ":yield", e.g. "11457=3002:0.78" for spinach boiled down to 78% of its raw
+// weight.
+func parseCookSpec(spec string) (int, int, float64, error) {
+    equals := strings.Index(spec, "=")
+    if equals < 0 {
+        return 0, 0, 0, fmt.Errorf("--cook %q should look like \"foodId=retentionCode[:yield]\"", spec)
+    }
+    foodId, err := strconv.Atoi(strings.TrimSpace(spec[:equals]))
+    if err != nil {
+        return 0, 0, 0, fmt.Errorf("--cook %q: bad food id", spec)
+    }
+    parts := strings.SplitN(spec[equals + 1:], ":", 2)
+    code, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+    if err != nil {
+        return 0, 0, 0, fmt.Errorf("--cook %q: bad retention code", spec)
+    }
+    yield := float64(1)
+    if len(parts) == 2 {
+        yield, err = strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+        if err != nil || yield <= 0 {
+            return 0, 0, 0, fmt.Errorf("--cook %q: bad yield", spec)
+        }
+    }
+    return foodId, code, yield, nil
+}
+
+// cookFoods applies the --cook flags to foods, which are changed in place.
+func cookFoods(opts *Options, nutrientNameToId map[string]int, foods map[int]Food) error {
+    if len(opts.cook) == 0 {
+        return nil
+    }
+    path := opts.retentionPath
+    if path == "" {
+        path = filepath.Join(opts.dataDir, retentionFile)
+    }
+    cookings, err := readRetentionFactors(path)
+    if err != nil {
+        return fmt.Errorf("--cook needs the USDA retention factors: %v", err)
+    }
+
+    for _, spec := range opts.cook {
+        foodId, code, yield, err := parseCookSpec(spec)
+        if err != nil {
+            return err
+        }
+        food, exists := foods[foodId]
+        if !exists {
+            return fmt.Errorf("--cook %q: no food %d, or it is excluded", spec, foodId)
+        }
+        retention, exists := cookings[code]
+        if !exists {
+            return fmt.Errorf("--cook %q: no retention code %d in %s", spec, code, path)
+        }
+        cooking := *retention
+        cooking.yield = yield
+        foods[foodId] = food.cooked(&cooking, nutrientNameToId["Water"])
+    }
+    return nil
+}
+
+// cooked is the food after cooking, still per raw gram.
+func (food Food) cooked(cooking *Cooking, waterId int) Food {
+    nutrients := make([]NutrientInFood, len(food.nutrients))
+    for i, nutrientInFood := range food.nutrients {
+        id := nutrientInFood.nutrient.id
+        if factor, exists := cooking.factors[id]; exists {
+            nutrientInFood.amountPerG *= factor
+        }
+        if id == waterId {
+            // A gram of raw food gains or loses yield - 1 grams, all water
+            nutrientInFood.amountPerG += cooking.yield - 1
+            if nutrientInFood.amountPerG < 0 {
+                nutrientInFood.amountPerG = 0
+            }
+        }
+        nutrients[i] = nutrientInFood
+    }
+    food.nutrients = nutrients
+    food.cooking = cooking
+    return food
+}
+
+// cookedString is " (about 70 grams once boiled greens)", or "" for raw
+// foods.
+func (food *Food) cookedString(grams int) string {
+    if food.cooking == nil {
+        return ""
+    }
+    return fmt.Sprintf(" (about %.0f grams once %s)", float64(grams) * food.cooking.yield, strings.ToLower(food.cooking.description))
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "strings"
+)
+
+// runRobustness optimizes a recipe, then takes each ingredient out in turn,
+// re-optimizes the rest and reports which targets can no longer be met.
+// Useful for knowing ahead of time which ingredients need a substitute on
+// hand.
+func runRobustness(args []string) {
+    fs := flag.NewFlagSet("supershake robustness", flag.ExitOnError)
+    opts := newOptions(fs)
+    fs.Parse(args)
+    applyProfile(fs, opts)
+
+    fmt.Println(tr("Loading"))
+    problem := loadProblem(opts)
+    recipe, score := optimize(problem, NewRecipe(problem.foods, problem.nutrients), false)
+    fmt.Printf("Optimized recipe scores %.2f with %d ingredients\n\n", score, len(recipe.foodQuantities))
+
+    for _, foodId := range recipe.FoodIdsByGrams() {
+        food := problem.foods[foodId]
+        grams := recipe.foodQuantities[foodId]
+
+        // Starting from the full recipe minus this food keeps the
+        // re-optimization short, since most of the recipe is still good
+        without := problem.withoutFood(foodId)
+        start := recipe.Clone(problem.foods, problem.nutrients)
+        start.RemoveFood(problem.foods, &food, grams)
+        reoptimized, newScore := optimize(without, start, false)
+
+        var broken []string
+        for _, target := range problem.targets {
+            before := recipe.amountOf(problem.nutrientNameToId, target.nutrient)
+            after := reoptimized.amountOf(problem.nutrientNameToId, target.nutrient)
+            if target.isMet(before) && !target.isMet(after) {
+                broken = append(broken, fmt.Sprintf("%s (%.2f, want %s)", target.nutrient, after, target.rangeString()))
+            }
+        }
+
+        fmt.Printf("Without %d grams of %s: score %.2f (%+.2f)\n", grams, food.description, newScore, newScore - score)
+        if len(broken) == 0 {
+            fmt.Println("  every target still met")
+        } else {
+            fmt.Println("  misses " + strings.Join(broken, "\n  misses "))
+        }
+    }
+}
+
+// withoutFood is a copy of the problem that can't use one food.
+func (problem *Problem) withoutFood(foodId int) *Problem {
+    foods := make(map[int]Food, len(problem.foods))
+    for id, food := range problem.foods {
+        if id != foodId {
+            foods[id] = food
+        }
+    }
+    without := *problem
+    without.foods = foods
+    return &without
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "encoding/hex"
+    "flag"
+    "fmt"
+    "math/rand"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+)
+
+// "supershake selftest" runs the whole pipeline on the fixture dataset,
+// generating it, loading it, optimizing with both solvers and reporting,
+// and checks each stage's output against checksums of a known good build.
+// It needs no data, network or Go toolchain, so packagers and users can run
+// it to see their build behaves like everyone else's.
+//
+// Each stage's output is written out as text first, rounded so that
+// differences in the last bits of floating point between platforms don't
+// matter, and the checksum is of that text. -v prints the text of stages
+// that fail, to diff against a good build's.
+//
+// Changing the optimizer, the targets or the fixture changes these on
+// purpose; run "supershake selftest -v" and update them when it does.
+
+var selftestChecksums = map[string]string{
+    "fixture": "73935bb59190c1ca",
+    "load": "3037195314b73fee",
+    "hill": "6f017190ccfbbc3f",
+    "lp": "a07eb8efdc451452",
+    "report": "a20376d7fe3285cc",
+}
+
+// selftestStages in the order they run
+var selftestStages = []string{"fixture", "load", "hill", "lp", "report"}
+
+func checksum(text string) string {
+    sum := sha256.Sum256([]byte(text))
+    return hex.EncodeToString(sum[:8])
+}
+
+// selftestFixture generates the fixture from its seed and compares it to
+// the copy built into the binary.
+func selftestFixture(dir string) string {
+    writeFixture(dir, 50, fixtureNutrients, rand.New(rand.NewSource(1)))
+    names, err := filepath.Glob(filepath.Join(dir, "*.txt"))
+    if err != nil {
+        panic(err)
+    }
+    sort.Strings(names)
+    var out strings.Builder
+    for _, name := range names {
+        data, err := os.ReadFile(name)
+        if err != nil {
+            panic(err)
+        }
+        embedded, err := embeddedFixture.ReadFile("testdata/fixture/" + filepath.Base(name))
+        same := err == nil && bytes.Equal(data, embedded)
+        fmt.Fprintf(&out, "%s %s same-as-built-in=%v\n", filepath.Base(name), checksum(string(data)), same)
+    }
+    return out.String()
+}
+
+// selftestLoad is what the loader made of the fixture.
+func selftestLoad(problem *Problem) string {
+    var out strings.Builder
+    ids := make([]int, 0, len(problem.foods))
+    for id := range problem.foods {
+        ids = append(ids, id)
+    }
+    sort.Ints(ids)
+    for _, id := range ids {
+        food := problem.foods[id]
+        fmt.Fprintf(&out, "%d %s %q", id, food.foodGroup, food.description)
+        for _, nutrient := range food.nutrients {
+            fmt.Fprintf(&out, " %d=%.4g", nutrient.nutrient.id, nutrient.amountPerG)
+        }
+        out.WriteString("\n")
+    }
+    for _, target := range problem.targets {
+        fmt.Fprintf(&out, "target %q %.4g %.4g\n", target.nutrient, target.min, target.max)
+    }
+    return out.String()
+}
+
+// selftestRecipe is a recipe's grams and score.
+func selftestRecipe(problem *Problem, recipe *Recipe) string {
+    var out strings.Builder
+    ids := make([]int, 0, len(recipe.foodQuantities))
+    for id, grams := range recipe.foodQuantities {
+        if grams > 0 {
+            ids = append(ids, id)
+        }
+    }
+    sort.Ints(ids)
+    for _, id := range ids {
+        fmt.Fprintf(&out, "%d %dg\n", id, recipe.foodQuantities[id])
+    }
+    fmt.Fprintf(&out, "score %.2f\n", recipe.Score(problem, false))
+    return out.String()
+}
+
+// selftestReport is the nutrient report for a recipe, as printRecipe
+// prints it.
+func selftestReport(problem *Problem, recipe *Recipe) string {
+    var out strings.Builder
+    for _, target := range problem.targets {
+        amount := recipe.amountOf(problem.nutrientNameToId, target.nutrient)
+        fmt.Fprintf(&out, "%q %.4g %s\n", target.nutrient, amount,
+            targetUnits(problem.nutrients, problem.nutrientNameToId, target.nutrient))
+    }
+    fmt.Fprintf(&out, "mass %dg\n", recipe.Mass())
+    return out.String()
+}
+
+// runSelftest handles "supershake selftest".
+func runSelftest(args []string) {
+    fs := flag.NewFlagSet("supershake selftest", flag.ExitOnError)
+    verbose := fs.Bool("v", false, "print the output of stages that fail")
+    fs.Parse(args)
+
+    dir, err := os.MkdirTemp("", "supershake-selftest")
+    if err != nil {
+        panic(err)
+    }
+    defer os.RemoveAll(dir)
+
+    outputs := make(map[string]string)
+    outputs["fixture"] = selftestFixture(dir)
+
+    // The defaults, but always the built in exclusions and no rejections,
+    // whatever is in the working directory
+    optionsFs := flag.NewFlagSet("selftest", flag.ContinueOnError)
+    opts := newOptions(optionsFs)
+    optionsFs.Parse([]string{"--data-dir", dir, "--rejected", ""})
+    opts.exclusionList, err = parseExclusions(defaultExclusions, "the built in exclusions")
+    if err != nil {
+        panic(err)
+    }
+
+    problem := loadProblem(opts)
+    outputs["load"] = selftestLoad(problem)
+    hill, _ := optimize(problem, NewRecipe(problem.foods, problem.nutrients), false)
+    outputs["hill"] = selftestRecipe(problem, hill)
+    lp, err := solveWithLP(problem)
+    if err != nil {
+        outputs["lp"] = "error: " + err.Error() + "\n"
+    } else {
+        outputs["lp"] = selftestRecipe(problem, lp)
+    }
+    outputs["report"] = selftestReport(problem, hill)
+
+    failed := 0
+    fmt.Println()
+    for _, stage := range selftestStages {
+        got := checksum(outputs[stage])
+        if got == selftestChecksums[stage] {
+            fmt.Printf("  %-8s ok      %s\n", stage, got)
+            continue
+        }
+        failed++
+        fmt.Printf("  %-8s FAILED  %s, want %s\n", stage, got, selftestChecksums[stage])
+        if *verbose {
+            fmt.Print(outputs[stage])
+        }
+    }
+    if failed > 0 {
+        fmt.Printf("%d of %d stages differ from a known good build\n", failed, len(selftestStages))
+        os.Exit(1)
+    }
+    fmt.Println("All stages match a known good build")
+}
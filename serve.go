@@ -0,0 +1,364 @@
+package main
+
+import (
+    "bytes"
+    "crypto/rand"
+    "encoding/base64"
+    "encoding/json"
+    "errors"
+    "flag"
+    "fmt"
+    htmltemplate "html/template"
+    "io"
+    "net/http"
+    "os"
+    "strings"
+    "sync"
+    "time"
+)
+
+// "supershake serve" loads the data once and optimizes over HTTP:
+//
+//     POST /optimize     optimize with the server's flags, answering with the
+//                        recipe as JSON, the same as --save-recipe writes
+//     POST /share        save a recipe JSON under a short token
+//     GET  /r/<token>    a read-only HTML page for a shared recipe
+//     GET  /api/...      the foods and nutrients, see api.go
+//     /jobs              optimizing in the background, see jobs.go
+//     GET  /history      every recipe the server has optimized, oldest first,
+//                        as the --history file has them
+//     /profiles          people's profiles, see below
+//     /graphql           with --graphql, any of the above as GraphQL, see
+//                        graphql_schema.go
+//
+// Shared recipes, jobs, history and profiles are kept in --store (see
+// storage.go), so they outlive the server, encrypted when there's a
+// passphrase (see store.go). By default that's files in --share-dir.
+//
+// Profiles are kept for a front end to offer each person their own flags,
+// in the form of a profiles.yaml entry as JSON:
+//
+//     GET  /profiles          the names of the profiles kept
+//     GET  /profiles/<name>   a profile, {"sex": ["female"], "age": ["34"]}
+//     PUT  /profiles/<name>   keep a profile, checking its flags are ones
+//                             supershake has
+
+type server struct {
+    problem *Problem
+    store storage
+    optimizing sync.Mutex // one optimization at a time, they use every core they can
+    webhookSecret string
+    running map[string]bool // jobs this server is running, the rest of those stored as running were cut short
+    jobsLock sync.Mutex
+}
+
+// runServe handles "supershake serve".
+func runServe(args []string) {
+    fs := flag.NewFlagSet("supershake serve", flag.ExitOnError)
+    opts := newOptions(fs)
+    addr := fs.String("addr", "localhost:8080", "address to listen on")
+    shareDir := fs.String("share-dir", "shares", "directory shared recipes are kept in")
+    storeSpec := fs.String("store", "", "where to keep shared recipes, jobs, history and profiles, as dir:path (default dir: and --share-dir)")
+    webhookSecret := fs.String("webhook-secret", os.Getenv("SUPERSHAKE_WEBHOOK_SECRET"), "key job callbacks are signed with (default $SUPERSHAKE_WEBHOOK_SECRET)")
+    graphql := fs.Bool("graphql", false, "also serve a GraphQL endpoint at /graphql")
+    fs.Parse(args)
+
+    if *storeSpec == "" {
+        *storeSpec = "dir:" + *shareDir
+    }
+    store, err := openStorage(*storeSpec, opts.passphrase())
+    if err != nil {
+        fmt.Fprintln(os.Stderr, "--store:", err)
+        os.Exit(2)
+    }
+    defer store.close()
+    fmt.Println(tr("Loading"))
+    s := &server{problem: loadProblem(opts), store: store, webhookSecret: *webhookSecret, running: make(map[string]bool)}
+
+    mux := http.NewServeMux()
+    mux.HandleFunc("/optimize", s.handleOptimize)
+    mux.HandleFunc("/share", s.handleShare)
+    mux.HandleFunc("/r/", s.handleShared)
+    mux.HandleFunc("/jobs", s.handleJobs)
+    mux.HandleFunc("/jobs/", s.handleJob)
+    mux.HandleFunc("/history", s.handleHistory)
+    mux.HandleFunc("/profiles", s.handleProfiles)
+    mux.HandleFunc("/profiles/", s.handleProfile)
+    mux.HandleFunc("/api/foods", s.handleFoods)
+    mux.HandleFunc("/api/foods/", s.handleFood)
+    mux.HandleFunc("/api/nutrients", s.handleNutrients)
+    mux.HandleFunc("/api/curve", s.handleCurve)
+    if *graphql {
+        mux.HandleFunc("/graphql", s.handleGraphQL)
+    }
+    fmt.Println("Listening on", *addr)
+    if err := http.ListenAndServe(*addr, mux); err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(1)
+    }
+}
+
+func (s *server) handleOptimize(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "POST to optimize", http.StatusMethodNotAllowed)
+        return
+    }
+    recipe, err := s.optimize()
+    if _, infeasible := err.(*infeasibleError); infeasible {
+        http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+        return
+    }
+    data, err := recipeJSON(s.problem, recipe)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    w.Write(data)
+}
+
+// optimize runs the optimizer with the server's flags, recording the
+// recipe in the history, or fails with an infeasibleError.
+func (s *server) optimize() (*Recipe, error) {
+    s.optimizing.Lock()
+    recipe, score := optimize(s.problem, NewRecipe(s.problem.foods, s.problem.nutrients), false)
+    s.optimizing.Unlock()
+    if err := recipe.feasible(s.problem); err != nil {
+        return nil, err
+    }
+
+    file := newRecipeFile(s.problem, recipe)
+    file.Id = newShareToken()
+    file.DerivedBy = "optimized"
+    created := time.Now()
+    file.Created = created.Format(time.RFC3339)
+    data, err := json.Marshal(historyEntry{recipeFile: file, Score: score})
+    if err != nil { panic(err) }
+    // Keyed by time first, so they list oldest first
+    key := created.UTC().Format("20060102T150405.000000000Z") + "-" + file.Id
+    if err := s.store.put(storeHistory, key, data); err != nil {
+        fmt.Printf("Couldn't record recipe %s in the history: %v\n", file.Id, err)
+    }
+    return recipe, nil
+}
+
+func (s *server) handleHistory(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        http.Error(w, "GET only", http.StatusMethodNotAllowed)
+        return
+    }
+    keys, err := s.store.keys(storeHistory)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    entries := []json.RawMessage{}
+    for _, key := range keys {
+        data, err := s.store.get(storeHistory, key)
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusInternalServerError)
+            return
+        }
+        entries = append(entries, json.RawMessage(data))
+    }
+    writePrivateJSON(w, entries)
+}
+
+// Shared recipes are small, anything bigger isn't one
+const maxShareBytes = 1 << 16
+
+func (s *server) handleShare(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "POST a recipe to share it", http.StatusMethodNotAllowed)
+        return
+    }
+    data, err := io.ReadAll(io.LimitReader(r.Body, maxShareBytes))
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    quantities, err := parseRecipeFile(data, "recipe")
+    if err == nil {
+        _, err = s.recipeFrom(quantities)
+    }
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    token := newShareToken()
+    if err := s.store.put(storeRecipes, token, data); err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]string{"token": token, "url": "/r/" + token})
+}
+
+// newShareToken is 8 URL safe characters, hard enough to guess that only
+// people given the link find the recipe.
+func newShareToken() string {
+    random := make([]byte, 6)
+    if _, err := rand.Read(random); err != nil {
+        panic(err)
+    }
+    return base64.RawURLEncoding.EncodeToString(random)
+}
+
+// recipeFrom builds a recipe out of food id -> grams, failing if a food
+// isn't one the server knows.
+func (s *server) recipeFrom(quantities map[int]int) (*Recipe, error) {
+    recipe := NewRecipe(s.problem.foods, s.problem.nutrients)
+    for foodId, grams := range quantities {
+        food, exists := s.problem.foods[foodId]
+        if !exists {
+            return nil, fmt.Errorf("no food %d", foodId)
+        }
+        recipe.AddFood(s.problem.foods, &food, grams)
+    }
+    return recipe, nil
+}
+
+type sharedRecipePage struct {
+    Score string
+    Foods []string
+    Rows []weeklyReportRow
+}
+
+const sharedRecipeHTML = `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>A supershake recipe</title></head>
+<body>
+<h1>A supershake recipe</h1>
+<p>Score {{.Score}}, lower is better.</p>
+<ul>{{range .Foods}}
+<li>{{.}}</li>{{end}}
+</ul>
+<h2>Nutrients</h2>
+<table>
+<tr><th></th><th>Nutrient</th><th>Amount</th><th>Target</th></tr>{{range .Rows}}
+<tr><td>{{.Status}}</td><td>{{.Nutrient}}</td><td>{{.Amount}}</td><td>{{.Want}}</td></tr>{{end}}
+</table>
+</body></html>
+`
+
+// errNoSharedRecipe is returned for tokens nothing was shared under.
+var errNoSharedRecipe = errors.New("no recipe is shared under that token")
+
+// sharedRecipe reads the recipe shared under token.
+func (s *server) sharedRecipe(token string) (*Recipe, error) {
+    data, err := s.store.get(storeRecipes, token)
+    if err == errNotStored {
+        return nil, errNoSharedRecipe
+    } else if err != nil {
+        return nil, err
+    }
+    quantities, err := parseRecipeFile(data, token)
+    if err != nil {
+        return nil, err
+    }
+    return s.recipeFrom(quantities)
+}
+
+func (s *server) handleShared(w http.ResponseWriter, r *http.Request) {
+    recipe, err := s.sharedRecipe(strings.TrimPrefix(r.URL.Path, "/r/"))
+    if err == errNoSharedRecipe {
+        http.NotFound(w, r)
+        return
+    } else if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+
+    page := sharedRecipePage{Score: fmt.Sprintf("%.2f", recipe.Score(s.problem, false))}
+    for _, foodId := range recipe.FoodIdsByGrams() {
+        food := s.problem.foods[foodId]
+        page.Foods = append(page.Foods, food.gramsString(recipe.foodQuantities[foodId]) + " of " + food.description)
+    }
+    for _, target := range s.problem.targets {
+        amount := recipe.amountOf(s.problem.nutrientNameToId, target.nutrient)
+        status := "ok"
+        if !target.isMet(amount) {
+            status = "HIGH"
+            if amount < target.min {
+                status = "LOW"
+            }
+        }
+        page.Rows = append(page.Rows, weeklyReportRow{status, target.nutrient, fmt.Sprintf("%.2f", amount), target.rangeString()})
+    }
+
+    var out bytes.Buffer
+    tmpl := htmltemplate.Must(htmltemplate.New("shared").Parse(sharedRecipeHTML))
+    if err := tmpl.Execute(&out, page); err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("Content-Type", "text/html; charset=utf-8")
+    w.Write(out.Bytes())
+}
+
+func (s *server) handleProfiles(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        http.Error(w, "GET only", http.StatusMethodNotAllowed)
+        return
+    }
+    names, err := s.store.keys(storeProfiles)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    if names == nil {
+        names = []string{}
+    }
+    writePrivateJSON(w, names)
+}
+
+func (s *server) handleProfile(w http.ResponseWriter, r *http.Request) {
+    name := strings.TrimPrefix(r.URL.Path, "/profiles/")
+    switch r.Method {
+    case http.MethodGet:
+        data, err := s.store.get(storeProfiles, name)
+        if err == errNotStored {
+            http.NotFound(w, r)
+            return
+        } else if err != nil {
+            http.Error(w, err.Error(), http.StatusInternalServerError)
+            return
+        }
+        writePrivateJSON(w, json.RawMessage(data))
+    case http.MethodPut:
+        if !validStoreKey(name) {
+            http.Error(w, "profile names are letters, digits, -, _ and dots", http.StatusBadRequest)
+            return
+        }
+        var flags map[string][]string
+        data, err := io.ReadAll(io.LimitReader(r.Body, maxShareBytes))
+        if err == nil {
+            err = json.Unmarshal(data, &flags)
+        }
+        if err == nil {
+            err = checkProfileFlags(profile{name, flags})
+        }
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        if data, err = json.Marshal(flags); err == nil {
+            err = s.store.put(storeProfiles, name, data)
+        }
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusInternalServerError)
+            return
+        }
+        w.WriteHeader(http.StatusNoContent)
+    default:
+        http.Error(w, "GET or PUT", http.StatusMethodNotAllowed)
+    }
+}
+
+// checkProfileFlags checks p's flags are ones supershake has, with values
+// they take.
+func checkProfileFlags(p profile) error {
+    fs := flag.NewFlagSet("profile", flag.ContinueOnError)
+    newOptions(fs)
+    return p.apply(fs)
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "math"
+)
+
+// A dense two-phase simplex, enough to solve linearProgram without an
+// external solver. The problems are a few hundred rows at most by one column
+// per food, which a dense tableau handles fine.
+
+const simplexEpsilon = 1e-9
+
+var (
+    errInfeasible = errors.New("no recipe meets every constraint")
+    errUnbounded = errors.New("the objective can grow without limit, add a cap like --max-mass-g")
+)
+
+type simplexTableau struct {
+    rows [][]float64 // constraint rows, with the right hand side last
+    objective []float64 // reduced costs, with minus the objective value last
+    basis []int // the basic column of each row
+}
+
+func (t *simplexTableau) pivot(row int, column int) {
+    pivotRow := t.rows[row]
+    scale := pivotRow[column]
+    for j := range pivotRow {
+        pivotRow[j] /= scale
+    }
+    eliminate := func(r []float64) {
+        factor := r[column]
+        if factor == 0 {
+            return
+        }
+        for j := range r {
+            r[j] -= factor * pivotRow[j]
+        }
+    }
+    for i, r := range t.rows {
+        if i != row {
+            eliminate(r)
+        }
+    }
+    eliminate(t.objective)
+    t.basis[row] = column
+}
+
+// minimize pivots until no allowed column improves the objective. Columns
+// enter by most negative reduced cost, switching to Bland's rule, which
+// can't cycle, once progress stalls.
+func (t *simplexTableau) minimize(allowed int) error {
+    rhs := len(t.objective) - 1
+    stalled := 0
+    for iteration := 0; ; iteration++ {
+        if iteration > 50000 {
+            return errors.New("simplex did not converge")
+        }
+        column := -1
+        for j := 0; j < allowed; j++ {
+            if t.objective[j] >= -simplexEpsilon {
+                continue
+            }
+            if column < 0 || (stalled < 50 && t.objective[j] < t.objective[column]) {
+                column = j
+                if stalled >= 50 {
+                    break
+                }
+            }
+        }
+        if column < 0 {
+            return nil
+        }
+
+        row := -1
+        bestRatio := math.Inf(1)
+        for i, r := range t.rows {
+            if r[column] <= simplexEpsilon {
+                continue
+            }
+            ratio := r[rhs] / r[column]
+            if ratio < bestRatio - simplexEpsilon || (ratio < bestRatio + simplexEpsilon && row >= 0 && t.basis[i] < t.basis[row]) {
+                row, bestRatio = i, ratio
+            }
+        }
+        if row < 0 {
+            return errUnbounded
+        }
+        if bestRatio < simplexEpsilon {
+            stalled++
+        } else {
+            stalled = 0
+        }
+        t.pivot(row, column)
+    }
+}
+
+// solveLinearProgram returns the optimal value of each variable, and the
+// objective there.
+func solveLinearProgram(lp *linearProgram) ([]float64, float64, error) {
+    numVariables := len(lp.variables)
+    numRows := len(lp.constraints)
+    // Columns: variables, a slack or surplus per row, an artificial per row,
+    // then the right hand side
+    slackStart := numVariables
+    artificialStart := slackStart + numRows
+    rhs := artificialStart + numRows
+
+    t := &simplexTableau{basis: make([]int, numRows)}
+    for i, constraint := range lp.constraints {
+        row := make([]float64, rhs + 1)
+        sign := float64(1)
+        if constraint.rhs < 0 {
+            sign = -1
+        }
+        for _, term := range constraint.terms {
+            row[term.variable] += sign * term.coef
+        }
+        if constraint.sense == 'L' {
+            row[slackStart + i] = sign
+        } else {
+            row[slackStart + i] = -sign
+        }
+        row[artificialStart + i] = 1
+        row[rhs] = sign * constraint.rhs
+        t.rows = append(t.rows, row)
+        t.basis[i] = artificialStart + i
+    }
+
+    // Phase one: minimize the artificials to find a feasible start
+    t.objective = make([]float64, rhs + 1)
+    for _, row := range t.rows {
+        for j := 0; j < artificialStart; j++ {
+            t.objective[j] -= row[j]
+        }
+        t.objective[rhs] -= row[rhs]
+    }
+    if err := t.minimize(artificialStart); err != nil {
+        return nil, 0, err
+    }
+    if -t.objective[rhs] > 1e-6 {
+        return nil, 0, errInfeasible
+    }
+    // Drive any artificials left in the basis, at zero, out of it
+    for i, column := range t.basis {
+        if column < artificialStart {
+            continue
+        }
+        for j := 0; j < artificialStart; j++ {
+            if math.Abs(t.rows[i][j]) > simplexEpsilon {
+                t.pivot(i, j)
+                break
+            }
+        }
+    }
+
+    // Phase two: the real objective, as minimizing
+    t.objective = make([]float64, rhs + 1)
+    for j, coef := range lp.objective {
+        if lp.maximize {
+            coef = -coef
+        }
+        t.objective[j] = coef
+    }
+    for i, column := range t.basis {
+        if factor := t.objective[column]; factor != 0 {
+            for j := range t.objective {
+                t.objective[j] -= factor * t.rows[i][j]
+            }
+        }
+    }
+    if err := t.minimize(artificialStart); err != nil {
+        return nil, 0, err
+    }
+
+    values := make([]float64, numVariables)
+    for i, column := range t.basis {
+        if column < numVariables {
+            values[column] = t.rows[i][rhs]
+        }
+    }
+    value := float64(0)
+    for j, coef := range lp.objective {
+        value += coef * values[j]
+    }
+    return values, value, nil
+}
+
+// solveWithLP finds the recipe by linear programming, rounded to the step
+// size, then hill climbs from there to settle the rounding and the
+// penalties the linear program leaves out.
+func solveWithLP(problem *Problem) (*Recipe, error) {
+    lp := buildLinearProgram(problem)
+    values, value, err := solveLinearProgram(lp)
+    if err != nil {
+        return nil, err
+    }
+    fmt.Printf("LP optimum %g over %d variables and %d constraints\n", value, len(lp.variables), len(lp.constraints))
+
+    // Rounding up could go over a cap the linear program kept to, and the
+    // hill climbing only ever steps back under some of them. With a mass cap
+    // or a hard max every food rounds down instead, which keeps every amount
+    // at or under the linear program's.
+    floorAll := problem.opts.maxMassG > 0
+    for _, target := range problem.targets {
+        floorAll = floorAll || problem.maxIsHard(target)
+    }
+    recipe := NewRecipe(problem.foods, problem.nutrients)
+    for i, id := range lp.foodIds {
+        step := problem.opts.stepSize
+        grams := int(math.Round(values[i] / float64(step))) * step
+        if floorAll || problem.mercury[id] > 0 && problem.opts.maxMercuryUg > 0 || problem.prices[id] > 0 && problem.opts.maxCost > 0 {
+            // Rounding fish up could go over the mercury cap, and anything
+            // priced over the cost cap
+            grams = int(math.Floor(values[i] / float64(step))) * step
+        }
+        if grams > 0 {
+            food := problem.foods[id]
+            recipe.AddFood(problem.foods, &food, grams)
+        }
+    }
+    polished, _ := optimize(problem, recipe, false)
+    return polished, nil
+}
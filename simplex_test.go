@@ -0,0 +1,148 @@
+package main
+
+import (
+    "math"
+    "testing"
+)
+
+// lpTolerance is how far the simplex's answers may be from exact
+const lpTolerance = 1e-6
+
+func TestSolveLinearProgram(t *testing.T) {
+    for _, test := range []struct {
+        name string
+        lp linearProgram
+        values []float64
+        value float64
+        err error
+    }{
+        {"maximize", linearProgram{
+            // The textbook one: max 3x + 5y, x <= 4, 2y <= 12, 3x + 2y <= 18
+            maximize: true,
+            variables: []string{"x", "y"},
+            objective: []float64{3, 5},
+            constraints: []lpConstraint{
+                {"a", []lpTerm{{0, 1}}, 'L', 4},
+                {"b", []lpTerm{{1, 2}}, 'L', 12},
+                {"c", []lpTerm{{0, 3}, {1, 2}}, 'L', 18},
+            },
+        }, []float64{2, 6}, 36, nil},
+        {"minimize with minimums", linearProgram{
+            // A diet problem: two foods costing 2 and 3, needing 8 of one
+            // nutrient and 6 of another
+            variables: []string{"x", "y"},
+            objective: []float64{2, 3},
+            constraints: []lpConstraint{
+                {"n1", []lpTerm{{0, 2}, {1, 1}}, 'G', 8},
+                {"n2", []lpTerm{{0, 1}, {1, 2}}, 'G', 6},
+            },
+        }, []float64{10.0 / 3, 4.0 / 3}, 32.0 / 3, nil},
+        {"negative right hand side", linearProgram{
+            // -x <= -2 is x >= 2
+            variables: []string{"x"},
+            objective: []float64{1},
+            constraints: []lpConstraint{
+                {"a", []lpTerm{{0, -1}}, 'L', -2},
+            },
+        }, []float64{2}, 2, nil},
+        {"equal bounds", linearProgram{
+            variables: []string{"x", "y"},
+            objective: []float64{1, 1},
+            constraints: []lpConstraint{
+                {"min", []lpTerm{{0, 1}, {1, 1}}, 'G', 5},
+                {"max", []lpTerm{{0, 1}, {1, 1}}, 'L', 5},
+                {"x", []lpTerm{{0, 1}}, 'G', 1},
+            },
+        }, nil, 5, nil},
+        {"infeasible", linearProgram{
+            variables: []string{"x"},
+            objective: []float64{1},
+            constraints: []lpConstraint{
+                {"min", []lpTerm{{0, 1}}, 'G', 10},
+                {"max", []lpTerm{{0, 1}}, 'L', 5},
+            },
+        }, nil, 0, errInfeasible},
+        {"unbounded", linearProgram{
+            maximize: true,
+            variables: []string{"x", "y"},
+            objective: []float64{1, 1},
+            constraints: []lpConstraint{
+                {"a", []lpTerm{{0, 1}, {1, -1}}, 'L', 1},
+            },
+        }, nil, 0, errUnbounded},
+    } {
+        t.Run(test.name, func(t *testing.T) {
+            values, value, err := solveLinearProgram(&test.lp)
+            if err != test.err {
+                t.Fatalf("got error %v, want %v", err, test.err)
+            }
+            if err != nil {
+                return
+            }
+            if math.Abs(value - test.value) > lpTolerance {
+                t.Errorf("objective %g, want %g", value, test.value)
+            }
+            for i, want := range test.values {
+                if math.Abs(values[i] - want) > lpTolerance {
+                    t.Errorf("%s = %g, want %g", test.lp.variables[i], values[i], want)
+                }
+            }
+            checkLPSolution(t, &test.lp, values)
+        })
+    }
+}
+
+// checkLPSolution fails t if values break any of lp's constraints.
+func checkLPSolution(t *testing.T, lp *linearProgram, values []float64) {
+    t.Helper()
+    for i, value := range values {
+        if value < -lpTolerance {
+            t.Errorf("%s = %g, below zero", lp.variables[i], value)
+        }
+    }
+    for _, constraint := range lp.constraints {
+        sum := float64(0)
+        for _, term := range constraint.terms {
+            sum += term.coef * values[term.variable]
+        }
+        slack := lpTolerance * math.Max(1, math.Abs(constraint.rhs))
+        if constraint.sense == 'L' && sum > constraint.rhs + slack || constraint.sense == 'G' && sum < constraint.rhs - slack {
+            t.Errorf("%s: %g %c %g doesn't hold", constraint.name, sum, constraint.sense, constraint.rhs)
+        }
+    }
+}
+
+func TestSolveWithLPFixture(t *testing.T) {
+    for _, test := range []struct {
+        name string
+        args []string
+    }{
+        {"targets", []string{"--imputed", "trust"}},
+        {"mass cap", []string{"--imputed", "trust", "--max-mass-g", "900"}},
+        {"maximize protein", []string{"--imputed", "trust", "--maximize", "Protein", "--max-mass-g", "1500"}},
+    } {
+        t.Run(test.name, func(t *testing.T) {
+            problem := fixtureProblem(t, test.args...)
+            lp := buildLinearProgram(problem)
+            values, _, err := solveLinearProgram(lp)
+            if err != nil {
+                t.Fatal(err)
+            }
+            checkLPSolution(t, lp, values)
+
+            recipe, err := solveWithLP(problem)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if err := checkRecipeInvariants(problem, recipe); err != nil {
+                t.Error(err)
+            }
+            // The hill climbing from the rounded LP answer leaves it where
+            // no move helps
+            score := recipe.Score(problem, false)
+            if better, move := bestMove(problem, recipe); better < score {
+                t.Errorf("stopped at %.4f, but %v reaches %.4f", score, move, better)
+            }
+        })
+    }
+}
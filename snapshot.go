@@ -0,0 +1,229 @@
+package main
+
+import (
+    "bufio"
+    "encoding/gob"
+    "fmt"
+    "os"
+    "time"
+)
+
+// A snapshot is the parsed SR26 data written out with encoding/gob, so runs
+// after the first can skip the CSV parsing. It holds every food, before
+// filtering, so changing the exclusions doesn't need a rebuild.
+
+// Bump whenever the snapshot types change shape, or the loaders read the
+// same files into them differently, as 8 did decoding Latin-1 descriptions.
+const snapshotVersion = 8
+
+type snapshotSourceFile struct {
+    Name string
+    Size int64
+    ModTime time.Time
+}
+
+type snapshotNutrient struct {
+    Id int
+    Units string
+    Description string
+    DatasetDescription string
+}
+
+type snapshotNutrientInFood struct {
+    NutrientId int
+    AmountPerG float64
+    SourceCode int
+    Imputed bool
+    DataPoints int
+    StdErrorPerG float64
+}
+
+type snapshotMeasure struct {
+    Amount float64
+    Description string
+    Grams float64
+}
+
+type snapshotFood struct {
+    Id int
+    FoodGroup string
+    Description string
+    Manufacturer string
+    Nutrients []snapshotNutrientInFood
+    Measures []snapshotMeasure
+    RefusePct float64
+    RefuseDescription string
+}
+
+type snapshot struct {
+    Version int
+    Sources []snapshotSourceFile
+    Nutrients []snapshotNutrient
+    Foods []snapshotFood
+}
+
+// snapshotSources describes the data files as they are on disk now, so a
+// snapshot built from different files can be spotted.
+func snapshotSources(dataDir string) ([]snapshotSourceFile, error) {
+    var sources []snapshotSourceFile
+    for _, name := range dataFilesIn(dataDir) {
+        found, err := findDataFile(dataDir, name)
+        if err != nil {
+            return nil, err
+        }
+        sources = append(sources, snapshotSourceFile{name, found.size, found.modTime.UTC()})
+    }
+    return sources, nil
+}
+
+func sameSources(a []snapshotSourceFile, b []snapshotSourceFile) bool {
+    if len(a) != len(b) {
+        return false
+    }
+    for i := range a {
+        if a[i].Name != b[i].Name || a[i].Size != b[i].Size || !a[i].ModTime.Equal(b[i].ModTime) {
+            return false
+        }
+    }
+    return true
+}
+
+func writeSnapshot(path string, sources []snapshotSourceFile, nutrients map[int]Nutrient, foods map[int]Food) error {
+    snap := snapshot{Version: snapshotVersion, Sources: sources}
+    for _, nutrient := range nutrients {
+        snap.Nutrients = append(snap.Nutrients, snapshotNutrient{nutrient.id, nutrient.units, nutrient.description, nutrient.datasetDescription})
+    }
+    for _, food := range foods {
+        sf := snapshotFood{Id: food.id, FoodGroup: food.foodGroup, Description: food.description, Manufacturer: food.manufacturer, RefusePct: food.refusePct, RefuseDescription: food.refuseDescription}
+        for _, nutrientInFood := range food.nutrients {
+            sf.Nutrients = append(sf.Nutrients, snapshotNutrientInFood{nutrientInFood.nutrient.id, nutrientInFood.amountPerG, nutrientInFood.sourceCode, nutrientInFood.imputed, nutrientInFood.dataPoints, nutrientInFood.stdErrorPerG})
+        }
+        for _, measure := range food.measures {
+            sf.Measures = append(sf.Measures, snapshotMeasure{measure.amount, measure.description, measure.grams})
+        }
+        snap.Foods = append(snap.Foods, sf)
+    }
+
+    // Write next to the real file and rename, so an interrupted run never
+    // leaves a truncated snapshot behind
+    tmpPath := path + ".tmp"
+    file, err := os.Create(tmpPath)
+    if err != nil {
+        return err
+    }
+    writer := bufio.NewWriter(file)
+    if err := gob.NewEncoder(writer).Encode(&snap); err != nil {
+        file.Close()
+        return err
+    }
+    if err := writer.Flush(); err != nil {
+        file.Close()
+        return err
+    }
+    if err := file.Close(); err != nil {
+        return err
+    }
+    return os.Rename(tmpPath, path)
+}
+
+// readSnapshot loads a snapshot, failing if it was built by a different
+// version or from different data files.
+func readSnapshot(path string, sources []snapshotSourceFile) (map[int]Nutrient, map[string]int, map[int]Food, error) {
+    file, err := os.Open(path)
+    if err != nil {
+        return nil, nil, nil, err
+    }
+    defer file.Close()
+
+    var snap snapshot
+    if err := gob.NewDecoder(bufio.NewReader(file)).Decode(&snap); err != nil {
+        return nil, nil, nil, err
+    }
+    if snap.Version != snapshotVersion {
+        return nil, nil, nil, fmt.Errorf("snapshot is version %d, want %d", snap.Version, snapshotVersion)
+    }
+    if !sameSources(snap.Sources, sources) {
+        return nil, nil, nil, fmt.Errorf("data files have changed since the snapshot was made")
+    }
+
+    nutrients := make(map[int]Nutrient, len(snap.Nutrients))
+    nutrientNameToId := make(map[string]int, len(snap.Nutrients))
+    for _, sn := range snap.Nutrients {
+        nutrients[sn.Id] = Nutrient{sn.Id, sn.Units, sn.Description, sn.DatasetDescription}
+        nutrientNameToId[sn.Description] = sn.Id
+    }
+
+    foods := make(map[int]Food, len(snap.Foods))
+    for _, sf := range snap.Foods {
+        food := Food{id: sf.Id, foodGroup: sf.FoodGroup, description: sf.Description, manufacturer: sf.Manufacturer, refusePct: sf.RefusePct, refuseDescription: sf.RefuseDescription}
+        food.nutrients = make([]NutrientInFood, 0, len(sf.Nutrients))
+        for _, sn := range sf.Nutrients {
+            food.nutrients = append(food.nutrients, NutrientInFood{nutrients[sn.NutrientId], sn.AmountPerG, sn.SourceCode, sn.Imputed, sn.DataPoints, sn.StdErrorPerG})
+        }
+        for _, sm := range sf.Measures {
+            food.measures = append(food.measures, Measure{sm.Amount, sm.Description, sm.Grams})
+        }
+        foods[sf.Id] = food
+    }
+    return nutrients, nutrientNameToId, foods, nil
+}
+
+// snapshotSource is the SR26 data, read through a snapshot when there is an
+// up to date one and written to it when there isn't.
+type snapshotSource struct {
+    dataDir string
+    path string
+    rebuild bool
+    foods map[int]Food // loaded along with the nutrients
+}
+
+func (source *snapshotSource) Name() string {
+    return "SR26 via " + source.path
+}
+
+func (source *snapshotSource) LoadNutrients() (map[int]Nutrient, error) {
+    sr26 := newSRSource(source.dataDir, nil)
+    sources, err := snapshotSources(source.dataDir)
+    if err != nil {
+        // Let the normal loader explain the missing files
+        nutrients, err := sr26.LoadNutrients()
+        if err == nil {
+            source.foods, err = sr26.LoadFoods(nutrients)
+        }
+        return nutrients, err
+    }
+
+    if !source.rebuild {
+        nutrients, _, foods, err := readSnapshot(source.path, sources)
+        if err == nil {
+            source.foods = foods
+            return nutrients, nil
+        }
+        if !os.IsNotExist(err) {
+            fmt.Printf("Rebuilding snapshot %s: %v\n", source.path, err)
+        }
+    }
+
+    nutrients, err := sr26.LoadNutrients()
+    if err != nil {
+        return nil, err
+    }
+    if source.foods, err = sr26.LoadFoods(nutrients); err != nil {
+        return nil, err
+    }
+    if err := writeSnapshot(source.path, sources, nutrients, source.foods); err != nil {
+        fmt.Printf("Could not write snapshot %s: %v\n", source.path, err)
+    }
+    return nutrients, nil
+}
+
+// LoadFoods hands back what LoadNutrients read, which already points at the
+// same nutrient definitions.
+func (source *snapshotSource) LoadFoods(nutrients map[int]Nutrient) (map[int]Food, error) {
+    if source.foods == nil {
+        if _, err := source.LoadNutrients(); err != nil {
+            return nil, err
+        }
+    }
+    return source.foods, nil
+}
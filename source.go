@@ -0,0 +1,217 @@
+package main
+
+import (
+    "fmt"
+    "os"
+    "sort"
+    "strings"
+)
+
+// FoodSource is a database of foods. Sources share one set of nutrient
+// definitions, keyed by SR26 nutrient id, so foods from different sources can
+// go in the same recipe.
+//
+// A file that can't be read at all, a CSV without the columns it needs or
+// a YAML file that doesn't parse, is an error with the file and line where
+// there is one, so that a bad file of someone's own is reported rather than
+// crashing the CLI or the server. Single bad rows of the big datasets are
+// skipped and reported instead, see usda_reader.go.
+type FoodSource interface {
+    Name() string
+    // LoadNutrients returns the nutrients the source defines
+    LoadNutrients() (map[int]Nutrient, error)
+    // LoadFoods returns the source's foods, with their nutrients linked to
+    // the definitions in nutrients
+    LoadFoods(nutrients map[int]Nutrient) (map[int]Food, error)
+}
+
+// sr26Source reads the extracted USDA SR26 files in dataDir. If keep is
+// set, foods it rejects are dropped while reading.
+type sr26Source struct {
+    dataDir string
+    keep func(*Food) bool
+}
+
+func (source sr26Source) Name() string {
+    return "SR26 in " + source.dataDir
+}
+
+func (source sr26Source) LoadNutrients() (map[int]Nutrient, error) {
+    nutrients, _ := getNutrients(source.dataDir)
+    return nutrients, nil
+}
+
+func (source sr26Source) LoadFoods(nutrients map[int]Nutrient) (map[int]Food, error) {
+    return getFoods(source.dataDir, nutrients, source.keep), nil
+}
+
+// combinedSource is several sources used together, highest priority first.
+// The first source to define a nutrient wins, and when the same food turns
+// up in more than one source the higher priority copy is kept. The same food
+// is the same id, or with dedupDescriptions also the same description.
+type combinedSource struct {
+    sources []FoodSource
+    dedupDescriptions bool
+}
+
+func (combined combinedSource) Name() string {
+    names := make([]string, len(combined.sources))
+    for i, source := range combined.sources {
+        names[i] = source.Name()
+    }
+    return strings.Join(names, " + ")
+}
+
+func (combined combinedSource) LoadNutrients() (map[int]Nutrient, error) {
+    nutrients := make(map[int]Nutrient, 150)
+    for _, source := range combined.sources {
+        sourceNutrients, err := source.LoadNutrients()
+        if err != nil {
+            return nil, err
+        }
+        for id, nutrient := range sourceNutrients {
+            if _, exists := nutrients[id]; !exists {
+                nutrients[id] = nutrient
+            }
+        }
+    }
+    return nutrients, nil
+}
+
+func (combined combinedSource) LoadFoods(nutrients map[int]Nutrient) (map[int]Food, error) {
+    foods := make(map[int]Food, 10000)
+    descriptions := make(map[string]bool)
+    for _, source := range combined.sources {
+        sourceFoods, err := source.LoadFoods(nutrients)
+        if err != nil {
+            return nil, err
+        }
+        // In id order, so which duplicate is dropped doesn't vary
+        ids := make([]int, 0, len(sourceFoods))
+        for id := range sourceFoods {
+            ids = append(ids, id)
+        }
+        sort.Ints(ids)
+
+        // Foods from one source only conflict with earlier sources
+        sourceDescriptions := make(map[string]bool)
+        dropped := 0
+        for _, id := range ids {
+            food := sourceFoods[id]
+            description := strings.ToLower(strings.TrimSpace(food.description))
+            if _, exists := foods[id]; exists {
+                dropped++
+                continue
+            }
+            if combined.dedupDescriptions && descriptions[description] {
+                dropped++
+                continue
+            }
+            foods[id] = food
+            sourceDescriptions[description] = true
+        }
+        for description := range sourceDescriptions {
+            descriptions[description] = true
+        }
+        if dropped > 0 {
+            fmt.Printf("Skipped %d foods from %s already loaded from a higher priority source\n", dropped, source.Name())
+        }
+    }
+    return foods, nil
+}
+
+func nutrientNameIndex(nutrients map[int]Nutrient) map[string]int {
+    nutrientNameToId := make(map[string]int, len(nutrients))
+    for id, nutrient := range nutrients {
+        nutrientNameToId[nutrient.description] = id
+    }
+    return nutrientNameToId
+}
+
+// Names for the kinds of source in --source-priority
+var sourceKinds = []string{"custom", "table", "data", "extra", "branded", "off"}
+
+// newFoodSource is the source the options ask for: SR26, any extra datasets
+// in the same format (e.g. SR Legacy), the branded foods database, an Open
+// Food Facts dump, any --table files and the custom foods file, in --source-priority order,
+// with any --anti-nutrients added.
+func newFoodSource(opts *Options) FoodSource {
+    return newFilteredFoodSource(opts, nil)
+}
+
+// newFilteredFoodSource is newFoodSource, but with the SR format datasets
+// only keeping the foods keep accepts. The snapshot holds every food, so it
+// isn't used when filtering.
+func newFilteredFoodSource(opts *Options, keep func(*Food) bool) FoodSource {
+    var data FoodSource
+    switch opts.source {
+    case sourceSR26:
+        data = newSRSource(opts.dataDir, keep)
+        if opts.snapshotPath != "" && keep == nil {
+            data = &snapshotSource{dataDir: opts.dataDir, path: opts.snapshotPath, rebuild: opts.rebuildCache}
+        }
+    case sourceCNF:
+        // The snapshot only knows the SR formats
+        data = cnfSource{opts.dataDir, keep}
+    default:
+        fmt.Fprintf(os.Stderr, "Unknown --source %q, want %s or %s\n", opts.source, sourceSR26, sourceCNF)
+        os.Exit(2)
+    }
+
+    combined := combinedSource{dedupDescriptions: opts.dedupDescriptions}
+    for _, kind := range strings.Split(opts.sourcePriority, ",") {
+        switch strings.TrimSpace(kind) {
+        case "custom":
+            combined.sources = append(combined.sources, customFoodsSource{opts.customFoodsPath})
+        case "table":
+            for _, path := range opts.tableMappings {
+                combined.sources = append(combined.sources, tableSource{path})
+            }
+        case "data":
+            combined.sources = append(combined.sources, data)
+        case "extra":
+            for _, dir := range opts.extraDataDirs {
+                combined.sources = append(combined.sources, newSRSource(dir, keep))
+            }
+        case "branded":
+            if opts.brandedDir != "" {
+                combined.sources = append(combined.sources, brandedSource{opts.brandedDir})
+            }
+        case "off":
+            if opts.offDumpPath != "" {
+                combined.sources = append(combined.sources, openFoodFactsSource{opts.offDumpPath})
+            }
+        default:
+            fmt.Fprintf(os.Stderr, "Unknown source %q in --source-priority, want some of %s\n", kind, strings.Join(sourceKinds, ","))
+            os.Exit(2)
+        }
+    }
+    if opts.antiNutrientsPath != "" {
+        return antiNutrientSource{combined, opts.antiNutrientsPath}
+    }
+    return combined
+}
+
+// loadSource loads a source's nutrients and foods, exiting if one of its
+// files is bad.
+func loadSource(source FoodSource) (map[int]Nutrient, map[string]int, map[int]Food) {
+    nutrients, err := source.LoadNutrients()
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(2)
+    }
+    nutrientNameToId := nutrientNameIndex(nutrients)
+    foods, err := source.LoadFoods(nutrients)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(2)
+    }
+    fillVitaminARAE(nutrients, nutrientNameToId, foods)
+    return nutrients, nutrientNameToId, foods
+}
+
+// loadNutrientsAndFoods loads everything the options point at, unfiltered.
+func loadNutrientsAndFoods(opts *Options) (map[int]Nutrient, map[string]int, map[int]Food) {
+    ensureDataFiles(opts)
+    return loadSource(newFoodSource(opts))
+}
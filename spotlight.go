@@ -0,0 +1,181 @@
+package main
+
+import (
+    "fmt"
+    "os"
+    "sort"
+    "strings"
+)
+
+// --spotlight looks at the foods left after the exclusions, before any
+// optimizing, for nutrients few of them supply. Those targets will be tight
+// whatever the optimizer does, so for each it says which rule is keeping
+// the other sources out, or how much a supplement would need to give.
+
+// A food is a good source of a nutrient if 100 g has this share of the
+// target's minimum, as with "good source" on a label
+const spotlightSourceShare = 0.1
+
+// Fewer good sources than this and a nutrient is tight
+const spotlightMinSources = 3
+
+// What a recipe may weigh when --max-mass-g isn't set, where the mass
+// penalty tops out
+const spotlightMassG = 3000
+
+// How many foods to name as examples
+const spotlightExamples = 3
+
+// ruleFacets is what leaves out foods that only failed --facet or
+// --without-facet.
+const ruleFacets = "--facet/--without-facet"
+
+type nutrientConcern struct {
+    target Target
+    units string
+    sources []Food // good sources that may be used, best first
+    best float64 // the most a recipe of only the best usable food gets
+    bestFood *Food
+    excluded map[string][]Food // rule -> good sources it leaves out, best first
+}
+
+// findConcerns returns the targets pool has too few good sources for, in
+// target order.
+func findConcerns(opts *Options, targets []Target, nutrients map[int]Nutrient, nutrientNameToId map[string]int, allFoods map[int]Food, pool map[int]Food) []nutrientConcern {
+    massG := float64(opts.maxMassG)
+    if massG <= 0 {
+        massG = spotlightMassG
+    }
+
+    // In id order, so ties come out the same every run
+    ids := make([]int, 0, len(allFoods))
+    for id := range allFoods {
+        ids = append(ids, id)
+    }
+    sort.Ints(ids)
+
+    var concerns []nutrientConcern
+    for _, target := range targets {
+        if target.min <= 0 {
+            continue
+        }
+        concern := nutrientConcern{target: target, units: targetUnits(nutrients, nutrientNameToId, target.nutrient), excluded: make(map[string][]Food)}
+        perGram := make(map[int]float64, len(allFoods))
+        for _, id := range ids {
+            food := allFoods[id]
+            amount := food.perGramOf(nutrientNameToId, target.nutrient)
+            perGram[id] = amount
+            _, usable := pool[id]
+            if usable && amount * massG > concern.best {
+                concern.best = amount * massG
+                concern.bestFood = &food
+            }
+            if amount * 100 < target.min * spotlightSourceShare {
+                continue
+            }
+            if usable {
+                concern.sources = append(concern.sources, food)
+                continue
+            }
+            rule := exclusionRule(opts, &food)
+            if rule == "" {
+                rule = ruleFacets
+            }
+            concern.excluded[rule] = append(concern.excluded[rule], food)
+        }
+        if len(concern.sources) >= spotlightMinSources && concern.best >= target.min {
+            continue
+        }
+
+        byAmount := func(foods []Food) {
+            sort.SliceStable(foods, func(i, j int) bool {
+                return perGram[foods[i].id] > perGram[foods[j].id]
+            })
+        }
+        byAmount(concern.sources)
+        for _, foods := range concern.excluded {
+            byAmount(foods)
+        }
+        concerns = append(concerns, concern)
+    }
+    return concerns
+}
+
+func foodExamples(foods []Food) string {
+    var names []string
+    for i, food := range foods {
+        if i == spotlightExamples {
+            names = append(names, "...")
+            break
+        }
+        names = append(names, food.description)
+    }
+    return strings.Join(names, "; ")
+}
+
+func printSpotlight(concerns []nutrientConcern, poolSize int) {
+    fmt.Println("NUTRIENTS OF CONCERN")
+    if len(concerns) == 0 {
+        fmt.Printf("None: every target has at least %d good sources among the %d foods allowed.\n", spotlightMinSources, poolSize)
+        return
+    }
+    for _, concern := range concerns {
+        target := concern.target
+        fmt.Printf("%s (want %s %s)\n", target.nutrient, target.rangeString(), concern.units)
+        fmt.Printf("    %d of the %d foods allowed have %.0f%% of it in 100 g", len(concern.sources), poolSize, spotlightSourceShare * 100)
+        if len(concern.sources) > 0 {
+            fmt.Printf(": %s", foodExamples(concern.sources))
+        }
+        fmt.Println()
+        if concern.best < target.min {
+            if concern.bestFood == nil {
+                fmt.Println("    no food allowed has any")
+            } else {
+                fmt.Printf("    even a recipe of only %s gets %.2f\n", concern.bestFood.description, concern.best)
+            }
+        }
+
+        rules := make([]string, 0, len(concern.excluded))
+        for rule := range concern.excluded {
+            rules = append(rules, rule)
+        }
+        sort.Slice(rules, func(i, j int) bool {
+            if len(concern.excluded[rules[i]]) != len(concern.excluded[rules[j]]) {
+                return len(concern.excluded[rules[i]]) > len(concern.excluded[rules[j]])
+            }
+            return rules[i] < rules[j]
+        })
+        for _, rule := range rules {
+            foods := concern.excluded[rule]
+            fmt.Printf("    relaxing %s would allow %d more: %s\n", rule, len(foods), foodExamples(foods))
+        }
+
+        if concern.best < target.min {
+            fmt.Printf("    or a supplement of at least %.2f %s would close the gap\n", target.min - concern.best, concern.units)
+        } else {
+            fmt.Printf("    or a supplement of %g %s would cover it\n", target.min, concern.units)
+        }
+    }
+}
+
+// runSpotlight loads everything, applies the exclusions and prints the
+// nutrients of concern.
+func runSpotlight(opts *Options) {
+    if err := checkBlender(opts.blender); err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(2)
+    }
+    if err := checkDiets(opts); err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(2)
+    }
+    fmt.Println(tr("Loading"))
+    nutrients, nutrientNameToId, allFoods := loadNutrientsAndFoods(opts)
+    pool := filterFoods(opts, allFoods)
+    targets, _, err := effectiveTargets(opts, nutrients, nutrientNameToId)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(2)
+    }
+    printSpotlight(findConcerns(opts, targets, nutrients, nutrientNameToId, allFoods, pool), len(pool))
+}
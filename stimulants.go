@@ -0,0 +1,203 @@
+package main
+
+import (
+    "fmt"
+    "os"
+    "strconv"
+    "strings"
+)
+
+// Stimulants, caffeine and the like, are penalized on a scale of their own
+// rather than as targets: nothing up to a threshold, then a penalty per mg.
+// They're in the stimulants: list in targets.yaml, where each has:
+//
+//     nutrient  what's limited
+//     over      no penalty up to this much
+//     from      once over, the penalty counts from here rather than from
+//               over, so crossing over costs (over - from) * per at once
+//     per       penalty per unit past from
+//
+// The built in caffeine limit, 1 per mg over 5 once over 20 mg, all but
+// rules out coffee. A --targets file's stimulants: list changes it a field
+// at a time, and --stimulant "Caffeine=200mg:0.2" sets the threshold and
+// the penalty per mg past it, with no jump, so a cup a day can be allowed
+// for. Theobromine, from cocoa, isn't limited unless it's added.
+
+type stimulantLimit struct {
+    nutrient string
+    over float64
+    from float64
+    per float64
+    notes string
+    units string // what over and from are in, "" for the nutrient's units in the data
+}
+
+// parseStimulants reads the stimulants: list in data, applying each entry
+// to limits a field at a time. Without nutrientNameToId the nutrients
+// aren't checked and amounts are kept in their own units, as parseTargets
+// keeps them.
+func parseStimulants(limits []stimulantLimit, data string, source string, nutrients map[int]Nutrient, nutrientNameToId map[string]int, weightKg float64) ([]stimulantLimit, error) {
+    document, err := parseYAML(data)
+    if err != nil {
+        return nil, fmt.Errorf("%s: %v", source, err)
+    }
+    root, _ := document.(map[string]interface{})
+    entries, _ := root["stimulants"].([]interface{})
+    for i, entry := range entries {
+        fields, _ := entry.(map[string]interface{})
+        nutrient, _ := fields["nutrient"].(string)
+        if nutrient == "" || (nutrientNameToId != nil && !isTargetNutrient(nutrientNameToId, nutrient)) {
+            return nil, fmt.Errorf("%s: stimulant %d: no nutrient %q", source, i + 1, nutrient)
+        }
+        declared, _ := fields["units"].(string)
+        declared = canonicalUnit(declared)
+        units := ""
+        if nutrientNameToId != nil {
+            units = targetUnits(nutrients, nutrientNameToId, nutrient)
+        } else {
+            for _, key := range []string{"from", "over"} {
+                text, _ := fields[key].(string)
+                if _, unit, err := parseAmount(withUnits(text, declared)); err == nil && unit != "" {
+                    units = unit
+                }
+            }
+        }
+        limit := stimulantLimit{nutrient: nutrient, per: 1}
+        index := len(limits)
+        for j := range limits {
+            if limits[j].nutrient == nutrient {
+                limit, index = limits[j], j
+            }
+        }
+        if nutrientNameToId == nil {
+            limit.units = units
+        }
+        _, givesFrom := fields["from"]
+        for key, value := range fields {
+            text, _ := value.(string)
+            switch key {
+            case "nutrient", "units":
+            case "notes":
+                limit.notes = text
+            case "over", "from":
+                amount, err := parseTargetBound(withUnits(text, declared), units, nutrient, weightKg)
+                if err != nil {
+                    return nil, fmt.Errorf("%s: %s %s: %v", source, nutrient, key, err)
+                }
+                if key == "over" {
+                    limit.over = amount
+                    if !givesFrom {
+                        limit.from = amount
+                    }
+                } else {
+                    limit.from = amount
+                }
+            case "per":
+                limit.per, err = strconv.ParseFloat(strings.TrimSpace(text), 64)
+                if err != nil || limit.per < 0 {
+                    return nil, fmt.Errorf("%s: %s: per should be a number, 0 or more, not %q", source, nutrient, text)
+                }
+            default:
+                return nil, fmt.Errorf("%s: %s: unknown field %q", source, nutrient, key)
+            }
+        }
+        if limit.from > limit.over {
+            return nil, fmt.Errorf("%s: %s: from is over over", source, nutrient)
+        }
+        if index == len(limits) {
+            limits = append(limits, limit)
+        } else {
+            limits[index] = limit
+        }
+    }
+    return limits, nil
+}
+
+// findStimulants is targets.yaml's stimulant limits with the --targets
+// file's and the --stimulant flags applied.
+func findStimulants(opts *Options, nutrients map[int]Nutrient, nutrientNameToId map[string]int) ([]stimulantLimit, error) {
+    limits, err := parseStimulants(nil, targetsYAML, "targets.yaml", nil, nil, 0)
+    if err != nil {
+        return nil, err
+    }
+    for i, limit := range limits {
+        units := targetUnits(nutrients, nutrientNameToId, limit.nutrient)
+        if limit.units == "" || units == "" {
+            continue
+        }
+        for _, amount := range []*float64{&limit.over, &limit.from} {
+            if *amount, err = convertNutrientUnits(*amount, limit.units, units, limit.nutrient); err != nil {
+                return nil, fmt.Errorf("targets.yaml: %s is in %s, but the data has it in %s", limit.nutrient, limit.units, units)
+            }
+        }
+        limits[i].over, limits[i].from, limits[i].units = limit.over, limit.from, ""
+    }
+    if opts.targetsPath != "" {
+        data, err := os.ReadFile(opts.targetsPath)
+        if err != nil {
+            return nil, err
+        }
+        if limits, err = parseStimulants(limits, string(data), opts.targetsPath, nutrients, nutrientNameToId, opts.weightKg); err != nil {
+            return nil, err
+        }
+    }
+    for _, spec := range opts.stimulants {
+        equals := strings.LastIndex(spec, "=")
+        if equals < 0 {
+            return nil, fmt.Errorf("--stimulant %q should look like \"Nutrient=over:per\"", spec)
+        }
+        nutrient := strings.TrimSpace(spec[:equals])
+        if !isTargetNutrient(nutrientNameToId, nutrient) {
+            return nil, fmt.Errorf("--stimulant %q: no nutrient %q", spec, nutrient)
+        }
+        limit := stimulantLimit{nutrient: nutrient, per: 1}
+        index := len(limits)
+        for i := range limits {
+            if limits[i].nutrient == nutrient {
+                limit, index = limits[i], i
+            }
+        }
+        over, per, givesPer := strings.Cut(spec[equals + 1:], ":")
+        limit.over, err = parseTargetBound(over, targetUnits(nutrients, nutrientNameToId, nutrient), nutrient, opts.weightKg)
+        if err != nil {
+            return nil, fmt.Errorf("--stimulant %q: %v", spec, err)
+        }
+        limit.from = limit.over
+        if givesPer {
+            if limit.per, err = strconv.ParseFloat(strings.TrimSpace(per), 64); err != nil || limit.per < 0 {
+                return nil, fmt.Errorf("--stimulant %q: bad penalty per unit %q", spec, per)
+            }
+        }
+        limit.notes = ""
+        if index == len(limits) {
+            limits = append(limits, limit)
+        } else {
+            limits[index] = limit
+        }
+    }
+    return limits, nil
+}
+
+func (limit stimulantLimit) describe(units string) string {
+    if limit.per == 0 {
+        return "not penalized"
+    }
+    if limit.from == limit.over {
+        return fmt.Sprintf("%s per %s over %s %s", amountString(limit.per), units, amountString(limit.over), units)
+    }
+    return fmt.Sprintf("%s per %s over %s, once over %s %s", amountString(limit.per), units, amountString(limit.from), amountString(limit.over), units)
+}
+
+// stimulantPenalty is the stimulant limits' part of the score.
+func (recipe *Recipe) stimulantPenalty(problem *Problem, verbose bool) float64 {
+    penalty := float64(0)
+    for _, limit := range problem.stimulants {
+        amount := recipe.amountOf(problem.nutrientNameToId, limit.nutrient)
+        if amount > limit.over && limit.per > 0 {
+            over := (amount - limit.from) * limit.per
+            if verbose { fmt.Printf("Penalty for %s: %f\n", strings.ToLower(limit.nutrient), over) }
+            penalty += over
+        }
+    }
+    return penalty
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+)
+
+// What the server keeps, shared recipes, jobs, the history of what it
+// optimized and people's profiles, goes through a storage, picked with
+// "supershake serve --store". The only one so far is
+//
+//     dir:shares    a file for each under a directory, the default, with
+//                   --share-dir as the directory
+//
+// Each is a JSON document kept under a kind and a key, encrypted when
+// there's a passphrase (see store.go). sqlite: and postgres: storages over
+// database/sql would fit the same interface, but database/sql ships without
+// drivers and supershake builds from the standard library alone, so they
+// wait until there's a module manifest to pin a driver in.
+
+// The kinds of thing kept
+const (
+    storeRecipes = "recipes"
+    storeJobs = "jobs"
+    storeHistory = "history"
+    storeProfiles = "profiles"
+)
+
+// errNotStored is returned for keys nothing is kept under.
+var errNotStored = errors.New("nothing is stored under that key")
+
+type storage interface {
+    // put keeps data under kind and key, replacing what was there
+    put(kind string, key string, data []byte) error
+    // get returns what's kept under kind and key, errNotStored if nothing
+    get(kind string, key string) ([]byte, error)
+    // keys are the keys of everything of a kind, in order
+    keys(kind string) ([]string, error)
+    close() error
+}
+
+// validStoreKey says whether key is fit to be a file name and a URL path
+// element: letters, digits, -, _ and dots, not at the start.
+func validStoreKey(key string) bool {
+    if key == "" || len(key) > 128 || key[0] == '.' {
+        return false
+    }
+    for _, c := range key {
+        if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+            return false
+        }
+    }
+    return true
+}
+
+// openStorage opens the storage spec names, see above. A spec without a
+// scheme is a directory.
+func openStorage(spec string, passphrase string) (storage, error) {
+    scheme, rest, found := strings.Cut(spec, ":")
+    if !found {
+        scheme, rest = "dir", spec
+    }
+    switch scheme {
+    case "dir":
+        return openFileStorage(rest, passphrase)
+    }
+    return nil, fmt.Errorf("unknown storage %q, want dir:path", spec)
+}
+
+// fileStorage keeps each thing in dir/kind/key.json, apart from shared
+// recipes, which are in dir itself as they always were.
+type fileStorage struct {
+    dir string
+    passphrase string
+}
+
+func openFileStorage(dir string, passphrase string) (*fileStorage, error) {
+    if dir == "" {
+        return nil, fmt.Errorf("dir: storage needs a directory")
+    }
+    if err := os.MkdirAll(dir, 0755); err != nil {
+        return nil, err
+    }
+    return &fileStorage{dir, passphrase}, nil
+}
+
+func (store *fileStorage) kindDir(kind string) string {
+    if kind == storeRecipes {
+        return store.dir
+    }
+    return filepath.Join(store.dir, kind)
+}
+
+func (store *fileStorage) put(kind string, key string, data []byte) error {
+    if !validStoreKey(key) {
+        return fmt.Errorf("bad key %q", key)
+    }
+    if err := os.MkdirAll(store.kindDir(kind), 0755); err != nil {
+        return err
+    }
+    return writeStoreFile(filepath.Join(store.kindDir(kind), key + ".json"), store.passphrase, data)
+}
+
+func (store *fileStorage) get(kind string, key string) ([]byte, error) {
+    if !validStoreKey(key) {
+        return nil, errNotStored
+    }
+    data, err := readStoreFile(filepath.Join(store.kindDir(kind), key + ".json"), store.passphrase)
+    if os.IsNotExist(err) {
+        return nil, errNotStored
+    }
+    return data, err
+}
+
+func (store *fileStorage) keys(kind string) ([]string, error) {
+    entries, err := os.ReadDir(store.kindDir(kind))
+    if os.IsNotExist(err) {
+        return nil, nil
+    } else if err != nil {
+        return nil, err
+    }
+    var keys []string
+    for _, entry := range entries {
+        key := strings.TrimSuffix(entry.Name(), ".json")
+        if !entry.IsDir() && key != entry.Name() && validStoreKey(key) {
+            keys = append(keys, key)
+        }
+    }
+    sort.Strings(keys)
+    return keys, nil
+}
+
+func (store *fileStorage) close() error {
+    return nil
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+    "bufio"
+    "bytes"
+    "crypto/aes"
+    "crypto/cipher"
+    "crypto/pbkdf2"
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/base64"
+    "errors"
+    "flag"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "sync"
+)
+
+// Personal files, the recipe history, the intake log, the profiles and the
+// server's shared recipes, can say a lot about someone's health, so they
+// can be encrypted at rest with a passphrase from $SUPERSHAKE_PASSPHRASE or
+// --passphrase-file. Only the standard library is used: the key is derived
+// with PBKDF2-SHA256 and each record sealed with AES-256-GCM, which does
+// the job NaCl's secretbox would.
+//
+// An encrypted file starts with a header line holding the salt, then has one
+// line per record, each base64 of nonce and ciphertext, so appending to a log
+// doesn't mean rewriting it. A file is encrypted or not from when it's
+// created; "supershake encrypt" and "supershake decrypt" convert old ones.
+//
+// Each record is sealed with the file's name and its place in the file as
+// associated data, so records reordered, dropped from the middle or copied
+// into another file don't decrypt. That means a renamed file doesn't either;
+// decrypt it under its old name and encrypt it under the new one.
+
+const storeHeader = "supershake-encrypted v2 "
+
+// v1 files, from before records were bound to their place, are still read,
+// and appended to as v1
+const storeHeaderV1 = "supershake-encrypted v1 "
+
+// Iterations of PBKDF2, as OWASP recommends for SHA-256
+const storeKeyIterations = 600000
+
+const storeSaltBytes = 16
+
+// Deriving a key takes a moment, so each salt's is kept, which matters for
+// the server reading one shared recipe after another
+var storeKeys = make(map[string]cipher.AEAD)
+var storeKeysLock sync.Mutex
+
+// errNeedPassphrase is returned for encrypted files when there's no
+// passphrase.
+var errNeedPassphrase = errors.New("is encrypted, set $SUPERSHAKE_PASSPHRASE or --passphrase-file")
+
+func storeCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+    storeKeysLock.Lock()
+    defer storeKeysLock.Unlock()
+    cacheKey := passphrase + "\x00" + string(salt)
+    if aead, cached := storeKeys[cacheKey]; cached {
+        return aead, nil
+    }
+    key, err := pbkdf2.Key(sha256.New, passphrase, salt, storeKeyIterations, 32)
+    if err != nil {
+        return nil, err
+    }
+    block, err := aes.NewCipher(key)
+    if err != nil {
+        return nil, err
+    }
+    aead, err := cipher.NewGCM(block)
+    if err != nil {
+        return nil, err
+    }
+    storeKeys[cacheKey] = aead
+    return aead, nil
+}
+
+// storeFileCipher reads the header of an encrypted file, returning nil for a
+// file that isn't encrypted, and whether its records are bound to their
+// place by recordData.
+func storeFileCipher(path string, header string, passphrase string) (cipher.AEAD, bool, error) {
+    prefix := storeHeader
+    if strings.HasPrefix(header, storeHeaderV1) {
+        prefix = storeHeaderV1
+    } else if !strings.HasPrefix(header, storeHeader) {
+        return nil, false, nil
+    }
+    if passphrase == "" {
+        return nil, false, fmt.Errorf("%s %v", path, errNeedPassphrase)
+    }
+    salt, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(header, prefix))
+    if err != nil {
+        return nil, false, fmt.Errorf("%s: bad header: %v", path, err)
+    }
+    aead, err := storeCipher(passphrase, salt)
+    return aead, prefix == storeHeader, err
+}
+
+// recordData is the associated data the index'th record of the file at path
+// is sealed with, nil for a v1 file.
+func recordData(path string, index int, bound bool) []byte {
+    if !bound {
+        return nil
+    }
+    return []byte(filepath.Base(path) + "\x00" + strconv.Itoa(index))
+}
+
+// newStoreHeader makes the header line for a new encrypted file, and its
+// cipher.
+func newStoreHeader(passphrase string) (string, cipher.AEAD, error) {
+    salt := make([]byte, storeSaltBytes)
+    if _, err := rand.Read(salt); err != nil {
+        return "", nil, err
+    }
+    aead, err := storeCipher(passphrase, salt)
+    if err != nil {
+        return "", nil, err
+    }
+    return storeHeader + base64.StdEncoding.EncodeToString(salt) + "\n", aead, nil
+}
+
+func sealRecord(aead cipher.AEAD, record []byte, associated []byte) (string, error) {
+    nonce := make([]byte, aead.NonceSize())
+    if _, err := rand.Read(nonce); err != nil {
+        return "", err
+    }
+    return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, record, associated)) + "\n", nil
+}
+
+// readStoreFile reads a file, decrypting it if it's encrypted. Errors from
+// opening it come back as they are, so os.IsNotExist works.
+func readStoreFile(path string, passphrase string) ([]byte, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    return openStoreData(path, passphrase, data)
+}
+
+// openStoreData decrypts what the encrypted file at path holds, or returns
+// data as is if it isn't encrypted.
+func openStoreData(path string, passphrase string, data []byte) ([]byte, error) {
+    header, rest, _ := strings.Cut(string(data), "\n")
+    aead, bound, err := storeFileCipher(path, header, passphrase)
+    if aead == nil || err != nil {
+        return data, err
+    }
+
+    var plain bytes.Buffer
+    scanner := bufio.NewScanner(strings.NewReader(rest))
+    scanner.Buffer(make([]byte, 64 * 1024), 1 << 24)
+    for line := 2; scanner.Scan(); line++ {
+        sealed, err := base64.StdEncoding.DecodeString(scanner.Text())
+        if err != nil || len(sealed) < aead.NonceSize() {
+            return nil, fmt.Errorf("%s line %d: not an encrypted record", path, line)
+        }
+        record, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], recordData(path, line - 2, bound))
+        if err != nil {
+            return nil, fmt.Errorf("%s line %d: can't decrypt, wrong passphrase, or was the file renamed or a record moved?", path, line)
+        }
+        plain.Write(record)
+    }
+    return plain.Bytes(), scanner.Err()
+}
+
+// writeStoreFile replaces a file with data, encrypted when there's a
+// passphrase.
+func writeStoreFile(path string, passphrase string, data []byte) error {
+    sealed, err := sealStoreData(path, passphrase, data)
+    if err != nil {
+        return err
+    }
+    perm := os.FileMode(0644)
+    if passphrase != "" {
+        perm = 0600
+    }
+    if err := os.WriteFile(path, sealed, perm); err != nil {
+        return err
+    }
+    // WriteFile only sets the permissions of a file it creates, and one
+    // being encrypted in place already exists
+    return os.Chmod(path, perm)
+}
+
+// sealStoreData is what an encrypted file at path holding data would, or
+// data when there's no passphrase.
+func sealStoreData(path string, passphrase string, data []byte) ([]byte, error) {
+    if passphrase == "" {
+        return data, nil
+    }
+    header, aead, err := newStoreHeader(passphrase)
+    if err != nil {
+        return nil, err
+    }
+    record, err := sealRecord(aead, data, recordData(path, 0, true))
+    if err != nil {
+        return nil, err
+    }
+    return []byte(header + record), nil
+}
+
+// appendStoreFile adds data to the end of a file, in the file's own format.
+// A new file is encrypted when there's a passphrase; adding to an
+// unencrypted one with a passphrase is refused rather than mixing the two.
+func appendStoreFile(path string, passphrase string, data []byte) error {
+    existing, err := os.ReadFile(path)
+    if err != nil && !os.IsNotExist(err) {
+        return err
+    }
+    header, rest, _ := strings.Cut(string(existing), "\n")
+
+    var toWrite string
+    switch {
+    case header == "" && passphrase != "":
+        // New or empty, so start it encrypted
+        newHeader, aead, err := newStoreHeader(passphrase)
+        if err != nil {
+            return err
+        }
+        record, err := sealRecord(aead, data, recordData(path, 0, true))
+        if err != nil {
+            return err
+        }
+        toWrite = newHeader + record
+    case strings.HasPrefix(header, storeHeader) || strings.HasPrefix(header, storeHeaderV1):
+        aead, bound, err := storeFileCipher(path, header, passphrase)
+        if err != nil {
+            return err
+        }
+        // Each record is a line
+        index := strings.Count(rest, "\n")
+        if toWrite, err = sealRecord(aead, data, recordData(path, index, bound)); err != nil {
+            return err
+        }
+    case passphrase != "":
+        return fmt.Errorf("%s isn't encrypted, run supershake encrypt %s first", path, path)
+    default:
+        toWrite = string(data)
+    }
+
+    perm := os.FileMode(0644)
+    if passphrase != "" {
+        perm = 0600
+    }
+    f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, perm)
+    if err != nil {
+        return err
+    }
+    if _, err := f.WriteString(toWrite); err != nil {
+        f.Close()
+        return err
+    }
+    return f.Close()
+}
+
+// passphrase is the passphrase personal files are encrypted with, "" for
+// none. A --passphrase-file that can't be read exits.
+func (opts *Options) passphrase() string {
+    if opts.passphraseFile == "" {
+        return os.Getenv("SUPERSHAKE_PASSPHRASE")
+    }
+    data, err := os.ReadFile(opts.passphraseFile)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(2)
+    }
+    return strings.TrimRight(string(data), "\r\n")
+}
+
+// runCrypt handles "supershake encrypt" and "supershake decrypt", which
+// convert files in place.
+func runCrypt(command string, args []string) {
+    fs := flag.NewFlagSet("supershake " + command, flag.ExitOnError)
+    opts := newOptions(fs)
+    fs.Parse(args)
+
+    passphrase := opts.passphrase()
+    if passphrase == "" || fs.NArg() == 0 {
+        fmt.Fprintf(os.Stderr, "Usage: SUPERSHAKE_PASSPHRASE=... supershake %s file...\n", command)
+        os.Exit(2)
+    }
+    for _, path := range fs.Args() {
+        data, err := readStoreFile(path, passphrase)
+        if err == nil {
+            if command == "encrypt" {
+                err = writeStoreFile(path, passphrase, data)
+            } else {
+                err = writeStoreFile(path, "", data)
+            }
+        }
+        if err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            os.Exit(1)
+        }
+        fmt.Printf("%sed %s\n", command, path)
+    }
+}
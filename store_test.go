@@ -0,0 +1,155 @@
+package main
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+const testPassphrase = "correct horse battery staple"
+
+func TestStoreRoundTrip(t *testing.T) {
+    dir := t.TempDir()
+    for _, test := range []struct {
+        name string
+        passphrase string
+        records []string
+    }{
+        {"plain", "", []string{"{\"a\":1}\n", "{\"b\":2}\n"}},
+        {"encrypted", testPassphrase, []string{"{\"a\":1}\n"}},
+        {"encrypted log", testPassphrase, []string{"{\"a\":1}\n", "{\"b\":2}\n", "{\"c\":3}\n"}},
+    } {
+        t.Run(test.name, func(t *testing.T) {
+            written := filepath.Join(dir, strings.ReplaceAll(test.name, " ", "_") + ".json")
+            if err := writeStoreFile(written, test.passphrase, []byte(strings.Join(test.records, ""))); err != nil {
+                t.Fatal(err)
+            }
+            appended := filepath.Join(dir, strings.ReplaceAll(test.name, " ", "_") + ".jsonl")
+            for _, record := range test.records {
+                if err := appendStoreFile(appended, test.passphrase, []byte(record)); err != nil {
+                    t.Fatal(err)
+                }
+            }
+            want := strings.Join(test.records, "")
+            for _, path := range []string{written, appended} {
+                raw, err := os.ReadFile(path)
+                if err != nil {
+                    t.Fatal(err)
+                }
+                if encrypted := strings.HasPrefix(string(raw), storeHeader); encrypted != (test.passphrase != "") {
+                    t.Errorf("%s: encrypted is %v with passphrase %q", path, encrypted, test.passphrase)
+                }
+                got, err := readStoreFile(path, test.passphrase)
+                if err != nil {
+                    t.Fatal(err)
+                }
+                if string(got) != want {
+                    t.Errorf("%s reads back as %q, want %q", path, got, want)
+                }
+            }
+        })
+    }
+}
+
+// tamperedCopy writes the records of the encrypted file at path, changed by
+// change, to a file named name.
+func tamperedCopy(t *testing.T, path string, name string, change func(records []string) []string) string {
+    t.Helper()
+    data, err := os.ReadFile(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    header, rest, _ := strings.Cut(string(data), "\n")
+    records := change(strings.Split(strings.TrimSuffix(rest, "\n"), "\n"))
+    copied := filepath.Join(filepath.Dir(path), name)
+    if err := os.WriteFile(copied, []byte(header + "\n" + strings.Join(records, "\n") + "\n"), 0600); err != nil {
+        t.Fatal(err)
+    }
+    return copied
+}
+
+func TestStoreDetectsMovedRecords(t *testing.T) {
+    dir := t.TempDir()
+    path := filepath.Join(dir, "history.jsonl")
+    for _, record := range []string{"first\n", "second\n", "third\n"} {
+        if err := appendStoreFile(path, testPassphrase, []byte(record)); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    for _, test := range []struct {
+        name string
+        file string
+        change func(records []string) []string
+    }{
+        {"swapped", "history.jsonl", func(records []string) []string {
+            return []string{records[1], records[0], records[2]}
+        }},
+        {"dropped from the middle", "history.jsonl", func(records []string) []string {
+            return []string{records[0], records[2]}
+        }},
+        {"copied to another file", "other.jsonl", func(records []string) []string {
+            return records
+        }},
+    } {
+        t.Run(test.name, func(t *testing.T) {
+            copied := tamperedCopy(t, path, test.file, test.change)
+            if _, err := readStoreFile(copied, testPassphrase); err == nil {
+                t.Error("read without an error")
+            }
+        })
+    }
+
+    if _, err := readStoreFile(path, "wrong"); err == nil {
+        t.Error("read with the wrong passphrase")
+    }
+    if _, err := readStoreFile(path, ""); err == nil {
+        t.Error("read without a passphrase")
+    }
+}
+
+// v1 files, from before records were bound to their file and index, still
+// read and take appends
+func TestStoreReadsV1Files(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "intake.csv")
+    header, aead, err := newStoreHeader(testPassphrase)
+    if err != nil {
+        t.Fatal(err)
+    }
+    header = storeHeaderV1 + strings.TrimPrefix(header, storeHeader)
+    record, err := sealRecord(aead, []byte("old\n"), nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := os.WriteFile(path, []byte(header + record), 0600); err != nil {
+        t.Fatal(err)
+    }
+    if err := appendStoreFile(path, testPassphrase, []byte("new\n")); err != nil {
+        t.Fatal(err)
+    }
+    got, err := readStoreFile(path, testPassphrase)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if string(got) != "old\nnew\n" {
+        t.Errorf("reads back as %q", got)
+    }
+}
+
+func TestStoreTightensPermissions(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "profiles.yaml")
+    if err := os.WriteFile(path, []byte("profiles:\n"), 0644); err != nil {
+        t.Fatal(err)
+    }
+    if err := writeStoreFile(path, testPassphrase, []byte("profiles:\n")); err != nil {
+        t.Fatal(err)
+    }
+    info, err := os.Stat(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if perm := info.Mode().Perm(); perm != 0600 {
+        t.Errorf("encrypted file has permissions %o, want 600", perm)
+    }
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+    "encoding/csv"
+    "fmt"
+    "io"
+    "math"
+    "os"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// --supplement-prices adds a report to the recipe: for each target it still
+// falls short of, what closing the gap costs with food, the cheapest priced
+// food that does it alone, against what it costs in pills, so the gaps
+// cheaper to take as a supplement than to blend stand out. The file has a
+// row per supplement:
+//
+//     nutrient,amount,units,price,description
+//     Vitamin D (D2 + D3),25,µg,0.04,"Vitamin D3 1000 IU softgel"
+//
+// amount is what one pill has and price what one pill costs, in the same
+// currency as --prices.
+
+type supplementPrice struct {
+    nutrient string // a target nutrient's name
+    amount float64 // per pill, in the nutrient's units
+    price float64 // per pill
+    description string
+}
+
+// readSupplementPrices reads the supplement price list, converting amounts
+// to the units nutrients are measured in.
+func readSupplementPrices(path string, nutrients map[int]Nutrient, nutrientNameToId map[string]int) ([]supplementPrice, error) {
+    file, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+
+    reader := csv.NewReader(file)
+    reader.FieldsPerRecord = -1
+    header, err := reader.Read()
+    if err != nil && err != io.EOF {
+        return nil, fmt.Errorf("%s: %v", path, err)
+    }
+    if len(header) < 4 || strings.TrimSpace(header[0]) != "nutrient" {
+        return nil, fmt.Errorf("%s: expected a nutrient,amount,units,price header", path)
+    }
+
+    var supplements []supplementPrice
+    for {
+        record, err := reader.Read()
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            return nil, fmt.Errorf("%s: %v", path, err)
+        }
+        line, _ := reader.FieldPos(0)
+        if len(record) < 4 {
+            return nil, fmt.Errorf("%s line %d: expected nutrient,amount,units,price", path, line)
+        }
+        nutrient := strings.TrimSpace(record[0])
+        _, known := nutrientNameToId[nutrient]
+        if _, derived := derivedNutrients[nutrient]; !known && !derived {
+            return nil, fmt.Errorf("%s line %d: unknown nutrient %q", path, line, nutrient)
+        }
+        amount, err := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+        if err != nil || amount <= 0 {
+            return nil, fmt.Errorf("%s line %d: bad amount %q", path, line, record[1])
+        }
+        amount, err = convertNutrientUnits(amount, strings.TrimSpace(record[2]), targetUnits(nutrients, nutrientNameToId, nutrient), nutrient)
+        if err != nil {
+            return nil, fmt.Errorf("%s line %d: %v", path, line, err)
+        }
+        price, err := strconv.ParseFloat(strings.TrimSpace(record[3]), 64)
+        if err != nil || price < 0 {
+            return nil, fmt.Errorf("%s line %d: bad price %q", path, line, record[3])
+        }
+        description := nutrient
+        if len(record) > 4 && strings.TrimSpace(record[4]) != "" {
+            description = strings.TrimSpace(record[4])
+        }
+        supplements = append(supplements, supplementPrice{nutrient, amount, price, description})
+    }
+    return supplements, nil
+}
+
+// deficiencyCost is what closing one target's gap costs each way. A nil
+// food or supplement means there's no way to close it that way.
+type deficiencyCost struct {
+    target Target
+    gap float64
+    food *Food
+    foodGrams int
+    foodCost float64
+    supplement *supplementPrice
+    pills int
+    supplementCost float64
+    overMax bool // the pills would take the nutrient over its max
+}
+
+// deficiencyCosts works out the cheapest food and the cheapest supplement
+// for each target recipe falls short of.
+func deficiencyCosts(problem *Problem, recipe *Recipe, supplements []supplementPrice) []deficiencyCost {
+    var costs []deficiencyCost
+    for _, target := range problem.targets {
+        amount := recipe.amountOf(problem.nutrientNameToId, target.nutrient)
+        if amount >= target.min {
+            continue
+        }
+        cost := deficiencyCost{target: target, gap: target.min - amount}
+
+        for _, foodId := range sortedFoodIds(problem.foods) {
+            price, priced := problem.prices[foodId]
+            if !priced {
+                continue
+            }
+            food := problem.foods[foodId]
+            perGram := food.perGramOf(problem.nutrientNameToId, target.nutrient)
+            if perGram <= 0 {
+                continue
+            }
+            grams := roundUpToStep(cost.gap / perGram, problem.opts.stepSize)
+            if grams > maxGapGrams {
+                continue
+            }
+            foodCost := price * float64(food.purchasedGrams(grams))
+            if cost.food == nil || foodCost < cost.foodCost {
+                cost.food, cost.foodGrams, cost.foodCost = &food, grams, foodCost
+            }
+        }
+
+        for i := range supplements {
+            supplement := &supplements[i]
+            if !strings.EqualFold(supplement.nutrient, target.nutrient) {
+                continue
+            }
+            pills := int(math.Ceil(cost.gap / supplement.amount))
+            supplementCost := float64(pills) * supplement.price
+            if cost.supplement == nil || supplementCost < cost.supplementCost {
+                cost.supplement, cost.pills, cost.supplementCost = supplement, pills, supplementCost
+                cost.overMax = target.max > 0 && amount + float64(pills) * supplement.amount > target.max
+            }
+        }
+        costs = append(costs, cost)
+    }
+    return costs
+}
+
+// sortedFoodIds is the foods' ids, smallest first, so ties always go the
+// same way.
+func sortedFoodIds(foods map[int]Food) []int {
+    ids := make([]int, 0, len(foods))
+    for id := range foods {
+        ids = append(ids, id)
+    }
+    sort.Ints(ids)
+    return ids
+}
+
+// printDeficiencyCosts prints the food and supplement cost of each gap
+// recipe leaves, and which is cheaper.
+func printDeficiencyCosts(problem *Problem, recipe *Recipe, supplements []supplementPrice) {
+    costs := deficiencyCosts(problem, recipe, supplements)
+    fmt.Println("COST OF DEFICIENCIES (closing each gap with food or a supplement)")
+    if len(costs) == 0 {
+        fmt.Println("The recipe meets every minimum, there are no gaps to close")
+        return
+    }
+    for _, cost := range costs {
+        units := targetUnits(problem.nutrients, problem.nutrientNameToId, cost.target.nutrient)
+        fmt.Printf("%s, %.4g%s short\n", cost.target.nutrient, cost.gap, units)
+        if cost.food != nil {
+            fmt.Printf("  food:       %8.2f  %s of %s\n", cost.foodCost, cost.food.gramsString(cost.foodGrams), cost.food.description)
+        } else {
+            fmt.Printf("  food:       %8s  no priced food closes it in under %dg\n", "-", maxGapGrams)
+        }
+        if cost.supplement != nil {
+            note := ""
+            if cost.overMax {
+                note = ", over the max"
+            }
+            fmt.Printf("  supplement: %8.2f  %d × %s%s\n", cost.supplementCost, cost.pills, cost.supplement.description, note)
+        } else {
+            fmt.Printf("  supplement: %8s  none in the supplement prices\n", "-")
+        }
+
+        switch {
+        case cost.supplement != nil && !cost.overMax && (cost.food == nil || cost.supplementCost < cost.foodCost):
+            fmt.Println("  cheaper as a pill")
+        case cost.food != nil:
+            fmt.Println("  cheaper to blend")
+        }
+    }
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+    _ "embed"
+    "fmt"
+    "math"
+    "os"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// --supplement lets the optimizer close a gap with a pill rather than a lot
+// of food. Supplements come from supplements.yaml, and a --supplements-file
+// in the same format, and go into the recipe as foods, but only in whole
+// servings:
+//
+//     --supplement fish-oil        0 or 1 softgel, the optimizer decides
+//     --supplement vitamin-d=0:2   0 to 2 drops
+//     --supplement multivitamin=1  always 1 tablet
+//
+// A supplement costs a recipe what any food does, one more ingredient, so
+// one only goes in when it does more for the score than that. The step and
+// swap moves leave supplements alone, and supplementMoves adds or takes
+// away a serving. The linear program's grams aren't whole servings, so
+// --supplement needs --solver hill.
+
+//go:embed supplements.yaml
+var supplementsYAML string
+
+// Supplements get ids after the custom foods'
+const firstSupplementId = 990001
+
+type supplementInfo struct {
+    name string
+    food Food
+    price float64 // per serving, 0 if not known
+}
+
+// supplementDose is how many servings of a supplement a recipe may have.
+type supplementDose struct {
+    name string
+    servingGrams int
+    min int
+    max int
+}
+
+// readSupplements reads the supplements in data, converting the amounts to
+// the nutrients' units. Nutrients the data doesn't have are left out of the
+// built in ones, as any dataset may lack some, but are an error in a
+// --supplements-file.
+func readSupplements(data string, source string, builtIn bool, nutrients map[int]Nutrient, nutrientNameToId map[string]int) ([]supplementInfo, error) {
+    document, err := parseYAML(data)
+    if err != nil {
+        return nil, fmt.Errorf("%s: %v", source, err)
+    }
+    root, _ := document.(map[string]interface{})
+    entries, ok := root["supplements"].([]interface{})
+    if !ok {
+        return nil, fmt.Errorf("%s: expected a list under \"supplements:\"", source)
+    }
+    var supplements []supplementInfo
+    for i, entry := range entries {
+        fields, _ := entry.(map[string]interface{})
+        name, _ := fields["name"].(string)
+        description, _ := fields["description"].(string)
+        serving, _ := fields["serving"].(string)
+        amounts, _ := fields["nutrients"].(map[string]interface{})
+        if name == "" || description == "" || len(amounts) == 0 {
+            return nil, fmt.Errorf("%s: supplement %d needs a name, a description and nutrients", source, i + 1)
+        }
+        if serving == "" {
+            serving = "serving"
+        }
+        grams := float64(1)
+        if fields["grams"] != nil {
+            if grams, err = yamlFloat(fields["grams"]); err != nil || grams <= 0 {
+                return nil, fmt.Errorf("%s: %s: bad grams", source, name)
+            }
+        }
+        servingGrams := int(math.Max(math.Round(grams), 1))
+        supplement := supplementInfo{name: name}
+        if fields["price"] != nil {
+            if supplement.price, err = yamlFloat(fields["price"]); err != nil || supplement.price < 0 {
+                return nil, fmt.Errorf("%s: %s: bad price", source, name)
+            }
+        }
+        supplement.food = Food{
+            description: description,
+            measures: []Measure{{1, serving, float64(servingGrams)}},
+        }
+
+        // Sorted so the nutrients come out the same every time
+        var names []string
+        for nutrient := range amounts {
+            names = append(names, nutrient)
+        }
+        sort.Strings(names)
+        for _, nutrient := range names {
+            text, _ := amounts[nutrient].(string)
+            id, exists := nutrientNameToId[nutrient]
+            if !exists {
+                if builtIn {
+                    continue
+                }
+                return nil, fmt.Errorf("%s: %s: no nutrient %q", source, name, nutrient)
+            }
+            amount, unit, err := parseAmount(text)
+            if err == nil {
+                amount, err = convertNutrientUnits(amount, unit, nutrients[id].units, nutrient)
+            }
+            if err != nil {
+                return nil, fmt.Errorf("%s: %s: %s: %v", source, name, nutrient, err)
+            }
+            supplement.food.nutrients = append(supplement.food.nutrients, NutrientInFood{nutrient: nutrients[id], amountPerG: amount / float64(servingGrams), sourceCode: sourceLabel})
+        }
+        supplements = append(supplements, supplement)
+    }
+    return supplements, nil
+}
+
+// loadSupplements reads the built in supplements and the
+// --supplements-file's, which replace built in ones of the same name.
+func loadSupplements(opts *Options, nutrients map[int]Nutrient, nutrientNameToId map[string]int) ([]supplementInfo, error) {
+    supplements, err := readSupplements(supplementsYAML, "supplements.yaml", true, nutrients, nutrientNameToId)
+    if err != nil || opts.supplementsPath == "" {
+        return supplements, err
+    }
+    data, err := os.ReadFile(opts.supplementsPath)
+    if err != nil {
+        return nil, err
+    }
+    extra, err := readSupplements(string(data), opts.supplementsPath, false, nutrients, nutrientNameToId)
+    if err != nil {
+        return nil, err
+    }
+    for _, supplement := range extra {
+        replaced := false
+        for i := range supplements {
+            if supplements[i].name == supplement.name {
+                supplements[i], replaced = supplement, true
+            }
+        }
+        if !replaced {
+            supplements = append(supplements, supplement)
+        }
+    }
+    return supplements, nil
+}
+
+// addSupplements adds the --supplement supplements to foods, and their
+// prices to prices, returning their doses by food id and the grams of the
+// fixed ones the recipe has to have.
+func addSupplements(opts *Options, nutrients map[int]Nutrient, nutrientNameToId map[string]int, foods map[int]Food, prices map[int]float64) (map[int]supplementDose, map[int]int, error) {
+    if len(opts.supplements) == 0 {
+        return nil, nil, nil
+    }
+    if opts.solver == "lp" {
+        return nil, nil, fmt.Errorf("--supplement needs --solver hill, the LP solver doesn't keep to whole servings")
+    }
+    known, err := loadSupplements(opts, nutrients, nutrientNameToId)
+    if err != nil {
+        return nil, nil, err
+    }
+    doses := make(map[int]supplementDose)
+    required := make(map[int]int)
+    for i, spec := range opts.supplements {
+        name, servings := spec, "0:1"
+        if equals := strings.Index(spec, "="); equals >= 0 {
+            name, servings = spec[:equals], spec[equals + 1:]
+        }
+        name = strings.TrimSpace(name)
+        var supplement *supplementInfo
+        for j := range known {
+            if strings.EqualFold(known[j].name, name) {
+                supplement = &known[j]
+            }
+        }
+        if supplement == nil {
+            var names []string
+            for _, other := range known {
+                names = append(names, other.name)
+            }
+            return nil, nil, fmt.Errorf("--supplement: no supplement %q, want one of %s", name, strings.Join(names, ", "))
+        }
+        dose := supplementDose{name: supplement.name, servingGrams: int(supplement.food.measures[0].grams)}
+        bounds := strings.SplitN(servings, ":", 2)
+        if len(bounds) == 1 {
+            bounds = append(bounds, bounds[0])
+        }
+        if dose.min, err = strconv.Atoi(strings.TrimSpace(bounds[0])); err == nil {
+            dose.max, err = strconv.Atoi(strings.TrimSpace(bounds[1]))
+        }
+        if err != nil || dose.min < 0 || dose.max < dose.min || dose.max == 0 {
+            return nil, nil, fmt.Errorf("--supplement %q: want whole servings, like name=1 or name=0:2", spec)
+        }
+
+        food := supplement.food
+        food.id = firstSupplementId + i
+        foods[food.id] = food
+        doses[food.id] = dose
+        if dose.min > 0 {
+            required[food.id] = dose.min * dose.servingGrams
+        }
+        if supplement.price > 0 {
+            prices[food.id] = supplement.price / float64(dose.servingGrams)
+        }
+    }
+    return doses, required, nil
+}
+
+func (problem *Problem) isSupplement(foodId int) bool {
+    _, exists := problem.supplements[foodId]
+    return exists
+}
+
+// withRequired is start with at least the required grams of each food, the
+// fixed supplement doses among them.
+func (problem *Problem) withRequired(start *Recipe) *Recipe {
+    recipe := start
+    for foodId, grams := range problem.required {
+        if have := recipe.foodQuantities[foodId]; have < grams {
+            if recipe == start {
+                recipe = start.Clone(problem.foods, problem.nutrients)
+            }
+            food := problem.foods[foodId]
+            recipe.AddFood(problem.foods, &food, grams - have)
+        }
+    }
+    return recipe
+}
+
+// supplementMoves adds or takes away a serving of a supplement, within its
+// dose.
+type supplementMoves struct{}
+
+func (supplementMoves) Moves(problem *Problem, recipe *Recipe, step int, yield func(Move)) {
+    for foodId, dose := range problem.supplements {
+        servings := recipe.foodQuantities[foodId] / dose.servingGrams
+        if servings > dose.min {
+            yield(Move{{foodId, -dose.servingGrams}})
+        }
+        if servings < dose.max {
+            yield(Move{{foodId, dose.servingGrams}})
+        }
+    }
+}
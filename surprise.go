@@ -0,0 +1,136 @@
+package main
+
+import (
+    "fmt"
+    "math/rand"
+    "sort"
+    "time"
+)
+
+// --surprise N makes a recipe that still meets the targets but shares as few
+// ingredients as it can with the last N recipes saved to the history, for
+// when the same best shake every day gets boring. Sameness is the Jaccard
+// similarity of the ingredient sets, averaged over those recipes, and is
+// scored as a penalty like any other. The search also starts from a few
+// random foods none of them used, so each run goes somewhere new.
+
+// The penalty for a recipe with the same ingredients as all of the recent
+// ones, enough to trade away a little of the targets for variety
+const surpriseWeight = 30
+
+// How many random foods to start from, and how much of each
+const surpriseStartFoods = 3
+const surpriseStartG = 50
+
+// recentIngredients returns the ingredient sets of the last n recipes in the
+// history, newest first.
+func recentIngredients(historyPath string, passphrase string, n int) ([]map[int]bool, error) {
+    entries, err := readHistory(historyPath, passphrase)
+    if err != nil {
+        return nil, err
+    }
+    var recent []map[int]bool
+    for i := len(entries) - 1; i >= 0 && len(recent) < n; i-- {
+        ingredients := make(map[int]bool, len(entries[i].Foods))
+        for _, food := range entries[i].Foods {
+            if food.Grams > 0 {
+                ingredients[food.Id] = true
+            }
+        }
+        recent = append(recent, ingredients)
+    }
+    return recent, nil
+}
+
+// jaccardSimilarity is how many ingredients a and b share out of all either
+// uses, 1 for the same ingredients and 0 for none in common.
+func jaccardSimilarity(a, b map[int]bool) float64 {
+    shared := 0
+    for foodId := range a {
+        if b[foodId] {
+            shared++
+        }
+    }
+    union := len(a) + len(b) - shared
+    if union == 0 {
+        return 0
+    }
+    return float64(shared) / float64(union)
+}
+
+func (recipe *Recipe) ingredients() map[int]bool {
+    ingredients := make(map[int]bool, len(recipe.foodQuantities))
+    for foodId, grams := range recipe.foodQuantities {
+        if grams > 0 {
+            ingredients[foodId] = true
+        }
+    }
+    return ingredients
+}
+
+// samenessPenalty is the penalty for a recipe's mean similarity to the
+// recent recipes, 0 without --surprise.
+func (recipe *Recipe) samenessPenalty(problem *Problem, verbose bool) float64 {
+    if len(problem.recentIngredients) == 0 {
+        return 0
+    }
+    ingredients := recipe.ingredients()
+    total := float64(0)
+    for _, recent := range problem.recentIngredients {
+        total += jaccardSimilarity(ingredients, recent)
+    }
+    penalty := total / float64(len(problem.recentIngredients)) * surpriseWeight
+    if verbose { fmt.Printf("Penalty for sameness: %f\n", penalty) }
+    return penalty
+}
+
+// surpriseStart reads the recent recipes into problem and returns a recipe
+// of a few random foods none of them used.
+func surpriseStart(problem *Problem, seed int64) (*Recipe, error) {
+    opts := problem.opts
+    recent, err := recentIngredients(opts.historyPath, opts.passphrase(), opts.surprise)
+    if err != nil {
+        return nil, err
+    }
+    if len(recent) == 0 {
+        fmt.Printf("No recipes saved in %s yet, so anything is a surprise\n", opts.historyPath)
+    } else {
+        fmt.Printf("Steering away from the last %d recipes in %s\n", len(recent), opts.historyPath)
+    }
+    problem.recentIngredients = recent
+
+    used := make(map[int]bool)
+    for _, ingredients := range recent {
+        for foodId := range ingredients {
+            used[foodId] = true
+        }
+    }
+    var fresh []int
+    for foodId := range problem.foods {
+        if !used[foodId] && !problem.isSupplement(foodId) {
+            fresh = append(fresh, foodId)
+        }
+    }
+    // Sorted first so a seed always picks the same foods
+    sort.Ints(fresh)
+    rng := rand.New(rand.NewSource(seed))
+    rng.Shuffle(len(fresh), func(i, j int) { fresh[i], fresh[j] = fresh[j], fresh[i] })
+
+    start := NewRecipe(problem.foods, problem.nutrients)
+    for i := 0; i < len(fresh) && i < surpriseStartFoods; i++ {
+        if opts.maxMassG > 0 && start.Mass() + surpriseStartG > opts.maxMassG {
+            break
+        }
+        food := problem.foods[fresh[i]]
+        start.AddFood(problem.foods, &food, surpriseStartG)
+    }
+    return start, nil
+}
+
+// surpriseSeed is --surprise-seed, or the time when it's 0.
+func surpriseSeed(opts *Options) int64 {
+    if opts.surpriseSeed != 0 {
+        return opts.surpriseSeed
+    }
+    return time.Now().UnixNano()
+}
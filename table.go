@@ -0,0 +1,360 @@
+package main
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "unicode/utf8"
+)
+
+// --table reads any delimited nutrition file, lab results or a spreadsheet
+// export, as described by a small YAML mapping:
+//
+//   file: lab_results.csv   # relative to the mapping, may be gzipped
+//   delimiter: ";"          # default ",", "\t" for tabs
+//   quote: '"'              # default '"', "" for none
+//   decimal: ","            # default "."
+//   header: true            # whether the first row is column names, default true
+//   skip_rows: 2            # rows before the header to ignore, default 0
+//   per_grams: 100          # the amount of food the values are for, default 100
+//   id: 1                   # the food id column
+//   description: Sample     # the food description column
+//   nutrients:
+//     - column: 3
+//       nutrient: Protein   # a NUTR_DEF.txt description or number
+//       units: g            # the file's units, converted to the nutrient's
+//     - column: Vit C (ug/g)
+//       nutrient: 401
+//       units: µg
+//       scale: 100          # multiplied in after reading, e.g. per g to per 100 g
+//
+// Columns count from 1 as a spreadsheet does, or are named by their header.
+// Empty or non-numeric values (ND, <LOD) are taken as not measured. Foods
+// from tables are the user's own, so like custom foods the exclusion rules
+// meant for SR26 don't apply to them.
+
+type tableMapping struct {
+    path string // of the mapping
+    file string
+    delimiter rune
+    quote rune // 0 for none
+    decimal string
+    header bool
+    skipRows int
+    perGrams float64
+    id string
+    description string
+    nutrients []tableNutrient
+}
+
+type tableNutrient struct {
+    column string
+    nutrient string
+    units string
+    scale float64
+}
+
+type tableSource struct {
+    mappingPath string
+}
+
+func (source tableSource) Name() string {
+    return source.mappingPath
+}
+
+func (source tableSource) LoadNutrients() (map[int]Nutrient, error) {
+    return nil, nil
+}
+
+// readTableMapping reads and checks a mapping file.
+func readTableMapping(path string) (*tableMapping, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    document, err := parseYAML(string(data))
+    if err != nil {
+        return nil, fmt.Errorf("%s: %v", path, err)
+    }
+    fields, ok := document.(map[string]interface{})
+    if !ok {
+        return nil, fmt.Errorf("%s: expected a map", path)
+    }
+
+    mapping := &tableMapping{path: path, delimiter: ',', quote: '"', decimal: ".", header: true, perGrams: 100}
+    for key, value := range fields {
+        text, _ := value.(string)
+        switch key {
+        case "file":
+            mapping.file = text
+        case "delimiter", "quote":
+            if text == "\\t" {
+                text = "\t"
+            }
+            r, size := utf8.DecodeRuneInString(text)
+            if size != len(text) || (key == "delimiter" && text == "") {
+                return nil, fmt.Errorf("%s: %s should be one character, got %q", path, key, text)
+            }
+            if key == "delimiter" {
+                mapping.delimiter = r
+            } else if text == "" {
+                mapping.quote = 0
+            } else {
+                mapping.quote = r
+            }
+        case "decimal":
+            if text != "." && text != "," {
+                return nil, fmt.Errorf("%s: decimal should be \".\" or \",\", got %q", path, text)
+            }
+            mapping.decimal = text
+        case "header":
+            mapping.header, err = strconv.ParseBool(text)
+            if err != nil {
+                return nil, fmt.Errorf("%s: header should be true or false, got %q", path, text)
+            }
+        case "skip_rows":
+            mapping.skipRows, err = strconv.Atoi(text)
+            if err != nil || mapping.skipRows < 0 {
+                return nil, fmt.Errorf("%s: skip_rows should be a number, got %q", path, text)
+            }
+        case "per_grams":
+            mapping.perGrams, err = yamlFloat(value)
+            if err != nil || mapping.perGrams <= 0 {
+                return nil, fmt.Errorf("%s: per_grams should be a positive number, got %v", path, value)
+            }
+        case "id":
+            mapping.id = text
+        case "description":
+            mapping.description = text
+        case "nutrients":
+            entries, ok := value.([]interface{})
+            if !ok {
+                return nil, fmt.Errorf("%s: expected a list under \"nutrients:\"", path)
+            }
+            for i, entry := range entries {
+                nutrient, err := parseTableNutrient(entry)
+                if err != nil {
+                    return nil, fmt.Errorf("%s: nutrient %d: %v", path, i + 1, err)
+                }
+                mapping.nutrients = append(mapping.nutrients, nutrient)
+            }
+        default:
+            return nil, fmt.Errorf("%s: unknown field %q", path, key)
+        }
+    }
+
+    switch {
+    case mapping.file == "":
+        return nil, fmt.Errorf("%s: no file given", path)
+    case mapping.id == "" || mapping.description == "":
+        return nil, fmt.Errorf("%s: give the id and description columns", path)
+    case len(mapping.nutrients) == 0:
+        return nil, fmt.Errorf("%s: no nutrients mapped", path)
+    case mapping.delimiter == mapping.quote:
+        return nil, fmt.Errorf("%s: the delimiter and quote can't be the same", path)
+    }
+    return mapping, nil
+}
+
+func parseTableNutrient(entry interface{}) (tableNutrient, error) {
+    fields, ok := entry.(map[string]interface{})
+    if !ok {
+        return tableNutrient{}, fmt.Errorf("not a map")
+    }
+    nutrient := tableNutrient{scale: 1}
+    for key, value := range fields {
+        text, _ := value.(string)
+        switch key {
+        case "column":
+            nutrient.column = text
+        case "nutrient":
+            nutrient.nutrient = text
+        case "units":
+            nutrient.units = text
+        case "scale":
+            var err error
+            nutrient.scale, err = yamlFloat(value)
+            if err != nil {
+                return nutrient, fmt.Errorf("scale: %v", err)
+            }
+        default:
+            return nutrient, fmt.Errorf("unknown field %q", key)
+        }
+    }
+    if nutrient.column == "" || nutrient.nutrient == "" {
+        return nutrient, fmt.Errorf("give the column and nutrient")
+    }
+    return nutrient, nil
+}
+
+// tableColumn finds a column by 1-based number or header name, returning
+// its 0-based index.
+func tableColumn(header []string, column string) (int, error) {
+    if number, err := strconv.Atoi(column); err == nil {
+        if number < 1 {
+            return 0, fmt.Errorf("column %d, columns count from 1", number)
+        }
+        return number - 1, nil
+    }
+    if index := findColumn(header, []string{column}); index >= 0 {
+        return index, nil
+    }
+    return 0, fmt.Errorf("no column %q", column)
+}
+
+// tableNutrientId finds a nutrient by its number or description.
+func tableNutrientId(nutrients map[int]Nutrient, nutrientNameToId map[string]int, name string) (int, bool) {
+    if id, err := strconv.Atoi(name); err == nil {
+        _, exists := nutrients[id]
+        return id, exists
+    }
+    id, exists := nutrientNameToId[name]
+    return id, exists
+}
+
+// splitDelimited splits a line into fields. A quoted field may hold the
+// delimiter, and a doubled quote inside one is a quote.
+func splitDelimited(line string, delimiter rune, quote rune) ([]string, error) {
+    var fields []string
+    var field strings.Builder
+    quoted, wasQuoted := false, false
+    runes := []rune(line)
+    for i := 0; i < len(runes); i++ {
+        r := runes[i]
+        switch {
+        case quoted && r == quote:
+            if i + 1 < len(runes) && runes[i + 1] == quote {
+                field.WriteRune(quote)
+                i++
+            } else {
+                quoted = false
+            }
+        case quoted:
+            field.WriteRune(r)
+        case r == delimiter:
+            fields = append(fields, strings.TrimSpace(field.String()))
+            field.Reset()
+            wasQuoted = false
+        case quote != 0 && r == quote && !wasQuoted && strings.TrimSpace(field.String()) == "":
+            field.Reset()
+            quoted, wasQuoted = true, true
+        default:
+            field.WriteRune(r)
+        }
+    }
+    if quoted {
+        return nil, fmt.Errorf("unterminated quote")
+    }
+    return append(fields, strings.TrimSpace(field.String())), nil
+}
+
+func (source tableSource) LoadFoods(nutrients map[int]Nutrient) (map[int]Food, error) {
+    mapping, err := readTableMapping(source.mappingPath)
+    if err != nil {
+        return nil, err
+    }
+    path := mapping.file
+    if !filepath.IsAbs(path) {
+        path = filepath.Join(filepath.Dir(mapping.path), path)
+    }
+    file, err := openDataFile(filepath.Dir(path), filepath.Base(path))
+    if err != nil {
+        return nil, fmt.Errorf("%s: %v", mapping.path, err)
+    }
+    defer file.Close()
+    reader := bufio.NewReader(newTextReader(file))
+
+    lineNumber := 0
+    readRow := func() ([]string, error) {
+        line, err := reader.ReadString('\n')
+        if line == "" && err != nil {
+            return nil, err
+        }
+        lineNumber++
+        row, err := splitDelimited(strings.TrimRight(line, "\r\n"), mapping.delimiter, mapping.quote)
+        if err != nil {
+            return nil, fmt.Errorf("%s line %d: %v", path, lineNumber, err)
+        }
+        return row, nil
+    }
+    for i := 0; i < mapping.skipRows; i++ {
+        if _, err := readRow(); err != nil && err != io.EOF {
+            return nil, err
+        }
+    }
+    var header []string
+    if mapping.header {
+        header, err = readRow()
+        if err != nil {
+            return nil, fmt.Errorf("%s: no header: %v", path, err)
+        }
+    }
+
+    // Every column is found up front, so a mistake in the mapping shows
+    // before any rows are read
+    idColumn, err := tableColumn(header, mapping.id)
+    if err != nil {
+        return nil, fmt.Errorf("%s: %v", mapping.path, err)
+    }
+    descriptionColumn, err := tableColumn(header, mapping.description)
+    if err != nil {
+        return nil, fmt.Errorf("%s: %v", mapping.path, err)
+    }
+    nutrientNameToId := nutrientNameIndex(nutrients)
+    columns := make([]int, len(mapping.nutrients))
+    nutrientIds := make([]int, len(mapping.nutrients))
+    for i, mapped := range mapping.nutrients {
+        if columns[i], err = tableColumn(header, mapped.column); err != nil {
+            return nil, fmt.Errorf("%s: %v", mapping.path, err)
+        }
+        id, exists := tableNutrientId(nutrients, nutrientNameToId, mapped.nutrient)
+        if !exists {
+            return nil, fmt.Errorf("%s: no nutrient %q", mapping.path, mapped.nutrient)
+        }
+        nutrientIds[i] = id
+        if _, err := convertNutrientUnits(1, mapped.units, nutrients[id].units, nutrients[id].description); mapped.units != "" && err != nil {
+            return nil, fmt.Errorf("%s: %s: %v", mapping.path, mapped.nutrient, err)
+        }
+    }
+
+    foods := make(map[int]Food)
+    for {
+        row, err := readRow()
+        if err == io.EOF {
+            break
+        } else if err != nil {
+            return nil, err
+        }
+        if len(row) == 1 && row[0] == "" {
+            continue
+        }
+        id, err := strconv.Atoi(brandedField(row, idColumn))
+        if err != nil {
+            fmt.Printf("%s line %d: skipped, the id %q isn't a number\n", path, lineNumber, brandedField(row, idColumn))
+            continue
+        }
+        food := Food{id: id, foodGroup: customFoodGroup, description: brandedField(row, descriptionColumn)}
+        for i, mapped := range mapping.nutrients {
+            text := brandedField(row, columns[i])
+            if mapping.decimal == "," {
+                text = strings.Replace(text, ",", ".", 1)
+            }
+            amount, err := strconv.ParseFloat(text, 64)
+            if err != nil {
+                continue
+            }
+            nutrient := nutrients[nutrientIds[i]]
+            if mapped.units != "" {
+                // Checked above, so this can't fail
+                amount, _ = convertNutrientUnits(amount, mapped.units, nutrient.units, nutrient.description)
+            }
+            food.nutrients = append(food.nutrients, NutrientInFood{nutrient: nutrient, amountPerG: amount * mapped.scale / mapping.perGrams})
+        }
+        foods[id] = food
+    }
+    return foods, nil
+}
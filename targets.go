@@ -0,0 +1,540 @@
+package main
+
+import (
+    _ "embed"
+    "fmt"
+    "math"
+    "os"
+    "strconv"
+    "strings"
+)
+
+// Target is the daily range wanted for one nutrient. Below min is penalized
+// in proportion to the shortfall; above the midpoint of min and max is
+// penalized in proportion to the excess. A max of 0 means no upper limit.
+type Target struct {
+    nutrient string // NUTR_DEF.txt description, or a derived nutrient, see derived.go
+    min float64
+    max float64
+    weight float64 // multiplies the penalty, 0 for the usual 1
+    notes string
+    hard bool // recipes outside the range are infeasible, see hard.go
+    units string // what min and max are in, "" for the nutrient's units in the data
+}
+
+// penaltyWeight is what the target's penalty is multiplied by.
+func (target Target) penaltyWeight() float64 {
+    if target.weight == 0 {
+        return 1
+    }
+    return target.weight
+}
+
+// The defaults are for a 145 lb man, see dri.go for anyone else. They're
+// in targets.yaml, along with the nutrients left out and why.
+
+//go:embed targets.yaml
+var targetsYAML string
+
+// builtinTargets is targets.yaml, parsed the first time it's needed.
+var builtinTargets []Target
+
+func defaultTargets() []Target {
+    if builtinTargets == nil {
+        targets, err := parseTargets(targetsYAML, "targets.yaml", nil, nil, 0)
+        if err != nil {
+            panic(err)
+        }
+        builtinTargets = targets
+    }
+    return builtinTargets
+}
+
+// amountOf is the recipe's total of a nutrient, by description, including
+// derived nutrients.
+func (recipe *Recipe) amountOf(nutrientNameToId map[string]int, nutrient string) float64 {
+    if parts, exists := derivedNutrients[nutrient]; exists {
+        total := float64(0)
+        for _, part := range parts {
+            total += part.factor * recipe.nutrientTotals[nutrientNameToId[part.nutrient]]
+        }
+        return total
+    }
+    return recipe.nutrientTotals[nutrientNameToId[nutrient]]
+}
+
+// perGramOf is how much of a target's nutrient one gram of food has.
+func (food *Food) perGramOf(nutrientNameToId map[string]int, nutrient string) float64 {
+    amountOf := func(name string) float64 {
+        id, exists := nutrientNameToId[name]
+        if !exists {
+            return 0
+        }
+        for _, nutrientInFood := range food.nutrients {
+            if nutrientInFood.nutrient.id == id {
+                return nutrientInFood.amountPerG
+            }
+        }
+        return 0
+    }
+    if parts, exists := derivedNutrients[nutrient]; exists {
+        total := float64(0)
+        for _, part := range parts {
+            total += part.factor * amountOf(part.nutrient)
+        }
+        return total
+    }
+    return amountOf(nutrient)
+}
+
+// isMet reports whether amount is inside the target range. Amounts above the
+// midpoint are penalized but still count as met.
+func (target Target) isMet(amount float64) bool {
+    return amount >= target.min && (target.max == 0 || amount <= target.max)
+}
+
+func (target Target) rangeString() string {
+    if target.max == 0 {
+        return "at least " + amountString(target.min)
+    }
+    return amountString(target.min) + " to " + amountString(target.max)
+}
+
+// amountString formats a target amount for people. Amounts scaled by
+// --weight-kg or worked out, like a range's midpoint, pick up float noise
+// such as 6.6499999999999995, so they're rounded to 10 significant digits.
+func amountString(amount float64) string {
+    return strconv.FormatFloat(amount, 'g', 10, 64)
+}
+
+// Where a target came from, for targets show --effective
+const (
+    targetSourceDefault = "default"
+    targetSourceFlag = "--target"
+    targetSourceMaxKcal = "--max-kcal"
+)
+
+const energyNutrient = "Energy, kcal"
+
+// targetUnits is the units a target is measured in: those of its nutrient,
+// or of the first part of a derived one.
+func targetUnits(nutrients map[int]Nutrient, nutrientNameToId map[string]int, nutrient string) string {
+    if parts, exists := derivedNutrients[nutrient]; exists {
+        nutrient = parts[0].nutrient
+    }
+    id, exists := nutrientNameToId[nutrient]
+    if !exists {
+        return ""
+    }
+    return nutrients[id].units
+}
+
+// parseTargetBound reads one end of a target range for nutrient, e.g.
+// "2.5g" or "600 IU", converting it to units if it has units of its own. A
+// bound per kg of body weight, like "1.6 g/kg" or "35 ml/kg", is multiplied
+// by weightKg, the --weight-kg, which is 0 when it wasn't given. An empty
+// bound is 0.
+//
+// A bound with units that can't be converted to the nutrient's, like "2 g"
+// of something the data has in IU, is an error rather than being taken as
+// a number in whatever units the data happens to use.
+func parseTargetBound(bound string, units string, nutrient string, weightKg float64) (float64, error) {
+    bound = strings.TrimSpace(bound)
+    if bound == "" {
+        return 0, nil
+    }
+    amount, unit, err := parseAmount(bound)
+    if err != nil {
+        return 0, err
+    }
+    if compact := strings.ReplaceAll(unit, " ", ""); strings.HasSuffix(compact, "/kg") {
+        if weightKg <= 0 {
+            return 0, fmt.Errorf("%q is per kg of body weight, which needs --weight-kg", bound)
+        }
+        amount, unit = amount * weightKg, canonicalUnit(strings.TrimSuffix(compact, "/kg"))
+    }
+    if unit != "" {
+        return convertNutrientUnits(amount, unit, units, nutrient)
+    }
+    return amount, nil
+}
+
+// withUnits is bound with units added, if it has none of its own.
+func withUnits(bound string, units string) string {
+    if _, unit, err := parseAmount(bound); err != nil || unit != "" || units == "" || strings.TrimSpace(bound) == "" {
+        return bound
+    }
+    return bound + " " + units
+}
+
+// inDataUnits converts the targets given in units of their own, as the
+// built in ones are, to their nutrients' units in the data, failing if a
+// nutrient's units there aren't the same kind of thing.
+func inDataUnits(targets []Target, source string, nutrients map[int]Nutrient, nutrientNameToId map[string]int) ([]Target, error) {
+    for i, target := range targets {
+        units := targetUnits(nutrients, nutrientNameToId, target.nutrient)
+        if target.units == "" || units == "" {
+            continue
+        }
+        for _, bound := range []*float64{&target.min, &target.max} {
+            converted, err := convertNutrientUnits(*bound, target.units, units, target.nutrient)
+            if err != nil {
+                return nil, fmt.Errorf("%s: %s is in %s, but the data has it in %s", source, target.nutrient, target.units, units)
+            }
+            *bound = converted
+        }
+        target.units = ""
+        targets[i] = target
+    }
+    return targets, nil
+}
+
+func isTargetNutrient(nutrientNameToId map[string]int, nutrient string) bool {
+    _, isDerived := derivedNutrients[nutrient]
+    _, exists := nutrientNameToId[nutrient]
+    return exists || isDerived
+}
+
+// parseTargetOverride reads a --target flag, "Nutrient=min:max". Either
+// bound may be left out, and may have units, e.g. "Calcium, Ca=1g:2.5g".
+// Amounts are converted to the nutrient's units.
+func parseTargetOverride(spec string, nutrients map[int]Nutrient, nutrientNameToId map[string]int, weightKg float64) (Target, error) {
+    equals := strings.LastIndex(spec, "=")
+    if equals < 0 {
+        return Target{}, fmt.Errorf("--target %q should look like \"Nutrient=min:max\"", spec)
+    }
+    nutrient := strings.TrimSpace(spec[:equals])
+    if !isTargetNutrient(nutrientNameToId, nutrient) {
+        return Target{}, fmt.Errorf("--target %q: no nutrient %q", spec, nutrient)
+    }
+    units := targetUnits(nutrients, nutrientNameToId, nutrient)
+
+    bounds := strings.SplitN(spec[equals + 1:], ":", 2)
+    values := make([]float64, 2)
+    for i, bound := range bounds {
+        amount, err := parseTargetBound(bound, units, nutrient, weightKg)
+        if err != nil {
+            return Target{}, fmt.Errorf("--target %q: %v", spec, err)
+        }
+        values[i] = amount
+    }
+    if values[1] != 0 && values[1] < values[0] {
+        return Target{}, fmt.Errorf("--target %q: max is below min", spec)
+    }
+    return Target{nutrient: nutrient, min: values[0], max: values[1]}, nil
+}
+
+// readTargetsFile reads a table of targets, in the format of targets.yaml:
+//
+//   targets:
+//     - nutrient: Protein
+//       min: 120 g
+//       max: 250 g
+//       weight: 2
+//     - nutrient: "Calcium, Ca"
+//       min: 1000
+//
+// Either bound may be left out, and units are converted like --target's. A
+// "units: mg" field gives the units of bounds without their own.
+func readTargetsFile(path string, nutrients map[int]Nutrient, nutrientNameToId map[string]int, weightKg float64) ([]Target, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    return parseTargets(string(data), path, nutrients, nutrientNameToId, weightKg)
+}
+
+// parseTargets reads a targets file from source. Without nutrientNameToId
+// the nutrients aren't checked and the bounds are kept in their own units,
+// those of min if min and max differ, which is how the built in targets
+// are read before any data is loaded. inDataUnits converts them once it is.
+// Bounds per kg are multiplied by weightKg, see parseTargetBound.
+func parseTargets(data string, source string, nutrients map[int]Nutrient, nutrientNameToId map[string]int, weightKg float64) ([]Target, error) {
+    document, err := parseYAML(data)
+    if err != nil {
+        return nil, fmt.Errorf("%s: %v", source, err)
+    }
+    root, _ := document.(map[string]interface{})
+    entries, ok := root["targets"].([]interface{})
+    if !ok {
+        return nil, fmt.Errorf("%s: expected a list under \"targets:\"", source)
+    }
+
+    var targets []Target
+    seen := make(map[string]bool)
+    for i, entry := range entries {
+        fields, ok := entry.(map[string]interface{})
+        if !ok {
+            return nil, fmt.Errorf("%s: target %d is not a map", source, i + 1)
+        }
+        nutrient, _ := fields["nutrient"].(string)
+        if nutrient == "" || (nutrientNameToId != nil && !isTargetNutrient(nutrientNameToId, nutrient)) {
+            return nil, fmt.Errorf("%s: target %d: no nutrient %q", source, i + 1, nutrient)
+        }
+        if seen[nutrient] {
+            return nil, fmt.Errorf("%s: %q is listed twice", source, nutrient)
+        }
+        seen[nutrient] = true
+        // The units of bounds without their own
+        declared := ""
+        if text, given := fields["units"].(string); given {
+            if declared = canonicalUnit(text); declared == "" {
+                return nil, fmt.Errorf("%s: %s: empty units", source, nutrient)
+            }
+        }
+        target := Target{nutrient: nutrient}
+        units := ""
+        if nutrientNameToId != nil {
+            units = targetUnits(nutrients, nutrientNameToId, nutrient)
+        } else {
+            for _, key := range []string{"max", "min"} {
+                text, _ := fields[key].(string)
+                if _, unit, err := parseAmount(withUnits(text, declared)); err == nil && unit != "" {
+                    units = unit
+                }
+            }
+            // Without data a bare number is in the nutrient's units, which
+            // aren't known to convert to those of the other bound
+            for _, key := range []string{"min", "max"} {
+                text, _ := fields[key].(string)
+                if _, unit, err := parseAmount(withUnits(text, declared)); err == nil && unit == "" && units != "" {
+                    return nil, fmt.Errorf("%s: %s: give both min and max units, or neither", source, nutrient)
+                }
+            }
+            target.units = units
+        }
+
+        for key, value := range fields {
+            text, _ := value.(string)
+            var bound *float64
+            switch key {
+            case "nutrient":
+                continue
+            case "notes":
+                target.notes = text
+                continue
+            case "units":
+                continue
+            case "weight":
+                target.weight, err = strconv.ParseFloat(strings.TrimSpace(text), 64)
+                if err != nil || target.weight <= 0 {
+                    return nil, fmt.Errorf("%s: %s: weight should be a number above 0, not %q", source, nutrient, text)
+                }
+                continue
+            case "hard":
+                target.hard, err = strconv.ParseBool(strings.TrimSpace(text))
+                if err != nil {
+                    return nil, fmt.Errorf("%s: %s: hard should be true or false, not %q", source, nutrient, text)
+                }
+                continue
+            case "min":
+                bound = &target.min
+            case "max":
+                bound = &target.max
+            default:
+                return nil, fmt.Errorf("%s: %s: unknown field %q", source, nutrient, key)
+            }
+            *bound, err = parseTargetBound(withUnits(text, declared), units, nutrient, weightKg)
+            if err != nil {
+                return nil, fmt.Errorf("%s: %s %s: %v", source, nutrient, key, err)
+            }
+        }
+        if target.max != 0 && target.max < target.min {
+            return nil, fmt.Errorf("%s: %s: max is below min", source, nutrient)
+        }
+        targets = append(targets, target)
+    }
+    return targets, nil
+}
+
+// overrideTargets applies a --targets file to targets: each of its entries
+// changes only the fields it gives, so "weight: 2" alone keeps the range,
+// and nutrients not in targets are added. A file with only stimulants:
+// leaves the targets alone.
+func overrideTargets(targets []Target, path string, nutrients map[int]Nutrient, nutrientNameToId map[string]int, weightKg float64) ([]Target, []string, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, nil, err
+    }
+    document, err := parseYAML(string(data))
+    if err != nil {
+        return nil, nil, fmt.Errorf("%s: %v", path, err)
+    }
+    root, _ := document.(map[string]interface{})
+    if _, hasTargets := root["targets"]; !hasTargets && root["stimulants"] != nil {
+        return targets, nil, nil
+    }
+    overrides, err := parseTargets(string(data), path, nutrients, nutrientNameToId, weightKg)
+    if err != nil {
+        return nil, nil, err
+    }
+    // Which fields each entry gives, as parseTargets can't tell a bound of 0
+    // from one left out
+    entries := document.(map[string]interface{})["targets"].([]interface{})
+
+    var changed []string
+    for i, override := range overrides {
+        fields := entries[i].(map[string]interface{})
+        target := Target{nutrient: override.nutrient}
+        for _, existing := range targets {
+            if existing.nutrient == override.nutrient {
+                target = existing
+            }
+        }
+        // A new range needs its own notes
+        _, givesMin := fields["min"]
+        _, givesMax := fields["max"]
+        if givesMin || givesMax {
+            target.notes = ""
+        }
+        if givesMin {
+            target.min = override.min
+        }
+        if givesMax {
+            target.max = override.max
+        }
+        if _, given := fields["weight"]; given {
+            target.weight = override.weight
+        }
+        if _, given := fields["notes"]; given {
+            target.notes = override.notes
+        }
+        if _, given := fields["hard"]; given {
+            target.hard = override.hard
+        }
+        if target.max != 0 && target.max < target.min {
+            return nil, nil, fmt.Errorf("%s: %s: max is below min", path, target.nutrient)
+        }
+        // Not setTarget, which would keep it hard
+        replaced := false
+        for i := range targets {
+            if targets[i].nutrient == target.nutrient {
+                targets[i], replaced = target, true
+            }
+        }
+        if !replaced {
+            targets = append(targets, target)
+        }
+        changed = append(changed, target.nutrient)
+    }
+    return targets, changed, nil
+}
+
+// setTarget replaces the target for a nutrient, or adds one. A target
+// without a weight keeps that of the one it replaces, and a hard one's
+// stays hard.
+func setTarget(targets []Target, target Target) []Target {
+    for i := range targets {
+        if targets[i].nutrient == target.nutrient {
+            if target.weight == 0 {
+                target.weight = targets[i].weight
+            }
+            target.hard = target.hard || targets[i].hard
+            targets[i] = target
+            return targets
+        }
+    }
+    return append(targets, target)
+}
+
+// effectiveTargets is the default table, the --subject-to file or the DRIs
+// for the body stats given, with life-stage --preset changes, the --targets
+// file, --max-kcal and the --target overrides applied and the --baseline
+// taken off, along with where each target came from.
+func effectiveTargets(opts *Options, nutrients map[int]Nutrient, nutrientNameToId map[string]int) ([]Target, map[string]string, error) {
+    if err := loadDerivedNutrients(opts, nutrientNameToId); err != nil {
+        return nil, nil, err
+    }
+    targets := make([]Target, len(defaultTargets()))
+    copy(targets, defaultTargets())
+    targets, err := inDataUnits(targets, "targets.yaml", nutrients, nutrientNameToId)
+    if err != nil {
+        return nil, nil, err
+    }
+    baseSource := targetSourceDefault
+    if opts.subjectTo != "" {
+        fromFile, err := readTargetsFile(opts.subjectTo, nutrients, nutrientNameToId, opts.weightKg)
+        if err != nil {
+            return nil, nil, err
+        }
+        targets, baseSource = fromFile, opts.subjectTo
+    }
+    sources := make(map[string]string, len(targets))
+    for _, target := range targets {
+        sources[target.nutrient] = baseSource
+    }
+
+    stats, err := bodyStatsFrom(opts)
+    if err != nil {
+        return nil, nil, err
+    }
+    if stats != nil {
+        if opts.subjectTo != "" {
+            return nil, nil, fmt.Errorf("give --subject-to or body stats like --sex, not both")
+        }
+        targets = driTargets(targets, stats)
+        for _, nutrient := range driNutrients() {
+            sources[nutrient] = targetSourceDRI
+        }
+    }
+
+    if targets, err = presetTargets(targets, sources, opts, stats); err != nil {
+        return nil, nil, err
+    }
+
+    if opts.targetsPath != "" {
+        var changed []string
+        targets, changed, err = overrideTargets(targets, opts.targetsPath, nutrients, nutrientNameToId, opts.weightKg)
+        if err != nil {
+            return nil, nil, err
+        }
+        for _, nutrient := range changed {
+            sources[nutrient] = opts.targetsPath
+        }
+    }
+
+    if opts.maxKcal > 0 {
+        energy := Target{nutrient: energyNutrient, max: opts.maxKcal}
+        for _, target := range targets {
+            if target.nutrient == energyNutrient {
+                energy.min = math.Min(target.min, opts.maxKcal)
+            }
+        }
+        targets = setTarget(targets, energy)
+        sources[energyNutrient] = targetSourceMaxKcal
+    }
+
+    for _, spec := range opts.targetOverrides {
+        override, err := parseTargetOverride(spec, nutrients, nutrientNameToId, opts.weightKg)
+        if err != nil {
+            return nil, nil, err
+        }
+        targets = setTarget(targets, override)
+        sources[override.nutrient] = targetSourceFlag
+    }
+    if targets, err = markHardTargets(targets, opts.hardTargets); err != nil {
+        return nil, nil, err
+    }
+    if targets, err = subtractBaseline(targets, opts, nutrients, nutrientNameToId); err != nil {
+        return nil, nil, err
+    }
+    return targets, sources, nil
+}
+
+// penaltyShape describes how calcPenalty, and aboveMax past the max, treat
+// a target.
+func (target Target) penaltyShape(aboveMax string) string {
+    below := fmt.Sprintf("below %s: linear, 100 at zero", amountString(target.min))
+    if target.min == 0 {
+        below = "no minimum"
+    }
+    if target.max == 0 {
+        return below + "; no maximum"
+    }
+    midpoint := target.min + (target.max - target.min) / 2
+    shape := fmt.Sprintf("%s; above %s: linear, 100 at %s", below, amountString(midpoint), amountString(target.max))
+    if aboveMax == aboveMaxSteep {
+        shape += ", then adding the square of the percentage over"
+    }
+    return shape
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "os"
+)
+
+// runTargets handles "supershake targets show", which lists the targets
+// recipes are scored against. With --effective it shows everything that goes
+// into the score: where each target came from, its units and how misses are
+// penalized, plus the penalties that aren't in the targets table.
+// "supershake targets defaults" prints the built in targets.yaml, to start a
+// --targets file from, and "supershake targets derived" and "supershake
+// targets supplements" the built in derived nutrients and supplements.
+func runTargets(args []string) {
+    if len(args) > 0 && args[0] == "defaults" {
+        fmt.Print(targetsYAML)
+        return
+    }
+    if len(args) > 0 && args[0] == "derived" {
+        fmt.Print(derivedYAML)
+        return
+    }
+    if len(args) > 0 && args[0] == "supplements" {
+        fmt.Print(supplementsYAML)
+        return
+    }
+    if len(args) == 0 || args[0] != "show" {
+        fmt.Fprintln(os.Stderr, "Usage: supershake targets show [--effective] [flags]")
+        fmt.Fprintln(os.Stderr, "       supershake targets defaults")
+        fmt.Fprintln(os.Stderr, "       supershake targets derived")
+        fmt.Fprintln(os.Stderr, "       supershake targets supplements")
+        os.Exit(2)
+    }
+
+    fs := flag.NewFlagSet("supershake targets show", flag.ExitOnError)
+    opts := newOptions(fs)
+    effective := fs.Bool("effective", false, "show each target's source, units and penalty shape")
+    fs.Parse(args[1:])
+    applyProfile(fs, opts)
+
+    // Only the nutrient definitions are needed, so skip loading the foods
+    ensureDataFiles(opts)
+    nutrients, err := newFoodSource(opts).LoadNutrients()
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(2)
+    }
+    nutrientNameToId := nutrientNameIndex(nutrients)
+    problem := newProblem(opts, nutrients, nutrientNameToId, nil)
+
+    for _, target := range problem.targets {
+        units := targetUnits(nutrients, nutrientNameToId, target.nutrient)
+        if !*effective {
+            fmt.Printf("%s: %s %s\n", target.nutrient, target.rangeString(), units)
+            continue
+        }
+        fmt.Printf("%s\n", target.nutrient)
+        fmt.Printf("  range:   %s %s\n", target.rangeString(), units)
+        fmt.Printf("  source:  %s\n", problem.targetSources[target.nutrient])
+        if target.hard {
+            fmt.Printf("  hard:    recipes outside the range are never returned\n")
+        } else if problem.maxIsHard(target) {
+            fmt.Printf("  hard:    recipes over the max are never returned\n")
+        }
+        if problem.objective != "" {
+            fmt.Printf("  penalty: constraint, %d per 1%% outside the range\n", constraintWeight)
+        } else {
+            fmt.Printf("  penalty: %s\n", target.penaltyShape(opts.aboveMax))
+            if weight := target.penaltyWeight(); weight != 1 {
+                fmt.Printf("  weight:  %g\n", weight)
+            }
+        }
+        if target.notes != "" {
+            fmt.Printf("  notes:   %s\n", target.notes)
+        }
+        if units == "" {
+            fmt.Printf("  warning: not in this dataset, always scores as zero\n")
+        }
+    }
+
+    if *effective {
+        if renamed := renamedNutrients(nutrients); len(renamed) > 0 {
+            fmt.Println("\nRenamed nutrients (NUTR_DEF.txt gives them duplicate descriptions)")
+            for _, line := range renamed {
+                fmt.Println("  " + line)
+            }
+        }
+        fmt.Println("\nOther penalties (built in)")
+        for _, limit := range problem.stimulants {
+            fmt.Printf("  %s: %s\n", limit.nutrient, limit.describe(targetUnits(nutrients, nutrientNameToId, limit.nutrient)))
+        }
+        fmt.Println("  Dihydrophylloquinone: 1 per µg")
+        fmt.Println("  Number of foods: 0.1 per food, at most 10")
+        if opts.maxMassG == 0 {
+            fmt.Println("  Mass: 10 per 3000 g, at most 10")
+        } else {
+            fmt.Printf("  Mass: none, recipes are capped at %d g\n", opts.maxMassG)
+        }
+    }
+}
@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestTargetStrings(t *testing.T) {
+    // Variables, so the products pick up float noise as --weight-kg's do
+    perKg, tenth := 1.1, 0.1
+    for _, test := range []struct {
+        target Target
+        rangeString string
+        penaltyShape string
+    }{
+        {Target{min: 2.3, max: 11}, "2.3 to 11", "below 2.3: linear, 100 at zero; above 6.65: linear, 100 at 11"},
+        {Target{min: perKg * 70}, "at least 77", "below 77: linear, 100 at zero; no maximum"},
+        {Target{max: tenth * 3}, "0 to 0.3", "no minimum; above 0.15: linear, 100 at 0.3"},
+        {Target{min: 2700, max: 10000}, "2700 to 10000", "below 2700: linear, 100 at zero; above 6350: linear, 100 at 10000"},
+    } {
+        if got := test.target.rangeString(); got != test.rangeString {
+            t.Errorf("%+v: range %q, want %q", test.target, got, test.rangeString)
+        }
+        if got := test.target.penaltyShape(aboveMaxLinear); got != test.penaltyShape {
+            t.Errorf("%+v: penalty %q, want %q", test.target, got, test.penaltyShape)
+        }
+    }
+}
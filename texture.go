@@ -0,0 +1,128 @@
+package main
+
+import (
+    "fmt"
+    "strings"
+)
+
+// Not everything blends. A personal blender turns powders, liquids and soft
+// foods into a shake but leaves raw kale in strings and almonds in chunks.
+// Foods are sorted by form, like NOVA classes, from the description and then
+// the food group, and --blender leaves out the forms it can't handle.
+
+const (
+    formPowder = "powder"
+    formLiquid = "liquid"
+    formSoft = "soft"
+    formFibrous = "fibrous"
+    formHard = "hard" // needs a high-power blender
+)
+
+// foodFormKeywords are checked in order against the lower case description,
+// the first match deciding the form.
+var foodFormKeywords = []struct {
+    keyword string
+    form string
+}{
+    {"powder", formPowder},
+    {"flour", formPowder},
+    {"dry mix", formPowder},
+    {"dried", formHard},
+    {"dehydrated", formHard},
+    {"juice", formLiquid},
+    {"milk", formLiquid},
+    {"oil,", formLiquid},
+    {"syrup", formLiquid},
+    {"honey", formLiquid},
+    {"water", formLiquid},
+    {"yogurt", formSoft},
+    {"egg,", formSoft},
+    {"tofu", formSoft},
+    {"butter", formSoft},
+    {"banana", formSoft},
+    {"avocado", formSoft},
+    {"cooked", formSoft},
+    {"boiled", formSoft},
+    {"canned", formSoft},
+    {"mature seeds, raw", formHard}, // dry beans and lentils
+    {"raw", formFibrous},
+}
+
+// foodGroupForms is the form for each SR26 food group when no keyword
+// matches.
+var foodGroupForms = map[string]string{
+    "0100": formSoft, // dairy and egg
+    "0200": formPowder, // spices and herbs
+    "0300": formSoft, // baby foods
+    "0400": formLiquid, // fats and oils
+    "0600": formLiquid, // soups, sauces and gravies
+    "0800": formHard, // breakfast cereals
+    "0900": formSoft, // fruits
+    "1100": formFibrous, // vegetables
+    "1200": formHard, // nuts and seeds
+    "1400": formLiquid, // beverages
+    "1600": formHard, // legumes
+    "2000": formHard, // cereal grains and pasta
+    "2500": formHard, // snacks
+}
+
+// foodForm guesses how a food blends. Meat, fish and whatever else matches
+// nothing are taken as fibrous.
+func foodForm(food *Food) string {
+    description := strings.ToLower(food.description)
+    for _, rule := range foodFormKeywords {
+        if strings.Contains(description, rule.keyword) {
+            return rule.form
+        }
+    }
+    if form, exists := foodGroupForms[food.foodGroup]; exists {
+        return form
+    }
+    return formFibrous
+}
+
+// blenderClasses says what each class of blender does with each form: blend
+// it, blend it once it has soaked, or not at all (missing).
+var blenderClasses = map[string]map[string]string{
+    "personal": {
+        formPowder: "blend",
+        formLiquid: "blend",
+        formSoft: "blend",
+    },
+    "standard": {
+        formPowder: "blend",
+        formLiquid: "blend",
+        formSoft: "blend",
+        formFibrous: "blend",
+        formHard: "soak",
+    },
+    "high-power": {
+        formPowder: "blend",
+        formLiquid: "blend",
+        formSoft: "blend",
+        formFibrous: "blend",
+        formHard: "blend",
+    },
+}
+
+func checkBlender(blender string) error {
+    if _, exists := blenderClasses[blender]; !exists {
+        return fmt.Errorf("unknown --blender %q, want personal, standard or high-power", blender)
+    }
+    return nil
+}
+
+// canBlend reports whether the blender can handle the food at all.
+func canBlend(blender string, food *Food) bool {
+    _, handles := blenderClasses[blender][foodForm(food)]
+    return handles
+}
+
+// soakNote is printed after a food that needs soaking first, "" for foods
+// that go straight in.
+func soakNote(blender string, food *Food) string {
+    if blenderClasses[blender][foodForm(food)] != "soak" {
+        return ""
+    }
+    return ", soaked overnight first"
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+    "bufio"
+    "compress/gzip"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "io"
+    "math"
+    "os"
+)
+
+// --trace records every step the hill climber tries to a gzipped JSON lines
+// file, for studying how the search goes and for replaying it. The first
+// line says how the run was made and where it started:
+//
+//     {"version":1,"args":["--data-dir","sr26"],"step":5,"start":{"11090":10}}
+//
+// and then there's a line for each step tried, the food, the grams added or
+// taken away and the score that gave, with the step each round took marked
+// accepted, and a last line once the search stops:
+//
+//     {"round":1,"food":11090,"delta":5,"score":231.2}
+//     {"round":1,"food":9040,"delta":5,"score":229.8,"accepted":true}
+//     {"done":true,"rounds":240,"score":128.4}
+//
+// "supershake replay trace.jsonl.gz" loads the data with the same flags,
+// takes the same steps, checks each scores what it did and prints the
+// recipe. Scores are sums over maps, so they're compared to within a
+// rounding error rather than bit for bit.
+//
+// A move of more than one step, as from --moves swap, has its first step as
+// food and delta, and the rest in also:
+//
+//     {"round":3,"food":11090,"delta":-5,"also":[{"food":9040,"delta":5}],"score":210.4}
+
+const traceVersion = 1
+
+type traceHeader struct {
+    Version int `json:"version"`
+    Args []string `json:"args"`
+    Step int `json:"step"`
+    Start map[int]int `json:"start"`
+}
+
+type traceStep struct {
+    Food int `json:"food"`
+    Delta int `json:"delta"`
+}
+
+type traceMove struct {
+    Round int `json:"round"`
+    Food int `json:"food"`
+    Delta int `json:"delta"`
+    Also []traceStep `json:"also,omitempty"`
+    Score float64 `json:"score"`
+    Accepted bool `json:"accepted,omitempty"`
+}
+
+type traceEnd struct {
+    Done bool `json:"done"`
+    Rounds int `json:"rounds"`
+    Score float64 `json:"score"`
+}
+
+// traceLine is any of the above, for reading them back.
+type traceLine struct {
+    Version int `json:"version"`
+    Args []string `json:"args"`
+    Step int `json:"step"`
+    Start map[int]int `json:"start"`
+    Round int `json:"round"`
+    Food int `json:"food"`
+    Delta int `json:"delta"`
+    Also []traceStep `json:"also"`
+    Score float64 `json:"score"`
+    Accepted bool `json:"accepted"`
+    Done bool `json:"done"`
+    Rounds int `json:"rounds"`
+}
+
+type optimizerTrace struct {
+    file *os.File
+    compressed *gzip.Writer
+    encoder *json.Encoder
+    err error // the first write that failed
+}
+
+// newTrace starts a trace at path for a run made with args from start.
+func newTrace(path string, args []string, step int, start *Recipe) (*optimizerTrace, error) {
+    file, err := os.Create(path)
+    if err != nil {
+        return nil, err
+    }
+    compressed := gzip.NewWriter(file)
+    trace := &optimizerTrace{file: file, compressed: compressed, encoder: json.NewEncoder(compressed)}
+    trace.write(traceHeader{traceVersion, args, step, start.foodQuantities})
+    return trace, trace.err
+}
+
+func (trace *optimizerTrace) write(line interface{}) {
+    if trace.err == nil {
+        trace.err = trace.encoder.Encode(line)
+    }
+}
+
+func newTraceMove(round int, move Move, score float64, accepted bool) traceMove {
+    line := traceMove{Round: round, Food: move[0].FoodId, Delta: move[0].Grams, Score: score, Accepted: accepted}
+    for _, step := range move[1:] {
+        line.Also = append(line.Also, traceStep{step.FoodId, step.Grams})
+    }
+    return line
+}
+
+func (trace *optimizerTrace) move(round int, move Move, score float64) {
+    trace.write(newTraceMove(round, move, score, false))
+}
+
+func (trace *optimizerTrace) accept(round int, move Move, score float64) {
+    trace.write(newTraceMove(round, move, score, true))
+}
+
+func (trace *optimizerTrace) finish(rounds int, score float64) {
+    trace.write(traceEnd{true, rounds, score})
+}
+
+func (trace *optimizerTrace) Close() error {
+    err := trace.err
+    if closeErr := trace.compressed.Close(); err == nil {
+        err = closeErr
+    }
+    if closeErr := trace.file.Close(); err == nil {
+        err = closeErr
+    }
+    return err
+}
+
+// sameScore allows for the summing order changing between runs.
+func sameScore(a, b float64) bool {
+    return math.Abs(a - b) <= 1e-9 * math.Max(1, math.Max(math.Abs(a), math.Abs(b)))
+}
+
+// runReplay handles "supershake replay [flags] trace.jsonl.gz". Flags given
+// are used instead of the ones recorded.
+func runReplay(args []string) {
+    fs := flag.NewFlagSet("supershake replay", flag.ExitOnError)
+    opts := newOptions(fs)
+    demo := fs.Bool("demo", false, "replay against the built in sample data")
+    quiet := fs.Bool("quiet", false, "don't print each accepted step")
+    fs.Parse(args)
+    if fs.NArg() != 1 {
+        fmt.Fprintln(os.Stderr, "Usage: supershake replay [--quiet] [flags] trace.jsonl.gz")
+        os.Exit(2)
+    }
+    path := fs.Arg(0)
+
+    file, err := os.Open(path)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(1)
+    }
+    defer file.Close()
+    compressed, err := gzip.NewReader(file)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+        os.Exit(1)
+    }
+    decoder := json.NewDecoder(bufio.NewReader(compressed))
+    var header traceLine
+    if err := decoder.Decode(&header); err != nil || header.Version == 0 {
+        fmt.Fprintf(os.Stderr, "%s: not a supershake trace\n", path)
+        os.Exit(1)
+    }
+    if header.Version != traceVersion {
+        fmt.Fprintf(os.Stderr, "%s: trace version %d, this build reads %d\n", path, header.Version, traceVersion)
+        os.Exit(1)
+    }
+
+    // Without flags of its own the run's are used, as "supershake demo" or
+    // plain supershake had them
+    ownFlags := false
+    fs.Visit(func(f *flag.Flag) {
+        ownFlags = ownFlags || f.Name != "quiet"
+    })
+    if !ownFlags {
+        recorded := header.Args
+        if len(recorded) > 0 && recorded[0] == "demo" {
+            recorded = append([]string{"--demo"}, recorded[1:]...)
+        }
+        if err := fs.Parse(recorded); err != nil {
+            fmt.Fprintf(os.Stderr, "%s: recorded flags: %v\n", path, err)
+            os.Exit(1)
+        }
+    }
+    if *demo {
+        useDemoData(fs, opts)
+    }
+    opts.tracePath = ""
+    opts.stepSize = header.Step
+
+    fmt.Println(tr("Loading"))
+    problem := loadProblem(opts)
+    recipe := NewRecipe(problem.foods, problem.nutrients)
+    for foodId, grams := range header.Start {
+        food, exists := problem.foods[foodId]
+        if !exists {
+            fmt.Fprintf(os.Stderr, "%s: the run started with food %d, which these flags leave out\n", path, foodId)
+            os.Exit(1)
+        }
+        recipe.AddFood(problem.foods, &food, grams)
+    }
+
+    moves, accepted, finished := 0, 0, false
+    for {
+        var line traceLine
+        err := decoder.Decode(&line)
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+            os.Exit(1)
+        }
+        if line.Done {
+            finished = true
+            if score := recipe.Score(problem, false); !sameScore(score, line.Score) {
+                fmt.Fprintf(os.Stderr, "The replay ends on %f, the run ended on %f\n", score, line.Score)
+                os.Exit(1)
+            }
+            break
+        }
+
+        move := Move{{line.Food, line.Delta}}
+        for _, step := range line.Also {
+            move = append(move, Step{step.Food, step.Delta})
+        }
+        for _, step := range move {
+            if _, exists := problem.foods[step.FoodId]; !exists {
+                fmt.Fprintf(os.Stderr, "%s: round %d tried food %d, which these flags leave out\n", path, line.Round, step.FoodId)
+                os.Exit(1)
+            }
+        }
+        move.apply(problem.foods, recipe)
+        score := recipe.Score(problem, false)
+        if !sameScore(score, line.Score) {
+            fmt.Fprintf(os.Stderr, "Round %d: %s scores %f, the run had %f\n", line.Round, move.describe(problem.foods), score, line.Score)
+            os.Exit(1)
+        }
+        moves++
+        if line.Accepted {
+            accepted++
+            if !*quiet {
+                fmt.Printf("Round %d: %s, score %f\n", line.Round, move.describe(problem.foods), score)
+            }
+        } else {
+            move.undo(problem.foods, recipe)
+        }
+    }
+
+    if !finished {
+        fmt.Printf("The trace stops before the search did, the run was probably cut short\n")
+    }
+    fmt.Printf("Replayed %d steps tried, %d taken, every score matches\n\n", moves, accepted)
+    printRecipe(problem, recipe)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+    "fmt"
+    "math"
+    "math/rand"
+    "sort"
+)
+
+// SR26 gives a standard error for values backed by two or more analyses.
+// Scoring the recipe many times over with each of those values drawn from
+// its error shows how much the result depends on the data being exact: a
+// recipe that only just meets a target may well not, in the food actually
+// bought.
+
+// sampledRecipe is recipe with every value that has a standard error drawn
+// from a normal distribution around it, never below zero.
+func sampledRecipe(problem *Problem, recipe *Recipe, rng *rand.Rand) *Recipe {
+    sampled := &Recipe{nutrientTotals: make(map[int]float64, len(recipe.nutrientTotals)), foodQuantities: recipe.foodQuantities}
+    for nutrientId := range recipe.nutrientTotals {
+        sampled.nutrientTotals[nutrientId] = 0
+    }
+    // In a fixed order so the same seed gives the same draws
+    for _, foodId := range recipe.FoodIdsByGrams() {
+        grams := float64(recipe.foodQuantities[foodId])
+        for _, nutrientInFood := range problem.foods[foodId].nutrients {
+            amount := nutrientInFood.amountPerG
+            if nutrientInFood.stdErrorPerG > 0 {
+                amount = math.Max(0, amount + rng.NormFloat64() * nutrientInFood.stdErrorPerG)
+            }
+            sampled.nutrientTotals[nutrientInFood.nutrient.id] += amount * grams
+        }
+    }
+    return sampled
+}
+
+// percentile of sorted values, p from 0 to 100.
+func percentile(sorted []float64, p float64) float64 {
+    if len(sorted) == 0 {
+        return 0
+    }
+    return sorted[int(math.Round(p / 100 * float64(len(sorted) - 1)))]
+}
+
+// printUncertainty scores samples draws of the recipe and reports the spread
+// of the score and how often each target is met.
+func printUncertainty(problem *Problem, recipe *Recipe, samples int) {
+    rng := rand.New(rand.NewSource(1))
+    scores := make([]float64, samples)
+    met := make([]int, len(problem.targets))
+    for sample := 0; sample < samples; sample++ {
+        sampled := sampledRecipe(problem, recipe, rng)
+        scores[sample] = sampled.Score(problem, false)
+        for i, target := range problem.targets {
+            if target.isMet(sampled.amountOf(problem.nutrientNameToId, target.nutrient)) {
+                met[i]++
+            }
+        }
+    }
+    sort.Float64s(scores)
+    mean := float64(0)
+    for _, score := range scores {
+        mean += score
+    }
+    mean /= float64(samples)
+
+    fmt.Printf("DATA UNCERTAINTY (%d draws within the standard errors)\n", samples)
+    fmt.Printf("Score %.2f as given; %.2f on average, 90%% between %.2f and %.2f\n",
+        recipe.Score(problem, false), mean, percentile(scores, 5), percentile(scores, 95))
+    for i, target := range problem.targets {
+        if !target.isMet(recipe.amountOf(problem.nutrientNameToId, target.nutrient)) || met[i] == samples {
+            continue
+        }
+        fmt.Printf("  %s met in %.0f%% of draws\n", target.nutrient, float64(met[i]) / float64(samples) * 100)
+    }
+}
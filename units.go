@@ -0,0 +1,181 @@
+package main
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+)
+
+// Scale of each mass unit relative to a gram
+var massUnits = map[string]float64{
+    "kg": 1000,
+    "g": 1,
+    "mg": 0.001,
+    "µg": 0.000001,
+}
+
+// Scale of each volume unit relative to a millilitre, for water, a ml of
+// which weighs a gram
+var volumeUnits = map[string]float64{
+    "l": 1000,
+    "ml": 1,
+}
+
+// Scale of each energy unit relative to a kilocalorie
+var energyUnits = map[string]float64{
+    "kcal": 1,
+    "kJ": 1 / 4.184,
+}
+
+// canonicalUnit maps the spellings people actually type onto the units used
+// in NUTR_DEF.txt.
+func canonicalUnit(unit string) string {
+    switch strings.ToLower(strings.TrimSpace(unit)) {
+    case "kg":
+        return "kg"
+    case "g", "gram", "grams":
+        return "g"
+    case "mg":
+        return "mg"
+    case "µg", "μg", "ug", "mcg":
+        return "µg"
+    case "l", "litre", "liter", "litres", "liters":
+        return "l"
+    case "ml":
+        return "ml"
+    case "kcal", "cal", "calories":
+        return "kcal"
+    case "kj":
+        return "kJ"
+    case "iu":
+        return "IU"
+    }
+    return strings.TrimSpace(unit)
+}
+
+// convertUnits converts amount from one unit to another, failing when the
+// two units don't measure the same kind of thing.
+func convertUnits(amount float64, from string, to string) (float64, error) {
+    from = canonicalUnit(from)
+    to = canonicalUnit(to)
+    if from == to {
+        return amount, nil
+    }
+    if fromScale, exists := massUnits[from]; exists {
+        if toScale, exists := massUnits[to]; exists {
+            return amount * fromScale / toScale, nil
+        }
+    }
+    if fromScale, exists := energyUnits[from]; exists {
+        if toScale, exists := energyUnits[to]; exists {
+            return amount * fromScale / toScale, nil
+        }
+    }
+    return 0, fmt.Errorf("cannot convert %s to %s", from, to)
+}
+
+// International units measure a vitamin's activity, so what an IU weighs
+// depends on the vitamin, and for vitamin A and E on its form. iuWeights
+// are what one IU is of each nutrient SR26 has by weight, for targets,
+// labels and food tables given in IU:
+//
+//     vitamin A   0.3 µg of retinol, which is 0.3 µg RAE, or 0.6 µg of
+//                 beta-carotene from a supplement
+//     vitamin D   0.025 µg, so 1 µg is 40 IU
+//     vitamin E   0.67 mg of natural alpha-tocopherol, 0.45 mg of the
+//                 synthetic form added to foods
+//
+// IU of vitamin A from food carotenoids isn't worth the same RAE at all,
+// 1 IU of dietary beta-carotene being 0.05 µg RAE, so foods' RAE are
+// worked out from their retinol and carotenoids instead, see
+// fillVitaminARAE.
+var iuWeights = map[string]struct {
+    amount float64
+    units string
+}{
+    "Vitamin A, RAE": {0.3, "µg"},
+    "Retinol": {0.3, "µg"},
+    "Carotene, beta": {0.6, "µg"},
+    "Vitamin D (D2 + D3)": {0.025, "µg"},
+    "Vitamin D2 (ergocalciferol)": {0.025, "µg"},
+    "Vitamin D3 (cholecalciferol)": {0.025, "µg"},
+    "Vitamin E (alpha-tocopherol)": {0.67, "mg"},
+    "Vitamin E, added": {0.45, "mg"},
+}
+
+// convertNutrientUnits is convertUnits for an amount of nutrient, which can
+// also convert IU to and from a weight for the vitamins in iuWeights, and a
+// volume of water to grams.
+func convertNutrientUnits(amount float64, from string, to string, nutrient string) (float64, error) {
+    from = canonicalUnit(from)
+    to = canonicalUnit(to)
+    if scale, exists := volumeUnits[from]; exists && nutrient == "Water" {
+        amount, from = amount * scale, "g"
+    }
+    if (from == "IU") == (to == "IU") {
+        return convertUnits(amount, from, to)
+    }
+    weight, exists := iuWeights[nutrient]
+    if !exists {
+        return 0, fmt.Errorf("cannot convert %s to %s for %s", from, to, nutrient)
+    }
+    if from == "IU" {
+        return convertUnits(amount * weight.amount, weight.units, to)
+    }
+    converted, err := convertUnits(amount, from, weight.units)
+    return converted / weight.amount, err
+}
+
+// parseAmount splits "24 g" or "24g" into 24 and "g".
+func parseAmount(input string) (float64, string, error) {
+    input = strings.TrimSpace(input)
+    split := len(input)
+    for i, r := range input {
+        if !(r >= '0' && r <= '9') && r != '.' && r != '-' {
+            split = i
+            break
+        }
+    }
+    amount, err := strconv.ParseFloat(input[:split], 64)
+    if err != nil {
+        return 0, "", fmt.Errorf("bad amount %q", input)
+    }
+    return amount, canonicalUnit(input[split:]), nil
+}
+
+// Retinol activity of carotenoids in food, in µg RAE per µg (IOM 2001)
+var raeFactors = map[string]float64{
+    "Retinol": 1,
+    "Carotene, beta": 1.0 / 12,
+    "Carotene, alpha": 1.0 / 24,
+    "Cryptoxanthin, beta": 1.0 / 24,
+}
+
+// fillVitaminARAE works out vitamin A RAE for foods that have retinol or
+// carotenoids but no RAE, as custom foods, labels and other tables often
+// don't. SR26 has RAE for everything it has carotenoids for, so its foods
+// are left alone.
+func fillVitaminARAE(nutrients map[int]Nutrient, nutrientNameToId map[string]int, foods map[int]Food) {
+    raeId, exists := nutrientNameToId["Vitamin A, RAE"]
+    if !exists {
+        return
+    }
+    for id, food := range foods {
+        rae := float64(0)
+        hasCarotenoids, hasRAE := false, false
+        for _, nutrientInFood := range food.nutrients {
+            if nutrientInFood.nutrient.id == raeId {
+                hasRAE = true
+            }
+            if factor, counts := raeFactors[nutrientInFood.nutrient.description]; counts {
+                rae += nutrientInFood.amountPerG * factor
+                hasCarotenoids = true
+            }
+        }
+        if hasRAE || !hasCarotenoids {
+            continue
+        }
+        food.nutrients = append(food.nutrients, NutrientInFood{nutrient: nutrients[raeId], amountPerG: rae, sourceCode: sourceCalculated})
+        foods[id] = food
+    }
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+    "bufio"
+    "bytes"
+    "encoding/csv"
+    "fmt"
+    "io"
+    "os"
+    "strconv"
+    "unicode/utf16"
+    "unicode/utf8"
+)
+
+// usdaFile reads one of the ^-separated, ~text~ SR files a row at a time.
+// Malformed rows don't stop the load: they're skipped, and a summary of
+// what was skipped and why is printed when the file is closed.
+type usdaFile struct {
+    name string
+    file io.Closer
+    reader *csv.Reader
+    line int // of the current row
+    skipped int
+    problems []string // the first few skipped rows, for the summary
+}
+
+// How many skipped rows the summary describes in full
+const maxReportedProblems = 10
+
+// fieldError is a problem with one field of one row.
+type fieldError struct {
+    file string
+    line int
+    field int // 1-based, as in the SR26 documentation
+    message string
+}
+
+func (err *fieldError) Error() string {
+    return fmt.Sprintf("%s line %d field %d: %s", err.file, err.line, err.field, err.message)
+}
+
+// openUSDAFile opens filename in dataDir, compressed or not, exiting with
+// download instructions if it isn't there.
+func openUSDAFile(dataDir string, filename string) *usdaFile {
+    inputFile, err := openDataFile(dataDir, filename)
+    if err != nil {
+        fmt.Println(tr("File not found. Download the USDA SR26 database from:"))
+        fmt.Println(sr26URL)
+        fmt.Println(tr("Extract it and point --data-dir at the extracted files, or run with --download"))
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(1)
+    }
+
+    csvReader := csv.NewReader(newTextReader(inputFile))
+    csvReader.Comma = '^'
+    csvReader.LazyQuotes = true
+    csvReader.FieldsPerRecord = -1
+    // Rows are parsed straight into foods and never kept, so one slice will do
+    csvReader.ReuseRecord = true
+
+    return &usdaFile{name: filename, file: inputFile, reader: csvReader}
+}
+
+// next returns the next row, or nil at the end of the file. Rows the CSV
+// reader can't make sense of are skipped.
+func (f *usdaFile) next() []string {
+    for {
+        record, err := f.reader.Read()
+        if err == io.EOF {
+            return nil
+        }
+        if err != nil {
+            if parseErr, ok := err.(*csv.ParseError); ok {
+                f.line = parseErr.Line
+            }
+            f.skip(fmt.Errorf("%s: %v", f.name, err))
+            continue
+        }
+        f.line, _ = f.reader.FieldPos(0)
+        return record
+    }
+}
+
+// skip records a row that was left out.
+func (f *usdaFile) skip(err error) {
+    f.skipped++
+    if len(f.problems) < maxReportedProblems {
+        f.problems = append(f.problems, err.Error())
+    }
+}
+
+func (f *usdaFile) fieldError(record []string, field int, format string, args ...interface{}) error {
+    return &fieldError{f.name, f.line, field + 1, fmt.Sprintf(format, args...)}
+}
+
+// text reads a ~text~ field.
+func (f *usdaFile) text(record []string, field int) (string, error) {
+    if field >= len(record) {
+        return "", f.fieldError(record, field, "missing, row has %d fields", len(record))
+    }
+    value := record[field]
+    if len(value) < 2 || value[0] != '~' || value[len(value) - 1] != '~' {
+        return "", f.fieldError(record, field, "expected ~text~, got %q", value)
+    }
+    return value[1:len(value) - 1], nil
+}
+
+// textInt reads a ~text~ field holding a number, like an NDB number.
+func (f *usdaFile) textInt(record []string, field int) (int, error) {
+    value, err := f.text(record, field)
+    if err != nil {
+        return 0, err
+    }
+    number, err := strconv.Atoi(value)
+    if err != nil {
+        return 0, f.fieldError(record, field, "expected a number, got %q", value)
+    }
+    return number, nil
+}
+
+// float reads a bare numeric field.
+func (f *usdaFile) float(record []string, field int) (float64, error) {
+    if field >= len(record) {
+        return 0, f.fieldError(record, field, "missing, row has %d fields", len(record))
+    }
+    number, err := strconv.ParseFloat(record[field], 64)
+    if err != nil {
+        return 0, f.fieldError(record, field, "expected a number, got %q", record[field])
+    }
+    return number, nil
+}
+
+// int reads a bare integer field.
+func (f *usdaFile) int(record []string, field int) (int, error) {
+    if field >= len(record) {
+        return 0, f.fieldError(record, field, "missing, row has %d fields", len(record))
+    }
+    number, err := strconv.Atoi(record[field])
+    if err != nil {
+        return 0, f.fieldError(record, field, "expected a whole number, got %q", record[field])
+    }
+    return number, nil
+}
+
+// close closes the file and reports any skipped rows.
+func (f *usdaFile) close() {
+    f.file.Close()
+    if f.skipped == 0 {
+        return
+    }
+    fmt.Printf("Skipped %d malformed rows in %s:\n", f.skipped, f.name)
+    for _, problem := range f.problems {
+        fmt.Println("  " + problem)
+    }
+    if f.skipped > len(f.problems) {
+        fmt.Printf("  and %d more\n", f.skipped - len(f.problems))
+    }
+}
+
+// newTextReader reads a data file as UTF-8 with \n line endings, however
+// it was saved: a byte order mark is dropped, UTF-16 from Windows editors is
+// converted, and so are \r\n and old Mac \r line endings, along with
+// Latin-1 as latin1Reader does.
+func newTextReader(file io.Reader) io.Reader {
+    buffered := bufio.NewReader(file)
+    bom, _ := buffered.Peek(3)
+    switch {
+    case len(bom) >= 3 && bom[0] == 0xEF && bom[1] == 0xBB && bom[2] == 0xBF:
+        buffered.Discard(3)
+    case len(bom) >= 2 && bom[0] == 0xFF && bom[1] == 0xFE:
+        buffered.Discard(2)
+        return &latin1Reader{reader: bufio.NewReader(&utf16Reader{reader: buffered, bigEndian: false})}
+    case len(bom) >= 2 && bom[0] == 0xFE && bom[1] == 0xFF:
+        buffered.Discard(2)
+        return &latin1Reader{reader: bufio.NewReader(&utf16Reader{reader: buffered, bigEndian: true})}
+    }
+    return &latin1Reader{reader: buffered}
+}
+
+// utf16Reader converts UTF-16 to UTF-8.
+type utf16Reader struct {
+    reader *bufio.Reader
+    bigEndian bool
+    pending []byte
+}
+
+func (r *utf16Reader) Read(p []byte) (int, error) {
+    for len(r.pending) == 0 {
+        unit, err := r.readUnit()
+        if err != nil {
+            return 0, err
+        }
+        char := rune(unit)
+        if utf16.IsSurrogate(char) {
+            second, err := r.readUnit()
+            if err != nil {
+                return 0, err
+            }
+            char = utf16.DecodeRune(char, rune(second))
+        }
+        r.pending = utf8.AppendRune(r.pending[:0], char)
+    }
+    n := copy(p, r.pending)
+    r.pending = r.pending[n:]
+    return n, nil
+}
+
+func (r *utf16Reader) readUnit() (uint16, error) {
+    var pair [2]byte
+    if _, err := io.ReadFull(r.reader, pair[:]); err != nil {
+        if err == io.ErrUnexpectedEOF {
+            err = io.EOF
+        }
+        return 0, err
+    }
+    if r.bigEndian {
+        return uint16(pair[0]) << 8 | uint16(pair[1]), nil
+    }
+    return uint16(pair[1]) << 8 | uint16(pair[0]), nil
+}
+
+// latin1Reader passes UTF-8 through and converts everything else from
+// Latin-1, which is what SR26 is written in, a line at a time. Files saved
+// as UTF-8, like the fixture, are left alone, so "Crème" comes out right
+// either way.
+type latin1Reader struct {
+    reader *bufio.Reader
+    pending []byte // converted but not yet read
+}
+
+func (r *latin1Reader) Read(p []byte) (int, error) {
+    for len(r.pending) == 0 {
+        line, err := r.reader.ReadSlice('\n')
+        if err == bufio.ErrBufferFull {
+            // A line longer than the buffer goes through in pieces, which
+            // only matters if a piece splits a UTF-8 character
+            err = nil
+        }
+        if len(line) > 0 {
+            r.pending = append(r.pending[:0], normalizeLineEndings(decodeLatin1(line))...)
+        }
+        if err != nil && len(r.pending) == 0 {
+            return 0, err
+        }
+        if err != nil {
+            break
+        }
+    }
+    n := copy(p, r.pending)
+    r.pending = r.pending[n:]
+    return n, nil
+}
+
+// normalizeLineEndings turns \r\n and lone \r into \n. A file with only
+// \r endings comes through as one long line, which this splits.
+func normalizeLineEndings(line []byte) []byte {
+    if bytes.IndexByte(line, '\r') < 0 {
+        return line
+    }
+    return bytes.ReplaceAll(bytes.ReplaceAll(line, []byte("\r\n"), []byte("\n")), []byte("\r"), []byte("\n"))
+}
+
+// decodeLatin1 returns line as UTF-8, converting it from Latin-1 unless it
+// already is UTF-8.
+func decodeLatin1(line []byte) []byte {
+    if utf8.Valid(line) {
+        return line
+    }
+    decoded := make([]byte, 0, len(line) + 8)
+    for _, b := range line {
+        decoded = utf8.AppendRune(decoded, rune(b))
+    }
+    return decoded
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "math"
+    "os"
+    "sort"
+)
+
+// After loading, the data is checked for things that would quietly skew
+// recipes: units that can't be converted, foods with nothing measured,
+// negative amounts, and energy that doesn't add up from the macros. A
+// summary is printed, --validation-report writes every issue as JSON, and
+// --quarantine keeps suspect foods out of recipes.
+
+const (
+    issueUnknownUnits = "unknown-units"
+    issueRenamedNutrient = "renamed-nutrient"
+    issueNoNutrients = "no-nutrients"
+    issueNegativeAmount = "negative-amount"
+    issueEnergyMismatch = "energy-mismatch"
+)
+
+// Energy is only flagged when it's off from 4/4/9 by both of these, per
+// 100 g. SR26 uses food specific Atwater factors, so small differences are
+// expected.
+const energyMismatchPct = 25
+const energyMismatchKcal = 25
+
+type validationIssue struct {
+    Kind string `json:"kind"`
+    FoodId int `json:"food_id,omitempty"`
+    NutrientId int `json:"nutrient_id,omitempty"`
+    Message string `json:"message"`
+}
+
+// quarantines reports whether the issue makes its food suspect.
+func (issue validationIssue) quarantines() bool {
+    return issue.FoodId != 0
+}
+
+// validateData checks the loaded nutrients and foods, returning the issues
+// ordered by kind and then id.
+func validateData(nutrients map[int]Nutrient, nutrientNameToId map[string]int, foods map[int]Food) []validationIssue {
+    var issues []validationIssue
+    for _, id := range sortedNutrientIds(nutrients) {
+        nutrient := nutrients[id]
+        if !isKnownUnit(nutrient.units) {
+            issues = append(issues, validationIssue{issueUnknownUnits, 0, id,
+                fmt.Sprintf("%s is in %q, which targets can't be converted to", nutrient.description, nutrient.units)})
+        }
+    }
+    for _, line := range renamedNutrients(nutrients) {
+        issues = append(issues, validationIssue{Kind: issueRenamedNutrient, Message: line})
+    }
+
+    foodIds := make([]int, 0, len(foods))
+    for id := range foods {
+        foodIds = append(foodIds, id)
+    }
+    sort.Ints(foodIds)
+    for _, id := range foodIds {
+        food := foods[id]
+        if len(food.nutrients) == 0 {
+            issues = append(issues, validationIssue{issueNoNutrients, id, 0,
+                fmt.Sprintf("%s has no nutrient values", food.description)})
+            continue
+        }
+        for _, nutrientInFood := range food.nutrients {
+            if nutrientInFood.amountPerG < 0 {
+                issues = append(issues, validationIssue{issueNegativeAmount, id, nutrientInFood.nutrient.id,
+                    fmt.Sprintf("%s has %.2f%s of %s per 100 g", food.description, nutrientInFood.amountPerG * 100,
+                        nutrientInFood.nutrient.units, nutrientInFood.nutrient.description)})
+            }
+        }
+        if message := energyMismatch(&food, nutrientNameToId); message != "" {
+            issues = append(issues, validationIssue{issueEnergyMismatch, id, nutrientNameToId[energyNutrient], message})
+        }
+    }
+
+    sort.SliceStable(issues, func(i, j int) bool {
+        return issues[i].Kind < issues[j].Kind
+    })
+    return issues
+}
+
+func isKnownUnit(units string) bool {
+    _, mass := massUnits[units]
+    _, energy := energyUnits[units]
+    return mass || energy || units == "IU"
+}
+
+// energyMismatch compares a food's energy with 4 kcal per gram of protein
+// and carbohydrate and 9 per gram of fat, returning what's wrong or "".
+// Foods missing any of the four values aren't checked.
+func energyMismatch(food *Food, nutrientNameToId map[string]int) string {
+    amounts := make(map[string]float64, 4)
+    for _, name := range []string{energyNutrient, "Protein", "Carbohydrate, by difference", "Total lipid (fat)"} {
+        id, exists := nutrientNameToId[name]
+        if !exists {
+            return ""
+        }
+        found := false
+        for _, nutrientInFood := range food.nutrients {
+            if nutrientInFood.nutrient.id == id {
+                amounts[name] = nutrientInFood.amountPerG * 100
+                found = true
+                break
+            }
+        }
+        if !found {
+            return ""
+        }
+    }
+    fromMacros := 4 * amounts["Protein"] + 4 * amounts["Carbohydrate, by difference"] + 9 * amounts["Total lipid (fat)"]
+    difference := math.Abs(amounts[energyNutrient] - fromMacros)
+    if difference <= energyMismatchKcal || difference <= fromMacros * energyMismatchPct / 100 {
+        return ""
+    }
+    return fmt.Sprintf("%s has %.0f kcal per 100 g but its macros add up to %.0f", food.description, amounts[energyNutrient], fromMacros)
+}
+
+// validateFoods validates the data, prints a summary, writes the report if
+// asked to, and returns foods less the quarantined ones when --quarantine
+// is given. Custom foods are reported but never quarantined.
+func validateFoods(opts *Options, nutrients map[int]Nutrient, nutrientNameToId map[string]int, foods map[int]Food) map[int]Food {
+    issues := validateData(nutrients, nutrientNameToId, foods)
+
+    counts := make(map[string]int)
+    var kinds []string
+    for _, issue := range issues {
+        if counts[issue.Kind] == 0 {
+            kinds = append(kinds, issue.Kind)
+        }
+        counts[issue.Kind]++
+    }
+    for _, kind := range kinds {
+        fmt.Printf("Validation: %d %s\n", counts[kind], kind)
+    }
+
+    if opts.validationReportPath != "" {
+        report := struct {
+            Counts map[string]int `json:"counts"`
+            Issues []validationIssue `json:"issues"`
+        }{counts, issues}
+        data, err := json.MarshalIndent(report, "", "  ")
+        if err != nil {
+            panic(err)
+        }
+        if err := os.WriteFile(opts.validationReportPath, append(data, '\n'), 0644); err != nil {
+            panic(err)
+        }
+        fmt.Println("Wrote validation report to", opts.validationReportPath)
+    }
+
+    if !opts.quarantine {
+        return foods
+    }
+    suspect := make(map[int]bool)
+    for _, issue := range issues {
+        if issue.quarantines() && foods[issue.FoodId].foodGroup != customFoodGroup {
+            suspect[issue.FoodId] = true
+        }
+    }
+    if len(suspect) == 0 {
+        return foods
+    }
+    kept := make(map[int]Food, len(foods))
+    for id, food := range foods {
+        if !suspect[id] {
+            kept[id] = food
+        }
+    }
+    fmt.Printf("Quarantined %d suspect foods\n", len(suspect))
+    return kept
+}
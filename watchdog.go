@@ -0,0 +1,159 @@
+package main
+
+import (
+    "fmt"
+    "os"
+    "runtime"
+    "runtime/pprof"
+    "sort"
+    "strconv"
+    "strings"
+    "sync/atomic"
+    "syscall"
+    "time"
+)
+
+// A long run over a big dataset, say with --moves swap, can grow its heap
+// for hours. --max-heap-mb watches it: every watchdogInterval the heap is
+// checked, and each time it's grown by half since last reported that's
+// logged to stderr. Past the cap, the places holding the most memory are
+// logged, and at the end of the hill climber's round the best recipe so far
+// is written to --checkpoint and the process starts over in place, with
+// the same flags and --start-recipe as the checkpoint, so it carries on
+// from there with a fresh heap. After maxWatchdogRestarts restarts it stops
+// instead, leaving the checkpoint to carry on from by hand.
+//
+// The restarted run's recipe is re-optimized from the checkpoint, so its
+// lineage doesn't go back past it, and a --trace starts again from it.
+
+const watchdogInterval = 5 * time.Second
+
+const maxWatchdogRestarts = 3
+
+// The environment variable counting restarts
+const watchdogRestartsEnv = "SUPERSHAKE_WATCHDOG_RESTARTS"
+
+type heapWatchdog struct {
+    capBytes uint64
+    checkpointPath string
+    over atomic.Bool
+}
+
+// startHeapWatchdog starts watching the heap, nil without --max-heap-mb.
+func startHeapWatchdog(opts *Options) *heapWatchdog {
+    if opts.maxHeapMB <= 0 {
+        return nil
+    }
+    watchdog := &heapWatchdog{capBytes: uint64(opts.maxHeapMB) << 20, checkpointPath: opts.checkpointPath}
+    go watchdog.watch()
+    return watchdog
+}
+
+func (watchdog *heapWatchdog) watch() {
+    ticker := time.NewTicker(watchdogInterval)
+    defer ticker.Stop()
+    reported := uint64(0)
+    for range ticker.C {
+        var stats runtime.MemStats
+        runtime.ReadMemStats(&stats)
+        if stats.HeapAlloc > reported * 3 / 2 {
+            fmt.Fprintf(os.Stderr, "Watchdog: heap at %d MB of %d MB\n", stats.HeapAlloc >> 20, watchdog.capBytes >> 20)
+            reported = stats.HeapAlloc
+        }
+        if stats.HeapAlloc > watchdog.capBytes && !watchdog.over.Load() {
+            fmt.Fprintf(os.Stderr, "Watchdog: heap at %d MB, over --max-heap-mb, held by:\n", stats.HeapAlloc >> 20)
+            printAllocationHotSpots(5)
+            watchdog.over.Store(true)
+        }
+    }
+}
+
+// overCap is whether the heap has gone past --max-heap-mb.
+func (watchdog *heapWatchdog) overCap() bool {
+    return watchdog != nil && watchdog.over.Load()
+}
+
+// printAllocationHotSpots logs the n functions that allocated the most of
+// what's still in use, going by the runtime's sampled memory profile.
+func printAllocationHotSpots(n int) {
+    var records []runtime.MemProfileRecord
+    count, _ := runtime.MemProfile(nil, true)
+    for {
+        records = make([]runtime.MemProfileRecord, count + 50)
+        var ok bool
+        count, ok = runtime.MemProfile(records, true)
+        if ok {
+            records = records[:count]
+            break
+        }
+    }
+
+    inUse := make(map[string]int64)
+    for _, record := range records {
+        frames := runtime.CallersFrames(record.Stack())
+        for {
+            frame, more := frames.Next()
+            // Charge it to the first function that isn't the runtime's
+            if !more || !strings.HasPrefix(frame.Function, "runtime.") && !strings.HasPrefix(frame.Function, "internal/") {
+                inUse[fmt.Sprintf("%s (%s:%d)", frame.Function, frame.File, frame.Line)] += record.InUseBytes()
+                break
+            }
+        }
+    }
+    var sites []string
+    for site := range inUse {
+        sites = append(sites, site)
+    }
+    sort.Slice(sites, func(i, j int) bool { return inUse[sites[i]] > inUse[sites[j]] })
+    for i, site := range sites {
+        if i == n {
+            break
+        }
+        fmt.Fprintf(os.Stderr, "  %8.1f MB  %s\n", float64(inUse[site]) / (1 << 20), site)
+    }
+}
+
+// restart checkpoints best and starts the process over from it, or if it
+// can't, exits.
+func (watchdog *heapWatchdog) restart(problem *Problem, best *Recipe) {
+    data, err := recipeJSON(problem, best)
+    if err == nil {
+        err = os.WriteFile(watchdog.checkpointPath, data, 0644)
+    }
+    if err != nil {
+        fmt.Fprintln(os.Stderr, "Watchdog: couldn't write the checkpoint:", err)
+        os.Exit(1)
+    }
+    if problem.trace != nil {
+        problem.trace.Close()
+    }
+    fmt.Fprintf(os.Stderr, "Watchdog: wrote the best recipe so far to %s\n", watchdog.checkpointPath)
+
+    restarts, _ := strconv.Atoi(os.Getenv(watchdogRestartsEnv))
+    if restarts >= maxWatchdogRestarts {
+        fmt.Fprintf(os.Stderr, "Watchdog: already restarted %d times, carry on with --start-recipe %s\n", restarts, watchdog.checkpointPath)
+        os.Exit(1)
+    }
+    executable, err := os.Executable()
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Watchdog: can't restart, %v, carry on with --start-recipe %s\n", err, watchdog.checkpointPath)
+        os.Exit(1)
+    }
+    // Later flags win, and --surprise can't start from a recipe
+    args := append(append([]string{}, os.Args...), "--start-recipe=" + watchdog.checkpointPath, "--surprise=0")
+    var env []string
+    for _, variable := range os.Environ() {
+        if !strings.HasPrefix(variable, watchdogRestartsEnv + "=") {
+            env = append(env, variable)
+        }
+    }
+    env = append(env, fmt.Sprintf("%s=%d", watchdogRestartsEnv, restarts + 1))
+    fmt.Fprintf(os.Stderr, "Watchdog: restarting from the checkpoint, restart %d of %d\n", restarts + 1, maxWatchdogRestarts)
+    // The profiling timer would outlive the exec and kill the new process
+    // before it can handle the signal
+    pprof.StopCPUProfile()
+    err = syscall.Exec(executable, args, env)
+    // Only back here if it failed, as it does on Windows
+    fmt.Fprintf(os.Stderr, "Watchdog: can't restart, %v, carry on with --start-recipe %s\n", err, watchdog.checkpointPath)
+    os.Exit(1)
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "os"
+    "strconv"
+    "strings"
+)
+
+// "supershake week" plans a recipe for each day of the week, one optimization
+// per day, keeping to rules on how often a food may or must appear, like
+// "sardines<=2" (at most two days) or "flax>=7" (every day). A rule names a
+// food id, a --diet tag such as fish, or text in the description.
+
+type frequencyRule struct {
+    text string // as given, for messages
+    subject string
+    foodId int // when subject is a food id, else 0
+    atMost bool // <= rather than >=
+    days int
+}
+
+func parseFrequencyRule(text string) (frequencyRule, error) {
+    rule := frequencyRule{text: text}
+    operator := "<="
+    if strings.Contains(text, ">=") {
+        operator = ">="
+    }
+    parts := strings.SplitN(text, operator, 2)
+    if len(parts) != 2 {
+        return rule, fmt.Errorf("bad --frequency %q, want food<=days or food>=days", text)
+    }
+    rule.subject = strings.ToLower(strings.TrimSpace(parts[0]))
+    rule.atMost = operator == "<="
+    days, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+    if err != nil || days < 0 || rule.subject == "" {
+        return rule, fmt.Errorf("bad --frequency %q, want food<=days or food>=days", text)
+    }
+    rule.days = days
+    rule.foodId, _ = strconv.Atoi(rule.subject)
+    return rule, nil
+}
+
+// matches reports whether the rule is about food.
+func (rule *frequencyRule) matches(opts *Options, food *Food) bool {
+    if rule.foodId != 0 {
+        return food.id == rule.foodId
+    }
+    for _, tag := range foodTags(opts, food) {
+        if tag == rule.subject {
+            return true
+        }
+    }
+    return strings.Contains(strings.ToLower(food.description), rule.subject)
+}
+
+// appearsIn reports whether any food the rule is about is in recipe.
+func (rule *frequencyRule) appearsIn(problem *Problem, recipe *Recipe) bool {
+    for foodId := range recipe.foodQuantities {
+        food := problem.foods[foodId]
+        if rule.matches(problem.opts, &food) {
+            return true
+        }
+    }
+    return false
+}
+
+// planDay optimizes one day's recipe given how many days each rule's
+// foods have appeared so far: foods whose limit is used up are left out,
+// and a rule that needs every remaining day gets a food required.
+func planDay(problem *Problem, rules []frequencyRule, counts []int, daysLeft int) (*Recipe, float64, error) {
+    opts := problem.opts
+    foods := make(map[int]Food, len(problem.foods))
+    for id, food := range problem.foods {
+        keep := true
+        for i := range rules {
+            if rules[i].atMost && counts[i] >= rules[i].days && rules[i].matches(opts, &food) {
+                keep = false
+            }
+        }
+        if keep {
+            foods[id] = food
+        }
+    }
+    day := *problem
+    day.foods = foods
+    day.required = make(map[int]int)
+    for id, grams := range problem.required {
+        day.required[id] = grams
+    }
+
+    recipe, score := optimize(&day, NewRecipe(day.foods, day.nutrients), false)
+    for i := range rules {
+        rule := &rules[i]
+        if rule.atMost || rule.days - counts[i] < daysLeft || rule.appearsIn(&day, recipe) {
+            continue
+        }
+        // Require whichever of its foods does the recipe the least harm
+        best, bestScore := 0, 0.0
+        for id, food := range day.foods {
+            if !rule.matches(opts, &food) {
+                continue
+            }
+            recipe.AddFood(day.foods, &food, opts.stepSize)
+            if score := recipe.Score(&day, false); best == 0 || score < bestScore {
+                best, bestScore = id, score
+            }
+            recipe.RemoveFood(day.foods, &food, opts.stepSize)
+        }
+        if best == 0 {
+            return nil, 0, fmt.Errorf("--frequency %s: no food the optimizer may use matches", rule.text)
+        }
+        day.required[best] = opts.stepSize
+    }
+    if len(day.required) > 0 {
+        start := recipe.Clone(day.foods, day.nutrients)
+        for id, grams := range day.required {
+            if start.foodQuantities[id] < grams {
+                food := day.foods[id]
+                start.AddFood(day.foods, &food, grams - start.foodQuantities[id])
+            }
+        }
+        recipe, score = optimize(&day, start, false)
+    }
+    if err := recipe.feasible(&day); err != nil {
+        return nil, 0, err
+    }
+    return recipe, score, nil
+}
+
+// runWeek handles "supershake week".
+func runWeek(args []string) {
+    fs := flag.NewFlagSet("supershake week", flag.ExitOnError)
+    opts := newOptions(fs)
+    days := fs.Int("days", 7, "how many days to plan")
+    var frequencies stringList
+    fs.Var(&frequencies, "frequency", "how many days a food, --diet tag or description text may appear, as \"sardines<=2\", or must, as \"flax>=7\" (repeatable)")
+    fs.Parse(args)
+    applyProfile(fs, opts)
+
+    var rules []frequencyRule
+    for _, text := range frequencies {
+        rule, err := parseFrequencyRule(text)
+        if err == nil && !rule.atMost && rule.days > *days {
+            err = fmt.Errorf("--frequency %s can't be met in %d days", text, *days)
+        }
+        if err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            os.Exit(2)
+        }
+        rules = append(rules, rule)
+    }
+
+    fmt.Println(tr("Loading"))
+    problem := loadProblem(opts)
+    counts := make([]int, len(rules))
+    for day := 1; day <= *days; day++ {
+        recipe, score, err := planDay(problem, rules, counts, *days - day + 1)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Day %d: %v\n", day, err)
+            os.Exit(1)
+        }
+        for i := range rules {
+            if rules[i].appearsIn(problem, recipe) {
+                counts[i]++
+            }
+        }
+
+        fmt.Printf("\nDay %d, score %.2f\n", day, score)
+        for _, foodId := range recipe.FoodIdsByGrams() {
+            food := problem.foods[foodId]
+            fmt.Printf("  %s of %s\n", food.gramsString(recipe.foodQuantities[foodId]), food.description)
+        }
+    }
+
+    if len(rules) > 0 {
+        fmt.Println("\nFREQUENCY")
+        for i, rule := range rules {
+            fmt.Printf("  %s: %d of %d days\n", rule.text, counts[i], *days)
+        }
+    }
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+    "bytes"
+    "flag"
+    "fmt"
+    htmltemplate "html/template"
+    "os"
+    "strings"
+    texttemplate "text/template"
+    "time"
+)
+
+// The weekly report summarizes the intake log: average intake against the
+// targets, what was missed, and what the gap analyzer would change. It comes
+// out as Markdown, HTML, or an HTML email ready for sendmail -t.
+
+type weeklyReport struct {
+    From string
+    To string
+    DaysLogged int
+    Days int
+    Rows []weeklyReportRow
+    Missed []string
+    Suggestions []string
+}
+
+type weeklyReportRow struct {
+    Status string
+    Nutrient string
+    Amount string
+    Want string
+}
+
+const weeklyMarkdown = `# Intake {{.From}} to {{.To}}
+
+{{.DaysLogged}} of {{.Days}} days logged.
+{{if .Missed}}
+## Missed targets
+{{range .Missed}}
+- {{.}}{{end}}
+{{else}}
+Every target met.
+{{end}}{{if .Suggestions}}
+## Suggested tweaks
+{{range .Suggestions}}
+- {{.}}{{end}}
+{{end}}
+## Average daily intake
+
+| | Nutrient | Average | Target |
+|---|---|---|---|
+{{range .Rows}}| {{.Status}} | {{.Nutrient}} | {{.Amount}} | {{.Want}} |
+{{end}}`
+
+const weeklyHTML = `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Intake {{.From}} to {{.To}}</title></head>
+<body>
+<h1>Intake {{.From}} to {{.To}}</h1>
+<p>{{.DaysLogged}} of {{.Days}} days logged.</p>
+{{if .Missed}}<h2>Missed targets</h2>
+<ul>{{range .Missed}}
+<li>{{.}}</li>{{end}}
+</ul>{{else}}<p>Every target met.</p>{{end}}
+{{if .Suggestions}}<h2>Suggested tweaks</h2>
+<ul>{{range .Suggestions}}
+<li>{{.}}</li>{{end}}
+</ul>{{end}}
+<h2>Average daily intake</h2>
+<table>
+<tr><th></th><th>Nutrient</th><th>Average</th><th>Target</th></tr>{{range .Rows}}
+<tr><td>{{.Status}}</td><td>{{.Nutrient}}</td><td>{{.Amount}}</td><td>{{.Want}}</td></tr>{{end}}
+</table>
+</body></html>
+`
+
+func buildWeeklyReport(problem *Problem, entries []intakeEntry, end time.Time, days int, candidates map[int]Food) weeklyReport {
+    from := end.AddDate(0, 0, -(days - 1)).Format(dateFormat)
+    to := end.Format(dateFormat)
+    average, daysLogged := intakeAverage(problem, entries, from, to)
+
+    report := weeklyReport{From: from, To: to, DaysLogged: daysLogged, Days: days}
+    if daysLogged == 0 {
+        return report
+    }
+    for _, target := range problem.targets {
+        amount := average.amountOf(problem.nutrientNameToId, target.nutrient)
+        status := "ok"
+        if !target.isMet(amount) {
+            status = "HIGH"
+            if amount < target.min {
+                status = "LOW"
+            }
+            report.Missed = append(report.Missed, fmt.Sprintf("%s: %.2f, want %s", target.nutrient, amount, target.rangeString()))
+        }
+        report.Rows = append(report.Rows, weeklyReportRow{status, target.nutrient, fmt.Sprintf("%.2f", amount), target.rangeString()})
+    }
+    for _, suggestion := range analyzeGaps(problem, average, candidates) {
+        report.Suggestions = append(report.Suggestions, suggestion.String())
+    }
+    return report
+}
+
+func renderWeeklyReport(report weeklyReport, format string, to string) ([]byte, error) {
+    var out bytes.Buffer
+    switch format {
+    case "markdown":
+        tmpl := texttemplate.Must(texttemplate.New("weekly").Parse(weeklyMarkdown))
+        err := tmpl.Execute(&out, report)
+        return out.Bytes(), err
+    case "html":
+        tmpl := htmltemplate.Must(htmltemplate.New("weekly").Parse(weeklyHTML))
+        err := tmpl.Execute(&out, report)
+        return out.Bytes(), err
+    case "email":
+        if to == "" {
+            return nil, fmt.Errorf("--format email needs --to")
+        }
+        body, err := renderWeeklyReport(report, "html", "")
+        if err != nil {
+            return nil, err
+        }
+        fmt.Fprintf(&out, "To: %s\r\n", to)
+        fmt.Fprintf(&out, "Subject: supershake intake %s to %s\r\n", report.From, report.To)
+        out.WriteString("MIME-Version: 1.0\r\n")
+        out.WriteString("Content-Type: text/html; charset=utf-8\r\n\r\n")
+        out.WriteString(strings.ReplaceAll(string(body), "\n", "\r\n"))
+        return out.Bytes(), nil
+    }
+    return nil, fmt.Errorf("unknown format %q, want markdown, html or email", format)
+}
+
+// runLogWeekly handles "supershake log weekly".
+func runLogWeekly(args []string) {
+    fs := flag.NewFlagSet("supershake log weekly", flag.ExitOnError)
+    opts := newOptions(fs)
+    logPath := fs.String("log", "intake_log.csv", "intake log file")
+    date := fs.String("date", time.Now().Format(dateFormat), "last day of the week, YYYY-MM-DD")
+    days := fs.Int("days", 7, "days the report covers")
+    format := fs.String("format", "markdown", "markdown, html, or email (a message for sendmail -t)")
+    to := fs.String("to", "", "recipient for --format email")
+    outPath := fs.String("out", "", "file to write the report to, default stdout")
+    fs.Parse(args)
+
+    end, err := time.Parse(dateFormat, *date)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, tr("Bad --date %q, want YYYY-MM-DD\n"), *date)
+        os.Exit(2)
+    }
+    entries, err := readIntakeLog(*logPath, opts.passphrase())
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(1)
+    }
+
+    nutrients, nutrientNameToId, allFoods := loadNutrientsAndFoods(opts)
+    problem := newProblem(opts, nutrients, nutrientNameToId, allFoods)
+    report := buildWeeklyReport(problem, entries, end, *days, filterFoods(opts, allFoods))
+
+    rendered, err := renderWeeklyReport(report, *format, *to)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(2)
+    }
+    if *outPath == "" {
+        os.Stdout.Write(rendered)
+        return
+    }
+    if err := os.WriteFile(*outPath, rendered, 0644); err != nil {
+        panic(err)
+    }
+    fmt.Println("Wrote", *outPath)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "os"
+    "strconv"
+    "strings"
+)
+
+// "supershake why-excluded" says which rules keep a food out of recipes.
+// keepFood stops at the first rule, so this checks every one of them, and
+// the steps after it (--facet, --quarantine, --imputed, --minimize cost),
+// to find all that would need relaxing.
+
+// findFood looks a food up by id or description, falling back on the
+// closest description.
+func findFood(foods map[int]Food, name string) (Food, bool) {
+    if id, err := strconv.Atoi(name); err == nil {
+        food, exists := foods[id]
+        return food, exists
+    }
+    for _, food := range foods {
+        if strings.EqualFold(food.description, name) {
+            return food, true
+        }
+    }
+    food, similarity := newFoodMatcher(foods).match(name)
+    if similarity == 0 {
+        return food, false
+    }
+    fmt.Printf("No food is called %q, the closest is %d %s\n", name, food.id, food.description)
+    return food, true
+}
+
+// whyExcluded returns every reason food can't go into a recipe, empty if
+// it can.
+func whyExcluded(opts *Options, nutrients map[int]Nutrient, nutrientNameToId map[string]int, food Food) []string {
+    var reasons []string
+    if whitelist := opts.onlyFoods(); whitelist != nil && !whitelist.allows(&food) {
+        reasons = append(reasons, fmt.Sprintf("%s: it isn't listed in %s", ruleOnlyFoods, whitelist.path))
+    }
+    if rejected := opts.rejected(); rejected != nil && rejected.rejects(&food) {
+        reasons = append(reasons, fmt.Sprintf("%s: it was rejected, \"supershake reject --undo %d\" allows it again", ruleRejected, food.id))
+    }
+
+    if food.foodGroup == customFoodGroup {
+        fmt.Println("It's a custom food, so the filters don't apply to it")
+    } else {
+        if !opts.foodGroupFilter().allows(food.foodGroup) {
+            reasons = append(reasons, fmt.Sprintf("%s: it's in food group %s %s", ruleFoodGroup, food.foodGroup, foodGroupName(food.foodGroup)))
+        }
+        for _, diet := range opts.dietNames() {
+            for _, ruledOut := range diets[diet] {
+                for _, tag := range foodTags(opts, &food) {
+                    if tag == ruledOut {
+                        reasons = append(reasons, fmt.Sprintf("%s %s: it's tagged %s", ruleDiet, diet, tag))
+                    }
+                }
+            }
+        }
+        if opts.maxNova > 0 && novaClass(&food) > opts.maxNova {
+            reasons = append(reasons, fmt.Sprintf("%s %d: it's NOVA class %d", ruleMaxNova, opts.maxNova, novaClass(&food)))
+        }
+        if !canBlend(opts.blender, &food) {
+            reasons = append(reasons, fmt.Sprintf("%s %s: it's too hard for that blender", ruleBlender, opts.blender))
+        }
+        if opts.maxPrepMinutes > 0 && !isCooked(opts, food.id) && prepMinutes(&food) > opts.maxPrepMinutes {
+            reasons = append(reasons, fmt.Sprintf("%s %d: it takes %d minutes to prepare", ruleMaxPrep, opts.maxPrepMinutes, prepMinutes(&food)))
+        }
+        if overPrice(opts, &food) {
+            reasons = append(reasons, fmt.Sprintf("%s %g: it costs %.2f per kg in %s", ruleMaxPrice, opts.maxPricePerKg, opts.prices()[food.id] * 1000, opts.pricesPath))
+        }
+        list := opts.exclusions()
+        for i := range list.exclusions {
+            rule := &list.exclusions[i]
+            if value := rule.matchedValue(&food); value != "" {
+                reason := fmt.Sprintf("%s, rule %d: %s %q", list.source, i + 1, rule.kind, value)
+                if rule.preset != "" {
+                    reason = fmt.Sprintf("%s %s: %s %q", rulePreset, rule.preset, rule.kind, value)
+                }
+                if rule.reason != "" {
+                    reason += " (" + rule.reason + ")"
+                }
+                reasons = append(reasons, reason)
+            }
+        }
+    }
+
+    if kept, err := filterByFacets(opts, map[int]Food{food.id: food}); err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(2)
+    } else if len(kept) == 0 {
+        reasons = append(reasons, ruleFacets + ": its LanguaL facets don't fit")
+    }
+
+    if opts.quarantine && food.foodGroup != customFoodGroup {
+        for _, issue := range validateData(nutrients, nutrientNameToId, map[int]Food{food.id: food}) {
+            if issue.quarantines() {
+                reasons = append(reasons, fmt.Sprintf("--quarantine: %s", issue.Message))
+            }
+        }
+    }
+    policies, err := newImputedPolicies(opts, nutrientNameToId)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(2)
+    }
+    if applyImputedPolicy(policies, map[int]Food{food.id: food}) > 0 {
+        reasons = append(reasons, "--imputed exclude-food: some of its values are imputed")
+    }
+    if opts.minimize == costObjective {
+        prices, err := readPrices(opts.pricesPath)
+        if err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            os.Exit(2)
+        }
+        if _, priced := prices[food.id]; !priced {
+            reasons = append(reasons, fmt.Sprintf("--minimize cost: it has no price in %s", opts.pricesPath))
+        }
+    }
+    return reasons
+}
+
+// runWhyExcluded handles "supershake why-excluded".
+func runWhyExcluded(args []string) {
+    fs := flag.NewFlagSet("supershake why-excluded", flag.ExitOnError)
+    opts := newOptions(fs)
+    fs.Parse(args)
+    applyProfile(fs, opts)
+    if fs.NArg() != 1 {
+        fmt.Fprintln(os.Stderr, "Usage: supershake why-excluded [flags] \"food description\" or id")
+        os.Exit(2)
+    }
+    if err := checkBlender(opts.blender); err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(2)
+    }
+    if err := checkDiets(opts); err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(2)
+    }
+
+    fmt.Println(tr("Loading"))
+    nutrients, nutrientNameToId, allFoods := loadNutrientsAndFoods(opts)
+    food, found := findFood(allFoods, fs.Arg(0))
+    if !found {
+        fmt.Printf("No food matches %q. It may not be in the data loaded, or may have been dropped as a duplicate of one from a higher priority source.\n", fs.Arg(0))
+        os.Exit(1)
+    }
+
+    fmt.Printf("%d %s (food group %s %s)\n", food.id, food.description, food.foodGroup, foodGroupName(food.foodGroup))
+    if tags := foodTags(opts, &food); len(tags) > 0 {
+        fmt.Printf("Tagged %s\n", strings.Join(tags, ", "))
+    }
+    reasons := whyExcluded(opts, nutrients, nutrientNameToId, food)
+    if len(reasons) == 0 {
+        fmt.Println("Not excluded, the optimizer may use it")
+        return
+    }
+    fmt.Println("Excluded by:")
+    for _, reason := range reasons {
+        fmt.Println("  " + reason)
+    }
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+)
+
+// A small YAML reader, enough for supershake's config files without pulling
+// in a dependency. It handles block maps and sequences, flow [lists] and
+// {maps}, quoted and plain scalars, and comments. Anchors, multi-line
+// strings, tags and multiple documents aren't supported.
+//
+// Maps come back as map[string]interface{}, sequences as []interface{} and
+// every scalar as a string, for the caller to convert.
+
+type yamlLine struct {
+    number int
+    indent int
+    text string
+}
+
+type yamlParser struct {
+    lines []yamlLine
+    pos int
+}
+
+// parseYAML parses a document. An empty document is nil.
+func parseYAML(data string) (interface{}, error) {
+    parser := &yamlParser{}
+    for i, line := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+        if strings.ContainsRune(line, '\t') && strings.TrimLeft(line, " \t") != strings.TrimLeft(line, " ") {
+            return nil, fmt.Errorf("line %d: indent with spaces, not tabs", i + 1)
+        }
+        text := strings.TrimRight(stripYAMLComment(line), " \t")
+        trimmed := strings.TrimLeft(text, " ")
+        if trimmed == "" || trimmed == "---" {
+            continue
+        }
+        parser.lines = append(parser.lines, yamlLine{i + 1, len(text) - len(trimmed), trimmed})
+    }
+    if len(parser.lines) == 0 {
+        return nil, nil
+    }
+    value, err := parser.parseNode(parser.lines[0].indent)
+    if err != nil {
+        return nil, err
+    }
+    if parser.pos < len(parser.lines) {
+        line := parser.lines[parser.pos]
+        return nil, fmt.Errorf("line %d: unexpected %q, check the indentation", line.number, line.text)
+    }
+    return value, nil
+}
+
+// stripYAMLComment drops a # comment, unless the # is inside quotes or
+// part of a word.
+func stripYAMLComment(line string) string {
+    var quote rune
+    for i, r := range line {
+        switch {
+        case quote != 0:
+            if r == quote {
+                quote = 0
+            }
+        case r == '"' || r == '\'':
+            quote = r
+        case r == '#' && (i == 0 || line[i - 1] == ' ' || line[i - 1] == '\t'):
+            return line[:i]
+        }
+    }
+    return line
+}
+
+func (parser *yamlParser) parseNode(indent int) (interface{}, error) {
+    line := parser.lines[parser.pos]
+    if line.indent != indent {
+        return nil, fmt.Errorf("line %d: bad indentation", line.number)
+    }
+    if line.text == "-" || strings.HasPrefix(line.text, "- ") {
+        return parser.parseSequence(indent)
+    }
+    if _, _, isKey := splitYAMLKey(line.text); isKey {
+        return parser.parseMap(indent)
+    }
+    parser.pos++
+    return parseYAMLValue(line.text, line.number)
+}
+
+func (parser *yamlParser) parseSequence(indent int) (interface{}, error) {
+    var items []interface{}
+    for parser.pos < len(parser.lines) {
+        line := parser.lines[parser.pos]
+        if line.indent != indent || !(line.text == "-" || strings.HasPrefix(line.text, "- ")) {
+            break
+        }
+        rest := strings.TrimLeft(strings.TrimPrefix(line.text, "-"), " ")
+        if rest == "" {
+            parser.pos++
+            if parser.pos >= len(parser.lines) || parser.lines[parser.pos].indent <= indent {
+                items = append(items, nil)
+                continue
+            }
+            item, err := parser.parseNode(parser.lines[parser.pos].indent)
+            if err != nil {
+                return nil, err
+            }
+            items = append(items, item)
+            continue
+        }
+        // "- key: value" starts a map indented to where the key is, so
+        // the rest of its keys line up underneath
+        parser.lines[parser.pos] = yamlLine{line.number, indent + len(line.text) - len(rest), rest}
+        item, err := parser.parseNode(parser.lines[parser.pos].indent)
+        if err != nil {
+            return nil, err
+        }
+        items = append(items, item)
+    }
+    return items, nil
+}
+
+func (parser *yamlParser) parseMap(indent int) (interface{}, error) {
+    values := make(map[string]interface{})
+    for parser.pos < len(parser.lines) {
+        line := parser.lines[parser.pos]
+        if line.indent < indent {
+            break
+        }
+        if line.indent > indent {
+            return nil, fmt.Errorf("line %d: bad indentation", line.number)
+        }
+        key, rest, isKey := splitYAMLKey(line.text)
+        if !isKey {
+            break
+        }
+        if _, exists := values[key]; exists {
+            return nil, fmt.Errorf("line %d: %q appears twice", line.number, key)
+        }
+        parser.pos++
+
+        if rest != "" {
+            value, err := parseYAMLValue(rest, line.number)
+            if err != nil {
+                return nil, err
+            }
+            values[key] = value
+            continue
+        }
+        if parser.pos >= len(parser.lines) {
+            values[key] = nil
+            continue
+        }
+        next := parser.lines[parser.pos]
+        isItem := next.text == "-" || strings.HasPrefix(next.text, "- ")
+        if next.indent > indent || (next.indent == indent && isItem) {
+            value, err := parser.parseNode(next.indent)
+            if err != nil {
+                return nil, err
+            }
+            values[key] = value
+        } else {
+            values[key] = nil
+        }
+    }
+    return values, nil
+}
+
+// splitYAMLKey splits "key: value" or "key:", with the key possibly quoted.
+func splitYAMLKey(text string) (string, string, bool) {
+    if text == "" || text[0] == '[' || text[0] == '{' {
+        return "", "", false
+    }
+    start := 0
+    if text[0] == '"' || text[0] == '\'' {
+        end := strings.IndexByte(text[1:], text[0])
+        if end < 0 {
+            return "", "", false
+        }
+        start = end + 2
+    }
+    for i := start; i < len(text); i++ {
+        if text[i] == ':' && (i == len(text) - 1 || text[i + 1] == ' ') {
+            key := unquoteYAML(strings.TrimSpace(text[:i]))
+            return key, strings.TrimSpace(text[i + 1:]), true
+        }
+    }
+    return "", "", false
+}
+
+func unquoteYAML(text string) string {
+    if len(text) >= 2 && text[0] == '"' && text[len(text) - 1] == '"' {
+        if unquoted, err := strconv.Unquote(text); err == nil {
+            return unquoted
+        }
+        return text[1:len(text) - 1]
+    }
+    if len(text) >= 2 && text[0] == '\'' && text[len(text) - 1] == '\'' {
+        return strings.ReplaceAll(text[1:len(text) - 1], "''", "'")
+    }
+    return text
+}
+
+// parseYAMLValue parses a scalar or a flow collection on one line.
+func parseYAMLValue(text string, lineNumber int) (interface{}, error) {
+    text = strings.TrimSpace(text)
+    if text == "" {
+        return nil, nil
+    }
+    if text[0] != '[' && text[0] != '{' {
+        if text == "~" || text == "null" {
+            return nil, nil
+        }
+        return unquoteYAML(text), nil
+    }
+
+    closer := byte(']')
+    if text[0] == '{' {
+        closer = '}'
+    }
+    if text[len(text) - 1] != closer {
+        return nil, fmt.Errorf("line %d: unclosed %c", lineNumber, text[0])
+    }
+    parts := splitYAMLFlow(text[1:len(text) - 1])
+
+    if closer == ']' {
+        items := make([]interface{}, 0, len(parts))
+        for _, part := range parts {
+            item, err := parseYAMLValue(part, lineNumber)
+            if err != nil {
+                return nil, err
+            }
+            items = append(items, item)
+        }
+        return items, nil
+    }
+    values := make(map[string]interface{}, len(parts))
+    for _, part := range parts {
+        key, rest, isKey := splitYAMLKey(part)
+        if !isKey {
+            return nil, fmt.Errorf("line %d: %q is not key: value", lineNumber, part)
+        }
+        value, err := parseYAMLValue(rest, lineNumber)
+        if err != nil {
+            return nil, err
+        }
+        values[key] = value
+    }
+    return values, nil
+}
+
+// splitYAMLFlow splits the inside of a flow collection on its top level
+// commas.
+func splitYAMLFlow(text string) []string {
+    var parts []string
+    depth, start := 0, 0
+    var quote byte
+    for i := 0; i < len(text); i++ {
+        c := text[i]
+        switch {
+        case quote != 0:
+            if c == quote {
+                quote = 0
+            }
+        case c == '"' || c == '\'':
+            quote = c
+        case c == '[' || c == '{':
+            depth++
+        case c == ']' || c == '}':
+            depth--
+        case c == ',' && depth == 0:
+            parts = append(parts, strings.TrimSpace(text[start:i]))
+            start = i + 1
+        }
+    }
+    if last := strings.TrimSpace(text[start:]); last != "" {
+        parts = append(parts, last)
+    }
+    return parts
+}
+
+// yamlFloat reads a number out of a parsed scalar.
+func yamlFloat(value interface{}) (float64, error) {
+    text, ok := value.(string)
+    if !ok {
+        return 0, fmt.Errorf("expected a number, got %v", value)
+    }
+    number, err := strconv.ParseFloat(text, 64)
+    if err != nil {
+        return 0, fmt.Errorf("expected a number, got %q", text)
+    }
+    return number, nil
+}
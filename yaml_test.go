@@ -0,0 +1,112 @@
+package main
+
+import (
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func TestParseYAML(t *testing.T) {
+    for _, test := range []struct {
+        name string
+        yaml string
+        want interface{}
+    }{
+        {"empty", "", nil},
+        {"only comments", "# nothing\n---\n", nil},
+        {"scalar", "hello", "hello"},
+        {"map", "a: 1\nb: two\n", map[string]interface{}{"a": "1", "b": "two"}},
+        {"nested map", "Protein:\n  min: 50\n  max: 200\n", map[string]interface{}{
+            "Protein": map[string]interface{}{"min": "50", "max": "200"},
+        }},
+        {"sequence", "- a\n- b\n", []interface{}{"a", "b"}},
+        {"sequence of maps", "- name: x\n  grams: 10\n- name: y\n", []interface{}{
+            map[string]interface{}{"name": "x", "grams": "10"},
+            map[string]interface{}{"name": "y"},
+        }},
+        {"sequence in a map", "foods:\n- a\n- b\nmax: 3\n", map[string]interface{}{
+            "foods": []interface{}{"a", "b"}, "max": "3",
+        }},
+        {"flow list", "foods: [a, \"b, c\", 'd']", map[string]interface{}{
+            "foods": []interface{}{"a", "b, c", "d"},
+        }},
+        {"flow map", "Caffeine: {over: 20, per: 1}", map[string]interface{}{
+            "Caffeine": map[string]interface{}{"over": "20", "per": "1"},
+        }},
+        {"nested flow", "x: [[1, 2], {a: 3}]", map[string]interface{}{
+            "x": []interface{}{[]interface{}{"1", "2"}, map[string]interface{}{"a": "3"}},
+        }},
+        {"comments", "a: 1 # one\nb: \"#2\" # two\nc: x#y\n", map[string]interface{}{
+            "a": "1", "b": "#2", "c": "x#y",
+        }},
+        {"nulls", "a:\nb: ~\nc: null\n", map[string]interface{}{"a": nil, "b": nil, "c": nil}},
+        {"quoted key", "\"Fatty acids, total saturated\": 20", map[string]interface{}{
+            "Fatty acids, total saturated": "20",
+        }},
+        {"windows line endings", "a: 1\r\nb: 2\r\n", map[string]interface{}{"a": "1", "b": "2"}},
+    } {
+        t.Run(test.name, func(t *testing.T) {
+            got, err := parseYAML(test.yaml)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if !reflect.DeepEqual(got, test.want) {
+                t.Errorf("got %#v, want %#v", got, test.want)
+            }
+        })
+    }
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+    for _, test := range []struct {
+        name string
+        yaml string
+        err string
+    }{
+        {"tab indent", "a:\n\tb: 1\n", "line 2: indent with spaces, not tabs"},
+        {"unclosed list", "a: [1, 2", "line 1: unclosed ["},
+        {"unclosed map", "a:\n  b: {c: 1\n", "line 2: unclosed {"},
+        {"flow map without a key", "a: {1}", "line 1: \"1\" is not key: value"},
+        {"bad indentation", "a: 1\n  b: 2\n", "line 2:"},
+    } {
+        t.Run(test.name, func(t *testing.T) {
+            _, err := parseYAML(test.yaml)
+            if err == nil || !strings.Contains(err.Error(), test.err) {
+                t.Errorf("got error %v, want %q", err, test.err)
+            }
+        })
+    }
+}
+
+func TestYAMLFloat(t *testing.T) {
+    for _, test := range []struct {
+        value interface{}
+        want float64
+        ok bool
+    }{
+        {"1.5", 1.5, true},
+        {"-2", -2, true},
+        {"1e3", 1000, true},
+        {"lots", 0, false},
+        {nil, 0, false},
+        {[]interface{}{"1"}, 0, false},
+    } {
+        got, err := yamlFloat(test.value)
+        if (err == nil) != test.ok || got != test.want {
+            t.Errorf("yamlFloat(%#v) = %g, %v", test.value, got, err)
+        }
+    }
+}
+
+// The built in files are what every run parses, so they had better
+func TestParseBuiltInYAML(t *testing.T) {
+    for name, yaml := range map[string]string{
+        "targets.yaml": targetsYAML,
+        "derived.yaml": derivedYAML,
+        "supplements.yaml": supplementsYAML,
+    } {
+        if _, err := parseYAML(yaml); err != nil {
+            t.Errorf("%s: %v", name, err)
+        }
+    }
+}